@@ -0,0 +1,47 @@
+package go_lsm_workshop
+
+import (
+	"errors"
+	"fmt"
+	"go-lsm-workshop/state"
+)
+
+var DbReadonlyErr = errors.New("db is in read-only mode because of a fatal background error")
+
+// BackgroundError returns the latest error of the background operations (flush or compaction), or nil.
+// The returned error (a *state.BackgroundError) is either retryable, and it is cleared once the failed operation succeeds
+// on a retry, or fatal, and it is never cleared.
+// Once a fatal background error occurs, the Db is in read-only mode: the reads continue to work, while all the writes fail
+// with DbReadonlyErr (wrapping the background error).
+func (db *Db) BackgroundError() error {
+	if backgroundError := db.backgroundError.Load(); backgroundError != nil {
+		return backgroundError
+	}
+	return nil
+}
+
+// handleBackgroundError records the outcome of a background operation, it is the state.BackgroundErrorHandler of the Db.
+// A fatal error is never replaced, a retryable error is replaced by the latest error, and it is cleared once the same
+// operation succeeds.
+func (db *Db) handleBackgroundError(operation state.BackgroundOperation, backgroundError *state.BackgroundError) {
+	for {
+		current := db.backgroundError.Load()
+		if current != nil && current.Fatal {
+			return
+		}
+		if backgroundError == nil && (current == nil || current.Operation != operation) {
+			return
+		}
+		if db.backgroundError.CompareAndSwap(current, backgroundError) {
+			return
+		}
+	}
+}
+
+// readonlyErr returns DbReadonlyErr (wrapping the background error) if the Db is in read-only mode, else nil.
+func (db *Db) readonlyErr() error {
+	if backgroundError := db.backgroundError.Load(); backgroundError != nil && backgroundError.Fatal {
+		return fmt.Errorf("%w: %w", DbReadonlyErr, backgroundError)
+	}
+	return nil
+}
@@ -0,0 +1,393 @@
+package go_lsm_workshop
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go-lsm-workshop/log"
+	"go-lsm-workshop/manifest"
+	"go-lsm-workshop/table"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var BackupNotFoundErr = errors.New("backup not found")
+var RestoreDirectoryExistsErr = errors.New("restore directory already exists")
+var BackupSSTableMismatchErr = errors.New("SSTable in the backup directory does not match the SSTable with the same id")
+
+const (
+	backupSSTablesDirectoryName    = "sstables"
+	backupsDirectoryName           = "backups"
+	backupSSTableChecksumsFileName = "sstable_checksums"
+)
+
+// BackupEngine creates incremental backups of a Db in a local directory, and restores them.
+// The layout of the backup directory is:
+// sstables/<id>.sst               -> SSTables shared by all the backups, stored by their ids.
+// backups/<backupId>/manifest          -> compacted manifest of the backup (Refer to state.Checkpoint.WriteManifest).
+// backups/<backupId>/sstable_checksums -> checksum and size of each SSTable of the backup (Refer to table.Checksum).
+// backups/<backupId>/wal/<id>.wal      -> WAL files of the memtables at the time of the backup (the tail of the backup).
+// Every backup is a checkpoint (Refer to Db.CheckpointWithOptions) taken without flushing the memtables. SSTables are
+// immutable and their ids are never reused in a Db, so a backup copies only the SSTables which are not already present
+// in the sstables directory. This also means that a backup directory must be used for a single Db.
+// An SSTable is skipped only if its checksum and size match the ones recorded by an earlier backup (and the ones of
+// the file in the sstables directory). A Db restored from an older backup reuses the SSTable ids of the newer backups,
+// so CreateBackup returns BackupSSTableMismatchErr instead of skipping a different SSTable with the same id.
+// The manifest of a backup is written last, a backup without a manifest is incomplete and is removed by PurgeOldBackups.
+type BackupEngine struct {
+	directory string
+	lock      sync.Mutex
+}
+
+// BackupInfo describes a backup.
+type BackupInfo struct {
+	Id                  uint64
+	CreatedAt           time.Time
+	SSTableIds          []uint64
+	LastCommitTimestamp uint64
+}
+
+// NewBackupEngine creates a new instance of BackupEngine for the given directory (created if it does not exist).
+func NewBackupEngine(directory string) (*BackupEngine, error) {
+	for _, directoryName := range []string{backupSSTablesDirectoryName, backupsDirectoryName} {
+		if err := os.MkdirAll(filepath.Join(directory, directoryName), os.ModePerm); err != nil {
+			return nil, err
+		}
+	}
+	return &BackupEngine{directory: directory}, nil
+}
+
+// CreateBackup creates a new backup of the Db, with the id one more than the id of the latest backup.
+// It returns ctx.Err() if the ctx is done before the checkpoint of the Db could begin.
+func (engine *BackupEngine) CreateBackup(ctx context.Context, db *Db) (BackupInfo, error) {
+	engine.lock.Lock()
+	defer engine.lock.Unlock()
+
+	if db.stopped.Load() {
+		return BackupInfo{}, DbAlreadyStoppedErr
+	}
+	backupIds, err := engine.allBackupIds()
+	if err != nil {
+		return BackupInfo{}, err
+	}
+	backupId := uint64(1)
+	if len(backupIds) > 0 {
+		backupId = slices.Max(backupIds) + 1
+	}
+
+	recordedChecksums, err := engine.recordedSSTableChecksums()
+	if err != nil {
+		return BackupInfo{}, err
+	}
+
+	checkpoint, err := db.beginCheckpoint(ctx, engine.backupDirectory(backupId), CheckpointOptions{SkipMemtableFlush: true})
+	if err != nil {
+		return BackupInfo{}, err
+	}
+	defer checkpoint.Release()
+
+	checksums := make(map[uint64]ssTableChecksum)
+	if err := checkpoint.CopySSTablesTo(engine.ssTablesDirectory(), func(ssTableId uint64, filePath string) (bool, error) {
+		checksum, err := newSSTableChecksum(filePath)
+		if err != nil {
+			return false, err
+		}
+		checksums[ssTableId] = checksum
+		return engine.hasSSTable(ssTableId, checksum, recordedChecksums)
+	}); err != nil {
+		return BackupInfo{}, err
+	}
+	if err := engine.writeSSTableChecksums(backupId, checksums); err != nil {
+		return BackupInfo{}, err
+	}
+	if err := checkpoint.WriteManifest(); err != nil {
+		return BackupInfo{}, err
+	}
+	return engine.backupInfo(backupId)
+}
+
+// ListBackups returns all the (complete) backups, in the increasing order of their ids.
+func (engine *BackupEngine) ListBackups() ([]BackupInfo, error) {
+	engine.lock.Lock()
+	defer engine.lock.Unlock()
+
+	return engine.listBackups()
+}
+
+// Restore restores the backup identified by backupId in the given directory (which must not exist).
+// The directory can then be opened as a Db (using Open), which yields exactly the state of the Db at the time of the backup.
+// All the files are copied (not linked), so the restored Db is independent of the backup directory.
+// It returns BackupSSTableMismatchErr if a restored SSTable does not match the checksum recorded by the backup.
+func (engine *BackupEngine) Restore(backupId uint64, directory string) error {
+	engine.lock.Lock()
+	defer engine.lock.Unlock()
+
+	backupInfo, err := engine.backupInfo(backupId)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(directory); err == nil {
+		return RestoreDirectoryExistsErr
+	}
+	checksums, err := engine.readSSTableChecksums(backupId)
+	if err != nil {
+		return err
+	}
+	for _, ssTableId := range backupInfo.SSTableIds {
+		targetPath := table.SSTableFilePath(ssTableId, directory)
+		if err := table.CopyFile(table.SSTableFilePath(ssTableId, engine.ssTablesDirectory()), targetPath); err != nil {
+			return err
+		}
+		checksum, err := newSSTableChecksum(targetPath)
+		if err != nil {
+			return err
+		}
+		if recordedChecksum, ok := checksums[ssTableId]; !ok || recordedChecksum != checksum {
+			return BackupSSTableMismatchErr
+		}
+	}
+	backupWALPath, walPath := log.NewWALPath(engine.backupDirectory(backupId)), log.NewWALPath(directory)
+	walFiles, err := os.ReadDir(backupWALPath.DirectoryPath)
+	if err != nil {
+		return err
+	}
+	for _, walFile := range walFiles {
+		if err := table.CopyFile(
+			filepath.Join(backupWALPath.DirectoryPath, walFile.Name()),
+			filepath.Join(walPath.DirectoryPath, walFile.Name()),
+		); err != nil {
+			return err
+		}
+	}
+	return table.CopyFile(manifest.FilePath(engine.backupDirectory(backupId)), manifest.FilePath(directory))
+}
+
+// PurgeOldBackups removes all the backups except the latest numberOfBackupsToKeep backups, along with the incomplete backups.
+// The SSTables which are not referred by any of the remaining backups are removed from the sstables directory.
+func (engine *BackupEngine) PurgeOldBackups(numberOfBackupsToKeep uint) error {
+	engine.lock.Lock()
+	defer engine.lock.Unlock()
+
+	backups, err := engine.listBackups()
+	if err != nil {
+		return err
+	}
+	backupIds, err := engine.allBackupIds()
+	if err != nil {
+		return err
+	}
+	if uint(len(backups)) > numberOfBackupsToKeep {
+		backups = backups[uint(len(backups))-numberOfBackupsToKeep:]
+	}
+	backupIdsToKeep := make(map[uint64]struct{})
+	ssTableFilesToKeep := make(map[string]struct{})
+	for _, backup := range backups {
+		backupIdsToKeep[backup.Id] = struct{}{}
+		for _, ssTableId := range backup.SSTableIds {
+			ssTableFilesToKeep[filepath.Base(table.SSTableFilePath(ssTableId, engine.ssTablesDirectory()))] = struct{}{}
+		}
+	}
+	for _, backupId := range backupIds {
+		if _, ok := backupIdsToKeep[backupId]; !ok {
+			if err := os.RemoveAll(engine.backupDirectory(backupId)); err != nil {
+				return err
+			}
+		}
+	}
+	ssTableFiles, err := os.ReadDir(engine.ssTablesDirectory())
+	if err != nil {
+		return err
+	}
+	for _, ssTableFile := range ssTableFiles {
+		if _, ok := ssTableFilesToKeep[ssTableFile.Name()]; !ok {
+			if err := os.Remove(filepath.Join(engine.ssTablesDirectory(), ssTableFile.Name())); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// listBackups returns all the (complete) backups, in the increasing order of their ids.
+func (engine *BackupEngine) listBackups() ([]BackupInfo, error) {
+	backupIds, err := engine.allBackupIds()
+	if err != nil {
+		return nil, err
+	}
+	var backups []BackupInfo
+	for _, backupId := range backupIds {
+		backupInfo, err := engine.backupInfo(backupId)
+		if errors.Is(err, BackupNotFoundErr) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		backups = append(backups, backupInfo)
+	}
+	return backups, nil
+}
+
+// allBackupIds returns the ids of all the backups (including the incomplete ones), in the increasing order.
+func (engine *BackupEngine) allBackupIds() ([]uint64, error) {
+	entries, err := os.ReadDir(filepath.Join(engine.directory, backupsDirectoryName))
+	if err != nil {
+		return nil, err
+	}
+	var backupIds []uint64
+	for _, entry := range entries {
+		backupId, err := strconv.ParseUint(entry.Name(), 10, 64)
+		if err != nil || !entry.IsDir() {
+			continue
+		}
+		backupIds = append(backupIds, backupId)
+	}
+	slices.Sort(backupIds)
+	return backupIds, nil
+}
+
+// backupInfo returns the BackupInfo of the backup identified by backupId, by reading its manifest.
+// It returns BackupNotFoundErr if the backup does not exist or is incomplete.
+func (engine *BackupEngine) backupInfo(backupId uint64) (BackupInfo, error) {
+	stat, err := os.Stat(manifest.FilePath(engine.backupDirectory(backupId)))
+	if os.IsNotExist(err) {
+		return BackupInfo{}, BackupNotFoundErr
+	}
+	if err != nil {
+		return BackupInfo{}, err
+	}
+	events, err := manifest.ReadEventsFrom(engine.backupDirectory(backupId))
+	if err != nil {
+		return BackupInfo{}, err
+	}
+	backupInfo := BackupInfo{Id: backupId, CreatedAt: stat.ModTime()}
+	for _, event := range events {
+		if stateSnapshot, ok := event.(*manifest.StateSnapshot); ok {
+			backupInfo.SSTableIds = append(backupInfo.SSTableIds, stateSnapshot.L0SSTableIds...)
+			for _, ssTableIds := range stateSnapshot.LevelSSTableIds {
+				backupInfo.SSTableIds = append(backupInfo.SSTableIds, ssTableIds...)
+			}
+			backupInfo.LastCommitTimestamp = stateSnapshot.LastCommitTimestamp
+		}
+	}
+	return backupInfo, nil
+}
+
+// ssTableChecksum is the checksum and the size of an SSTable file (Refer to table.Checksum).
+type ssTableChecksum struct {
+	checksum uint32
+	size     int64
+}
+
+// newSSTableChecksum computes the ssTableChecksum of the SSTable file at filePath.
+func newSSTableChecksum(filePath string) (ssTableChecksum, error) {
+	checksum, size, err := table.Checksum(filePath)
+	if err != nil {
+		return ssTableChecksum{}, err
+	}
+	return ssTableChecksum{checksum: checksum, size: size}, nil
+}
+
+// hasSSTable returns true if the sstables directory already contains the SSTable identified by ssTableId with the given
+// checksum, in which case the SSTable need not be copied again.
+// An SSTable which is not recorded by any (complete) backup is copied again (it could be left by an incomplete backup).
+// It returns BackupSSTableMismatchErr if the recorded checksum, or the checksum of the file in the sstables directory,
+// does not match the given checksum.
+func (engine *BackupEngine) hasSSTable(ssTableId uint64, checksum ssTableChecksum, recordedChecksums map[uint64]ssTableChecksum) (bool, error) {
+	recordedChecksum, ok := recordedChecksums[ssTableId]
+	if !ok {
+		return false, nil
+	}
+	if recordedChecksum != checksum {
+		return false, BackupSSTableMismatchErr
+	}
+	existingChecksum, err := newSSTableChecksum(table.SSTableFilePath(ssTableId, engine.ssTablesDirectory()))
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if existingChecksum != checksum {
+		return false, BackupSSTableMismatchErr
+	}
+	return true, nil
+}
+
+// recordedSSTableChecksums returns the checksums of the SSTables recorded by all the (complete) backups.
+func (engine *BackupEngine) recordedSSTableChecksums() (map[uint64]ssTableChecksum, error) {
+	backups, err := engine.listBackups()
+	if err != nil {
+		return nil, err
+	}
+	recordedChecksums := make(map[uint64]ssTableChecksum)
+	for _, backup := range backups {
+		checksums, err := engine.readSSTableChecksums(backup.Id)
+		if err != nil {
+			return nil, err
+		}
+		for ssTableId, checksum := range checksums {
+			recordedChecksums[ssTableId] = checksum
+		}
+	}
+	return recordedChecksums, nil
+}
+
+// writeSSTableChecksums writes (and syncs) the checksums of the SSTables of the backup identified by backupId.
+// Each line of the file is: <ssTableId> <size> <checksum>.
+func (engine *BackupEngine) writeSSTableChecksums(backupId uint64, checksums map[uint64]ssTableChecksum) error {
+	var content strings.Builder
+	for ssTableId, checksum := range checksums {
+		_, _ = fmt.Fprintf(&content, "%d %d %d\n", ssTableId, checksum.size, checksum.checksum)
+	}
+	file, err := os.Create(filepath.Join(engine.backupDirectory(backupId), backupSSTableChecksumsFileName))
+	if err != nil {
+		return err
+	}
+	if _, err := file.WriteString(content.String()); err != nil {
+		_ = file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		_ = file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// readSSTableChecksums reads the checksums of the SSTables of the backup identified by backupId
+// (Refer to writeSSTableChecksums).
+func (engine *BackupEngine) readSSTableChecksums(backupId uint64) (map[uint64]ssTableChecksum, error) {
+	content, err := os.ReadFile(filepath.Join(engine.backupDirectory(backupId), backupSSTableChecksumsFileName))
+	if err != nil {
+		return nil, err
+	}
+	checksums := make(map[uint64]ssTableChecksum)
+	for _, line := range strings.Split(strings.TrimSpace(string(content)), "\n") {
+		if len(line) == 0 {
+			continue
+		}
+		var ssTableId uint64
+		var checksum ssTableChecksum
+		if _, err := fmt.Sscanf(line, "%d %d %d", &ssTableId, &checksum.size, &checksum.checksum); err != nil {
+			return nil, err
+		}
+		checksums[ssTableId] = checksum
+	}
+	return checksums, nil
+}
+
+// backupDirectory returns the directory of the backup identified by backupId.
+func (engine *BackupEngine) backupDirectory(backupId uint64) string {
+	return filepath.Join(engine.directory, backupsDirectoryName, strconv.FormatUint(backupId, 10))
+}
+
+// ssTablesDirectory returns the directory which contains the SSTables of all the backups.
+func (engine *BackupEngine) ssTablesDirectory() string {
+	return filepath.Join(engine.directory, backupSSTablesDirectoryName)
+}
@@ -0,0 +1,57 @@
+package go_lsm_workshop
+
+import (
+	"context"
+	"go-lsm-workshop/state"
+)
+
+// CheckpointOptions represents the options for Db.CheckpointWithOptions.
+// If SkipMemtableFlush is true, the memtables are not flushed, instead their WAL files are copied to the checkpoint directory.
+type CheckpointOptions struct {
+	SkipMemtableFlush bool
+}
+
+// Checkpoint creates an online consistent checkpoint of the Db in the given directory (which must not exist), after
+// flushing all the memtables. Refer to CheckpointWithOptions.
+func (db *Db) Checkpoint(ctx context.Context, directory string) error {
+	return db.CheckpointWithOptions(ctx, directory, CheckpointOptions{})
+}
+
+// CheckpointWithOptions creates an online consistent checkpoint of the Db in the given directory (which must not exist).
+// The checkpoint is taken at a commit-timestamp assigned by txn.Oracle (Refer to txn.Oracle.ExecuteAtCommitTimestamp): it
+// contains all the commits before the commit-timestamp, and none after. Opening the directory (using Open) yields exactly
+// that view.
+// It involves the following:
+// 1) With the commits blocked and compaction excluded, the memtables are flushed (or their WAL files are copied if
+// CheckpointOptions.SkipMemtableFlush is true), and the live SSTables are pinned (Refer to state.StorageState.BeginCheckpoint).
+// 2) With the commits and compaction resumed, the pinned SSTables are hard-linked (or copied) and a compacted manifest is
+// written to the directory (Refer to state.Checkpoint.WriteSSTablesAndManifest).
+func (db *Db) CheckpointWithOptions(ctx context.Context, directory string, options CheckpointOptions) error {
+	if db.stopped.Load() {
+		return DbAlreadyStoppedErr
+	}
+	checkpoint, err := db.beginCheckpoint(ctx, directory, options)
+	if err != nil {
+		return err
+	}
+	defer checkpoint.Release()
+
+	return checkpoint.WriteSSTablesAndManifest()
+}
+
+// beginCheckpoint begins the state.Checkpoint at a commit-timestamp, holding the compactionLock.
+func (db *Db) beginCheckpoint(ctx context.Context, directory string, options CheckpointOptions) (*state.Checkpoint, error) {
+	db.compactionLock.Lock()
+	defer db.compactionLock.Unlock()
+
+	var checkpoint *state.Checkpoint
+	err := db.oracle.ExecuteAtCommitTimestamp(ctx, func(commitTimestamp uint64) error {
+		var err error
+		checkpoint, err = db.storageState.BeginCheckpoint(directory, commitTimestamp, !options.SkipMemtableFlush)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return checkpoint, nil
+}
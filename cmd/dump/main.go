@@ -0,0 +1,198 @@
+// Command dump exports a key range of a database to a logical dump (JSON lines), or imports a logical dump into a database.
+//
+// Usage:
+//
+//	dump export -path <db-directory> [storage options] [-start <key>] [-end <key>] [-as-of <timestamp>] [-output <file>]
+//	dump import -path <db-directory> [storage options] [-input <file>]
+//
+// The dump is written to stdout (and read from stdin) if the file is not given. Refer to go_lsm_workshop.DumpRecord for the format.
+// The database is opened with the storage options given by the flags (Refer to storageFlags), they must match the options
+// the database was created with, in particular the compaction strategy and the number of levels.
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	go_lsm_workshop "go-lsm-workshop"
+	"go-lsm-workshop/kv"
+	"go-lsm-workshop/state"
+	"io"
+	"os"
+	"time"
+)
+
+const maxKeySizeForUnboundedRange = 1024
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+	var err error
+	switch os.Args[1] {
+	case "export":
+		err = export(os.Args[2:])
+	case "import":
+		err = load(os.Args[2:])
+	default:
+		usage()
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// export exports the key range of the database to the output.
+func export(arguments []string) error {
+	flags := flag.NewFlagSet("export", flag.ExitOnError)
+	storage := newStorageFlags(flags)
+	start := flags.String("start", "", "first key of the range (inclusive)")
+	end := flags.String("end", "", "last key of the range (inclusive), all the keys from the start if empty")
+	asOfTimestamp := flags.Uint64("as-of", 0, "commit-timestamp as of which the keys are exported, the latest if zero")
+	output := flags.String("output", "", "file to write the dump to, stdout if empty")
+	_ = flags.Parse(arguments)
+
+	db, err := storage.open()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	writer := io.Writer(os.Stdout)
+	if len(*output) > 0 {
+		file, err := os.Create(*output)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			_ = file.Close()
+		}()
+		writer = file
+	}
+	endKey := []byte(*end)
+	if len(endKey) == 0 {
+		endKey = bytes.Repeat([]byte{0xFF}, maxKeySizeForUnboundedRange)
+	}
+	return db.Export(context.Background(), writer, kv.NewInclusiveKeyRange(kv.RawKey(*start), kv.RawKey(endKey)), *asOfTimestamp)
+}
+
+// load imports the dump from the input to the database.
+func load(arguments []string) error {
+	flags := flag.NewFlagSet("import", flag.ExitOnError)
+	storage := newStorageFlags(flags)
+	input := flags.String("input", "", "file to read the dump from, stdin if empty")
+	_ = flags.Parse(arguments)
+
+	db, err := storage.open()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	reader := io.Reader(os.Stdin)
+	if len(*input) > 0 {
+		file, err := os.Open(*input)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			_ = file.Close()
+		}()
+		reader = file
+	}
+	records, err := db.Import(context.Background(), reader)
+	fmt.Fprintf(os.Stderr, "imported %d records\n", records)
+	return err
+}
+
+// storageFlags represents the flags to open the database with, shared by export and import.
+type storageFlags struct {
+	path                  *string
+	compaction            *string
+	maxLevels             *uint
+	level0Trigger         *uint
+	memtableSizeInBytes   *int64
+	ssTableSizeInBytes    *int64
+	baseLevelSizeInBytes  *int64
+	levelSizeMultiplier   *uint
+	maxTotalSizeInBytes   *int64
+	sizeRatioPercent      *uint
+	maxSizeAmplification  *uint
+	numberOfSSTablesRatio *uint
+}
+
+// newStorageFlags registers the storage flags in the flags.
+func newStorageFlags(flags *flag.FlagSet) storageFlags {
+	return storageFlags{
+		path:                  flags.String("path", "", "directory of the database"),
+		compaction:            flags.String("compaction", "simple-leveled", "compaction strategy: simple-leveled, leveled, tiered or fifo"),
+		maxLevels:             flags.Uint("max-levels", 4, "number of levels (excluding level0), unused by fifo"),
+		level0Trigger:         flags.Uint("level0-trigger", 6, "level0 files (sorted runs for tiered) which trigger a compaction"),
+		memtableSizeInBytes:   flags.Int64("memtable-size", 4<<20, "size of a memtable in bytes"),
+		ssTableSizeInBytes:    flags.Int64("sstable-size", 4<<20, "size of an SSTable in bytes"),
+		baseLevelSizeInBytes:  flags.Int64("base-level-size", 64<<20, "target size of the base level in bytes (leveled)"),
+		levelSizeMultiplier:   flags.Uint("level-size-multiplier", 10, "target size multiplier between the levels (leveled)"),
+		maxTotalSizeInBytes:   flags.Int64("max-total-size", 0, "maximum total size of the SSTables in bytes, zero means no limit (fifo)"),
+		sizeRatioPercent:      flags.Uint("size-ratio", 1, "size ratio percent of the sorted runs to merge (tiered)"),
+		maxSizeAmplification:  flags.Uint("max-size-amplification", 200, "maximum size amplification percent (tiered)"),
+		numberOfSSTablesRatio: flags.Uint("sstables-ratio", 200, "number of SSTables ratio percent between the levels (simple-leveled)"),
+	}
+}
+
+// open opens the database in the path, with the storage options given by the flags.
+func (storage storageFlags) open() (*go_lsm_workshop.Db, error) {
+	if len(*storage.path) == 0 {
+		return nil, fmt.Errorf("-path is required")
+	}
+	compactionOptions := state.CompactionOptions{Duration: 5 * time.Second}
+	switch *storage.compaction {
+	case "simple-leveled":
+		compactionOptions.Strategy = state.SimpleLeveledCompactionStrategy
+		compactionOptions.StrategyOptions = state.SimpleLeveledCompactionOptions{
+			Level0FilesCompactionTrigger:    *storage.level0Trigger,
+			MaxLevels:                       *storage.maxLevels,
+			NumberOfSSTablesRatioPercentage: *storage.numberOfSSTablesRatio,
+		}
+	case "leveled":
+		compactionOptions.Strategy = state.LeveledCompactionStrategy
+		compactionOptions.LeveledStrategyOptions = state.LeveledCompactionOptions{
+			Level0FilesCompactionTrigger: *storage.level0Trigger,
+			BaseLevelSizeInBytes:         *storage.baseLevelSizeInBytes,
+			LevelSizeMultiplier:          *storage.levelSizeMultiplier,
+			MaxLevels:                    *storage.maxLevels,
+		}
+	case "tiered":
+		compactionOptions.Strategy = state.TieredCompactionStrategy
+		compactionOptions.TieredStrategyOptions = state.TieredCompactionOptions{
+			SortedRunsCompactionTrigger: *storage.level0Trigger,
+			MaxSizeAmplificationPercent: *storage.maxSizeAmplification,
+			SizeRatioPercent:            *storage.sizeRatioPercent,
+			MinMergeWidth:               2,
+			MaxLevels:                   *storage.maxLevels,
+		}
+	case "fifo":
+		compactionOptions.Strategy = state.FIFOCompactionStrategy
+		compactionOptions.FIFOStrategyOptions = state.FIFOCompactionOptions{
+			MaxTotalSizeInBytes: *storage.maxTotalSizeInBytes,
+		}
+	default:
+		return nil, fmt.Errorf("unsupported -compaction %q", *storage.compaction)
+	}
+	return go_lsm_workshop.Open(state.StorageOptions{
+		MemTableSizeInBytes:   *storage.memtableSizeInBytes,
+		SSTableSizeInBytes:    *storage.ssTableSizeInBytes,
+		Path:                  *storage.path,
+		MaximumMemtables:      5,
+		FlushMemtableDuration: 50 * time.Millisecond,
+		CompactionOptions:     compactionOptions,
+	})
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: dump export -path <db-directory> [storage options] [-start <key>] [-end <key>] [-as-of <timestamp>] [-output <file>]")
+	fmt.Fprintln(os.Stderr, "       dump import -path <db-directory> [storage options] [-input <file>]")
+	fmt.Fprintln(os.Stderr, "storage options: -compaction, -max-levels, -level0-trigger, -memtable-size, -sstable-size, ... (dump export -h)")
+	os.Exit(2)
+}
@@ -1,10 +1,12 @@
 package compact
 
 import (
+	"errors"
 	"go-lsm-workshop/kv"
 	"go-lsm-workshop/state"
 	"go-lsm-workshop/test_utility"
 	"go-lsm-workshop/txn"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -134,3 +136,98 @@ func TestGenerateSSTablesFromASingleIteratorHavingMultipleKeysWithDifferentTimes
 	assert.Nil(t, ssTableIterator.Next())
 	assert.False(t, ssTableIterator.IsValid())
 }
+
+func TestGenerateSSTablesFromASingleIteratorHavingADeletedKeyWithOlderVersionsWhichAreEligibleToBeDiscarded(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	storageState, _ := state.NewStorageState(rootPath)
+	oracle := txn.NewOracle(txn.NewExecutor(storageState))
+
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+		storageState.Close()
+		oracle.Close()
+	}()
+
+	iterator := newMockIterator(
+		[]kv.Key{
+			kv.NewStringKeyWithTimestamp("consensus", 11),
+			kv.NewStringKeyWithTimestamp("consensus", 10),
+			kv.NewStringKeyWithTimestamp("storage", 9),
+		},
+		[]kv.Value{
+			kv.EmptyValue,
+			kv.NewStringValue("Paxos"),
+			kv.NewStringValue("NVMe"),
+		},
+	)
+
+	oracle.SetBeginTimestamp(11)
+
+	compaction := NewCompaction(oracle, storageState.SSTableIdGenerator(), storageState.Options())
+	ssTables, err := compaction.ssTablesFromIterator(iterator)
+
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(ssTables))
+
+	ssTable := ssTables[0]
+	ssTableIterator, err := ssTable.SeekToFirst()
+
+	assert.Nil(t, err)
+	assert.Equal(t, "storage", ssTableIterator.Key().RawString())
+	assert.Equal(t, kv.NewStringValue("NVMe"), ssTableIterator.Value())
+
+	assert.Nil(t, ssTableIterator.Next())
+	assert.False(t, ssTableIterator.IsValid())
+}
+
+type failingIterator struct {
+	*mockIterator
+	failAtIndex int
+}
+
+func (iterator *failingIterator) Next() error {
+	if iterator.currentIndex+1 == iterator.failAtIndex {
+		return errors.New("failed to read the next key")
+	}
+	return iterator.mockIterator.Next()
+}
+
+func TestGenerateSSTablesFromAnIteratorWhichFailsRemovesTheNewSSTables(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	storageState, _ := state.NewStorageState(rootPath)
+	oracle := txn.NewOracle(txn.NewExecutor(storageState))
+
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+		storageState.Close()
+		oracle.Close()
+	}()
+
+	iterator := &failingIterator{
+		mockIterator: newMockIterator(
+			[]kv.Key{
+				kv.NewStringKeyWithTimestamp("consensus", 11),
+				kv.NewStringKeyWithTimestamp("distributed", 11),
+				kv.NewStringKeyWithTimestamp("storage", 11),
+			},
+			[]kv.Value{
+				kv.NewStringValue("VSR"),
+				kv.NewStringValue("TiKV"),
+				kv.NewStringValue("NVMe"),
+			},
+		),
+		failAtIndex: 2,
+	}
+
+	options := storageState.Options()
+	options.SSTableSizeInBytes = 1
+
+	compaction := NewCompaction(oracle, storageState.SSTableIdGenerator(), options)
+	ssTables, err := compaction.ssTablesFromIterator(iterator)
+
+	assert.Error(t, err)
+	assert.Nil(t, ssTables)
+
+	ssTableFilePaths, _ := filepath.Glob(filepath.Join(rootPath, "*.sst"))
+	assert.Empty(t, ssTableFilePaths)
+}
@@ -1,19 +1,26 @@
 package compact
 
 import (
+	"fmt"
 	"go-lsm-workshop/compact/meta"
 	"go-lsm-workshop/iterator"
 	"go-lsm-workshop/kv"
 	"go-lsm-workshop/state"
 	"go-lsm-workshop/table"
 	"go-lsm-workshop/txn"
+	"slices"
 )
 
 // Compaction represents core logic to compact table.SSTable files.
+// outputLevel is the level of the new table.SSTable files, which is passed to the state.CompactionFilter (Refer to atLevel).
+// olderSSTables are the table.SSTable files which are not compacted, but may hold the older versions of the compacted keys
+// (Refer to olderSSTablesThan). A deleted key is dropped only if none of them may contain the key (Refer to mayDropDeletedKey).
 type Compaction struct {
-	oracle      *txn.Oracle
-	idGenerator *state.SSTableIdGenerator
-	options     state.StorageOptions
+	oracle        *txn.Oracle
+	idGenerator   *state.SSTableIdGenerator
+	options       state.StorageOptions
+	outputLevel   int
+	olderSSTables []*table.SSTable
 }
 
 // NewCompaction creates a new instance of Compaction.
@@ -27,21 +34,162 @@ func NewCompaction(oracle *txn.Oracle, idGenerator *state.SSTableIdGenerator, op
 
 // Start performs compaction given an instance of state.StorageStateSnapshot.
 // It is called from compaction goroutine at fixed intervals.
-// It returns an instance of state.StorageStateChangeEvent if any two levels are eligible for compaction.
+// If the upper level table.SSTable files do not overlap with the lower level, they are moved to the lower level without
+// being rewritten (Refer to trivialMove).
+// The compaction is split into concurrent subcompactions if MaxSubcompactions > 1 (Refer to compactSSTables).
+// It returns an instance of state.StorageStateChangeEvent if any two levels (or sorted runs, with a
+// state.CompactionStrategy) are eligible for compaction.
+// It returns the error (if any) in compacting the table.SSTable files, and the caller decides whether to retry it
+// (Refer to state.BackgroundError).
 func (compaction *Compaction) Start(snapshot state.StorageStateSnapshot) (state.StorageStateChangeEvent, error) {
-	simpleLeveledCompaction := NewSimpleLeveledCompaction(compaction.options.CompactionOptions.StrategyOptions)
-	description, ok := simpleLeveledCompaction.CompactionDescription(snapshot)
+	if strategy, ok := compaction.compactionStrategy(); ok {
+		return compaction.startWith(strategy, snapshot)
+	}
+	description, ok := compaction.compactionDescription(snapshot)
 	if !ok {
 		return state.NoStorageStateChanges, nil
 	}
-	ssTables, err := compaction.compact(description, snapshot)
+	if event, ok := compaction.trivialMove(description, snapshot); ok {
+		return event, nil
+	}
+	var ssTables []*table.SSTable
+	var err error
+	if compaction.options.CompactionOptions.MaxSubcompactions > 1 {
+		ssTableIds := slices.Concat(description.UpperLevelSSTableIds, description.LowerLevelSSTableIds)
+		ssTables, err = compaction.atLevel(description.LowerLevel, ssTableIds, snapshot).compactSSTables(ssTableIds, snapshot)
+	} else {
+		ssTableIds := slices.Concat(description.UpperLevelSSTableIds, description.LowerLevelSSTableIds)
+		ssTables, err = compaction.atLevel(description.LowerLevel, ssTableIds, snapshot).compact(description, snapshot)
+	}
 	if err != nil {
-		return state.NoStorageStateChanges, nil
+		return state.NoStorageStateChanges, err
 	}
 	event := state.NewStorageStateChangeEvent(ssTables, description)
 	return event, nil
 }
 
+// compactionStrategy returns the state.CompactionStrategy: either the custom strategy registered in state.CompactionOptions,
+// or TieredCompaction, or FIFOCompaction. It returns false for the strategies which are described by meta.SimpleLeveledCompactionDescription.
+func (compaction *Compaction) compactionStrategy() (state.CompactionStrategy, bool) {
+	compactionOptions := compaction.options.CompactionOptions
+	if compactionOptions.CustomStrategy != nil {
+		return compactionOptions.CustomStrategy, true
+	}
+	switch compactionOptions.Strategy {
+	case state.TieredCompactionStrategy:
+		return NewTieredCompaction(compactionOptions.TieredStrategyOptions), true
+	case state.FIFOCompactionStrategy:
+		return NewFIFOCompaction(compactionOptions.FIFOStrategyOptions), true
+	default:
+		return nil, false
+	}
+}
+
+// startWith performs compaction of the table.SSTable files described by the state.CompactionStrategy.
+// It returns an error if the strategy describes a compaction which is not valid for the snapshot (Refer to validate).
+func (compaction *Compaction) startWith(strategy state.CompactionStrategy, snapshot state.StorageStateSnapshot) (state.StorageStateChangeEvent, error) {
+	description, ok := strategy.CompactionDescription(snapshot)
+	if !ok {
+		return state.NoStorageStateChanges, nil
+	}
+	if err := validate(description, snapshot, compaction.options.CompactionOptions.MaxLevels()); err != nil {
+		return state.NoStorageStateChanges, err
+	}
+	if description.DropsSSTables() {
+		return state.NewSortedRunsStorageStateChangeEvent(nil, description), nil
+	}
+	ssTableIds := description.AllSSTableIds()
+	ssTables, err := compaction.atLevel(description.OutputLevel, ssTableIds, snapshot).compactSSTables(ssTableIds, snapshot)
+	if err != nil {
+		return state.NoStorageStateChanges, err
+	}
+	return state.NewSortedRunsStorageStateChangeEvent(ssTables, description), nil
+}
+
+// atLevel returns a copy of the Compaction which compacts the table.SSTable files identified by ssTableIds (of the snapshot),
+// and writes the new table.SSTable files at the outputLevel.
+// The shared instance is not mutated, so CompactRange can run with its own output level.
+func (compaction *Compaction) atLevel(outputLevel int, ssTableIds []uint64, snapshot state.StorageStateSnapshot) *Compaction {
+	compactionAtLevel := *compaction
+	compactionAtLevel.outputLevel = outputLevel
+	compactionAtLevel.olderSSTables = olderSSTablesThan(ssTableIds, snapshot)
+	return &compactionAtLevel
+}
+
+// olderSSTablesThan returns the table.SSTable files of the snapshot which are not identified by ssTableIds, and may hold
+// the older versions of the keys in the table.SSTable files identified by ssTableIds:
+// 1) The level0 table.SSTable files which are older than the latest level0 table.SSTable in ssTableIds, and
+// 2) The table.SSTable files at the levels below the topmost level (other than level0) of ssTableIds, or at all the levels
+// if ssTableIds has a level0 table.SSTable.
+// It is conservative: it may return the table.SSTable files which only hold the newer versions (or other keys), that only
+// delays dropping the deleted keys.
+func olderSSTablesThan(ssTableIds []uint64, snapshot state.StorageStateSnapshot) []*table.SSTable {
+	var olderSSTables []*table.SSTable
+	compacted := false
+	for _, ssTableId := range snapshot.L0SSTableIds {
+		if slices.Contains(ssTableIds, ssTableId) {
+			compacted = true
+			continue
+		}
+		if compacted {
+			olderSSTables = append(olderSSTables, snapshot.SSTables[ssTableId])
+		}
+	}
+	for _, level := range snapshot.Levels {
+		levelCompacted := false
+		for _, ssTableId := range level.SSTableIds {
+			if slices.Contains(ssTableIds, ssTableId) {
+				levelCompacted = true
+			} else if compacted {
+				olderSSTables = append(olderSSTables, snapshot.SSTables[ssTableId])
+			}
+		}
+		compacted = compacted || levelCompacted
+	}
+	return olderSSTables
+}
+
+// mayDropDeletedKey returns true if none of the olderSSTables may contain (any version of) the key, so the deleted key can
+// be dropped in compaction without an older version becoming visible again.
+func (compaction *Compaction) mayDropDeletedKey(key kv.Key) bool {
+	keyRange := kv.NewInclusiveKeyRange(key, key)
+	for _, ssTable := range compaction.olderSSTables {
+		if ssTable.ContainsInclusive(keyRange) && ssTable.MayContain(key) {
+			return false
+		}
+	}
+	return true
+}
+
+// validate validates the meta.SortedRunsCompactionDescription against the snapshot: the OutputLevel must be between 0 (drop)
+// and maxLevels, and every table.SSTable of every meta.SortedRun must be present at its Level.
+func validate(description meta.SortedRunsCompactionDescription, snapshot state.StorageStateSnapshot, maxLevels uint) error {
+	if description.OutputLevel < 0 || description.OutputLevel > int(maxLevels) {
+		return fmt.Errorf("output level %d of compaction is not between 0 and %d", description.OutputLevel, maxLevels)
+	}
+	for _, sortedRun := range description.SortedRuns {
+		if sortedRun.Level < 0 || sortedRun.Level > int(maxLevels) {
+			return fmt.Errorf("input level %d of compaction is not between 0 and %d", sortedRun.Level, maxLevels)
+		}
+		ssTableIds := snapshot.SSTableIdsAt(sortedRun.Level)
+		for _, ssTableId := range sortedRun.SSTableIds {
+			if !slices.Contains(ssTableIds, ssTableId) {
+				return fmt.Errorf("SSTable %d of compaction is not present at level %d", ssTableId, sortedRun.Level)
+			}
+		}
+	}
+	return nil
+}
+
+// compactionDescription returns the meta.SimpleLeveledCompactionDescription from the configured compaction strategy
+// (Refer to state.CompactionOptions).
+func (compaction *Compaction) compactionDescription(snapshot state.StorageStateSnapshot) (meta.SimpleLeveledCompactionDescription, bool) {
+	if compaction.options.CompactionOptions.Strategy == state.LeveledCompactionStrategy {
+		return NewLeveledCompaction(compaction.options.CompactionOptions.LeveledStrategyOptions).CompactionDescription(snapshot)
+	}
+	return NewSimpleLeveledCompaction(compaction.options.CompactionOptions.StrategyOptions).CompactionDescription(snapshot)
+}
+
 // compact performs compaction by creating an instance of iterator.MergeIterator using the iterators present in adjacent levels
 // defined in meta.SimpleLeveledCompactionDescription.
 func (compaction *Compaction) compact(description meta.SimpleLeveledCompactionDescription, snapshot state.StorageStateSnapshot) ([]*table.SSTable, error) {
@@ -50,7 +198,7 @@ func (compaction *Compaction) compact(description meta.SimpleLeveledCompactionDe
 		ssTable := snapshot.SSTables[ssTableId]
 		ssTableIterator, err := ssTable.SeekToFirst()
 		if err != nil {
-			return nil, nil
+			return nil, err
 		}
 		upperLevelSSTableIterator = append(upperLevelSSTableIterator, ssTableIterator)
 	}
@@ -59,7 +207,7 @@ func (compaction *Compaction) compact(description meta.SimpleLeveledCompactionDe
 		ssTable := snapshot.SSTables[ssTableId]
 		ssTableIterator, err := ssTable.SeekToFirst()
 		if err != nil {
-			return nil, nil
+			return nil, err
 		}
 		lowerLevelSSTableIterator = append(lowerLevelSSTableIterator, ssTableIterator)
 	}
@@ -71,13 +219,39 @@ func (compaction *Compaction) compact(description meta.SimpleLeveledCompactionDe
 	return
 }
 
+// compactSSTables compacts all the table.SSTable files identified by ssTableIds (Refer to compact).
+// If MaxSubcompactions > 1 (Refer to state.CompactionOptions), the compaction is split into key-range shards which are
+// compacted concurrently (Refer to compactInShards).
+func (compaction *Compaction) compactSSTables(ssTableIds []uint64, snapshot state.StorageStateSnapshot) ([]*table.SSTable, error) {
+	if shards := compaction.subcompactionShards(ssTableIds, snapshot); len(shards) > 1 {
+		return compaction.compactInShards(ssTableIds, shards, snapshot)
+	}
+	return compaction.compact(meta.SimpleLeveledCompactionDescription{UpperLevelSSTableIds: ssTableIds}, snapshot)
+}
+
 // ssTablesFromIterator creates a slice of table.SSTable (/new SSTables) from the given iterator.
 // It skips all the keys with commit-timestamp <= maximum read-timestamp.
 // If the maximum read-timestamp in the system is 9, there is no point in storing any key with commit-timestamp < 9,
 // because all the read operations will be getting read-timestamp > 9 from txn.Oracle.
-func (compaction *Compaction) ssTablesFromIterator(iterator iterator.Iterator) ([]*table.SSTable, error) {
+// The (latest) version of a deleted key with commit-timestamp <= maximum read-timestamp is dropped (along with its older
+// versions) only if no table.SSTable outside the compaction may hold an older version of the key (Refer to mayDropDeletedKey),
+// else it is written as a deleted key, so that the older version does not become visible again.
+// The (latest) version of a key with commit-timestamp <= maximum read-timestamp is passed through the state.CompactionFilter
+// (if configured), only if the key has no version with commit-timestamp > maximum read-timestamp. The versions with
+// commit-timestamp > maximum read-timestamp are never filtered, as they may still be visible to some reads (Refer to filter).
+// Neither is the version just below them, because the reads with read-timestamp between the two versions still see it.
+// If it fails, the new table.SSTable files which are already built are removed, so a failed compaction leaves no orphan
+// table.SSTable files behind.
+func (compaction *Compaction) ssTablesFromIterator(iterator iterator.Iterator) (_ []*table.SSTable, err error) {
 	var ssTableBuilder *table.SSTableBuilder
 	var newSSTables []*table.SSTable
+	defer func() {
+		if err != nil {
+			for _, ssTable := range newSSTables {
+				_ = ssTable.Remove()
+			}
+		}
+	}()
 
 	var lastKey = kv.EmptyKey
 	var firstKeyOccurrence = false
@@ -92,7 +266,12 @@ func (compaction *Compaction) ssTablesFromIterator(iterator iterator.Iterator) (
 			firstKeyOccurrence = true
 		}
 
-		if !sameAsLastRawKey && iterator.Key().Timestamp() <= maxBeginTimestamp && iterator.Value().IsEmpty() {
+		if !sameAsLastRawKey &&
+			iterator.Key().Timestamp() <= maxBeginTimestamp &&
+			iterator.Value().IsEmpty() &&
+			compaction.mayDropDeletedKey(iterator.Key()) {
+			//the older versions of the deleted key are not visible to any read, so they are skipped as well.
+			firstKeyOccurrence = false
 			lastKey = iterator.Key()
 			if err := iterator.Next(); err != nil {
 				return nil, err
@@ -112,7 +291,20 @@ func (compaction *Compaction) ssTablesFromIterator(iterator iterator.Iterator) (
 			newSSTables = append(newSSTables, ssTable)
 			ssTableBuilder = table.NewSSTableBuilderWithDefaultBlockSize()
 		}
-		ssTableBuilder.Add(iterator.Key(), iterator.Value())
+		value, keep := iterator.Value(), true
+		if !sameAsLastRawKey && iterator.Key().Timestamp() <= maxBeginTimestamp {
+			value, keep = compaction.filter(iterator.Key(), value)
+		}
+		if !keep {
+			if !sameAsLastRawKey {
+				lastKey = iterator.Key()
+			}
+			if err := iterator.Next(); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		ssTableBuilder.Add(iterator.Key(), value)
 		if !sameAsLastRawKey {
 			lastKey = iterator.Key()
 		}
@@ -120,7 +312,7 @@ func (compaction *Compaction) ssTablesFromIterator(iterator iterator.Iterator) (
 			return nil, err
 		}
 	}
-	if ssTableBuilder != nil {
+	if ssTableBuilder != nil && !ssTableBuilder.IsEmpty() {
 		ssTable, err := compaction.buildNewSStable(ssTableBuilder)
 		if err != nil {
 			return nil, err
@@ -130,6 +322,30 @@ func (compaction *Compaction) ssTablesFromIterator(iterator iterator.Iterator) (
 	return newSSTables, nil
 }
 
+// filter applies the state.CompactionFilter (if configured) to the key/value pair, and returns the value to write along
+// with true, or false if the key/value pair should not be written. Deleted keys are not passed to the filter.
+// A dropped key is written as a deleted key (an empty value) if a table.SSTable outside the compaction may hold an older
+// version of the key (Refer to mayDropDeletedKey), because the older version would otherwise become visible again.
+// The deleted key is then retained by the later compactions as long as the older version exists, like any other deleted key.
+func (compaction *Compaction) filter(key kv.Key, value kv.Value) (kv.Value, bool) {
+	compactionFilter := compaction.options.CompactionFilter
+	if compactionFilter == nil || value.IsEmpty() {
+		return value, true
+	}
+	decision, newValue := compactionFilter.Filter(key, value, compaction.outputLevel)
+	switch decision {
+	case state.CompactionFilterDrop:
+		if compaction.mayDropDeletedKey(key) {
+			return kv.EmptyValue, false
+		}
+		return kv.EmptyValue, true
+	case state.CompactionFilterChangeValue:
+		return newValue, true
+	default:
+		return value, true
+	}
+}
+
 // buildNewSStable creates a new instance of table.SSTable.
 func (compaction *Compaction) buildNewSStable(ssTableBuilder *table.SSTableBuilder) (*table.SSTable, error) {
 	ssTableId := compaction.idGenerator.NextId()
@@ -0,0 +1,351 @@
+package compact
+
+import (
+	"go-lsm-workshop/kv"
+	"go-lsm-workshop/state"
+	"go-lsm-workshop/table"
+	"go-lsm-workshop/test_utility"
+	"go-lsm-workshop/txn"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type softDeleteCompactionFilter struct {
+	levels []int
+}
+
+func (filter *softDeleteCompactionFilter) Filter(key kv.Key, value kv.Value, level int) (state.CompactionFilterDecision, kv.Value) {
+	filter.levels = append(filter.levels, level)
+	if value.String() == "deleted" {
+		return state.CompactionFilterDrop, kv.EmptyValue
+	}
+	if strings.HasPrefix(value.String(), "v1:") {
+		return state.CompactionFilterChangeValue, kv.NewStringValue("v2:" + strings.TrimPrefix(value.String(), "v1:"))
+	}
+	return state.CompactionFilterKeep, value
+}
+
+func TestCompactionFilterWhichChangesTheValue(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	storageState, _ := state.NewStorageState(rootPath)
+	oracle := txn.NewOracle(txn.NewExecutor(storageState))
+
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+		storageState.Close()
+		oracle.Close()
+	}()
+
+	iterator := newMockIterator(
+		[]kv.Key{
+			kv.NewStringKeyWithTimestamp("consensus", 9),
+			kv.NewStringKeyWithTimestamp("storage", 9),
+		},
+		[]kv.Value{
+			kv.NewStringValue("v1:VSR"),
+			kv.NewStringValue("NVMe"),
+		},
+	)
+	oracle.SetBeginTimestamp(11)
+
+	filter := &softDeleteCompactionFilter{}
+	options := storageState.Options()
+	options.CompactionFilter = filter
+
+	compaction := NewCompaction(oracle, storageState.SSTableIdGenerator(), options)
+	ssTables, err := compaction.atLevel(2, nil, state.StorageStateSnapshot{}).ssTablesFromIterator(iterator)
+
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(ssTables))
+	assert.Equal(t, []int{2, 2}, filter.levels)
+
+	ssTableIterator, err := ssTables[0].SeekToFirst()
+	assert.Nil(t, err)
+	assert.Equal(t, "consensus", ssTableIterator.Key().RawString())
+	assert.Equal(t, kv.NewStringValue("v2:VSR"), ssTableIterator.Value())
+
+	assert.Nil(t, ssTableIterator.Next())
+	assert.Equal(t, "storage", ssTableIterator.Key().RawString())
+	assert.Equal(t, kv.NewStringValue("NVMe"), ssTableIterator.Value())
+}
+
+func TestCompactionFilterWhichDropsTheKeyAtTheLastLevel(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	storageState, _ := state.NewStorageState(rootPath)
+	oracle := txn.NewOracle(txn.NewExecutor(storageState))
+
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+		storageState.Close()
+		oracle.Close()
+	}()
+
+	iterator := newMockIterator(
+		[]kv.Key{
+			kv.NewStringKeyWithTimestamp("consensus", 10),
+			kv.NewStringKeyWithTimestamp("consensus", 9),
+			kv.NewStringKeyWithTimestamp("storage", 9),
+		},
+		[]kv.Value{
+			kv.NewStringValue("deleted"),
+			kv.NewStringValue("VSR"),
+			kv.NewStringValue("NVMe"),
+		},
+	)
+	oracle.SetBeginTimestamp(11)
+
+	options := storageState.Options()
+	options.CompactionFilter = &softDeleteCompactionFilter{}
+
+	compaction := NewCompaction(oracle, storageState.SSTableIdGenerator(), options)
+	lastLevel := int(options.CompactionOptions.MaxLevels())
+	ssTables, err := compaction.atLevel(lastLevel, nil, state.StorageStateSnapshot{}).ssTablesFromIterator(iterator)
+
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(ssTables))
+
+	ssTableIterator, err := ssTables[0].SeekToFirst()
+	assert.Nil(t, err)
+	assert.Equal(t, "storage", ssTableIterator.Key().RawString())
+	assert.Equal(t, kv.NewStringValue("NVMe"), ssTableIterator.Value())
+
+	assert.Nil(t, ssTableIterator.Next())
+	assert.False(t, ssTableIterator.IsValid())
+}
+
+func TestCompactionFilterWhichDropsTheKeyAboveTheLastLevelGivenAnOlderVersionAtALowerLevel(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	storageState, _ := state.NewStorageState(rootPath)
+	oracle := txn.NewOracle(txn.NewExecutor(storageState))
+
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+		storageState.Close()
+		oracle.Close()
+	}()
+
+	iterator := newMockIterator(
+		[]kv.Key{
+			kv.NewStringKeyWithTimestamp("consensus", 9),
+		},
+		[]kv.Value{
+			kv.NewStringValue("deleted"),
+		},
+	)
+	oracle.SetBeginTimestamp(11)
+
+	options := storageState.Options()
+	options.CompactionFilter = &softDeleteCompactionFilter{}
+
+	snapshot := state.StorageStateSnapshot{
+		Levels: []*state.Level{
+			{LevelNumber: 1, SSTableIds: []uint64{1}},
+			{LevelNumber: 2, SSTableIds: []uint64{100}},
+		},
+		SSTables: map[uint64]*table.SSTable{
+			100: buildSSTableWithKeys(t, rootPath, 100, "consensus"),
+		},
+	}
+	compaction := NewCompaction(oracle, storageState.SSTableIdGenerator(), options)
+	ssTables, err := compaction.atLevel(1, []uint64{1}, snapshot).ssTablesFromIterator(iterator)
+
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(ssTables))
+
+	ssTableIterator, err := ssTables[0].SeekToFirst()
+	assert.Nil(t, err)
+	assert.Equal(t, "consensus", ssTableIterator.Key().RawString())
+	assert.True(t, ssTableIterator.Value().IsEmpty())
+}
+
+func TestCompactionFilterWhichDropsTheKeyAboveTheLastLevelGivenNoOlderVersion(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	storageState, _ := state.NewStorageState(rootPath)
+	oracle := txn.NewOracle(txn.NewExecutor(storageState))
+
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+		storageState.Close()
+		oracle.Close()
+	}()
+
+	iterator := newMockIterator(
+		[]kv.Key{
+			kv.NewStringKeyWithTimestamp("consensus", 9),
+		},
+		[]kv.Value{
+			kv.NewStringValue("deleted"),
+		},
+	)
+	oracle.SetBeginTimestamp(11)
+
+	options := storageState.Options()
+	options.CompactionFilter = &softDeleteCompactionFilter{}
+
+	snapshot := state.StorageStateSnapshot{
+		Levels: []*state.Level{
+			{LevelNumber: 1, SSTableIds: []uint64{1}},
+			{LevelNumber: 2, SSTableIds: []uint64{100}},
+		},
+		SSTables: map[uint64]*table.SSTable{
+			100: buildSSTableWithKeys(t, rootPath, 100, "distributed"),
+		},
+	}
+	compaction := NewCompaction(oracle, storageState.SSTableIdGenerator(), options)
+	ssTables, err := compaction.atLevel(1, []uint64{1}, snapshot).ssTablesFromIterator(iterator)
+
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(ssTables))
+}
+
+func TestCompactionFilterDroppedKeyIsRetainedByTheNextCompactionGivenAnOlderVersionAtALowerLevel(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	storageState, _ := state.NewStorageState(rootPath)
+	oracle := txn.NewOracle(txn.NewExecutor(storageState))
+
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+		storageState.Close()
+		oracle.Close()
+	}()
+
+	iterator := newMockIterator(
+		[]kv.Key{
+			kv.NewStringKeyWithTimestamp("consensus", 9),
+			kv.NewStringKeyWithTimestamp("storage", 9),
+		},
+		[]kv.Value{
+			kv.NewStringValue("deleted"),
+			kv.NewStringValue("NVMe"),
+		},
+	)
+	oracle.SetBeginTimestamp(11)
+
+	options := storageState.Options()
+	options.CompactionFilter = &softDeleteCompactionFilter{}
+
+	olderSSTable := buildSSTableWithKeys(t, rootPath, 100, "consensus")
+	compaction := NewCompaction(oracle, storageState.SSTableIdGenerator(), options)
+	ssTables, err := compaction.atLevel(1, []uint64{1}, state.StorageStateSnapshot{
+		Levels: []*state.Level{
+			{LevelNumber: 1, SSTableIds: []uint64{1}},
+			{LevelNumber: 2, SSTableIds: []uint64{100}},
+		},
+		SSTables: map[uint64]*table.SSTable{100: olderSSTable},
+	}).ssTablesFromIterator(iterator)
+
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(ssTables))
+
+	snapshot := state.StorageStateSnapshot{
+		Levels: []*state.Level{
+			{LevelNumber: 1, SSTableIds: []uint64{ssTables[0].Id()}},
+			{LevelNumber: 2, SSTableIds: []uint64{100}},
+		},
+		SSTables: map[uint64]*table.SSTable{ssTables[0].Id(): ssTables[0], 100: olderSSTable},
+	}
+	ssTableIds := []uint64{ssTables[0].Id()}
+	ssTables, err = compaction.atLevel(1, ssTableIds, snapshot).compactSSTables(ssTableIds, snapshot)
+
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(ssTables))
+
+	ssTableIterator, err := ssTables[0].SeekToFirst()
+	assert.Nil(t, err)
+	assert.Equal(t, kv.NewStringKeyWithTimestamp("consensus", 9), ssTableIterator.Key())
+	assert.True(t, ssTableIterator.Value().IsEmpty())
+
+	assert.Nil(t, ssTableIterator.Next())
+	assert.Equal(t, "storage", ssTableIterator.Key().RawString())
+	assert.Equal(t, kv.NewStringValue("NVMe"), ssTableIterator.Value())
+}
+
+func TestCompactionFilterIsNotCalledForTheVersionsAboveMaxBeginTimestamp(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	storageState, _ := state.NewStorageState(rootPath)
+	oracle := txn.NewOracle(txn.NewExecutor(storageState))
+
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+		storageState.Close()
+		oracle.Close()
+	}()
+
+	iterator := newMockIterator(
+		[]kv.Key{
+			kv.NewStringKeyWithTimestamp("consensus", 12),
+			kv.NewStringKeyWithTimestamp("consensus", 10),
+		},
+		[]kv.Value{
+			kv.NewStringValue("deleted"),
+			kv.NewStringValue("v1:VSR"),
+		},
+	)
+	oracle.SetBeginTimestamp(11)
+
+	filter := &softDeleteCompactionFilter{}
+	options := storageState.Options()
+	options.CompactionFilter = filter
+
+	compaction := NewCompaction(oracle, storageState.SSTableIdGenerator(), options)
+	ssTables, err := compaction.atLevel(1, nil, state.StorageStateSnapshot{}).ssTablesFromIterator(iterator)
+
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(ssTables))
+	assert.Equal(t, 0, len(filter.levels))
+
+	ssTableIterator, err := ssTables[0].SeekToFirst()
+	assert.Nil(t, err)
+	assert.Equal(t, kv.NewStringKeyWithTimestamp("consensus", 12), ssTableIterator.Key())
+	assert.Equal(t, kv.NewStringValue("deleted"), ssTableIterator.Value())
+
+	assert.Nil(t, ssTableIterator.Next())
+	assert.Equal(t, kv.NewStringKeyWithTimestamp("consensus", 10), ssTableIterator.Key())
+	assert.Equal(t, kv.NewStringValue("v1:VSR"), ssTableIterator.Value())
+}
+
+func TestCompactionFilterIsNotCalledForTheVersionBelowAVersionAboveMaxBeginTimestamp(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	storageState, _ := state.NewStorageState(rootPath)
+	oracle := txn.NewOracle(txn.NewExecutor(storageState))
+
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+		storageState.Close()
+		oracle.Close()
+	}()
+
+	iterator := newMockIterator(
+		[]kv.Key{
+			kv.NewStringKeyWithTimestamp("consensus", 12),
+			kv.NewStringKeyWithTimestamp("consensus", 8),
+		},
+		[]kv.Value{
+			kv.NewStringValue("live"),
+			kv.NewStringValue("deleted"),
+		},
+	)
+	oracle.SetBeginTimestamp(10)
+
+	filter := &softDeleteCompactionFilter{}
+	options := storageState.Options()
+	options.CompactionFilter = filter
+
+	compaction := NewCompaction(oracle, storageState.SSTableIdGenerator(), options)
+	ssTables, err := compaction.atLevel(1, nil, state.StorageStateSnapshot{}).ssTablesFromIterator(iterator)
+
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(ssTables))
+	assert.Equal(t, 0, len(filter.levels))
+
+	ssTableIterator, err := ssTables[0].SeekToFirst()
+	assert.Nil(t, err)
+	assert.Equal(t, kv.NewStringKeyWithTimestamp("consensus", 12), ssTableIterator.Key())
+	assert.Equal(t, kv.NewStringValue("live"), ssTableIterator.Value())
+
+	assert.Nil(t, ssTableIterator.Next())
+	assert.Equal(t, kv.NewStringKeyWithTimestamp("consensus", 8), ssTableIterator.Key())
+	assert.Equal(t, kv.NewStringValue("deleted"), ssTableIterator.Value())
+}
@@ -0,0 +1,146 @@
+package compact
+
+import (
+	"go-lsm-workshop/compact/meta"
+	"go-lsm-workshop/kv"
+	"go-lsm-workshop/state"
+	"go-lsm-workshop/table"
+	"go-lsm-workshop/test_utility"
+	"go-lsm-workshop/txn"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type level0CompactionStrategy struct {
+	outputLevel int
+	maxLevels   uint
+}
+
+func (strategy level0CompactionStrategy) CompactionDescription(snapshot state.StorageStateSnapshot) (meta.SortedRunsCompactionDescription, bool) {
+	if len(snapshot.L0SSTableIds) < 2 {
+		return meta.NothingToCompactSortedRunsDescription, false
+	}
+	return meta.SortedRunsCompactionDescription{
+		SortedRuns:  []meta.SortedRun{{Level: 0, SSTableIds: snapshot.L0SSTableIds}},
+		OutputLevel: strategy.outputLevel,
+	}, true
+}
+
+func (strategy level0CompactionStrategy) MaxLevels() uint {
+	return strategy.maxLevels
+}
+
+func TestStartCompactionWithCustomStrategy(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	storageOptions := state.StorageOptions{
+		MemTableSizeInBytes:   250,
+		Path:                  rootPath,
+		MaximumMemtables:      2,
+		FlushMemtableDuration: 1 * time.Millisecond,
+		SSTableSizeInBytes:    8192,
+		CompactionOptions: state.CompactionOptions{
+			CustomStrategy: level0CompactionStrategy{outputLevel: 2, maxLevels: 2},
+		},
+	}
+
+	storageState, _ := state.NewStorageStateWithOptions(storageOptions)
+	oracle := txn.NewOracle(txn.NewExecutor(storageState))
+
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+		storageState.Close()
+		oracle.Close()
+	}()
+
+	buildL0SSTable := func(id uint64, key, value string) {
+		ssTableBuilder := table.NewSSTableBuilder(4096)
+		ssTableBuilder.Add(kv.NewStringKeyWithTimestamp(key, 9), kv.NewStringValue(value))
+
+		ssTable, err := ssTableBuilder.Build(id, rootPath)
+		assert.Nil(t, err)
+
+		storageState.SetSSTableAtLevel(ssTable, 0)
+	}
+
+	buildL0SSTable(storageState.SSTableIdGenerator().NextId(), "consensus", "paxos")
+	buildL0SSTable(storageState.SSTableIdGenerator().NextId(), "bolt", "b+tree")
+
+	compaction := NewCompaction(oracle, storageState.SSTableIdGenerator(), storageOptions)
+	storageStateChangeEvent, err := compaction.Start(storageState.Snapshot())
+	assert.Nil(t, err)
+
+	description, ok := storageStateChangeEvent.SortedRunsCompactionDescription()
+	assert.True(t, ok)
+	assert.Equal(t, 2, description.OutputLevel)
+	assert.Equal(t, []uint64{3, 2}, description.AllSSTableIds())
+
+	newSSTables := storageStateChangeEvent.NewSSTables
+	assert.Equal(t, 1, len(newSSTables))
+
+	iterator, err := newSSTables[0].SeekToFirst()
+
+	assert.Nil(t, err)
+	assert.Equal(t, "bolt", iterator.Key().RawString())
+
+	assert.Nil(t, iterator.Next())
+	assert.Equal(t, "consensus", iterator.Key().RawString())
+
+	assert.Nil(t, iterator.Next())
+	assert.False(t, iterator.IsValid())
+}
+
+func TestStartCompactionWithCustomStrategyDescribingAnInvalidOutputLevel(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	storageOptions := state.StorageOptions{
+		MemTableSizeInBytes:   250,
+		Path:                  rootPath,
+		MaximumMemtables:      2,
+		FlushMemtableDuration: 1 * time.Millisecond,
+		SSTableSizeInBytes:    8192,
+		CompactionOptions: state.CompactionOptions{
+			CustomStrategy: level0CompactionStrategy{outputLevel: 3, maxLevels: 2},
+		},
+	}
+
+	storageState, _ := state.NewStorageStateWithOptions(storageOptions)
+	oracle := txn.NewOracle(txn.NewExecutor(storageState))
+
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+		storageState.Close()
+		oracle.Close()
+	}()
+
+	for count := 0; count < 2; count++ {
+		ssTableBuilder := table.NewSSTableBuilder(4096)
+		ssTableBuilder.Add(kv.NewStringKeyWithTimestamp("consensus", 9), kv.NewStringValue("paxos"))
+
+		ssTable, err := ssTableBuilder.Build(storageState.SSTableIdGenerator().NextId(), rootPath)
+		assert.Nil(t, err)
+
+		storageState.SetSSTableAtLevel(ssTable, 0)
+	}
+
+	compaction := NewCompaction(oracle, storageState.SSTableIdGenerator(), storageOptions)
+	storageStateChangeEvent, err := compaction.Start(storageState.Snapshot())
+
+	assert.Error(t, err)
+	assert.False(t, storageStateChangeEvent.HasAnyChanges())
+}
+
+func TestValidateCompactionDescriptionWithSSTableNotPresentAtTheLevel(t *testing.T) {
+	snapshot := state.StorageStateSnapshot{
+		L0SSTableIds: []uint64{1},
+		Levels: []*state.Level{
+			{LevelNumber: 1, SSTableIds: []uint64{2}},
+		},
+	}
+	description := meta.SortedRunsCompactionDescription{
+		SortedRuns:  []meta.SortedRun{{Level: 1, SSTableIds: []uint64{1}}},
+		OutputLevel: 1,
+	}
+
+	assert.Error(t, validate(description, snapshot, 1))
+}
@@ -0,0 +1,64 @@
+package compact
+
+import (
+	"go-lsm-workshop/compact/meta"
+	"go-lsm-workshop/state"
+	"time"
+)
+
+// FIFOCompaction represents a FIFO compaction strategy for time-ordered data, which never merges the table.SSTable files.
+// All the table.SSTable files stay at level0, and the oldest ones are dropped (as a whole) once the total size of the
+// table.SSTable files exceeds MaxTotalSizeInBytes, or the age of a table.SSTable exceeds MaxAge.
+// It involves the following:
+// 1) The level0 table.SSTable files are traversed from the newest to the oldest, accumulating their sizes.
+// 2) The first table.SSTable which takes the accumulated size beyond MaxTotalSizeInBytes (or which is older than MaxAge),
+// and all the table.SSTable files older than it are dropped.
+// The dropped table.SSTable files are removed from state.StorageState, recorded in the manifest and submitted to
+// table.SSTableCleaner, which removes them once they are no longer referenced by any reads.
+// FIFOCompaction is a state.CompactionStrategy, and it describes a drop by a meta.SortedRunsCompactionDescription with
+// OutputLevel 0.
+type FIFOCompaction struct {
+	options state.FIFOCompactionOptions
+}
+
+// NewFIFOCompaction creates a new instance of FIFOCompaction.
+func NewFIFOCompaction(options state.FIFOCompactionOptions) FIFOCompaction {
+	return FIFOCompaction{
+		options: options,
+	}
+}
+
+// CompactionDescription returns the meta.SortedRunsCompactionDescription which drops the oldest level0 table.SSTable files.
+// It returns meta.NothingToCompactSortedRunsDescription, false if no table.SSTable needs to be dropped.
+func (compaction FIFOCompaction) CompactionDescription(stateSnapshot state.StorageStateSnapshot) (meta.SortedRunsCompactionDescription, bool) {
+	var totalSizeInBytes int64
+	ssTableIds := stateSnapshot.SSTableIdsAt(0)
+
+	for index, ssTableId := range ssTableIds {
+		ssTable := stateSnapshot.SSTables[ssTableId]
+		totalSizeInBytes += ssTable.SizeInBytes()
+
+		exceedsSize := compaction.options.MaxTotalSizeInBytes > 0 && totalSizeInBytes > compaction.options.MaxTotalSizeInBytes
+		if exceedsSize || compaction.exceedsAge(ssTable.CreationTime()) {
+			return meta.SortedRunsCompactionDescription{
+				SortedRuns:  []meta.SortedRun{{Level: 0, SSTableIds: ssTableIds[index:]}},
+				OutputLevel: 0,
+			}, true
+		}
+	}
+	return meta.NothingToCompactSortedRunsDescription, false
+}
+
+// MaxLevels returns 0, as FIFOCompaction keeps all the table.SSTable files at level0.
+func (compaction FIFOCompaction) MaxLevels() uint {
+	return 0
+}
+
+// exceedsAge returns true if the creationTime is older than MaxAge.
+// A table.SSTable whose creation time can not be determined is not considered to be aged.
+func (compaction FIFOCompaction) exceedsAge(creationTime time.Time, err error) bool {
+	if compaction.options.MaxAge <= 0 || err != nil {
+		return false
+	}
+	return time.Since(creationTime) > compaction.options.MaxAge
+}
@@ -0,0 +1,78 @@
+package compact
+
+import (
+	"go-lsm-workshop/state"
+	"go-lsm-workshop/table"
+	"go-lsm-workshop/test_utility"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFIFOCompactionWithNothingToDrop(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	defer test_utility.CleanupDirectoryWithTestName(t)
+
+	snapshot := state.StorageStateSnapshot{
+		L0SSTableIds: []uint64{2, 1},
+		SSTables: map[uint64]*table.SSTable{
+			1: buildSSTableWithKeys(t, rootPath, 1, "consensus"),
+			2: buildSSTableWithKeys(t, rootPath, 2, "distributed"),
+		},
+	}
+	compaction := NewFIFOCompaction(state.FIFOCompactionOptions{
+		MaxTotalSizeInBytes: 1 << 20,
+		MaxAge:              time.Hour,
+	})
+	_, ok := compaction.CompactionDescription(snapshot)
+
+	assert.False(t, ok)
+}
+
+func TestFIFOCompactionDropsTheOldestSSTablesBeyondMaxTotalSize(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	defer test_utility.CleanupDirectoryWithTestName(t)
+
+	snapshot := state.StorageStateSnapshot{
+		L0SSTableIds: []uint64{3, 2, 1},
+		SSTables: map[uint64]*table.SSTable{
+			1: buildSSTableWithKeys(t, rootPath, 1, "consensus"),
+			2: buildSSTableWithKeys(t, rootPath, 2, "distributed"),
+			3: buildSSTableWithKeys(t, rootPath, 3, "etcd"),
+		},
+	}
+	compaction := NewFIFOCompaction(state.FIFOCompactionOptions{
+		MaxTotalSizeInBytes: snapshot.SSTables[3].SizeInBytes() + 1,
+	})
+	description, ok := compaction.CompactionDescription(snapshot)
+
+	assert.True(t, ok)
+	assert.True(t, description.DropsSSTables())
+	assert.Equal(t, []uint64{2, 1}, description.AllSSTableIds())
+}
+
+func TestFIFOCompactionDropsTheSSTablesOlderThanMaxAge(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	defer test_utility.CleanupDirectoryWithTestName(t)
+
+	snapshot := state.StorageStateSnapshot{
+		L0SSTableIds: []uint64{3, 2, 1},
+		SSTables: map[uint64]*table.SSTable{
+			1: buildSSTableWithKeys(t, rootPath, 1, "consensus"),
+			2: buildSSTableWithKeys(t, rootPath, 2, "distributed"),
+			3: buildSSTableWithKeys(t, rootPath, 3, "etcd"),
+		},
+	}
+	twoHoursAgo := time.Now().Add(-2 * time.Hour)
+	assert.Nil(t, os.Chtimes(table.SSTableFilePath(1, rootPath), twoHoursAgo, twoHoursAgo))
+
+	compaction := NewFIFOCompaction(state.FIFOCompactionOptions{
+		MaxAge: time.Hour,
+	})
+	description, ok := compaction.CompactionDescription(snapshot)
+
+	assert.True(t, ok)
+	assert.Equal(t, []uint64{1}, description.AllSSTableIds())
+}
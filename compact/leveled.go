@@ -0,0 +1,161 @@
+package compact
+
+import (
+	"go-lsm-workshop/compact/meta"
+	"go-lsm-workshop/kv"
+	"go-lsm-workshop/state"
+	"math"
+)
+
+// LeveledCompaction represents a size-based leveled compaction strategy (similar to the leveled compaction of RocksDB with
+// dynamic level bytes), which compacts a single table.SSTable of a level with the overlapping table.SSTable files of the next level.
+// It involves the following:
+// 1) Target size per level: the target size of the last level is its actual size (but at least BaseLevelSizeInBytes), and
+// the target size of every level above is the target size of the level below divided by LevelSizeMultiplier. The base
+// level is the first level whose target size does not exceed BaseLevelSizeInBytes; the levels above the base level are
+// expected to be empty, and level0 is compacted directly into the base level. This keeps the ratio between the adjacent
+// levels close to LevelSizeMultiplier, irrespective of the size of the database.
+// 2) Score per level: for level0, it is the number of table.SSTable files / Level0FilesCompactionTrigger. For the levels
+// between the base level and the last level, it is the size of the level / its target size. A non-empty level above the
+// base level gets an infinite score, so that it is drained first. The last level is never compacted.
+// 3) The level with the highest score (>= 1) is picked. If it is level0, all the level0 table.SSTable files are compacted
+// with the overlapping table.SSTable files of the base level. Else, the table.SSTable with the least overlapping bytes in the
+// next level (relative to its own size) is compacted with the overlapping table.SSTable files of the next level.
+// Unlike SimpleLeveledCompaction, the sizes (and not the counts) of the table.SSTable files are considered, and a compaction
+// only involves the overlapping table.SSTable files of the next level, which keeps the compactions small.
+type LeveledCompaction struct {
+	options state.LeveledCompactionOptions
+}
+
+// NewLeveledCompaction creates a new instance of LeveledCompaction.
+func NewLeveledCompaction(options state.LeveledCompactionOptions) LeveledCompaction {
+	return LeveledCompaction{
+		options: options,
+	}
+}
+
+// CompactionDescription returns the meta.SimpleLeveledCompactionDescription.
+// If UpperLevel in meta.SimpleLeveledCompactionDescription is -1, it denotes, level0.
+// It returns an instance of meta.SimpleLeveledCompactionDescription if any level has a score >= 1, else it returns
+// meta.NothingToCompactDescription, false.
+func (compaction LeveledCompaction) CompactionDescription(stateSnapshot state.StorageStateSnapshot) (meta.SimpleLeveledCompactionDescription, bool) {
+	maxLevels := int(compaction.options.MaxLevels)
+	if maxLevels == 0 {
+		return meta.NothingToCompactDescription, false
+	}
+	levelSizes := compaction.levelSizes(stateSnapshot)
+	baseLevel, targetSizes := compaction.options.TargetSizes(levelSizes)
+	scores := compaction.scores(stateSnapshot, levelSizes, baseLevel, targetSizes)
+
+	levelToCompact, highestScore := -1, 0.0
+	for level, score := range scores {
+		if score >= 1 && score > highestScore {
+			levelToCompact, highestScore = level, score
+		}
+	}
+	if levelToCompact == -1 {
+		return meta.NothingToCompactDescription, false
+	}
+	if levelToCompact == 0 {
+		upperLevelSSTableIds := stateSnapshot.SSTableIdsAt(0)
+		return meta.SimpleLeveledCompactionDescription{
+			UpperLevel:           -1,
+			LowerLevel:           baseLevel,
+			UpperLevelSSTableIds: upperLevelSSTableIds,
+			LowerLevelSSTableIds: overlappingSSTableIds(stateSnapshot, baseLevel, keyRangeOf(stateSnapshot, upperLevelSSTableIds)),
+		}, true
+	}
+	upperLevelSSTableId := compaction.ssTableWithLeastOverlapRatio(stateSnapshot, levelToCompact)
+	return meta.SimpleLeveledCompactionDescription{
+		UpperLevel:           levelToCompact,
+		LowerLevel:           levelToCompact + 1,
+		UpperLevelSSTableIds: []uint64{upperLevelSSTableId},
+		LowerLevelSSTableIds: overlappingSSTableIds(
+			stateSnapshot,
+			levelToCompact+1,
+			keyRangeOf(stateSnapshot, []uint64{upperLevelSSTableId}),
+		),
+	}, true
+}
+
+// levelSizes returns the total size of the table.SSTable files at every level (index 0 denotes level0).
+func (compaction LeveledCompaction) levelSizes(stateSnapshot state.StorageStateSnapshot) []int64 {
+	levelSizes := make([]int64, compaction.options.MaxLevels+1)
+	for level := range levelSizes {
+		for _, ssTableId := range stateSnapshot.SSTableIdsAt(level) {
+			levelSizes[level] += stateSnapshot.SSTables[ssTableId].SizeInBytes()
+		}
+	}
+	return levelSizes
+}
+
+// scores returns the compaction score of every level (index 0 denotes level0). The last level has a zero score.
+func (compaction LeveledCompaction) scores(
+	stateSnapshot state.StorageStateSnapshot,
+	levelSizes []int64,
+	baseLevel int,
+	targetSizes []int64,
+) []float64 {
+	maxLevels := int(compaction.options.MaxLevels)
+	scores := make([]float64, maxLevels+1)
+	scores[0] = float64(len(stateSnapshot.L0SSTableIds)) / float64(max(compaction.options.Level0FilesCompactionTrigger, 1))
+
+	for level := 1; level < maxLevels; level++ {
+		switch {
+		case levelSizes[level] == 0:
+			scores[level] = 0
+		case level < baseLevel:
+			scores[level] = math.Inf(1)
+		default:
+			scores[level] = float64(levelSizes[level]) / float64(max(targetSizes[level], 1))
+		}
+	}
+	return scores
+}
+
+// ssTableWithLeastOverlapRatio returns the id of the table.SSTable at the level, which has the least ratio of the
+// overlapping bytes in the next level to its own size. Such a table.SSTable is the cheapest to compact (in terms of the
+// write amplification).
+func (compaction LeveledCompaction) ssTableWithLeastOverlapRatio(stateSnapshot state.StorageStateSnapshot, level int) uint64 {
+	var selectedSSTableId uint64
+	leastOverlapRatio := math.Inf(1)
+
+	for _, ssTableId := range stateSnapshot.SSTableIdsAt(level) {
+		ssTable := stateSnapshot.SSTables[ssTableId]
+		var overlappingBytes int64
+		for _, overlappingSSTableId := range overlappingSSTableIds(stateSnapshot, level+1, keyRangeOf(stateSnapshot, []uint64{ssTableId})) {
+			overlappingBytes += stateSnapshot.SSTables[overlappingSSTableId].SizeInBytes()
+		}
+		overlapRatio := float64(overlappingBytes) / float64(max(ssTable.SizeInBytes(), 1))
+		if overlapRatio < leastOverlapRatio {
+			selectedSSTableId, leastOverlapRatio = ssTableId, overlapRatio
+		}
+	}
+	return selectedSSTableId
+}
+
+// keyRangeOf returns the (raw) key range which spans all the table.SSTable files identified by ssTableIds.
+func keyRangeOf(stateSnapshot state.StorageStateSnapshot, ssTableIds []uint64) kv.InclusiveKeyRange[kv.Key] {
+	var startingKey, endingKey kv.Key
+	for index, ssTableId := range ssTableIds {
+		ssTable := stateSnapshot.SSTables[ssTableId]
+		if index == 0 || ssTable.StartingKey().IsRawKeyLesserThan(startingKey) {
+			startingKey = ssTable.StartingKey()
+		}
+		if index == 0 || ssTable.EndingKey().IsRawKeyGreaterThan(endingKey) {
+			endingKey = ssTable.EndingKey()
+		}
+	}
+	return kv.NewInclusiveKeyRange(startingKey, endingKey)
+}
+
+// overlappingSSTableIds returns the ids of the table.SSTable files at the level which overlap with the keyRange.
+func overlappingSSTableIds(stateSnapshot state.StorageStateSnapshot, level int, keyRange kv.InclusiveKeyRange[kv.Key]) []uint64 {
+	var ssTableIds []uint64
+	for _, ssTableId := range stateSnapshot.SSTableIdsAt(level) {
+		if stateSnapshot.SSTables[ssTableId].ContainsInclusive(keyRange) {
+			ssTableIds = append(ssTableIds, ssTableId)
+		}
+	}
+	return ssTableIds
+}
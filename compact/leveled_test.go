@@ -0,0 +1,112 @@
+package compact
+
+import (
+	"go-lsm-workshop/kv"
+	"go-lsm-workshop/state"
+	"go-lsm-workshop/table"
+	"go-lsm-workshop/test_utility"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func buildSSTableWithKeys(t *testing.T, rootPath string, id uint64, keys ...string) *table.SSTable {
+	ssTableBuilder := table.NewSSTableBuilder(4096)
+	for _, key := range keys {
+		ssTableBuilder.Add(kv.NewStringKeyWithTimestamp(key, 5), kv.NewStringValue("value-of-"+key))
+	}
+	ssTable, err := ssTableBuilder.Build(id, rootPath)
+	assert.Nil(t, err)
+	return ssTable
+}
+
+func TestLeveledCompactionWithNoCompaction(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	defer test_utility.CleanupDirectoryWithTestName(t)
+
+	snapshot := state.StorageStateSnapshot{
+		L0SSTableIds: []uint64{1},
+		Levels: []*state.Level{
+			{LevelNumber: 1, SSTableIds: nil},
+			{LevelNumber: 2, SSTableIds: []uint64{2}},
+		},
+		SSTables: map[uint64]*table.SSTable{
+			1: buildSSTableWithKeys(t, rootPath, 1, "consensus"),
+			2: buildSSTableWithKeys(t, rootPath, 2, "distributed"),
+		},
+	}
+	compaction := NewLeveledCompaction(state.LeveledCompactionOptions{
+		Level0FilesCompactionTrigger: 2,
+		BaseLevelSizeInBytes:         1 << 20,
+		LevelSizeMultiplier:          10,
+		MaxLevels:                    2,
+	})
+	_, ok := compaction.CompactionDescription(snapshot)
+
+	assert.False(t, ok)
+}
+
+func TestLeveledCompactionOfLevel0IntoTheBaseLevelWithOnlyOverlappingSSTables(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	defer test_utility.CleanupDirectoryWithTestName(t)
+
+	snapshot := state.StorageStateSnapshot{
+		L0SSTableIds: []uint64{2, 1},
+		Levels: []*state.Level{
+			{LevelNumber: 1, SSTableIds: nil},
+			{LevelNumber: 2, SSTableIds: nil},
+			{LevelNumber: 3, SSTableIds: []uint64{3, 4}},
+		},
+		SSTables: map[uint64]*table.SSTable{
+			1: buildSSTableWithKeys(t, rootPath, 1, "a", "c"),
+			2: buildSSTableWithKeys(t, rootPath, 2, "b", "d"),
+			3: buildSSTableWithKeys(t, rootPath, 3, "c", "e"),
+			4: buildSSTableWithKeys(t, rootPath, 4, "x", "z"),
+		},
+	}
+	compaction := NewLeveledCompaction(state.LeveledCompactionOptions{
+		Level0FilesCompactionTrigger: 2,
+		BaseLevelSizeInBytes:         1 << 20,
+		LevelSizeMultiplier:          10,
+		MaxLevels:                    3,
+	})
+	description, ok := compaction.CompactionDescription(snapshot)
+
+	assert.True(t, ok)
+	assert.Equal(t, -1, description.UpperLevel)
+	assert.Equal(t, 3, description.LowerLevel)
+	assert.Equal(t, []uint64{2, 1}, description.UpperLevelSSTableIds)
+	assert.Equal(t, []uint64{3}, description.LowerLevelSSTableIds)
+}
+
+func TestLeveledCompactionOfASingleSSTableWithTheLeastOverlap(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	defer test_utility.CleanupDirectoryWithTestName(t)
+
+	snapshot := state.StorageStateSnapshot{
+		L0SSTableIds: nil,
+		Levels: []*state.Level{
+			{LevelNumber: 1, SSTableIds: []uint64{1, 2}},
+			{LevelNumber: 2, SSTableIds: []uint64{3, 4}},
+		},
+		SSTables: map[uint64]*table.SSTable{
+			1: buildSSTableWithKeys(t, rootPath, 1, "a", "b"),
+			2: buildSSTableWithKeys(t, rootPath, 2, "m", "n"),
+			3: buildSSTableWithKeys(t, rootPath, 3, "a", "c"),
+			4: buildSSTableWithKeys(t, rootPath, 4, "n", "o", "p", "q", "r", "s", "t", "u", "v", "w", "x", "y", "z"),
+		},
+	}
+	compaction := NewLeveledCompaction(state.LeveledCompactionOptions{
+		Level0FilesCompactionTrigger: 2,
+		BaseLevelSizeInBytes:         1,
+		LevelSizeMultiplier:          10,
+		MaxLevels:                    2,
+	})
+	description, ok := compaction.CompactionDescription(snapshot)
+
+	assert.True(t, ok)
+	assert.Equal(t, 1, description.UpperLevel)
+	assert.Equal(t, 2, description.LowerLevel)
+	assert.Equal(t, []uint64{1}, description.UpperLevelSSTableIds)
+	assert.Equal(t, []uint64{3}, description.LowerLevelSSTableIds)
+}
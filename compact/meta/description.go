@@ -12,3 +12,38 @@ type SimpleLeveledCompactionDescription struct {
 	UpperLevelSSTableIds []uint64
 	LowerLevelSSTableIds []uint64
 }
+
+// NothingToCompactSortedRunsDescription represents none compaction of sorted runs.
+var NothingToCompactSortedRunsDescription = SortedRunsCompactionDescription{}
+
+// SortedRun represents the input table.SSTable ids at a Level (0 denotes level0) which will undergo compaction.
+// With compact.TieredCompaction, it is a sorted run: either a single level0 SSTable, or all the SSTables of a level.
+// With any other strategy (Refer to state.CompactionStrategy), it may be any subset of the SSTables of a level.
+type SortedRun struct {
+	Level      int
+	SSTableIds []uint64
+}
+
+// SortedRunsCompactionDescription defines the sorted runs (ordered from the newest to the oldest) which will undergo
+// compaction, and the OutputLevel at which the compacted sorted run is placed.
+// An OutputLevel of 0 denotes that the sorted runs are dropped without any compaction (Refer to compact.FIFOCompaction).
+// Unlike SimpleLeveledCompactionDescription, it can describe the compaction of any number of sorted runs
+// (Refer to compact.TieredCompaction), and it is the generic description for any state.CompactionStrategy.
+type SortedRunsCompactionDescription struct {
+	SortedRuns  []SortedRun
+	OutputLevel int
+}
+
+// AllSSTableIds returns the ids of all the table.SSTable files in all the SortedRuns.
+func (description SortedRunsCompactionDescription) AllSSTableIds() []uint64 {
+	var ssTableIds []uint64
+	for _, sortedRun := range description.SortedRuns {
+		ssTableIds = append(ssTableIds, sortedRun.SSTableIds...)
+	}
+	return ssTableIds
+}
+
+// DropsSSTables returns true if the table.SSTable files in all the SortedRuns are dropped without any compaction.
+func (description SortedRunsCompactionDescription) DropsSSTables() bool {
+	return description.OutputLevel == 0
+}
@@ -0,0 +1,64 @@
+package compact
+
+import (
+	"go-lsm-workshop/compact/meta"
+	"go-lsm-workshop/kv"
+	"go-lsm-workshop/state"
+)
+
+// CompactRange compacts all the table.SSTable files (from level0 to the targetLevel) which overlap with the keyRange into the
+// targetLevel (Refer to rangeCompactionDescription).
+// Unlike Start, it is not driven by any compaction strategy and it returns the error (if any) in compacting the
+// table.SSTable files. It returns state.NoStorageStateChanges if no table.SSTable overlaps with the keyRange.
+func (compaction *Compaction) CompactRange(
+	snapshot state.StorageStateSnapshot,
+	keyRange kv.InclusiveKeyRange[kv.Key],
+	targetLevel int,
+) (state.StorageStateChangeEvent, error) {
+	description, ok := rangeCompactionDescription(snapshot, keyRange, targetLevel)
+	if !ok {
+		return state.NoStorageStateChanges, nil
+	}
+	ssTableIds := description.AllSSTableIds()
+	ssTables, err := compaction.atLevel(description.OutputLevel, ssTableIds, snapshot).compactSSTables(ssTableIds, snapshot)
+	if err != nil {
+		return state.NoStorageStateChanges, err
+	}
+	return state.NewSortedRunsStorageStateChangeEvent(ssTables, description), nil
+}
+
+// rangeCompactionDescription returns the meta.SortedRunsCompactionDescription which compacts all the table.SSTable files
+// (from level0 to the targetLevel) overlapping with the keyRange into the targetLevel.
+// The key range is widened to span all the selected table.SSTable files, and the selection is repeated until it is stable.
+// This ensures that the new table.SSTable files at the targetLevel do not overlap with the (unselected) table.SSTable files
+// of the targetLevel, and that no (unselected) table.SSTable above the targetLevel holds the keys which are compacted.
+func rangeCompactionDescription(
+	snapshot state.StorageStateSnapshot,
+	keyRange kv.InclusiveKeyRange[kv.Key],
+	targetLevel int,
+) (meta.SortedRunsCompactionDescription, bool) {
+	totalSelectedSSTables := 0
+	for {
+		var sortedRuns []meta.SortedRun
+		var selectedSSTableIds []uint64
+
+		for level := 0; level <= targetLevel; level++ {
+			ssTableIds := overlappingSSTableIds(snapshot, level, keyRange)
+			if len(ssTableIds) > 0 {
+				sortedRuns = append(sortedRuns, meta.SortedRun{Level: level, SSTableIds: ssTableIds})
+				selectedSSTableIds = append(selectedSSTableIds, ssTableIds...)
+			}
+		}
+		if len(selectedSSTableIds) == 0 {
+			return meta.NothingToCompactSortedRunsDescription, false
+		}
+		if len(selectedSSTableIds) == totalSelectedSSTables {
+			return meta.SortedRunsCompactionDescription{
+				SortedRuns:  sortedRuns,
+				OutputLevel: targetLevel,
+			}, true
+		}
+		totalSelectedSSTables = len(selectedSSTableIds)
+		keyRange = keyRangeOf(snapshot, selectedSSTableIds)
+	}
+}
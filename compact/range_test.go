@@ -0,0 +1,69 @@
+package compact
+
+import (
+	"go-lsm-workshop/compact/meta"
+	"go-lsm-workshop/kv"
+	"go-lsm-workshop/state"
+	"go-lsm-workshop/table"
+	"go-lsm-workshop/test_utility"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRangeCompactionDescriptionWithWidenedKeyRange(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	defer test_utility.CleanupDirectoryWithTestName(t)
+
+	snapshot := state.StorageStateSnapshot{
+		L0SSTableIds: []uint64{2, 1},
+		Levels: []*state.Level{
+			{LevelNumber: 1, SSTableIds: []uint64{3, 4}},
+			{LevelNumber: 2, SSTableIds: []uint64{5, 6}},
+		},
+		SSTables: map[uint64]*table.SSTable{
+			1: buildSSTableWithKeys(t, rootPath, 1, "b", "c"),
+			2: buildSSTableWithKeys(t, rootPath, 2, "x", "z"),
+			3: buildSSTableWithKeys(t, rootPath, 3, "a", "d"),
+			4: buildSSTableWithKeys(t, rootPath, 4, "m", "n"),
+			5: buildSSTableWithKeys(t, rootPath, 5, "d", "f"),
+			6: buildSSTableWithKeys(t, rootPath, 6, "p", "q"),
+		},
+	}
+	description, ok := rangeCompactionDescription(
+		snapshot,
+		kv.NewInclusiveKeyRange(kv.NewStringKeyWithTimestamp("b", 0), kv.NewStringKeyWithTimestamp("b", 0)),
+		2,
+	)
+
+	assert.True(t, ok)
+	assert.Equal(t, 2, description.OutputLevel)
+	assert.Equal(t, []meta.SortedRun{
+		{Level: 0, SSTableIds: []uint64{1}},
+		{Level: 1, SSTableIds: []uint64{3}},
+		{Level: 2, SSTableIds: []uint64{5}},
+	}, description.SortedRuns)
+}
+
+func TestRangeCompactionDescriptionWithNoOverlappingSSTables(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	defer test_utility.CleanupDirectoryWithTestName(t)
+
+	snapshot := state.StorageStateSnapshot{
+		L0SSTableIds: []uint64{1},
+		Levels: []*state.Level{
+			{LevelNumber: 1, SSTableIds: []uint64{2}},
+		},
+		SSTables: map[uint64]*table.SSTable{
+			1: buildSSTableWithKeys(t, rootPath, 1, "b", "c"),
+			2: buildSSTableWithKeys(t, rootPath, 2, "x", "z"),
+		},
+	}
+	_, ok := rangeCompactionDescription(
+		snapshot,
+		kv.NewInclusiveKeyRange(kv.NewStringKeyWithTimestamp("m", 0), kv.NewStringKeyWithTimestamp("n", 0)),
+		1,
+	)
+
+	assert.False(t, ok)
+}
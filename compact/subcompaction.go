@@ -0,0 +1,166 @@
+package compact
+
+import (
+	"errors"
+	"go-lsm-workshop/iterator"
+	"go-lsm-workshop/kv"
+	"go-lsm-workshop/state"
+	"go-lsm-workshop/table"
+	"math"
+	"slices"
+	"sync"
+)
+
+// subcompactionShard represents a key-range shard of a compaction: all the keys with raw key >= start and raw key < end.
+// An empty start means the shard begins at the first key, and an empty end means the shard spans till the last key.
+// All the versions of a raw key belong to the same shard, so that each shard can skip the older versions independently.
+type subcompactionShard struct {
+	start kv.Key
+	end   kv.Key
+}
+
+// subcompactionShards splits the compaction of the table.SSTable files identified by ssTableIds into at most
+// MaxSubcompactions shards.
+// The shard boundaries are picked from the starting keys of the blocks of all the table.SSTable files (Refer to
+// block.MetaList), evenly spaced by the number of blocks, so that the shards are of roughly the same size.
+func (compaction *Compaction) subcompactionShards(ssTableIds []uint64, snapshot state.StorageStateSnapshot) []subcompactionShard {
+	maxSubcompactions := int(compaction.options.CompactionOptions.MaxSubcompactions)
+	if maxSubcompactions <= 1 {
+		return []subcompactionShard{{}}
+	}
+	var blockStartingKeys []kv.Key
+	for _, ssTableId := range ssTableIds {
+		blockStartingKeys = append(blockStartingKeys, snapshot.SSTables[ssTableId].BlockStartingKeys()...)
+	}
+	slices.SortFunc(blockStartingKeys, func(key, other kv.Key) int {
+		return key.CompareKeysWithDescendingTimestamp(other)
+	})
+	blockStartingKeys = slices.CompactFunc(blockStartingKeys, kv.Key.IsRawKeyEqualTo)
+	if len(blockStartingKeys) < 2 {
+		return []subcompactionShard{{}}
+	}
+
+	//the first starting key is the smallest key, which can not split the keys.
+	candidateBoundaries := blockStartingKeys[1:]
+	numberOfShards := min(maxSubcompactions, len(candidateBoundaries)+1)
+
+	shards := make([]subcompactionShard, 0, numberOfShards)
+	start := kv.EmptyKey
+	for shard := 1; shard < numberOfShards; shard++ {
+		end := candidateBoundaries[shard*len(candidateBoundaries)/numberOfShards]
+		shards = append(shards, subcompactionShard{start: start, end: end})
+		start = end
+	}
+	return append(shards, subcompactionShard{start: start, end: kv.EmptyKey})
+}
+
+// compactInShards compacts the table.SSTable files identified by ssTableIds by running a subcompaction for each shard
+// concurrently. The new table.SSTable files of all the shards are returned in the order of the shards (/keys), so that
+// they can be recorded in a single state.StorageStateChangeEvent.
+// If any subcompaction fails, the new table.SSTable files of all the subcompactions are removed.
+func (compaction *Compaction) compactInShards(
+	ssTableIds []uint64,
+	shards []subcompactionShard,
+	snapshot state.StorageStateSnapshot,
+) ([]*table.SSTable, error) {
+	ssTablesByShard := make([][]*table.SSTable, len(shards))
+	errs := make([]error, len(shards))
+
+	var wg sync.WaitGroup
+	for index, shard := range shards {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ssTablesByShard[index], errs[index] = compaction.subcompact(ssTableIds, shard, snapshot)
+		}()
+	}
+	wg.Wait()
+
+	newSSTables := slices.Concat(ssTablesByShard...)
+	if err := errors.Join(errs...); err != nil {
+		for _, ssTable := range newSSTables {
+			_ = ssTable.Remove()
+		}
+		return nil, err
+	}
+	return newSSTables, nil
+}
+
+// subcompact compacts the keys of the shard from the table.SSTable files identified by ssTableIds.
+// The iterators are positioned at the start of the shard (using table.SSTable's SeekToKey), and are bounded by the end
+// of the shard (Refer to shardBoundedIterator).
+func (compaction *Compaction) subcompact(
+	ssTableIds []uint64,
+	shard subcompactionShard,
+	snapshot state.StorageStateSnapshot,
+) ([]*table.SSTable, error) {
+	ssTables := make([]*table.SSTable, 0, len(ssTableIds))
+	iterators := make([]iterator.Iterator, 0, len(ssTableIds))
+	defer func() {
+		table.DecrementReferenceFor(ssTables)
+	}()
+
+	for _, ssTableId := range ssTableIds {
+		ssTable := snapshot.SSTables[ssTableId]
+		if shard.start.IsRawKeyEmpty() {
+			ssTableIterator, err := ssTable.SeekToFirst()
+			if err != nil {
+				return nil, err
+			}
+			iterators = append(iterators, ssTableIterator)
+			continue
+		}
+		ssTableIterator, err := ssTable.SeekToKey(kv.NewKey(shard.start.RawBytes(), math.MaxUint64))
+		if err != nil {
+			return nil, err
+		}
+		ssTables = append(ssTables, ssTable)
+		iterators = append(iterators, ssTableIterator)
+	}
+	return compaction.ssTablesFromIterator(
+		newShardBoundedIterator(iterator.NewMergeIterator(iterators, iterator.NoOperationOnCloseCallback), shard.end),
+	)
+}
+
+// shardBoundedIterator is an iterator.Iterator which does not go beyond (or up to) the raw key exclusiveEnd.
+// An empty exclusiveEnd means that the iterator is not bounded.
+type shardBoundedIterator struct {
+	inner        iterator.Iterator
+	exclusiveEnd kv.Key
+}
+
+// newShardBoundedIterator creates a new instance of shardBoundedIterator.
+func newShardBoundedIterator(inner iterator.Iterator, exclusiveEnd kv.Key) *shardBoundedIterator {
+	return &shardBoundedIterator{
+		inner:        inner,
+		exclusiveEnd: exclusiveEnd,
+	}
+}
+
+// Key returns kv.Key.
+func (iterator *shardBoundedIterator) Key() kv.Key {
+	return iterator.inner.Key()
+}
+
+// Value returns kv.Value.
+func (iterator *shardBoundedIterator) Value() kv.Value {
+	return iterator.inner.Value()
+}
+
+// Next advances the inner iterator.
+func (iterator *shardBoundedIterator) Next() error {
+	return iterator.inner.Next()
+}
+
+// IsValid returns true if the inner iterator is valid, and its raw key is less than the exclusiveEnd.
+func (iterator *shardBoundedIterator) IsValid() bool {
+	if !iterator.inner.IsValid() {
+		return false
+	}
+	return iterator.exclusiveEnd.IsRawKeyEmpty() || iterator.inner.Key().IsRawKeyLesserThan(iterator.exclusiveEnd)
+}
+
+// Close closes the inner iterator.
+func (iterator *shardBoundedIterator) Close() {
+	iterator.inner.Close()
+}
@@ -0,0 +1,108 @@
+package compact
+
+import (
+	"fmt"
+	"go-lsm-workshop/kv"
+	"go-lsm-workshop/state"
+	"go-lsm-workshop/table"
+	"go-lsm-workshop/test_utility"
+	"go-lsm-workshop/txn"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func buildSSTableWithKeysAtTimestamp(t *testing.T, rootPath string, id uint64, timestamp uint64, keys ...string) *table.SSTable {
+	ssTableBuilder := table.NewSSTableBuilder(4096)
+	for _, key := range keys {
+		ssTableBuilder.Add(kv.NewStringKeyWithTimestamp(key, timestamp), kv.NewStringValue(fmt.Sprintf("%v@%v", key, timestamp)))
+	}
+	ssTable, err := ssTableBuilder.Build(id, rootPath)
+	assert.Nil(t, err)
+	return ssTable
+}
+
+func TestSubcompactionShardsWithoutSubcompactions(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	defer test_utility.CleanupDirectoryWithTestName(t)
+
+	snapshot := state.StorageStateSnapshot{
+		SSTables: map[uint64]*table.SSTable{
+			1: buildSSTableWithKeys(t, rootPath, 1, keysWithPrefix("a", 1000)...),
+		},
+	}
+	compaction := NewCompaction(nil, state.NewSSTableIdGenerator(), state.StorageOptions{})
+	shards := compaction.subcompactionShards([]uint64{1}, snapshot)
+
+	assert.Equal(t, []subcompactionShard{{}}, shards)
+}
+
+func TestSubcompactionShardsFromBlockBoundaries(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	defer test_utility.CleanupDirectoryWithTestName(t)
+
+	snapshot := state.StorageStateSnapshot{
+		SSTables: map[uint64]*table.SSTable{
+			1: buildSSTableWithKeys(t, rootPath, 1, keysWithPrefix("a", 1000)...),
+			2: buildSSTableWithKeys(t, rootPath, 2, keysWithPrefix("a", 1000)...),
+		},
+	}
+	compaction := NewCompaction(nil, state.NewSSTableIdGenerator(), state.StorageOptions{
+		CompactionOptions: state.CompactionOptions{MaxSubcompactions: 3},
+	})
+	shards := compaction.subcompactionShards([]uint64{1, 2}, snapshot)
+
+	assert.Equal(t, 3, len(shards))
+	assert.True(t, shards[0].start.IsRawKeyEmpty())
+	assert.True(t, shards[2].end.IsRawKeyEmpty())
+	for index := 1; index < len(shards); index++ {
+		assert.True(t, shards[index].start.IsRawKeyEqualTo(shards[index-1].end))
+		assert.True(t, shards[index].start.IsRawKeyGreaterThan(shards[index-1].start))
+	}
+}
+
+func TestCompactSSTablesWithSubcompactions(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	storageState, _ := state.NewStorageState(rootPath)
+	oracle := txn.NewOracle(txn.NewExecutor(storageState))
+
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+		storageState.Close()
+		oracle.Close()
+	}()
+
+	keys := keysWithPrefix("a", 1000)
+	snapshot := state.StorageStateSnapshot{
+		SSTables: map[uint64]*table.SSTable{
+			1001: buildSSTableWithKeysAtTimestamp(t, rootPath, 1001, 7, keys...),
+			1002: buildSSTableWithKeysAtTimestamp(t, rootPath, 1002, 5, keys...),
+		},
+	}
+	oracle.SetBeginTimestamp(11)
+
+	options := storageState.Options()
+	options.CompactionOptions.MaxSubcompactions = 4
+
+	compaction := NewCompaction(oracle, storageState.SSTableIdGenerator(), options)
+	assert.Equal(t, 4, len(compaction.subcompactionShards([]uint64{1001, 1002}, snapshot)))
+
+	ssTables, err := compaction.compactSSTables([]uint64{1001, 1002}, snapshot)
+	assert.Nil(t, err)
+	assert.Equal(t, 4, len(ssTables))
+	assert.Equal(t, int64(0), snapshot.SSTables[1001].TotalReferences())
+	assert.Equal(t, int64(0), snapshot.SSTables[1002].TotalReferences())
+
+	var compactedKeys []string
+	for _, ssTable := range ssTables {
+		ssTableIterator, err := ssTable.SeekToFirst()
+		assert.Nil(t, err)
+		for ssTableIterator.IsValid() {
+			assert.Equal(t, uint64(7), ssTableIterator.Key().Timestamp())
+			assert.Equal(t, ssTableIterator.Key().RawString()+"@7", ssTableIterator.Value().String())
+			compactedKeys = append(compactedKeys, ssTableIterator.Key().RawString())
+			assert.Nil(t, ssTableIterator.Next())
+		}
+	}
+	assert.Equal(t, keys, compactedKeys)
+}
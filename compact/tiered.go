@@ -0,0 +1,128 @@
+package compact
+
+import (
+	"go-lsm-workshop/compact/meta"
+	"go-lsm-workshop/state"
+)
+
+// TieredCompaction represents a size-tiered (universal) compaction strategy, which merges the sorted runs of similar size.
+// It favours lower write amplification over read (and space) amplification.
+// A sorted run is either a level0 table.SSTable, or all the table.SSTable files of a (non-empty) level. The sorted runs are
+// ordered from the newest to the oldest: the level0 SSTables (latest first), followed by level1, level2 and so on.
+// Compaction is considered only if the number of sorted runs >= SortedRunsCompactionTrigger. It then involves the following
+// (in order):
+// Option1: Space amplification. If the size of all the sorted runs (except the oldest) / the size of the oldest sorted run
+// exceeds MaxSizeAmplificationPercent, all the sorted runs are merged (a full compaction).
+// Option2: Size ratio. Starting from the newest sorted run, the next (older) sorted run is included in the candidate as long as
+// its size is <= the size of the candidate so far * (100 + SizeRatioPercent) / 100. A candidate with at least
+// MinMergeWidth sorted runs is merged.
+// Option3: Number of sorted runs. The newest sorted runs are merged so that the number of sorted runs falls below the
+// SortedRunsCompactionTrigger.
+// The merged sorted run is placed at a level which keeps the order of the sorted runs (Refer to describe).
+// TieredCompaction is a state.CompactionStrategy.
+type TieredCompaction struct {
+	options state.TieredCompactionOptions
+}
+
+// NewTieredCompaction creates a new instance of TieredCompaction.
+func NewTieredCompaction(options state.TieredCompactionOptions) TieredCompaction {
+	return TieredCompaction{
+		options: options,
+	}
+}
+
+// CompactionDescription returns the meta.SortedRunsCompactionDescription.
+// It returns an instance of meta.SortedRunsCompactionDescription if the sorted runs are eligible for compaction, else it
+// returns meta.NothingToCompactSortedRunsDescription, false.
+func (compaction TieredCompaction) CompactionDescription(stateSnapshot state.StorageStateSnapshot) (meta.SortedRunsCompactionDescription, bool) {
+	sortedRuns, sizes := compaction.sortedRuns(stateSnapshot)
+	if compaction.options.MaxLevels == 0 || len(sortedRuns) < 2 || len(sortedRuns) < int(compaction.options.SortedRunsCompactionTrigger) {
+		return meta.NothingToCompactSortedRunsDescription, false
+	}
+	if compaction.options.MaxSizeAmplificationPercent > 0 {
+		var newerSortedRunsSize int64
+		for _, size := range sizes[:len(sizes)-1] {
+			newerSortedRunsSize += size
+		}
+		if newerSortedRunsSize*100 > int64(compaction.options.MaxSizeAmplificationPercent)*sizes[len(sizes)-1] {
+			return compaction.describe(sortedRuns, 0, len(sortedRuns)-1), true
+		}
+	}
+	minMergeWidth := max(int(compaction.options.MinMergeWidth), 2)
+	for start := 0; start < len(sortedRuns); start++ {
+		candidateSize, end := sizes[start], start+1
+		for end < len(sortedRuns) && float64(candidateSize)*float64(100+compaction.options.SizeRatioPercent)/100 >= float64(sizes[end]) {
+			candidateSize += sizes[end]
+			end++
+		}
+		if end-start >= minMergeWidth {
+			return compaction.describe(sortedRuns, start, end-1), true
+		}
+	}
+	numberOfSortedRunsToMerge := max(len(sortedRuns)-int(compaction.options.SortedRunsCompactionTrigger)+1, 2)
+	return compaction.describe(sortedRuns, 0, numberOfSortedRunsToMerge-1), true
+}
+
+// MaxLevels returns the maximum number of levels (excluding level0) used by TieredCompaction.
+func (compaction TieredCompaction) MaxLevels() uint {
+	return compaction.options.MaxLevels
+}
+
+// sortedRuns returns the sorted runs (from the newest to the oldest) along with their sizes.
+// The level0 SSTableIds in state.StorageStateSnapshot are already ordered from the newest to the oldest.
+func (compaction TieredCompaction) sortedRuns(stateSnapshot state.StorageStateSnapshot) ([]meta.SortedRun, []int64) {
+	var sortedRuns []meta.SortedRun
+	var sizes []int64
+
+	sizeOf := func(ssTableIds []uint64) int64 {
+		var size int64
+		for _, ssTableId := range ssTableIds {
+			size += stateSnapshot.SSTables[ssTableId].SizeInBytes()
+		}
+		return size
+	}
+	for _, ssTableId := range stateSnapshot.L0SSTableIds {
+		sortedRuns = append(sortedRuns, meta.SortedRun{Level: 0, SSTableIds: []uint64{ssTableId}})
+		sizes = append(sizes, sizeOf([]uint64{ssTableId}))
+	}
+	for level := 1; level <= int(compaction.options.MaxLevels); level++ {
+		ssTableIds := stateSnapshot.SSTableIdsAt(level)
+		if len(ssTableIds) == 0 {
+			continue
+		}
+		sortedRuns = append(sortedRuns, meta.SortedRun{Level: level, SSTableIds: ssTableIds})
+		sizes = append(sizes, sizeOf(ssTableIds))
+	}
+	return sortedRuns, sizes
+}
+
+// describe returns the meta.SortedRunsCompactionDescription for merging the sorted runs from the index first to the index
+// last (both inclusive), such that the merged sorted run stays older than the sorted runs before first, and newer than
+// the sorted runs after last.
+// If the last sorted run is at a level (other than level0), the merged sorted run is placed at that level.
+// Else, the older level0 sorted runs are included (level0 SSTables can not be placed after a level), and the merged sorted
+// run is placed at the level just above the next (older) sorted run. If there is no such (empty) level, the next sorted
+// run is included as well. If there is no older sorted run, the merged sorted run is placed at the last level.
+func (compaction TieredCompaction) describe(sortedRuns []meta.SortedRun, first, last int) meta.SortedRunsCompactionDescription {
+	for last+1 < len(sortedRuns) && sortedRuns[last+1].Level == 0 {
+		last++
+	}
+	outputLevel := sortedRuns[last].Level
+	if outputLevel == 0 {
+		if last+1 < len(sortedRuns) {
+			nextLevel := sortedRuns[last+1].Level
+			if nextLevel > 1 {
+				outputLevel = nextLevel - 1
+			} else {
+				last++
+				outputLevel = nextLevel
+			}
+		} else {
+			outputLevel = int(compaction.options.MaxLevels)
+		}
+	}
+	return meta.SortedRunsCompactionDescription{
+		SortedRuns:  sortedRuns[first : last+1],
+		OutputLevel: outputLevel,
+	}
+}
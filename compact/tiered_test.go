@@ -0,0 +1,221 @@
+package compact
+
+import (
+	"fmt"
+	"go-lsm-workshop/compact/meta"
+	"go-lsm-workshop/kv"
+	"go-lsm-workshop/state"
+	"go-lsm-workshop/table"
+	"go-lsm-workshop/test_utility"
+	"go-lsm-workshop/txn"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func keysWithPrefix(prefix string, count int) []string {
+	keys := make([]string, 0, count)
+	for index := 0; index < count; index++ {
+		keys = append(keys, fmt.Sprintf("%v-%04d", prefix, index))
+	}
+	return keys
+}
+
+func TestTieredCompactionWithNumberOfSortedRunsBelowTrigger(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	defer test_utility.CleanupDirectoryWithTestName(t)
+
+	snapshot := state.StorageStateSnapshot{
+		L0SSTableIds: []uint64{1},
+		Levels: []*state.Level{
+			{LevelNumber: 1, SSTableIds: nil},
+			{LevelNumber: 2, SSTableIds: []uint64{2}},
+		},
+		SSTables: map[uint64]*table.SSTable{
+			1: buildSSTableWithKeys(t, rootPath, 1, "consensus"),
+			2: buildSSTableWithKeys(t, rootPath, 2, "distributed"),
+		},
+	}
+	compaction := NewTieredCompaction(state.TieredCompactionOptions{
+		SortedRunsCompactionTrigger: 3,
+		MaxSizeAmplificationPercent: 200,
+		SizeRatioPercent:            1,
+		MinMergeWidth:               2,
+		MaxLevels:                   2,
+	})
+	_, ok := compaction.CompactionDescription(snapshot)
+
+	assert.False(t, ok)
+}
+
+func TestTieredCompactionOfAllSortedRunsWithSpaceAmplification(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	defer test_utility.CleanupDirectoryWithTestName(t)
+
+	snapshot := state.StorageStateSnapshot{
+		L0SSTableIds: []uint64{2, 1},
+		Levels: []*state.Level{
+			{LevelNumber: 1, SSTableIds: nil},
+			{LevelNumber: 2, SSTableIds: nil},
+			{LevelNumber: 3, SSTableIds: []uint64{3}},
+		},
+		SSTables: map[uint64]*table.SSTable{
+			1: buildSSTableWithKeys(t, rootPath, 1, keysWithPrefix("a", 50)...),
+			2: buildSSTableWithKeys(t, rootPath, 2, keysWithPrefix("b", 50)...),
+			3: buildSSTableWithKeys(t, rootPath, 3, "consensus"),
+		},
+	}
+	compaction := NewTieredCompaction(state.TieredCompactionOptions{
+		SortedRunsCompactionTrigger: 2,
+		MaxSizeAmplificationPercent: 200,
+		SizeRatioPercent:            1,
+		MinMergeWidth:               2,
+		MaxLevels:                   3,
+	})
+	description, ok := compaction.CompactionDescription(snapshot)
+
+	assert.True(t, ok)
+	assert.Equal(t, 3, description.OutputLevel)
+	assert.Equal(t, []meta.SortedRun{
+		{Level: 0, SSTableIds: []uint64{2}},
+		{Level: 0, SSTableIds: []uint64{1}},
+		{Level: 3, SSTableIds: []uint64{3}},
+	}, description.SortedRuns)
+}
+
+func TestTieredCompactionOfSortedRunsWithSimilarSizes(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	defer test_utility.CleanupDirectoryWithTestName(t)
+
+	snapshot := state.StorageStateSnapshot{
+		L0SSTableIds: []uint64{2, 1},
+		Levels: []*state.Level{
+			{LevelNumber: 1, SSTableIds: nil},
+			{LevelNumber: 2, SSTableIds: nil},
+			{LevelNumber: 3, SSTableIds: []uint64{3}},
+		},
+		SSTables: map[uint64]*table.SSTable{
+			1: buildSSTableWithKeys(t, rootPath, 1, "a"),
+			2: buildSSTableWithKeys(t, rootPath, 2, "b"),
+			3: buildSSTableWithKeys(t, rootPath, 3, keysWithPrefix("c", 1000)...),
+		},
+	}
+	compaction := NewTieredCompaction(state.TieredCompactionOptions{
+		SortedRunsCompactionTrigger: 3,
+		MaxSizeAmplificationPercent: 200,
+		SizeRatioPercent:            10,
+		MinMergeWidth:               2,
+		MaxLevels:                   3,
+	})
+	description, ok := compaction.CompactionDescription(snapshot)
+
+	assert.True(t, ok)
+	assert.Equal(t, 2, description.OutputLevel)
+	assert.Equal(t, []meta.SortedRun{
+		{Level: 0, SSTableIds: []uint64{2}},
+		{Level: 0, SSTableIds: []uint64{1}},
+	}, description.SortedRuns)
+}
+
+func TestTieredCompactionToReduceTheNumberOfSortedRuns(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	defer test_utility.CleanupDirectoryWithTestName(t)
+
+	snapshot := state.StorageStateSnapshot{
+		L0SSTableIds: []uint64{1},
+		Levels: []*state.Level{
+			{LevelNumber: 1, SSTableIds: []uint64{2}},
+			{LevelNumber: 2, SSTableIds: []uint64{3}},
+			{LevelNumber: 3, SSTableIds: []uint64{4}},
+		},
+		SSTables: map[uint64]*table.SSTable{
+			1: buildSSTableWithKeys(t, rootPath, 1, "a"),
+			2: buildSSTableWithKeys(t, rootPath, 2, keysWithPrefix("b", 10)...),
+			3: buildSSTableWithKeys(t, rootPath, 3, keysWithPrefix("c", 50)...),
+			4: buildSSTableWithKeys(t, rootPath, 4, keysWithPrefix("d", 200)...),
+		},
+	}
+	compaction := NewTieredCompaction(state.TieredCompactionOptions{
+		SortedRunsCompactionTrigger: 3,
+		SizeRatioPercent:            0,
+		MinMergeWidth:               2,
+		MaxLevels:                   3,
+	})
+	description, ok := compaction.CompactionDescription(snapshot)
+
+	assert.True(t, ok)
+	assert.Equal(t, 1, description.OutputLevel)
+	assert.Equal(t, []meta.SortedRun{
+		{Level: 0, SSTableIds: []uint64{1}},
+		{Level: 1, SSTableIds: []uint64{2}},
+	}, description.SortedRuns)
+}
+
+func TestStartTieredCompactionKeepsTheDeletedKeyGivenTheOldestSortedRunIsNotMerged(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	storageState, _ := state.NewStorageState(rootPath)
+	oracle := txn.NewOracle(txn.NewExecutor(storageState))
+
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+		storageState.Close()
+		oracle.Close()
+	}()
+
+	buildSSTable := func(id uint64, timestamp uint64, keyValuePairs ...string) *table.SSTable {
+		ssTableBuilder := table.NewSSTableBuilder(4096)
+		for index := 0; index < len(keyValuePairs); index += 2 {
+			ssTableBuilder.Add(kv.NewStringKeyWithTimestamp(keyValuePairs[index], timestamp), kv.NewStringValue(keyValuePairs[index+1]))
+		}
+		ssTable, err := ssTableBuilder.Build(id, rootPath)
+		assert.Nil(t, err)
+		return ssTable
+	}
+	snapshot := state.StorageStateSnapshot{
+		L0SSTableIds: []uint64{3, 2},
+		Levels: []*state.Level{
+			{LevelNumber: 1, SSTableIds: nil},
+			{LevelNumber: 2, SSTableIds: nil},
+			{LevelNumber: 3, SSTableIds: []uint64{1}},
+		},
+		SSTables: map[uint64]*table.SSTable{
+			1: buildSSTableWithKeys(t, rootPath, 1, append([]string{"consensus"}, keysWithPrefix("key", 2000)...)...),
+			2: buildSSTable(2, 8, "consensus", "raft", "etcd", "bbolt"),
+			3: buildSSTable(3, 9, "consensus", "", "etcd", "raft"),
+		},
+	}
+	oracle.SetBeginTimestamp(11)
+
+	options := storageState.Options()
+	options.CompactionOptions = state.CompactionOptions{
+		Strategy: state.TieredCompactionStrategy,
+		TieredStrategyOptions: state.TieredCompactionOptions{
+			SortedRunsCompactionTrigger: 2,
+			MaxSizeAmplificationPercent: 200,
+			SizeRatioPercent:            100,
+			MinMergeWidth:               2,
+			MaxLevels:                   3,
+		},
+	}
+	compaction := NewCompaction(oracle, storageState.SSTableIdGenerator(), options)
+	event, err := compaction.Start(snapshot)
+	assert.Nil(t, err)
+
+	description, ok := event.SortedRunsCompactionDescription()
+	assert.True(t, ok)
+	assert.Equal(t, 2, description.OutputLevel)
+	assert.Equal(t, []uint64{3, 2}, description.AllSSTableIds())
+	assert.Equal(t, 1, len(event.NewSSTables))
+
+	ssTableIterator, err := event.NewSSTables[0].SeekToFirst()
+	assert.Nil(t, err)
+	assert.Equal(t, kv.NewStringKeyWithTimestamp("consensus", 9), ssTableIterator.Key())
+	assert.True(t, ssTableIterator.Value().IsEmpty())
+
+	assert.Nil(t, ssTableIterator.Next())
+	assert.Equal(t, kv.NewStringKeyWithTimestamp("etcd", 9), ssTableIterator.Key())
+	assert.Equal(t, kv.NewStringValue("raft"), ssTableIterator.Value())
+
+	assert.Nil(t, ssTableIterator.Next())
+	assert.False(t, ssTableIterator.IsValid())
+}
@@ -0,0 +1,39 @@
+package compact
+
+import (
+	"go-lsm-workshop/compact/meta"
+	"go-lsm-workshop/state"
+	"go-lsm-workshop/table"
+)
+
+// trivialMove returns a state.StorageStateChangeEvent which moves the upper level table.SSTable files of the description to
+// the lower level without reading or rewriting them, and true if the compaction is a trivial move.
+// A compaction is a trivial move if:
+// 1) No state.CompactionFilter is configured (the filter must see all the values which are compacted), and
+// 2) The upper level table.SSTable files do not overlap with each other, and
+// 3) None of the upper level table.SSTable files overlaps with any table.SSTable of the lower level.
+// With a trivial move, the older versions (and the deleted keys) in the moved table.SSTable files are not dropped; they are
+// dropped whenever the table.SSTable files are compacted with the overlapping table.SSTable files of the next level.
+func (compaction *Compaction) trivialMove(description meta.SimpleLeveledCompactionDescription, snapshot state.StorageStateSnapshot) (state.StorageStateChangeEvent, bool) {
+	if compaction.options.CompactionFilter != nil || len(description.UpperLevelSSTableIds) == 0 {
+		return state.NoStorageStateChanges, false
+	}
+	ssTables := make([]*table.SSTable, 0, len(description.UpperLevelSSTableIds))
+	for index, ssTableId := range description.UpperLevelSSTableIds {
+		keyRange := keyRangeOf(snapshot, []uint64{ssTableId})
+		for _, otherSSTableId := range description.UpperLevelSSTableIds[index+1:] {
+			if snapshot.SSTables[otherSSTableId].ContainsInclusive(keyRange) {
+				return state.NoStorageStateChanges, false
+			}
+		}
+		if len(overlappingSSTableIds(snapshot, description.LowerLevel, keyRange)) > 0 {
+			return state.NoStorageStateChanges, false
+		}
+		ssTables = append(ssTables, snapshot.SSTables[ssTableId])
+	}
+	return state.NewSSTablesMovedStorageStateChangeEvent(ssTables, meta.SimpleLeveledCompactionDescription{
+		UpperLevel:           description.UpperLevel,
+		LowerLevel:           description.LowerLevel,
+		UpperLevelSSTableIds: description.UpperLevelSSTableIds,
+	}), true
+}
@@ -0,0 +1,115 @@
+package compact
+
+import (
+	"go-lsm-workshop/compact/meta"
+	"go-lsm-workshop/state"
+	"go-lsm-workshop/table"
+	"go-lsm-workshop/test_utility"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTrivialMoveOfNonOverlappingSSTablesAtLevel0(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	defer test_utility.CleanupDirectoryWithTestName(t)
+
+	snapshot := state.StorageStateSnapshot{
+		L0SSTableIds: []uint64{2, 1},
+		Levels:       []*state.Level{{LevelNumber: 1, SSTableIds: []uint64{3}}},
+		SSTables: map[uint64]*table.SSTable{
+			1: buildSSTableWithKeys(t, rootPath, 1, "accurate", "consensus"),
+			2: buildSSTableWithKeys(t, rootPath, 2, "distributed", "etcd"),
+			3: buildSSTableWithKeys(t, rootPath, 3, "raft", "zab"),
+		},
+	}
+	description := meta.SimpleLeveledCompactionDescription{
+		UpperLevel:           -1,
+		LowerLevel:           1,
+		UpperLevelSSTableIds: []uint64{2, 1},
+		LowerLevelSSTableIds: []uint64{3},
+	}
+	compaction := NewCompaction(nil, state.NewSSTableIdGenerator(), state.StorageOptions{})
+	event, ok := compaction.trivialMove(description, snapshot)
+
+	assert.True(t, ok)
+	assert.True(t, event.MovesSSTables())
+	assert.Equal(t, -1, event.CompactionUpperLevel())
+	assert.Equal(t, 1, event.CompactionLowerLevel())
+	assert.Equal(t, []uint64{2, 1}, event.CompactionUpperLevelSSTableIds())
+	assert.Equal(t, 0, len(event.CompactionLowerLevelSSTableIds()))
+	assert.Equal(t, []uint64{2, 1}, event.NewSSTableIds)
+}
+
+func TestNoTrivialMoveIfAnUpperLevelSSTableOverlapsWithTheLowerLevel(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	defer test_utility.CleanupDirectoryWithTestName(t)
+
+	snapshot := state.StorageStateSnapshot{
+		Levels: []*state.Level{
+			{LevelNumber: 1, SSTableIds: []uint64{1}},
+			{LevelNumber: 2, SSTableIds: []uint64{2}},
+		},
+		SSTables: map[uint64]*table.SSTable{
+			1: buildSSTableWithKeys(t, rootPath, 1, "accurate", "raft"),
+			2: buildSSTableWithKeys(t, rootPath, 2, "consensus", "etcd"),
+		},
+	}
+	description := meta.SimpleLeveledCompactionDescription{
+		UpperLevel:           1,
+		LowerLevel:           2,
+		UpperLevelSSTableIds: []uint64{1},
+		LowerLevelSSTableIds: []uint64{2},
+	}
+	compaction := NewCompaction(nil, state.NewSSTableIdGenerator(), state.StorageOptions{})
+	_, ok := compaction.trivialMove(description, snapshot)
+
+	assert.False(t, ok)
+}
+
+func TestNoTrivialMoveIfTheUpperLevelSSTablesOverlapWithEachOther(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	defer test_utility.CleanupDirectoryWithTestName(t)
+
+	snapshot := state.StorageStateSnapshot{
+		L0SSTableIds: []uint64{2, 1},
+		Levels:       []*state.Level{{LevelNumber: 1}},
+		SSTables: map[uint64]*table.SSTable{
+			1: buildSSTableWithKeys(t, rootPath, 1, "accurate", "etcd"),
+			2: buildSSTableWithKeys(t, rootPath, 2, "consensus", "raft"),
+		},
+	}
+	description := meta.SimpleLeveledCompactionDescription{
+		UpperLevel:           -1,
+		LowerLevel:           1,
+		UpperLevelSSTableIds: []uint64{2, 1},
+	}
+	compaction := NewCompaction(nil, state.NewSSTableIdGenerator(), state.StorageOptions{})
+	_, ok := compaction.trivialMove(description, snapshot)
+
+	assert.False(t, ok)
+}
+
+func TestNoTrivialMoveWithCompactionFilter(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	defer test_utility.CleanupDirectoryWithTestName(t)
+
+	snapshot := state.StorageStateSnapshot{
+		L0SSTableIds: []uint64{1},
+		Levels:       []*state.Level{{LevelNumber: 1}},
+		SSTables: map[uint64]*table.SSTable{
+			1: buildSSTableWithKeys(t, rootPath, 1, "accurate", "consensus"),
+		},
+	}
+	description := meta.SimpleLeveledCompactionDescription{
+		UpperLevel:           -1,
+		LowerLevel:           1,
+		UpperLevelSSTableIds: []uint64{1},
+	}
+	compaction := NewCompaction(nil, state.NewSSTableIdGenerator(), state.StorageOptions{
+		CompactionFilter: &softDeleteCompactionFilter{},
+	})
+	_, ok := compaction.trivialMove(description, snapshot)
+
+	assert.False(t, ok)
+}
@@ -0,0 +1,75 @@
+package go_lsm_workshop
+
+import (
+	"context"
+	"errors"
+	"go-lsm-workshop/compact"
+	"go-lsm-workshop/kv"
+)
+
+var InvalidTargetLevelErr = errors.New("target level must be between 1 and the maximum number of levels")
+
+// CompactRangeStats represents the outcome of Db.CompactRange.
+// BytesRead is the total size of the compacted (input) SSTables, and BytesWritten is the total size of the new SSTables.
+type CompactRangeStats struct {
+	InputSSTables  int
+	OutputSSTables int
+	BytesRead      int64
+	BytesWritten   int64
+}
+
+// CompactRange synchronously compacts all the SSTables (from level0 to the targetLevel) which overlap with the keyRange
+// into the targetLevel (Refer to compact.Compaction.CompactRange). It is meant to be used by operators after large deletes
+// or imports, to reclaim space and reduce the number of SSTables to read from.
+// The keyRange may be widened to include the SSTables which overlap with the selected SSTables, and the memtables are
+// not flushed, so the keys present only in the memtables are not compacted.
+// It holds the compactionLock, so it never runs concurrently with the compaction goroutine (or ingestion, or the beginning
+// of a checkpoint).
+// It returns DbReadonlyErr if the Db is in read-only mode (Refer to BackgroundError), and InvalidTargetLevelErr if the
+// targetLevel is not between 1 and the maximum number of levels of the configured compaction strategy.
+func (db *Db) CompactRange(ctx context.Context, keyRange kv.InclusiveKeyRange[kv.RawKey], targetLevel int) (CompactRangeStats, error) {
+	if db.stopped.Load() {
+		return CompactRangeStats{}, DbAlreadyStoppedErr
+	}
+	if err := db.readonlyErr(); err != nil {
+		return CompactRangeStats{}, err
+	}
+	if targetLevel < 1 || targetLevel > int(db.storageState.Options().CompactionOptions.MaxLevels()) {
+		return CompactRangeStats{}, InvalidTargetLevelErr
+	}
+	db.compactionLock.Lock()
+	defer db.compactionLock.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return CompactRangeStats{}, err
+	}
+	snapshot := db.storageState.Snapshot()
+	compaction := compact.NewCompaction(db.oracle, db.storageState.SSTableIdGenerator(), db.storageState.Options())
+	event, err := compaction.CompactRange(
+		snapshot,
+		kv.NewInclusiveKeyRange(kv.NewKey(keyRange.Start(), 0), kv.NewKey(keyRange.End(), 0)),
+		targetLevel,
+	)
+	if err != nil {
+		return CompactRangeStats{}, err
+	}
+	if !event.HasAnyChanges() {
+		return CompactRangeStats{}, nil
+	}
+
+	description, _ := event.SortedRunsCompactionDescription()
+	stats := CompactRangeStats{
+		InputSSTables:  len(description.AllSSTableIds()),
+		OutputSSTables: len(event.NewSSTables),
+	}
+	for _, ssTableId := range description.AllSSTableIds() {
+		stats.BytesRead += snapshot.SSTables[ssTableId].SizeInBytes()
+	}
+	for _, ssTable := range event.NewSSTables {
+		stats.BytesWritten += ssTable.SizeInBytes()
+	}
+	if err := db.storageState.Apply(event, false); err != nil {
+		return CompactRangeStats{}, err
+	}
+	return stats, nil
+}
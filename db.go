@@ -1,6 +1,7 @@
 package go_lsm_workshop
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"go-lsm-workshop/compact"
@@ -8,7 +9,7 @@ import (
 	"go-lsm-workshop/kv"
 	"go-lsm-workshop/state"
 	"go-lsm-workshop/txn"
-	"log/slog"
+	"sync"
 	"sync/atomic"
 	"time"
 )
@@ -21,6 +22,11 @@ type Db struct {
 	oracle       *txn.Oracle
 	stopped      atomic.Bool
 	stopChannel  chan struct{}
+	stats        stats
+	//backgroundError is the latest error of the background operations (Refer to BackgroundError).
+	backgroundError atomic.Pointer[state.BackgroundError]
+	//compactionLock ensures that compaction does not run concurrently with the ingestion of SSTables, and the beginning of a checkpoint.
+	compactionLock sync.Mutex
 }
 
 // KeyValue is an abstraction which contains a key/value pair.
@@ -36,50 +42,195 @@ func Open(options state.StorageOptions) (*Db, error) {
 	if err != nil {
 		return nil, err
 	}
+	return newDb(storageState), nil
+}
+
+// newDb creates a new instance of Db with the given StorageState, and starts compaction.
+func newDb(storageState *state.StorageState) *Db {
+	executor := txn.NewExecutor(storageState)
 	db := &Db{
 		storageState: storageState,
-		oracle:       txn.NewOracleWithLastCommitTimestamp(txn.NewExecutor(storageState), storageState.LastCommitTimestamp()),
+		oracle:       txn.NewOracleWithLastCommitTimestamp(executor, storageState.LastCommitTimestamp(), storageState.Options().LockTimeout),
 		stopChannel:  make(chan struct{}),
 	}
+	storageState.SetBackgroundErrorHandler(db.handleBackgroundError)
 	db.startCompaction()
-	return db, nil
+	return db
+}
+
+// NewTransaction creates a new txn.Transaction which can be kept open across function boundaries.
+// If readonly is true, a Readonly txn.Transaction (via txn.NewReadonlyTransactionWithContext) is created,
+// else a Readwrite txn.Transaction (via txn.NewReadwriteTransactionWithContext) is created.
+// Creating a transaction waits for all the commits till its begin-timestamp to be applied, it returns ctx.Err() if the
+// ctx is done before that.
+// The caller owns the transaction and must invoke Discard on it, typically using defer, even after a Commit.
+// Discard is idempotent, and it finishes the begin-timestamp of the transaction in txn.Oracle (if not already done).
+func (db *Db) NewTransaction(ctx context.Context, readonly bool) (*txn.Transaction, error) {
+	if db.stopped.Load() {
+		return nil, DbAlreadyStoppedErr
+	}
+	if readonly {
+		return txn.NewReadonlyTransactionWithContext(ctx, db.oracle, db.storageState)
+	}
+	if err := db.readonlyErr(); err != nil {
+		return nil, err
+	}
+	return txn.NewReadwriteTransactionWithContext(ctx, db.oracle, db.storageState)
+}
+
+// NewTransactionWithIsolationLevel creates a new Readwrite txn.Transaction with the given txn.IsolationLevel, which can be
+// kept open across function boundaries.
+// Like NewTransaction, the caller owns the transaction and must invoke Discard on it.
+func (db *Db) NewTransactionWithIsolationLevel(ctx context.Context, isolationLevel txn.IsolationLevel) (*txn.Transaction, error) {
+	if db.stopped.Load() {
+		return nil, DbAlreadyStoppedErr
+	}
+	if err := db.readonlyErr(); err != nil {
+		return nil, err
+	}
+	return txn.NewReadwriteTransactionWithContextAndIsolationLevel(ctx, db.oracle, db.storageState, isolationLevel)
 }
 
 // Read supports read operation by passing an instance of txn.Transaction (via txn.NewReadonlyTransaction) to the callback.
 // The passed transaction is a Readonly txn.Transaction which will panic on any form of write and commit operations.
-func (db *Db) Read(callback func(transaction *txn.Transaction)) error {
-	if db.stopped.Load() {
-		return DbAlreadyStoppedErr
+// It returns ctx.Err() (without invoking the callback) if the ctx is done before the transaction could begin.
+func (db *Db) Read(ctx context.Context, callback func(transaction *txn.Transaction)) error {
+	return db.View(ctx, func(transaction *txn.Transaction) error {
+		callback(transaction)
+		return nil
+	})
+}
+
+// View is a variant of Read which allows the callback to return an error.
+// The passed transaction is a Readonly txn.Transaction, which is discarded after the callback returns (or panics).
+// It returns the error returned by the callback.
+func (db *Db) View(ctx context.Context, callback func(transaction *txn.Transaction) error) error {
+	transaction, err := db.NewTransaction(ctx, true)
+	if err != nil {
+		return err
 	}
-	transaction := txn.NewReadonlyTransaction(db.oracle, db.storageState)
-	defer db.oracle.FinishBeginTimestamp(transaction)
+	defer transaction.Discard()
 
-	callback(transaction)
-	return nil
+	return callback(transaction)
 }
 
 // Write supports writes operation by passing an instance of txn.Transaction via (txn.NewReadwriteTransaction) to the callback.
 // The passed transaction is a Readwrite txn.Transaction which supports both read and write operations.
-func (db *Db) Write(callback func(transaction *txn.Transaction)) (*future.Future, error) {
+// It returns ctx.Err() if the ctx is done before the transaction could begin, or before it could be submitted for commit.
+// It returns DbReadonlyErr if the Db is in read-only mode because of a fatal background error (Refer to BackgroundError).
+// The returned future.Future can be waited with a deadline using future.Future.WaitWithContext.
+func (db *Db) Write(ctx context.Context, callback func(transaction *txn.Transaction)) (*future.Future, error) {
+	return db.WriteWithIsolationLevel(ctx, txn.Serializable, callback)
+}
+
+// WriteWithIsolationLevel is a variant of Write which runs the callback in a Readwrite txn.Transaction with the given
+// txn.IsolationLevel.
+func (db *Db) WriteWithIsolationLevel(
+	ctx context.Context,
+	isolationLevel txn.IsolationLevel,
+	callback func(transaction *txn.Transaction),
+) (*future.Future, error) {
+	return db.ExecuteWithIsolationLevel(ctx, isolationLevel, func(transaction *txn.Transaction) error {
+		callback(transaction)
+		return nil
+	})
+}
+
+// Execute is a variant of Write which allows the callback to return an error.
+// The passed transaction is a Readwrite txn.Transaction, which is committed only if the callback returns nil.
+// If the callback returns an error (or panics), the transaction is discarded and none of its writes are applied.
+// It returns the error returned by the callback.
+func (db *Db) Execute(ctx context.Context, callback func(transaction *txn.Transaction) error) (*future.Future, error) {
+	return db.ExecuteWithIsolationLevel(ctx, txn.Serializable, callback)
+}
+
+// ExecuteWithIsolationLevel is a variant of Execute which runs the callback in a Readwrite txn.Transaction with the given
+// txn.IsolationLevel.
+func (db *Db) ExecuteWithIsolationLevel(
+	ctx context.Context,
+	isolationLevel txn.IsolationLevel,
+	callback func(transaction *txn.Transaction) error,
+) (*future.Future, error) {
+	transaction, err := db.NewTransactionWithIsolationLevel(ctx, isolationLevel)
+	if err != nil {
+		return nil, err
+	}
+	defer transaction.Discard()
+
+	if err := callback(transaction); err != nil {
+		return nil, err
+	}
+	if err := db.readonlyErr(); err != nil {
+		return nil, err
+	}
+	return transaction.Commit(ctx)
+}
+
+// Update is a variant of Execute which retries the callback on txn.ConflictErr.
+// Every attempt runs the callback in a fresh Readwrite txn.Transaction, so the callback must be safe to run multiple times.
+// Between two attempts, Update waits for a jittered exponential backoff (Refer to RetryPolicy).
+// It gives up after RetryPolicy.MaxAttempts attempts (returning txn.ConflictErr), or when the ctx is done (returning ctx.Err()).
+// Errors other than txn.ConflictErr (including the errors returned by the callback) are returned without retrying.
+// The number of retries and conflicts are available in Stats.
+func (db *Db) Update(ctx context.Context, callback func(transaction *txn.Transaction) error, policy RetryPolicy) (*future.Future, error) {
+	for attempt := uint(1); ; attempt++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		resultingFuture, err := db.Execute(ctx, callback)
+		if !errors.Is(err, txn.ConflictErr) {
+			return resultingFuture, err
+		}
+		db.stats.transactionConflicts.Add(1)
+		if attempt >= policy.maxAttempts() {
+			db.stats.transactionsAborted.Add(1)
+			return nil, err
+		}
+		if err := db.waitFor(ctx, policy.backoff(attempt)); err != nil {
+			db.stats.transactionsAborted.Add(1)
+			return nil, err
+		}
+		db.stats.transactionRetries.Add(1)
+	}
+}
+
+// IngestSSTables ingests the externally built SSTables (built using table.SSTableBuilder), which is much faster than
+// writing the same keys via transactions for bulk loads, as it bypasses the WAL and memtables.
+// All the SSTables are ingested atomically at a single commit-timestamp assigned by txn.Oracle (Refer to
+// txn.Oracle.ExecuteAtCommitTimestamp), and are placed at the lowest level which does not overlap with the existing data
+// (Refer to state.StorageState.IngestSSTables).
+// The keys in each SSTable must be in strictly increasing order, and the SSTables must not overlap with each other.
+// Ingestion fails with state.IngestionOverlapsMemtablesErr if any of the keys in the memtables falls in the ranges of
+// the SSTables.
+// The commits are blocked while the SSTables are ingested, and compaction does not run concurrently with ingestion.
+// The ingested keys are not tracked for conflict detection, so the concurrent Readwrite transactions reading them do not
+// get txn.ConflictErr.
+func (db *Db) IngestSSTables(ctx context.Context, filePaths []string) error {
 	if db.stopped.Load() {
-		return nil, DbAlreadyStoppedErr
+		return DbAlreadyStoppedErr
+	}
+	if err := db.readonlyErr(); err != nil {
+		return err
 	}
-	transaction := txn.NewReadwriteTransaction(db.oracle, db.storageState)
-	defer db.oracle.FinishBeginTimestamp(transaction)
+	db.compactionLock.Lock()
+	defer db.compactionLock.Unlock()
 
-	callback(transaction)
-	return transaction.Commit()
+	return db.oracle.ExecuteAtCommitTimestamp(ctx, func(commitTimestamp uint64) error {
+		_, err := db.storageState.IngestSSTables(filePaths, commitTimestamp)
+		return err
+	})
 }
 
 // Scan supports scan operation by taking an instance of kv.InclusiveKeyRange.
 // It returns a slice of KeyValue in increasing order, if no error occurs.
 // This implementation only supports kv.InclusiveKeyRange, there is no support for Open and HalfOpen ranges.
-func (db *Db) Scan(keyRange kv.InclusiveKeyRange[kv.RawKey]) ([]KeyValue, error) {
-	if db.stopped.Load() {
-		return nil, DbAlreadyStoppedErr
+// The ctx is checked before moving to every key/value pair, it returns ctx.Err() if the ctx is done.
+func (db *Db) Scan(ctx context.Context, keyRange kv.InclusiveKeyRange[kv.RawKey]) ([]KeyValue, error) {
+	transaction, err := db.NewTransaction(ctx, true)
+	if err != nil {
+		return nil, err
 	}
-	transaction := txn.NewReadonlyTransaction(db.oracle, db.storageState)
-	defer db.oracle.FinishBeginTimestamp(transaction)
+	defer transaction.Discard()
 
 	iterator, err := transaction.Scan(keyRange)
 	if err != nil {
@@ -89,6 +240,9 @@ func (db *Db) Scan(keyRange kv.InclusiveKeyRange[kv.RawKey]) ([]KeyValue, error)
 
 	var keyValuePairs []KeyValue
 	for iterator.IsValid() {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
 		keyValuePairs = append(keyValuePairs, KeyValue{
 			Key:   iterator.Key().RawBytes(),
 			Value: iterator.Value().Bytes(),
@@ -113,34 +267,75 @@ func (db *Db) Close() {
 	}
 }
 
+// Stats returns the point-in-time Stats of the Db.
+func (db *Db) Stats() Stats {
+	return db.stats.snapshot(db.storageState, db.oracle)
+}
+
+// waitFor waits for the given duration.
+// It returns ctx.Err() if the ctx is done, or DbAlreadyStoppedErr if the Db is closed before the duration elapses.
+func (db *Db) waitFor(ctx context.Context, duration time.Duration) error {
+	timer := time.NewTimer(duration)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-db.stopChannel:
+		return DbAlreadyStoppedErr
+	}
+}
+
 // startCompaction start the compaction goroutine.
 // It attempts to perform compaction at fixed intervals.
 // If compaction happens between 2 levels, it returns a state.StorageStateChangeEvent,
 // which is then applied to state.StorageState.
+// The outcome of every compaction is reported (Refer to state.StorageState.ReportBackgroundOutcome). A compaction failing
+// with a retryable error is retried with backoff, and the compaction goroutine exits on a fatal error.
 func (db *Db) startCompaction() {
 	go func() {
 		compactionTimer := time.NewTimer(db.storageState.Options().CompactionOptions.Duration)
 		defer compactionTimer.Stop()
 
 		compaction := compact.NewCompaction(db.oracle, db.storageState.SSTableIdGenerator(), db.storageState.Options())
+		var failures uint
 		for {
 			select {
 			case <-compactionTimer.C:
-				storageStateChangeEvent, err := compaction.Start(db.storageState.Snapshot())
-				if err != nil {
-					slog.Error(fmt.Sprintf("error in starting compaction %v", err))
+				delay := db.storageState.Options().CompactionOptions.Duration
+				err := db.compact(compaction)
+				if backgroundError := db.storageState.ReportBackgroundOutcome(state.CompactionOperation, err); backgroundError == nil {
+					failures = 0
+				} else if backgroundError.Fatal {
 					return
+				} else {
+					failures++
+					delay = state.BackgroundRetryBackoff(delay, failures)
 				}
-				if storageStateChangeEvent.HasAnyChanges() {
-					if err := db.storageState.Apply(storageStateChangeEvent, false); err != nil {
-						slog.Error(fmt.Sprintf("error in apply state change event %v", err))
-						return
-					}
-				}
-				compactionTimer.Reset(db.storageState.Options().CompactionOptions.Duration)
+				compactionTimer.Reset(delay)
 			case <-db.stopChannel:
 				return
 			}
 		}
 	}()
 }
+
+// compact runs a compaction and applies the resulting state.StorageStateChangeEvent (if any) to state.StorageState,
+// holding the compactionLock.
+func (db *Db) compact(compaction *compact.Compaction) error {
+	db.compactionLock.Lock()
+	defer db.compactionLock.Unlock()
+
+	storageStateChangeEvent, err := compaction.Start(db.storageState.Snapshot())
+	if err != nil {
+		return fmt.Errorf("error in starting compaction %w", err)
+	}
+	if storageStateChangeEvent.HasAnyChanges() {
+		if err := db.storageState.Apply(storageStateChangeEvent, false); err != nil {
+			return fmt.Errorf("error in apply state change event %w", err)
+		}
+	}
+	return nil
+}
@@ -2,9 +2,22 @@
 
 package go_lsm_workshop
 
-import "go-lsm-workshop/state"
+import (
+	"go-lsm-workshop/state"
+	"time"
+)
 
 // StorageState returns the StorageState, it is only for testing.
 func (db *Db) StorageState() *state.StorageState {
 	return db.storageState
 }
+
+// Backoff returns the wait duration before the given retry, it is only for testing.
+func (policy RetryPolicy) Backoff(retry uint) time.Duration {
+	return policy.backoff(retry)
+}
+
+// MaxBeginTimestamp returns the maximum begin-timestamp till which all the transactions are done, it is only for testing.
+func (db *Db) MaxBeginTimestamp() uint64 {
+	return db.oracle.MaxBeginTimestamp()
+}
@@ -0,0 +1,152 @@
+package go_lsm_workshop
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-lsm-workshop/kv"
+	"go-lsm-workshop/txn"
+	"io"
+)
+
+var AsOfTimestampAfterLatestCommitTimestampErr = errors.New("as-of timestamp is after the latest commit-timestamp")
+var UnsupportedDumpFormatErr = errors.New("unsupported dump format")
+
+const (
+	dumpFormat        = "go-lsm-workshop/dump"
+	dumpFormatVersion = 1
+	maxDumpLineSize   = 64 << 20
+)
+
+// DumpHeader is the first line of a dump, it identifies the format and its version.
+type DumpHeader struct {
+	Format        string `json:"format"`
+	Version       int    `json:"version"`
+	AsOfTimestamp uint64 `json:"asOfTimestamp"`
+}
+
+// DumpRecord is a line (other than the first) of a dump, it represents a key/value pair or a tombstone (if Deleted is true).
+// Key and Value are encoded as (standard) base64 strings by encoding/json.
+// Timestamp is the commit-timestamp of the key in the exported Db, it is optional and disregarded by Db.Import.
+type DumpRecord struct {
+	Key       []byte `json:"key"`
+	Value     []byte `json:"value,omitempty"`
+	Timestamp uint64 `json:"timestamp,omitempty"`
+	Deleted   bool   `json:"deleted,omitempty"`
+}
+
+// Export writes a logical dump of all the keys in the keyRange, as of the asOfTimestamp, to the writer.
+// The dump is a stream of JSON lines: a DumpHeader followed by a DumpRecord for every key/value pair, in the increasing
+// order of the keys. The dump is independent of the SSTable format, it can be inspected with standard tools and loaded
+// using Import.
+// The export runs inside a Readonly txn.Transaction (Refer to View), so its begin-timestamp is registered in txn.Oracle for
+// the whole export, and compaction does not remove the versions visible at the begin-timestamp while the dump is written.
+// If asOfTimestamp is zero, the begin-timestamp of the transaction (the latest commit-timestamp) is used. An asOfTimestamp
+// after the begin-timestamp returns AsOfTimestampAfterLatestCommitTimestampErr.
+// An export of a view (as of a timestamp) contains no tombstones. Older versions of the keys are removed by compaction, so an
+// asOfTimestamp older than the begin-timestamp of the oldest active transaction may not yield the complete view.
+// The ctx is checked before writing every record, it returns ctx.Err() if the ctx is done.
+func (db *Db) Export(ctx context.Context, writer io.Writer, keyRange kv.InclusiveKeyRange[kv.RawKey], asOfTimestamp uint64) error {
+	return db.View(ctx, func(transaction *txn.Transaction) error {
+		if asOfTimestamp == 0 {
+			asOfTimestamp = transaction.BeginTimestamp()
+		}
+		if asOfTimestamp > transaction.BeginTimestamp() {
+			return AsOfTimestampAfterLatestCommitTimestampErr
+		}
+
+		bufferedWriter := bufio.NewWriter(writer)
+		encoder := json.NewEncoder(bufferedWriter)
+		if err := encoder.Encode(DumpHeader{Format: dumpFormat, Version: dumpFormatVersion, AsOfTimestamp: asOfTimestamp}); err != nil {
+			return err
+		}
+
+		iterator := db.storageState.Scan(kv.NewInclusiveKeyRange(
+			kv.NewKey(keyRange.Start(), asOfTimestamp),
+			kv.NewKey(keyRange.End(), asOfTimestamp),
+		))
+		defer iterator.Close()
+
+		for iterator.IsValid() {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if err := encoder.Encode(DumpRecord{
+				Key:       iterator.Key().RawBytes(),
+				Value:     iterator.Value().Bytes(),
+				Timestamp: iterator.Key().Timestamp(),
+			}); err != nil {
+				return err
+			}
+			if err := iterator.Next(); err != nil {
+				return err
+			}
+		}
+		return bufferedWriter.Flush()
+	})
+}
+
+// Import loads a logical dump (written by Export, or by any other tool following the format) from the reader.
+// The records are applied in batched commits using a WriteBatch, so Import is not atomic: the records imported before an
+// error are not rolled back. A DumpRecord with Deleted set to true deletes the key. The timestamps in the dump are
+// disregarded, every batch gets its own commit-timestamp.
+// It returns the number of records imported, and UnsupportedDumpFormatErr if the header is missing or has an unsupported
+// version.
+func (db *Db) Import(ctx context.Context, reader io.Reader) (uint64, error) {
+	if db.stopped.Load() {
+		return 0, DbAlreadyStoppedErr
+	}
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxDumpLineSize)
+
+	if !scanner.Scan() {
+		if err := scanner.Err(); err != nil {
+			return 0, err
+		}
+		return 0, UnsupportedDumpFormatErr
+	}
+	var header DumpHeader
+	if err := json.Unmarshal(scanner.Bytes(), &header); err != nil || header.Format != dumpFormat || header.Version != dumpFormatVersion {
+		return 0, UnsupportedDumpFormatErr
+	}
+
+	writeBatch := db.NewWriteBatch(ctx)
+	records, line := uint64(0), 1
+	for scanner.Scan() {
+		line++
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var record DumpRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return records, fmt.Errorf("invalid record at line %d: %w", line, err)
+		}
+		if len(record.Key) == 0 {
+			return records, fmt.Errorf("invalid record at line %d: empty key", line)
+		}
+		if record.Deleted || len(record.Value) == 0 {
+			if err := writeBatch.Delete(record.Key); err != nil {
+				return records, err
+			}
+		} else if err := writeBatch.Set(record.Key, record.Value); err != nil {
+			return records, err
+		}
+		records++
+	}
+	if err := scanner.Err(); err != nil {
+		return records, err
+	}
+	resultingFuture, err := writeBatch.Flush()
+	if err != nil {
+		return records, err
+	}
+	if err := resultingFuture.WaitWithContext(ctx); err != nil {
+		return records, err
+	}
+	if resultingFuture.Status().IsErr() {
+		return records, resultingFuture.Status().Err
+	}
+	return records, nil
+}
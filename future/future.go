@@ -1,5 +1,7 @@
 package future
 
+import "context"
+
 // Future represents the result of asynchronous computation.
 // Eg; a response to committing a batch. It allows the clients to wait until the batch is applied to
 // the state machine. Please check txn.Executor.
@@ -18,16 +20,43 @@ func NewFuture() *Future {
 	}
 }
 
+// NewCompositeFuture creates a new instance of Future which is marked as done when all the given futures are done.
+// It is marked as done with the Status Error of the first future (in the given order) with Status Error, else with Status Ok.
+func NewCompositeFuture(futures []*Future) *Future {
+	compositeFuture := NewFuture()
+	go func() {
+		status := OkStatus()
+		for _, future := range futures {
+			future.Wait()
+			if future.Status().IsErr() && status.IsOk() {
+				status = future.Status()
+			}
+		}
+		if status.IsErr() {
+			compositeFuture.MarkDoneAsError(status.Err)
+			return
+		}
+		compositeFuture.MarkDoneAsOk()
+	}()
+	return compositeFuture
+}
+
 // MarkDoneAsOk marks the Future as done with Status Ok.
+// The status is set before marking the Future as done, so that it is visible to the waiters.
 func (future *Future) MarkDoneAsOk() {
+	if !future.isDone {
+		future.status = OkStatus()
+	}
 	future.markDone()
-	future.status = OkStatus()
 }
 
 // MarkDoneAsError marks the Future as done with Status Error.
+// The status is set before marking the Future as done, so that it is visible to the waiters.
 func (future *Future) MarkDoneAsError(err error) {
+	if !future.isDone {
+		future.status = ErrorStatus(err)
+	}
 	future.markDone()
-	future.status = ErrorStatus(err)
 }
 
 // Wait waits until the Future is marked as done.
@@ -35,6 +64,17 @@ func (future *Future) Wait() {
 	<-future.responseChannel
 }
 
+// WaitWithContext waits until the Future is marked as done, or the ctx is done.
+// It returns ctx.Err() if the ctx is done before the Future is marked as done.
+func (future *Future) WaitWithContext(ctx context.Context) error {
+	select {
+	case <-future.responseChannel:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
 // Status returns the status.
 func (future *Future) Status() Status {
 	return future.status
@@ -1,9 +1,11 @@
 package future
 
 import (
+	"context"
 	"errors"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -41,3 +43,56 @@ func TestFutureWithErrorStatus(t *testing.T) {
 	future.MarkDoneAsError(errors.New("test error"))
 	wg.Wait()
 }
+
+func TestFutureWaitWithContextGivenFutureIsDone(t *testing.T) {
+	future := NewFuture()
+	future.MarkDoneAsOk()
+
+	err := future.WaitWithContext(context.Background())
+	assert.NoError(t, err)
+	assert.True(t, future.Status().IsOk())
+}
+
+func TestFutureWaitWithContextGivenDeadlineExceeds(t *testing.T) {
+	future := NewFuture()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Millisecond)
+	defer cancel()
+
+	err := future.WaitWithContext(ctx)
+	assert.Equal(t, context.DeadlineExceeded, err)
+	assert.False(t, future.isDone)
+}
+
+func TestCompositeFutureWithOkStatus(t *testing.T) {
+	aFuture, anotherFuture := NewFuture(), NewFuture()
+	compositeFuture := NewCompositeFuture([]*Future{aFuture, anotherFuture})
+
+	aFuture.MarkDoneAsOk()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, compositeFuture.WaitWithContext(ctx), context.DeadlineExceeded)
+
+	anotherFuture.MarkDoneAsOk()
+	compositeFuture.Wait()
+	assert.True(t, compositeFuture.Status().IsOk())
+}
+
+func TestCompositeFutureWithErrorStatus(t *testing.T) {
+	aFuture, anotherFuture := NewFuture(), NewFuture()
+	compositeFuture := NewCompositeFuture([]*Future{aFuture, anotherFuture})
+
+	anotherFuture.MarkDoneAsError(errors.New("test error"))
+	aFuture.MarkDoneAsOk()
+
+	compositeFuture.Wait()
+	assert.True(t, compositeFuture.Status().IsErr())
+	assert.Equal(t, "test error", compositeFuture.Status().Err.Error())
+}
+
+func TestCompositeFutureWithNoFutures(t *testing.T) {
+	compositeFuture := NewCompositeFuture(nil)
+	compositeFuture.Wait()
+	assert.True(t, compositeFuture.Status().IsOk())
+}
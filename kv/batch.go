@@ -1,10 +1,5 @@
 package kv
 
-import (
-	"bytes"
-	"errors"
-)
-
 // RawKeyValuePair represents the key/value pair with Kind.
 type RawKeyValuePair struct {
 	key   []byte
@@ -22,27 +17,39 @@ func (kv RawKeyValuePair) Value() Value {
 	return kv.value
 }
 
-var DuplicateKeyInBatchErr = errors.New("batch already contains the key")
-
 // Batch is a collection of RawKeyValuePair.
 // Batch is typically used in a transaction (txn.Transaction). All the inserts within a transaction (read/write transaction)
 // are batched and finally the entire Batch is committed.
+// Batch is an ordered map: pairs are kept sorted by key in a skiplist (Refer to orderedPairs), and a key appears at most once
+// (last-write-wins for any sequence of Put and Delete). This gives O(log n) lookups and inserts, and allows iterating the pairs
+// in the key order without sorting them.
+// Once a savepoint is taken (Refer to Savepoint), every Put and Delete is also recorded in an undoLog, which allows rolling
+// back the Batch to the savepoint (Refer to RollbackTo). The batches without savepoints (like the bulk writes) do not pay
+// for the undoLog.
 type Batch struct {
-	pairs []RawKeyValuePair
+	pairs       *orderedPairs
+	undoLog     []batchUndoEntry
+	recordsUndo bool
+}
+
+// batchUndoEntry represents the state of a key before a Put or Delete, it is used to undo the Put or Delete.
+type batchUndoEntry struct {
+	key      []byte
+	previous RawKeyValuePair
+	existed  bool
 }
 
 // NewBatch creates an empty Batch.
 func NewBatch() *Batch {
-	return &Batch{}
+	return &Batch{
+		pairs: newOrderedPairs(),
+	}
 }
 
-// Put puts the key/value pair in Batch.
-// Returns DuplicateKeyInBatchErr if the key is already present in the Batch.
+// Put puts the key/value pair in Batch, replacing the earlier Put or Delete of the same key (if any).
+// It never returns an error.
 func (batch *Batch) Put(key, value []byte) error {
-	if batch.Contains(key) {
-		return DuplicateKeyInBatchErr
-	}
-	batch.pairs = append(batch.pairs, RawKeyValuePair{
+	batch.set(RawKeyValuePair{
 		key:   key,
 		value: NewValue(value),
 		kind:  EntryKindPut,
@@ -50,9 +57,9 @@ func (batch *Batch) Put(key, value []byte) error {
 	return nil
 }
 
-// Delete is modeled as an append operation. It results in another RawKeyValuePair in batch with kind as EntryKindDelete.
+// Delete puts the key with kind as EntryKindDelete in Batch, replacing the earlier Put or Delete of the same key (if any).
 func (batch *Batch) Delete(key []byte) {
-	batch.pairs = append(batch.pairs, RawKeyValuePair{
+	batch.set(RawKeyValuePair{
 		key:   key,
 		value: EmptyValue,
 		kind:  EntryKindDelete,
@@ -60,34 +67,84 @@ func (batch *Batch) Delete(key []byte) {
 }
 
 // Get returns the Value for the given key if found.
+// The Value of a deleted key is EmptyValue.
 func (batch *Batch) Get(key []byte) (Value, bool) {
-	for _, pair := range batch.pairs {
-		if bytes.Equal(pair.key, key) {
-			return pair.value, true
-		}
+	pair, ok := batch.pairs.get(key)
+	if !ok {
+		return EmptyValue, false
 	}
-	return EmptyValue, false
+	return pair.value, true
 }
 
 // Contains returns true of the key is present in Batch.
 func (batch *Batch) Contains(key []byte) bool {
-	_, ok := batch.Get(key)
+	_, ok := batch.pairs.get(key)
 	return ok
 }
 
+// ContainsAnyKeyIn returns true if any of the keys present in Batch falls in the given keyRange.
+// It seeks to the first key >= the start of the keyRange, which is the only key that needs to be checked.
+func (batch *Batch) ContainsAnyKeyIn(keyRange InclusiveKeyRange[RawKey]) bool {
+	node := batch.pairs.ceiling(keyRange.Start(), nil)
+	return node != nil && keyRange.Contains(node.pair.key)
+}
+
 // IsEmpty returns true if the Batch is empty.
 func (batch *Batch) IsEmpty() bool {
-	return len(batch.pairs) == 0
+	return batch.pairs.length == 0
 }
 
 // Length returns the number of RawKeyValuePair(s) in the Batch.
 func (batch *Batch) Length() int {
-	return len(batch.pairs)
+	return batch.pairs.length
+}
+
+// Savepoint starts recording the Put and Delete operations in the undoLog (if not already), and returns the current
+// Mutations, which the Batch can be rolled back to (Refer to RollbackTo).
+func (batch *Batch) Savepoint() int {
+	batch.recordsUndo = true
+	return batch.Mutations()
 }
 
-// CloneKeyValuePairs clones the RawKeyValuePair(s) present in the Batch.
+// Mutations returns the number of Put and Delete operations recorded in the undoLog (and not rolled back), since the first
+// Savepoint. It identifies the current point in the Batch, which the Batch can be rolled back to.
+func (batch *Batch) Mutations() int {
+	return len(batch.undoLog)
+}
+
+// RollbackTo rolls back the Batch to the point where it had the given number of Mutations, undoing all the Put and Delete
+// operations applied after that (in the reverse order).
+// It is used to roll back a transaction to a savepoint. It panics if mutations is greater than the current Mutations.
+func (batch *Batch) RollbackTo(mutations int) {
+	if mutations > batch.Mutations() {
+		panic("batch can not be rolled back to a point ahead of its current mutations")
+	}
+	for entryIndex := len(batch.undoLog) - 1; entryIndex >= mutations; entryIndex-- {
+		entry := batch.undoLog[entryIndex]
+		if entry.existed {
+			batch.pairs.put(entry.previous)
+		} else {
+			batch.pairs.delete(entry.key)
+		}
+	}
+	clear(batch.undoLog[mutations:])
+	batch.undoLog = batch.undoLog[:mutations]
+}
+
+// CloneKeyValuePairs clones the RawKeyValuePair(s) present in the Batch, the cloned pairs are sorted by key.
 func (batch *Batch) CloneKeyValuePairs() []RawKeyValuePair {
 	keyValuePairs := make([]RawKeyValuePair, 0, batch.Length())
-	keyValuePairs = append(keyValuePairs, batch.pairs...)
+	for pair := range batch.pairs.all() {
+		keyValuePairs = append(keyValuePairs, pair)
+	}
 	return keyValuePairs
 }
+
+// set sets the pair in Batch (replacing the existing pair of the same key, if any), and records the undoLog entry once a
+// Savepoint is taken.
+func (batch *Batch) set(pair RawKeyValuePair) {
+	previous, existed := batch.pairs.put(pair)
+	if batch.recordsUndo {
+		batch.undoLog = append(batch.undoLog, batchUndoEntry{key: pair.key, previous: previous, existed: existed})
+	}
+}
@@ -1,6 +1,7 @@
 package kv
 
 import (
+	"fmt"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -17,13 +18,47 @@ func TestNonEmptyBatch(t *testing.T) {
 	assert.Equal(t, false, batch.IsEmpty())
 }
 
-func TestAddDuplicateKeyInBatch(t *testing.T) {
+func TestPutTheSameKeyInBatch(t *testing.T) {
 	batch := NewBatch()
 	_ = batch.Put([]byte("HDD"), []byte("Hard disk"))
-	err := batch.Put([]byte("HDD"), []byte("Hard disk"))
+	err := batch.Put([]byte("HDD"), []byte("Hard disk drive"))
 
-	assert.Error(t, err)
-	assert.Equal(t, DuplicateKeyInBatchErr, err)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, batch.Length())
+
+	value, ok := batch.Get([]byte("HDD"))
+	assert.True(t, ok)
+	assert.Equal(t, "Hard disk drive", value.String())
+}
+
+func TestPutDeletePutTheSameKeyInBatch(t *testing.T) {
+	batch := NewBatch()
+	_ = batch.Put([]byte("HDD"), []byte("Hard disk"))
+	batch.Delete([]byte("HDD"))
+
+	value, ok := batch.Get([]byte("HDD"))
+	assert.True(t, ok)
+	assert.True(t, value.IsEmpty())
+
+	_ = batch.Put([]byte("HDD"), []byte("Hard disk drive"))
+
+	value, ok = batch.Get([]byte("HDD"))
+	assert.True(t, ok)
+	assert.Equal(t, "Hard disk drive", value.String())
+	assert.Equal(t, 1, batch.Length())
+}
+
+func TestCloneKeyValuePairsSortedByKey(t *testing.T) {
+	batch := NewBatch()
+	_ = batch.Put([]byte("SSD"), []byte("Solid state drive"))
+	_ = batch.Put([]byte("HDD"), []byte("Hard disk"))
+	batch.Delete([]byte("NVMe"))
+
+	pairs := batch.CloneKeyValuePairs()
+	assert.Equal(t, 3, len(pairs))
+	assert.Equal(t, "HDD", string(pairs[0].Key()))
+	assert.Equal(t, "NVMe", string(pairs[1].Key()))
+	assert.Equal(t, "SSD", string(pairs[2].Key()))
 }
 
 func TestGetTheValueOfAKeyFromBatch(t *testing.T) {
@@ -69,3 +104,78 @@ func TestGetTheTimestampedBatch(t *testing.T) {
 	assert.Equal(t, "HDD", timestampedBatch.AllEntries()[0].RawString())
 	assert.Equal(t, "Hard disk", timestampedBatch.AllEntries()[0].Value.String())
 }
+
+func TestContainsAnyKeyInRange(t *testing.T) {
+	batch := NewBatch()
+	_ = batch.Put([]byte("HDD"), []byte("Hard disk"))
+	_ = batch.Put([]byte("SSD"), []byte("Solid state drive"))
+
+	assert.True(t, batch.ContainsAnyKeyIn(NewInclusiveKeyRange(RawKey("NVMe"), RawKey("SSD"))))
+	assert.True(t, batch.ContainsAnyKeyIn(NewInclusiveKeyRange(RawKey("Flash"), RawKey("Magnetic"))))
+}
+
+func TestDoesNotContainAnyKeyInRange(t *testing.T) {
+	batch := NewBatch()
+	_ = batch.Put([]byte("HDD"), []byte("Hard disk"))
+	_ = batch.Put([]byte("SSD"), []byte("Solid state drive"))
+
+	assert.False(t, batch.ContainsAnyKeyIn(NewInclusiveKeyRange(RawKey("NVMe"), RawKey("RAM"))))
+}
+
+func TestRollbackTheBatch(t *testing.T) {
+	batch := NewBatch()
+	_ = batch.Put([]byte("HDD"), []byte("Hard disk"))
+	mutations := batch.Savepoint()
+
+	_ = batch.Put([]byte("SSD"), []byte("Solid state drive"))
+	_ = batch.Put([]byte("HDD"), []byte("Hard disk drive"))
+	batch.Delete([]byte("NVMe"))
+	batch.Delete([]byte("HDD"))
+
+	batch.RollbackTo(mutations)
+
+	assert.Equal(t, 1, batch.Length())
+	assert.Equal(t, 0, batch.Mutations())
+	assert.False(t, batch.Contains([]byte("SSD")))
+	assert.False(t, batch.Contains([]byte("NVMe")))
+
+	value, ok := batch.Get([]byte("HDD"))
+	assert.True(t, ok)
+	assert.Equal(t, "Hard disk", value.String())
+}
+
+func TestBatchDoesNotRecordMutationsWithoutASavepoint(t *testing.T) {
+	batch := NewBatch()
+	_ = batch.Put([]byte("HDD"), []byte("Hard disk"))
+	batch.Delete([]byte("SSD"))
+
+	assert.Equal(t, 0, batch.Mutations())
+	assert.Equal(t, 0, len(batch.undoLog))
+
+	mutations := batch.Savepoint()
+	_ = batch.Put([]byte("NVMe"), []byte("Non-volatile memory"))
+
+	assert.Equal(t, 0, mutations)
+	assert.Equal(t, 1, batch.Mutations())
+}
+
+func TestLargeBatchKeepsThePairsSortedByKey(t *testing.T) {
+	batch := NewBatch()
+	for count := 9999; count >= 0; count-- {
+		_ = batch.Put([]byte(fmt.Sprintf("key-%05d", count)), []byte(fmt.Sprintf("value-%05d", count)))
+	}
+	for count := 0; count < 10000; count += 2 {
+		batch.Delete([]byte(fmt.Sprintf("key-%05d", count)))
+	}
+
+	pairs := batch.CloneKeyValuePairs()
+	assert.Equal(t, 10000, len(pairs))
+	for index, pair := range pairs {
+		assert.Equal(t, fmt.Sprintf("key-%05d", index), string(pair.Key()))
+		if index%2 == 0 {
+			assert.True(t, pair.Value().IsEmpty())
+		} else {
+			assert.Equal(t, fmt.Sprintf("value-%05d", index), pair.Value().String())
+		}
+	}
+}
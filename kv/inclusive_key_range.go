@@ -33,3 +33,8 @@ func (inclusiveKeyRange InclusiveKeyRange[T]) Start() T {
 func (inclusiveKeyRange InclusiveKeyRange[T]) End() T {
 	return inclusiveKeyRange.end
 }
+
+// Contains returns true if the given key is greater than or equal to the start key and less than or equal to the end key.
+func (inclusiveKeyRange InclusiveKeyRange[T]) Contains(key T) bool {
+	return inclusiveKeyRange.start.IsLessThanOrEqualTo(key) && key.IsLessThanOrEqualTo(inclusiveKeyRange.end)
+}
@@ -23,3 +23,16 @@ func TestInclusiveRange(t *testing.T) {
 	assert.Equal(t, NewStringKeyWithTimestamp("consensus", 10), inclusiveRange.Start())
 	assert.Equal(t, NewStringKeyWithTimestamp("distributed", 5), inclusiveRange.End())
 }
+
+func TestInclusiveRangeContainsTheKey(t *testing.T) {
+	inclusiveRange := NewInclusiveKeyRange(RawKey("consensus"), RawKey("distributed"))
+	assert.True(t, inclusiveRange.Contains(RawKey("consensus")))
+	assert.True(t, inclusiveRange.Contains(RawKey("db")))
+	assert.True(t, inclusiveRange.Contains(RawKey("distributed")))
+}
+
+func TestInclusiveRangeDoesNotContainTheKey(t *testing.T) {
+	inclusiveRange := NewInclusiveKeyRange(RawKey("consensus"), RawKey("distributed"))
+	assert.False(t, inclusiveRange.Contains(RawKey("accurate")))
+	assert.False(t, inclusiveRange.Contains(RawKey("epoch")))
+}
@@ -0,0 +1,114 @@
+package kv
+
+import (
+	"bytes"
+	"iter"
+	"math/rand/v2"
+)
+
+const maxOrderedPairsHeight = 20
+
+// orderedPairs is a skiplist of RawKeyValuePair(s) ordered by key, with at most one pair per key. It is the ordered map
+// behind Batch: lookups, inserts and deletes are O(log n) (expected), and the pairs can be iterated in the key order.
+// Unlike the skiplist of the memtable (Refer to memory/external.SkipList), it supports deletes (needed to roll back a
+// Batch) and it is not thread-safe.
+type orderedPairs struct {
+	head   *orderedPairNode
+	height int
+	length int
+}
+
+// orderedPairNode is a node of orderedPairs, next[level] is the next node at the level.
+type orderedPairNode struct {
+	pair RawKeyValuePair
+	next []*orderedPairNode
+}
+
+// newOrderedPairs creates an empty orderedPairs.
+func newOrderedPairs() *orderedPairs {
+	return &orderedPairs{
+		head:   &orderedPairNode{next: make([]*orderedPairNode, maxOrderedPairsHeight)},
+		height: 1,
+	}
+}
+
+// get returns the pair of the key and true, if the key is present.
+func (pairs *orderedPairs) get(key []byte) (RawKeyValuePair, bool) {
+	node := pairs.ceiling(key, nil)
+	if node == nil || !bytes.Equal(node.pair.key, key) {
+		return RawKeyValuePair{}, false
+	}
+	return node.pair, true
+}
+
+// put puts the pair, replacing the existing pair of the same key. It returns the replaced pair and true, if the key was present.
+func (pairs *orderedPairs) put(pair RawKeyValuePair) (RawKeyValuePair, bool) {
+	var predecessors [maxOrderedPairsHeight]*orderedPairNode
+	node := pairs.ceiling(pair.key, &predecessors)
+	if node != nil && bytes.Equal(node.pair.key, pair.key) {
+		previous := node.pair
+		node.pair = pair
+		return previous, true
+	}
+	height := pairs.randomHeight()
+	for level := pairs.height; level < height; level++ {
+		predecessors[level] = pairs.head
+	}
+	pairs.height = max(pairs.height, height)
+
+	newNode := &orderedPairNode{pair: pair, next: make([]*orderedPairNode, height)}
+	for level := 0; level < height; level++ {
+		newNode.next[level] = predecessors[level].next[level]
+		predecessors[level].next[level] = newNode
+	}
+	pairs.length++
+	return RawKeyValuePair{}, false
+}
+
+// delete deletes the pair of the key, if the key is present.
+func (pairs *orderedPairs) delete(key []byte) {
+	var predecessors [maxOrderedPairsHeight]*orderedPairNode
+	node := pairs.ceiling(key, &predecessors)
+	if node == nil || !bytes.Equal(node.pair.key, key) {
+		return
+	}
+	for level := 0; level < len(node.next); level++ {
+		predecessors[level].next[level] = node.next[level]
+	}
+	pairs.length--
+}
+
+// ceiling returns the first node with the key >= the given key, or nil if there is no such node.
+// If predecessors is not nil, it is filled with the last node with the key < the given key at every level.
+func (pairs *orderedPairs) ceiling(key []byte, predecessors *[maxOrderedPairsHeight]*orderedPairNode) *orderedPairNode {
+	node := pairs.head
+	for level := pairs.height - 1; level >= 0; level-- {
+		for node.next[level] != nil && bytes.Compare(node.next[level].pair.key, key) < 0 {
+			node = node.next[level]
+		}
+		if predecessors != nil {
+			predecessors[level] = node
+		}
+	}
+	return node.next[0]
+}
+
+// all returns an iterator over all the pairs in the key order.
+func (pairs *orderedPairs) all() iter.Seq[RawKeyValuePair] {
+	return func(yield func(RawKeyValuePair) bool) {
+		for node := pairs.head.next[0]; node != nil; node = node.next[0] {
+			if !yield(node.pair) {
+				return
+			}
+		}
+	}
+}
+
+// randomHeight returns the height of a new node, every level is taken with the probability of 1/4.
+func (pairs *orderedPairs) randomHeight() int {
+	height := 1
+	for height < maxOrderedPairsHeight && rand.Uint32N(4) == 0 {
+		height++
+	}
+	return height
+}
@@ -0,0 +1,52 @@
+package kv
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOrderedPairsPutAndGet(t *testing.T) {
+	pairs := newOrderedPairs()
+	_, existed := pairs.put(RawKeyValuePair{key: []byte("SSD"), value: NewStringValue("Solid state drive"), kind: EntryKindPut})
+	assert.False(t, existed)
+
+	previous, existed := pairs.put(RawKeyValuePair{key: []byte("SSD"), value: NewStringValue("NVMe"), kind: EntryKindPut})
+	assert.True(t, existed)
+	assert.Equal(t, "Solid state drive", previous.value.String())
+
+	pair, ok := pairs.get([]byte("SSD"))
+	assert.True(t, ok)
+	assert.Equal(t, "NVMe", pair.value.String())
+	assert.Equal(t, 1, pairs.length)
+
+	_, ok = pairs.get([]byte("HDD"))
+	assert.False(t, ok)
+}
+
+func TestOrderedPairsDelete(t *testing.T) {
+	pairs := newOrderedPairs()
+	for _, key := range []string{"SSD", "HDD", "NVMe"} {
+		pairs.put(RawKeyValuePair{key: []byte(key), value: NewStringValue(key), kind: EntryKindPut})
+	}
+	pairs.delete([]byte("NVMe"))
+	pairs.delete([]byte("RAM"))
+
+	var keys []string
+	for pair := range pairs.all() {
+		keys = append(keys, string(pair.key))
+	}
+	assert.Equal(t, []string{"HDD", "SSD"}, keys)
+	assert.Equal(t, 2, pairs.length)
+}
+
+func TestOrderedPairsCeiling(t *testing.T) {
+	pairs := newOrderedPairs()
+	for _, key := range []string{"SSD", "HDD"} {
+		pairs.put(RawKeyValuePair{key: []byte(key), value: NewStringValue(key), kind: EntryKindPut})
+	}
+
+	assert.Equal(t, "SSD", string(pairs.ceiling([]byte("NVMe"), nil).pair.key))
+	assert.Equal(t, "HDD", string(pairs.ceiling([]byte("HDD"), nil).pair.key))
+	assert.Nil(t, pairs.ceiling([]byte("Tape"), nil))
+}
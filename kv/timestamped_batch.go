@@ -40,7 +40,7 @@ type TimestampedBatch struct {
 // NewTimestampedBatchFrom creates a new instance of TimestampedBatch from Batch and commitTimestamp of the transaction.
 func NewTimestampedBatchFrom(batch Batch, commitTimestamp uint64) TimestampedBatch {
 	timestampedBatch := &TimestampedBatch{}
-	for _, pair := range batch.pairs {
+	for pair := range batch.pairs.all() {
 		if pair.kind == EntryKindPut {
 			timestampedBatch.put(NewKey(pair.key, commitTimestamp), pair.value)
 		} else if pair.kind == EntryKindDelete {
@@ -16,12 +16,22 @@ func TestBatchWithASingleEntry(t *testing.T) {
 func TestBatchWithTwoEntries(t *testing.T) {
 	batch := NewBatch()
 	_ = batch.Put([]byte("consensus"), []byte("raft"))
-	batch.Delete([]byte("consensus"))
+	batch.Delete([]byte("storage"))
 
 	timestampedBatch := NewTimestampedBatchFrom(*batch, 5)
 	assert.Equal(t, 2, len(timestampedBatch.AllEntries()))
 }
 
+func TestBatchWithAPutAndDeleteOfTheSameKey(t *testing.T) {
+	batch := NewBatch()
+	_ = batch.Put([]byte("consensus"), []byte("raft"))
+	batch.Delete([]byte("consensus"))
+
+	timestampedBatch := NewTimestampedBatchFrom(*batch, 5)
+	assert.Equal(t, 1, len(timestampedBatch.AllEntries()))
+	assert.True(t, timestampedBatch.AllEntries()[0].IsKindDelete())
+}
+
 func TestBatchWithThreeEntries(t *testing.T) {
 	batch := NewBatch()
 	_ = batch.Put([]byte("consensus"), []byte("raft"))
@@ -106,7 +106,7 @@ func (wal *WAL) Path() (string, error) {
 
 // CreateWalPathFor creates a WAL path for the memtable with id.
 func CreateWalPathFor(id uint64, walDirectoryPath string) string {
-	return filepath.Join(walDirectoryPath, fmt.Sprintf("%v.wal", id))
+	return filepath.Join(walDirectoryPath, fmt.Sprintf("%v%v", id, walFileExtension))
 }
 
 // newWAL creates a new instance of WAL.
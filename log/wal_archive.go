@@ -0,0 +1,293 @@
+package log
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"go-lsm-workshop/kv"
+	"go-lsm-workshop/table"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const walFileExtension = ".wal"
+const ingestionFileExtension = ".ingestion"
+const archivedWALsFileName = "archived_wals"
+
+var ArchivedWALExistsErr = errors.New("WAL file already exists in the archive")
+
+// WALArchive is a directory where the WAL files of the flushed memtables are archived (moved), instead of being deleted.
+// The archived WAL files are replayed in point-in-time recovery (Refer to state.StorageState.ReplayWALs).
+// Every archived WAL file is also recorded (with the first and the last commit-timestamps of its entries) in the
+// archived_wals file of the directory, which is never trimmed, so that point-in-time recovery can detect the archived WAL
+// files which are missing (Refer to ArchivedWALsIn).
+// The retention of the archived WAL files is limited by maxFiles (the oldest WAL files beyond maxFiles are removed) and
+// maxAge (the WAL files last modified before maxAge are removed). A zero value means no limit.
+type WALArchive struct {
+	directoryPath string
+	maxFiles      uint
+	maxAge        time.Duration
+	lock          sync.Mutex
+}
+
+// NewWALArchive creates a new instance of WALArchive, creating the directoryPath if it does not exist.
+func NewWALArchive(directoryPath string, maxFiles uint, maxAge time.Duration) (*WALArchive, error) {
+	if err := os.MkdirAll(directoryPath, os.ModePerm); err != nil {
+		return nil, err
+	}
+	return &WALArchive{
+		directoryPath: directoryPath,
+		maxFiles:      maxFiles,
+		maxAge:        maxAge,
+	}, nil
+}
+
+// ArchivedWAL describes an archived WAL file: its (memtable) id, and the first and the last commit-timestamps of its entries.
+type ArchivedWAL struct {
+	Id                   uint64
+	FirstCommitTimestamp uint64
+	LastCommitTimestamp  uint64
+}
+
+// Archive closes the WAL, records it in the archived_wals file and moves its file to the archive directory, and then
+// enforces the retention limits.
+// The file is hard-linked to the archive directory and then removed from the WAL directory (it is copied if it can not be
+// linked, for example, across file systems), so an existing file in the archive directory is never overwritten: Archive
+// returns ArchivedWALExistsErr instead.
+func (archive *WALArchive) Archive(wal *WAL) error {
+	archive.lock.Lock()
+	defer archive.lock.Unlock()
+
+	wal.Close()
+	sourcePath := wal.file.Name()
+	targetPath := filepath.Join(archive.directoryPath, filepath.Base(sourcePath))
+	if _, err := os.Stat(targetPath); err == nil {
+		return fmt.Errorf("%w: %v", ArchivedWALExistsErr, targetPath)
+	}
+	if err := archive.record(sourcePath); err != nil {
+		return err
+	}
+	if err := os.Link(sourcePath, targetPath); err != nil {
+		if os.IsExist(err) {
+			return fmt.Errorf("%w: %v", ArchivedWALExistsErr, targetPath)
+		}
+		if err := table.CopyFile(sourcePath, targetPath); err != nil {
+			return err
+		}
+	}
+	if err := os.Remove(sourcePath); err != nil {
+		return err
+	}
+	return archive.enforceRetention(time.Now())
+}
+
+// record appends the ArchivedWAL of the WAL file at walFilePath to the archived_wals file, as a line:
+// <id> <firstCommitTimestamp> <lastCommitTimestamp>.
+// A WAL file without any entries is not recorded, as it can not be missed in point-in-time recovery.
+func (archive *WALArchive) record(walFilePath string) error {
+	id, ok := WALIdOf(walFilePath)
+	if !ok {
+		return nil
+	}
+	archivedWAL := ArchivedWAL{Id: id}
+	wal, err := Recover(walFilePath, func(key kv.Key, value kv.Value) {
+		if archivedWAL.FirstCommitTimestamp == 0 {
+			archivedWAL.FirstCommitTimestamp = key.Timestamp()
+		}
+		archivedWAL.LastCommitTimestamp = key.Timestamp()
+	})
+	if err != nil {
+		return err
+	}
+	wal.Close()
+	if archivedWAL.FirstCommitTimestamp == 0 {
+		return nil
+	}
+
+	file, err := os.OpenFile(filepath.Join(archive.directoryPath, archivedWALsFileName), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(file, "%d %d %d\n", archivedWAL.Id, archivedWAL.FirstCommitTimestamp, archivedWAL.LastCommitTimestamp); err != nil {
+		_ = file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		_ = file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// RecordIngestion records an ingestion of SSTables at the commitTimestamp, as an empty file named after the commitTimestamp.
+// The ingested keys are not written to any WAL, so point-in-time recovery can not replay an ingestion, it uses the recorded
+// ingestions to detect that (Refer to IngestionCommitTimestampsIn).
+func (archive *WALArchive) RecordIngestion(commitTimestamp uint64) error {
+	archive.lock.Lock()
+	defer archive.lock.Unlock()
+
+	file, err := os.Create(archive.ingestionFilePath(commitTimestamp))
+	if err != nil {
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		_ = file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// RemoveIngestion removes the recorded ingestion at the commitTimestamp, it is used if the ingestion fails after it is recorded.
+func (archive *WALArchive) RemoveIngestion(commitTimestamp uint64) {
+	archive.lock.Lock()
+	defer archive.lock.Unlock()
+
+	_ = os.Remove(archive.ingestionFilePath(commitTimestamp))
+}
+
+// ingestionFilePath returns the path of the file which records the ingestion at the commitTimestamp.
+func (archive *WALArchive) ingestionFilePath(commitTimestamp uint64) string {
+	return filepath.Join(archive.directoryPath, strconv.FormatUint(commitTimestamp, 10)+ingestionFileExtension)
+}
+
+// DirectoryPath returns the path of the archive directory.
+func (archive *WALArchive) DirectoryPath() string {
+	return archive.directoryPath
+}
+
+// enforceRetention removes the archived WAL files which are older than maxAge, and the oldest WAL files beyond maxFiles.
+func (archive *WALArchive) enforceRetention(now time.Time) error {
+	filePaths, err := WALFilePathsIn(archive.directoryPath)
+	if err != nil {
+		return err
+	}
+	if archive.maxAge > 0 {
+		var retained []string
+		for _, filePath := range filePaths {
+			stat, err := os.Stat(filePath)
+			if err != nil {
+				return err
+			}
+			if now.Sub(stat.ModTime()) > archive.maxAge {
+				if err := os.Remove(filePath); err != nil {
+					return err
+				}
+				continue
+			}
+			retained = append(retained, filePath)
+		}
+		filePaths = retained
+	}
+	if archive.maxFiles > 0 && uint(len(filePaths)) > archive.maxFiles {
+		for _, filePath := range filePaths[:uint(len(filePaths))-archive.maxFiles] {
+			if err := os.Remove(filePath); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// WALFilePathsIn returns the paths of all the WAL files in the directoryPaths, in the increasing order of their (memtable) ids.
+func WALFilePathsIn(directoryPaths ...string) ([]string, error) {
+	type walFile struct {
+		id   uint64
+		path string
+	}
+	var walFiles []walFile
+	for _, directoryPath := range directoryPaths {
+		entries, err := os.ReadDir(directoryPath)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			if entry.IsDir() {
+				continue
+			}
+			id, ok := WALIdOf(entry.Name())
+			if !ok {
+				continue
+			}
+			walFiles = append(walFiles, walFile{id: id, path: filepath.Join(directoryPath, entry.Name())})
+		}
+	}
+	slices.SortStableFunc(walFiles, func(one, other walFile) int {
+		return cmp.Compare(one.id, other.id)
+	})
+	filePaths := make([]string, 0, len(walFiles))
+	for _, walFile := range walFiles {
+		filePaths = append(filePaths, walFile.path)
+	}
+	return filePaths, nil
+}
+
+// WALIdOf returns the (memtable) id of the WAL file at walFilePath, and false if walFilePath is not a WAL file.
+func WALIdOf(walFilePath string) (uint64, bool) {
+	fileName := filepath.Base(walFilePath)
+	if !strings.HasSuffix(fileName, walFileExtension) {
+		return 0, false
+	}
+	id, err := strconv.ParseUint(strings.TrimSuffix(fileName, walFileExtension), 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return id, true
+}
+
+// ArchivedWALsIn returns all the WAL files recorded as archived in the directoryPaths (Refer to WALArchive.Archive), in the
+// increasing order of their ids. The recorded WAL files may not exist anymore (for example, removed by the retention limits).
+func ArchivedWALsIn(directoryPaths ...string) ([]ArchivedWAL, error) {
+	var archivedWALs []ArchivedWAL
+	for _, directoryPath := range directoryPaths {
+		content, err := os.ReadFile(filepath.Join(directoryPath, archivedWALsFileName))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, line := range strings.Split(string(content), "\n") {
+			if len(line) == 0 {
+				continue
+			}
+			var archivedWAL ArchivedWAL
+			if _, err := fmt.Sscanf(line, "%d %d %d", &archivedWAL.Id, &archivedWAL.FirstCommitTimestamp, &archivedWAL.LastCommitTimestamp); err != nil {
+				return nil, err
+			}
+			archivedWALs = append(archivedWALs, archivedWAL)
+		}
+	}
+	slices.SortStableFunc(archivedWALs, func(one, other ArchivedWAL) int {
+		return cmp.Compare(one.Id, other.Id)
+	})
+	return archivedWALs, nil
+}
+
+// IngestionCommitTimestampsIn returns the commit-timestamps of all the ingestions recorded in the directoryPaths
+// (Refer to WALArchive.RecordIngestion), in the increasing order.
+func IngestionCommitTimestampsIn(directoryPaths ...string) ([]uint64, error) {
+	var commitTimestamps []uint64
+	for _, directoryPath := range directoryPaths {
+		entries, err := os.ReadDir(directoryPath)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			if entry.IsDir() || !strings.HasSuffix(entry.Name(), ingestionFileExtension) {
+				continue
+			}
+			commitTimestamp, err := strconv.ParseUint(strings.TrimSuffix(entry.Name(), ingestionFileExtension), 10, 64)
+			if err != nil {
+				continue
+			}
+			commitTimestamps = append(commitTimestamps, commitTimestamp)
+		}
+	}
+	slices.Sort(commitTimestamps)
+	return commitTimestamps, nil
+}
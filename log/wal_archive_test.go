@@ -0,0 +1,147 @@
+package log
+
+import (
+	"go-lsm-workshop/kv"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestArchiveWALsWithMaxFiles(t *testing.T) {
+	walDirectoryPath := filepath.Join(".", "TestArchiveWALsWithMaxFiles", "wal")
+	archiveDirectoryPath := filepath.Join(".", "TestArchiveWALsWithMaxFiles", "archive")
+	assert.Nil(t, os.MkdirAll(walDirectoryPath, os.ModePerm))
+	defer func() {
+		_ = os.RemoveAll(filepath.Join(".", "TestArchiveWALsWithMaxFiles"))
+	}()
+
+	archive, err := NewWALArchive(archiveDirectoryPath, 2, 0)
+	assert.Nil(t, err)
+
+	for id := uint64(1); id <= 3; id++ {
+		wal, err := NewWAL(id, walDirectoryPath)
+		assert.Nil(t, err)
+		assert.Nil(t, archive.Archive(wal))
+	}
+
+	walFilePaths, err := WALFilePathsIn(archiveDirectoryPath)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{
+		CreateWalPathFor(2, archiveDirectoryPath),
+		CreateWalPathFor(3, archiveDirectoryPath),
+	}, walFilePaths)
+
+	walFilePaths, err = WALFilePathsIn(walDirectoryPath)
+	assert.Nil(t, err)
+	assert.Empty(t, walFilePaths)
+}
+
+func TestArchiveWALsWithMaxAge(t *testing.T) {
+	walDirectoryPath := filepath.Join(".", "TestArchiveWALsWithMaxAge", "wal")
+	archiveDirectoryPath := filepath.Join(".", "TestArchiveWALsWithMaxAge", "archive")
+	assert.Nil(t, os.MkdirAll(walDirectoryPath, os.ModePerm))
+	defer func() {
+		_ = os.RemoveAll(filepath.Join(".", "TestArchiveWALsWithMaxAge"))
+	}()
+
+	archive, err := NewWALArchive(archiveDirectoryPath, 0, time.Hour)
+	assert.Nil(t, err)
+
+	wal, err := NewWAL(1, walDirectoryPath)
+	assert.Nil(t, err)
+	assert.Nil(t, archive.Archive(wal))
+
+	assert.Nil(t, archive.enforceRetention(time.Now().Add(2*time.Hour)))
+
+	walFilePaths, err := WALFilePathsIn(archiveDirectoryPath)
+	assert.Nil(t, err)
+	assert.Empty(t, walFilePaths)
+}
+
+func TestRecordIngestionsInWALArchive(t *testing.T) {
+	archiveDirectoryPath := filepath.Join(".", "TestRecordIngestionsInWALArchive", "archive")
+	defer func() {
+		_ = os.RemoveAll(filepath.Join(".", "TestRecordIngestionsInWALArchive"))
+	}()
+
+	archive, err := NewWALArchive(archiveDirectoryPath, 1, 0)
+	assert.Nil(t, err)
+
+	assert.Nil(t, archive.RecordIngestion(20))
+	assert.Nil(t, archive.RecordIngestion(5))
+	assert.Nil(t, archive.RecordIngestion(12))
+	archive.RemoveIngestion(12)
+
+	commitTimestamps, err := IngestionCommitTimestampsIn(archiveDirectoryPath)
+	assert.Nil(t, err)
+	assert.Equal(t, []uint64{5, 20}, commitTimestamps)
+
+	walFilePaths, err := WALFilePathsIn(archiveDirectoryPath)
+	assert.Nil(t, err)
+	assert.Empty(t, walFilePaths)
+}
+
+func TestArchiveRecordsTheCommitTimestampsOfTheWAL(t *testing.T) {
+	walDirectoryPath := filepath.Join(".", "TestArchiveRecordsTheCommitTimestampsOfTheWAL", "wal")
+	archiveDirectoryPath := filepath.Join(".", "TestArchiveRecordsTheCommitTimestampsOfTheWAL", "archive")
+	assert.Nil(t, os.MkdirAll(walDirectoryPath, os.ModePerm))
+	defer func() {
+		_ = os.RemoveAll(filepath.Join(".", "TestArchiveRecordsTheCommitTimestampsOfTheWAL"))
+	}()
+
+	archive, err := NewWALArchive(archiveDirectoryPath, 1, 0)
+	assert.Nil(t, err)
+
+	wal, err := NewWAL(3, walDirectoryPath)
+	assert.Nil(t, err)
+	assert.Nil(t, wal.Append(kv.NewStringKeyWithTimestamp("consensus", 5), kv.NewStringValue("raft")))
+	assert.Nil(t, wal.Append(kv.NewStringKeyWithTimestamp("storage", 7), kv.NewStringValue("NVMe")))
+	assert.Nil(t, archive.Archive(wal))
+
+	emptyWAL, err := NewWAL(4, walDirectoryPath)
+	assert.Nil(t, err)
+	assert.Nil(t, archive.Archive(emptyWAL))
+
+	wal, err = NewWAL(6, walDirectoryPath)
+	assert.Nil(t, err)
+	assert.Nil(t, wal.Append(kv.NewStringKeyWithTimestamp("database", 8), kv.NewStringValue("LSM")))
+	assert.Nil(t, archive.Archive(wal))
+
+	archivedWALs, err := ArchivedWALsIn(archiveDirectoryPath, walDirectoryPath)
+	assert.Nil(t, err)
+	assert.Equal(t, []ArchivedWAL{
+		{Id: 3, FirstCommitTimestamp: 5, LastCommitTimestamp: 7},
+		{Id: 6, FirstCommitTimestamp: 8, LastCommitTimestamp: 8},
+	}, archivedWALs)
+}
+
+func TestArchiveDoesNotOverwriteAnArchivedWAL(t *testing.T) {
+	walDirectoryPath := filepath.Join(".", "TestArchiveDoesNotOverwriteAnArchivedWAL", "wal")
+	archiveDirectoryPath := filepath.Join(".", "TestArchiveDoesNotOverwriteAnArchivedWAL", "archive")
+	assert.Nil(t, os.MkdirAll(walDirectoryPath, os.ModePerm))
+	defer func() {
+		_ = os.RemoveAll(filepath.Join(".", "TestArchiveDoesNotOverwriteAnArchivedWAL"))
+	}()
+
+	archive, err := NewWALArchive(archiveDirectoryPath, 0, 0)
+	assert.Nil(t, err)
+
+	wal, err := NewWAL(1, walDirectoryPath)
+	assert.Nil(t, err)
+	assert.Nil(t, wal.Append(kv.NewStringKeyWithTimestamp("consensus", 5), kv.NewStringValue("raft")))
+	assert.Nil(t, archive.Archive(wal))
+
+	wal, err = NewWAL(1, walDirectoryPath)
+	assert.Nil(t, err)
+	assert.ErrorIs(t, archive.Archive(wal), ArchivedWALExistsErr)
+
+	_, err = os.Stat(CreateWalPathFor(1, walDirectoryPath))
+	assert.Nil(t, err)
+
+	stat, err := os.Stat(CreateWalPathFor(1, archiveDirectoryPath))
+	assert.Nil(t, err)
+	assert.True(t, stat.Size() > 0)
+}
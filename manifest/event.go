@@ -16,9 +16,13 @@ const (
 
 // Event types.
 const (
-	MemtableCreatedEventType uint8 = iota
-	SSTableFlushedEventType  uint8 = 1
-	CompactionDoneEventType  uint8 = 2
+	MemtableCreatedEventType     uint8 = iota
+	SSTableFlushedEventType      uint8 = 1
+	CompactionDoneEventType      uint8 = 2
+	SSTablesIngestedEventType    uint8 = 3
+	StateSnapshotEventType       uint8 = 4
+	SortedRunsCompactedEventType uint8 = 5
+	SSTablesMovedEventType       uint8 = 6
 )
 
 // Event represents a manifest event.
@@ -43,6 +47,37 @@ type CompactionDone struct {
 	Description   meta.SimpleLeveledCompactionDescription
 }
 
+// SSTablesIngested defines an SSTables ingested event. (Externally built SSTables ingested at a level).
+// Level 0 represents level0.
+type SSTablesIngested struct {
+	SSTableIds      []uint64
+	Level           int
+	CommitTimestamp uint64
+}
+
+// SortedRunsCompacted defines a compaction done event for any number of sorted runs (Refer to
+// meta.SortedRunsCompactionDescription).
+type SortedRunsCompacted struct {
+	NewSSTableIds []uint64
+	Description   meta.SortedRunsCompactionDescription
+}
+
+// SSTablesMoved defines an SSTables moved event (a trivial move). The SSTables are moved from FromLevel to ToLevel without
+// rewriting them, because they do not overlap with any SSTable of ToLevel. Level 0 represents level0.
+type SSTablesMoved struct {
+	SSTableIds []uint64
+	FromLevel  int
+	ToLevel    int
+}
+
+// StateSnapshot defines the state of all the SSTables (at level0 and other levels) along with the last commit-timestamp.
+// It is the first event of a compacted manifest, written in a checkpoint (Refer to state.Checkpoint).
+type StateSnapshot struct {
+	L0SSTableIds        []uint64
+	LevelSSTableIds     [][]uint64
+	LastCommitTimestamp uint64
+}
+
 // NewMemtableCreated creates a new MemtableCreated event.
 func NewMemtableCreated(memtableId uint64) *MemtableCreated {
 	return &MemtableCreated{MemtableId: memtableId}
@@ -140,6 +175,248 @@ func decodeCompactionDone(buffer []byte) (*CompactionDone, int) {
 	return compactionDone, int(reader.count)
 }
 
+// NewSSTablesIngested creates a new SSTablesIngested event.
+func NewSSTablesIngested(ssTableIds []uint64, level int, commitTimestamp uint64) *SSTablesIngested {
+	return &SSTablesIngested{
+		SSTableIds:      ssTableIds,
+		Level:           level,
+		CommitTimestamp: commitTimestamp,
+	}
+}
+
+// encode encodes SSTablesIngested to byte slice.
+// Unlike CompactionDone, it uses a fixed layout (and not gob), so that the number of bytes consumed in decoding is exact.
+/*
+ ----------------------------------------------------------------------------------------------------------------------------
+| 1 byte event type | 8 bytes CommitTimestamp | 8 bytes Level | 8 bytes number of SSTableIds | 8 bytes for each SSTableId |
+ ----------------------------------------------------------------------------------------------------------------------------
+*/
+func (ssTablesIngested *SSTablesIngested) encode() ([]byte, error) {
+	buffer := make([]byte, eventTypeSize+3*idSize+uintptr(len(ssTablesIngested.SSTableIds))*idSize)
+	buffer[0] = SSTablesIngestedEventType
+
+	offset := eventTypeSize
+	binary.LittleEndian.PutUint64(buffer[offset:], ssTablesIngested.CommitTimestamp)
+	offset += idSize
+	binary.LittleEndian.PutUint64(buffer[offset:], uint64(ssTablesIngested.Level))
+	offset += idSize
+	binary.LittleEndian.PutUint64(buffer[offset:], uint64(len(ssTablesIngested.SSTableIds)))
+	offset += idSize
+	for _, ssTableId := range ssTablesIngested.SSTableIds {
+		binary.LittleEndian.PutUint64(buffer[offset:], ssTableId)
+		offset += idSize
+	}
+	return buffer, nil
+}
+
+// EventType returns the event type SSTablesIngestedEventType.
+func (ssTablesIngested *SSTablesIngested) EventType() uint8 {
+	return SSTablesIngestedEventType
+}
+
+// decodeSSTablesIngested decodes the SSTablesIngested event from the byte slice.
+func decodeSSTablesIngested(buffer []byte) (*SSTablesIngested, int) {
+	commitTimestamp := binary.LittleEndian.Uint64(buffer[:])
+	level := int(binary.LittleEndian.Uint64(buffer[idSize:]))
+	numberOfSSTableIds := int(binary.LittleEndian.Uint64(buffer[2*idSize:]))
+
+	offset := 3 * int(idSize)
+	ssTableIds := make([]uint64, 0, numberOfSSTableIds)
+	for count := 0; count < numberOfSSTableIds; count++ {
+		ssTableIds = append(ssTableIds, binary.LittleEndian.Uint64(buffer[offset:]))
+		offset += int(idSize)
+	}
+	return NewSSTablesIngested(ssTableIds, level, commitTimestamp), offset
+}
+
+// NewStateSnapshot creates a new StateSnapshot event.
+func NewStateSnapshot(l0SSTableIds []uint64, levelSSTableIds [][]uint64, lastCommitTimestamp uint64) *StateSnapshot {
+	return &StateSnapshot{
+		L0SSTableIds:        l0SSTableIds,
+		LevelSSTableIds:     levelSSTableIds,
+		LastCommitTimestamp: lastCommitTimestamp,
+	}
+}
+
+// encode encodes StateSnapshot to byte slice.
+// Each level (starting with level0) is encoded as the number of SSTableIds followed by the SSTableIds.
+/*
+ ---------------------------------------------------------------------------------------------------------------------
+| 1 byte event type | 8 bytes LastCommitTimestamp | 8 bytes number of levels (excluding level0) | level0 | level1 | ... |
+ ---------------------------------------------------------------------------------------------------------------------
+*/
+func (stateSnapshot *StateSnapshot) encode() ([]byte, error) {
+	encodeLevel := func(buffer []byte, ssTableIds []uint64) []byte {
+		buffer = binary.LittleEndian.AppendUint64(buffer, uint64(len(ssTableIds)))
+		for _, ssTableId := range ssTableIds {
+			buffer = binary.LittleEndian.AppendUint64(buffer, ssTableId)
+		}
+		return buffer
+	}
+	buffer := []byte{StateSnapshotEventType}
+	buffer = binary.LittleEndian.AppendUint64(buffer, stateSnapshot.LastCommitTimestamp)
+	buffer = binary.LittleEndian.AppendUint64(buffer, uint64(len(stateSnapshot.LevelSSTableIds)))
+	buffer = encodeLevel(buffer, stateSnapshot.L0SSTableIds)
+	for _, ssTableIds := range stateSnapshot.LevelSSTableIds {
+		buffer = encodeLevel(buffer, ssTableIds)
+	}
+	return buffer, nil
+}
+
+// EventType returns the event type StateSnapshotEventType.
+func (stateSnapshot *StateSnapshot) EventType() uint8 {
+	return StateSnapshotEventType
+}
+
+// decodeStateSnapshot decodes the StateSnapshot event from the byte slice.
+func decodeStateSnapshot(buffer []byte) (*StateSnapshot, int) {
+	offset := 0
+	decodeUint64 := func() uint64 {
+		value := binary.LittleEndian.Uint64(buffer[offset:])
+		offset += int(idSize)
+		return value
+	}
+	decodeLevel := func() []uint64 {
+		numberOfSSTableIds := int(decodeUint64())
+		ssTableIds := make([]uint64, 0, numberOfSSTableIds)
+		for count := 0; count < numberOfSSTableIds; count++ {
+			ssTableIds = append(ssTableIds, decodeUint64())
+		}
+		return ssTableIds
+	}
+	lastCommitTimestamp := decodeUint64()
+	numberOfLevels := int(decodeUint64())
+	l0SSTableIds := decodeLevel()
+	levelSSTableIds := make([][]uint64, 0, numberOfLevels)
+	for level := 0; level < numberOfLevels; level++ {
+		levelSSTableIds = append(levelSSTableIds, decodeLevel())
+	}
+	return NewStateSnapshot(l0SSTableIds, levelSSTableIds, lastCommitTimestamp), offset
+}
+
+// NewSortedRunsCompacted creates a new SortedRunsCompacted event.
+func NewSortedRunsCompacted(newSSTableIds []uint64, description meta.SortedRunsCompactionDescription) *SortedRunsCompacted {
+	return &SortedRunsCompacted{
+		NewSSTableIds: newSSTableIds,
+		Description:   description,
+	}
+}
+
+// encode encodes SortedRunsCompacted to byte slice.
+// Each sorted run is encoded as its level, the number of SSTableIds followed by the SSTableIds.
+/*
+ ------------------------------------------------------------------------------------------------------------------------------
+| 1 byte event type | 8 bytes OutputLevel | 8 bytes number of NewSSTableIds | NewSSTableIds | 8 bytes number of sorted runs |
+| sorted run1 | sorted run2 | ... |
+ ------------------------------------------------------------------------------------------------------------------------------
+*/
+func (sortedRunsCompacted *SortedRunsCompacted) encode() ([]byte, error) {
+	encodeIds := func(buffer []byte, ssTableIds []uint64) []byte {
+		buffer = binary.LittleEndian.AppendUint64(buffer, uint64(len(ssTableIds)))
+		for _, ssTableId := range ssTableIds {
+			buffer = binary.LittleEndian.AppendUint64(buffer, ssTableId)
+		}
+		return buffer
+	}
+	buffer := []byte{SortedRunsCompactedEventType}
+	buffer = binary.LittleEndian.AppendUint64(buffer, uint64(sortedRunsCompacted.Description.OutputLevel))
+	buffer = encodeIds(buffer, sortedRunsCompacted.NewSSTableIds)
+	buffer = binary.LittleEndian.AppendUint64(buffer, uint64(len(sortedRunsCompacted.Description.SortedRuns)))
+	for _, sortedRun := range sortedRunsCompacted.Description.SortedRuns {
+		buffer = binary.LittleEndian.AppendUint64(buffer, uint64(sortedRun.Level))
+		buffer = encodeIds(buffer, sortedRun.SSTableIds)
+	}
+	return buffer, nil
+}
+
+// EventType returns the event type SortedRunsCompactedEventType.
+func (sortedRunsCompacted *SortedRunsCompacted) EventType() uint8 {
+	return SortedRunsCompactedEventType
+}
+
+// decodeSortedRunsCompacted decodes the SortedRunsCompacted event from the byte slice.
+func decodeSortedRunsCompacted(buffer []byte) (*SortedRunsCompacted, int) {
+	offset := 0
+	decodeUint64 := func() uint64 {
+		value := binary.LittleEndian.Uint64(buffer[offset:])
+		offset += int(idSize)
+		return value
+	}
+	decodeIds := func() []uint64 {
+		numberOfSSTableIds := int(decodeUint64())
+		ssTableIds := make([]uint64, 0, numberOfSSTableIds)
+		for count := 0; count < numberOfSSTableIds; count++ {
+			ssTableIds = append(ssTableIds, decodeUint64())
+		}
+		return ssTableIds
+	}
+	outputLevel := int(decodeUint64())
+	newSSTableIds := decodeIds()
+	numberOfSortedRuns := int(decodeUint64())
+	sortedRuns := make([]meta.SortedRun, 0, numberOfSortedRuns)
+	for count := 0; count < numberOfSortedRuns; count++ {
+		level := int(decodeUint64())
+		sortedRuns = append(sortedRuns, meta.SortedRun{Level: level, SSTableIds: decodeIds()})
+	}
+	return NewSortedRunsCompacted(newSSTableIds, meta.SortedRunsCompactionDescription{
+		SortedRuns:  sortedRuns,
+		OutputLevel: outputLevel,
+	}), offset
+}
+
+// NewSSTablesMoved creates a new SSTablesMoved event.
+func NewSSTablesMoved(ssTableIds []uint64, fromLevel, toLevel int) *SSTablesMoved {
+	return &SSTablesMoved{
+		SSTableIds: ssTableIds,
+		FromLevel:  fromLevel,
+		ToLevel:    toLevel,
+	}
+}
+
+// encode encodes SSTablesMoved to byte slice.
+/*
+ ----------------------------------------------------------------------------------------------------------------------
+| 1 byte event type | 8 bytes FromLevel | 8 bytes ToLevel | 8 bytes number of SSTableIds | 8 bytes for each SSTableId |
+ ----------------------------------------------------------------------------------------------------------------------
+*/
+func (ssTablesMoved *SSTablesMoved) encode() ([]byte, error) {
+	buffer := make([]byte, eventTypeSize+3*idSize+uintptr(len(ssTablesMoved.SSTableIds))*idSize)
+	buffer[0] = SSTablesMovedEventType
+
+	offset := eventTypeSize
+	binary.LittleEndian.PutUint64(buffer[offset:], uint64(ssTablesMoved.FromLevel))
+	offset += idSize
+	binary.LittleEndian.PutUint64(buffer[offset:], uint64(ssTablesMoved.ToLevel))
+	offset += idSize
+	binary.LittleEndian.PutUint64(buffer[offset:], uint64(len(ssTablesMoved.SSTableIds)))
+	offset += idSize
+	for _, ssTableId := range ssTablesMoved.SSTableIds {
+		binary.LittleEndian.PutUint64(buffer[offset:], ssTableId)
+		offset += idSize
+	}
+	return buffer, nil
+}
+
+// EventType returns the event type SSTablesMovedEventType.
+func (ssTablesMoved *SSTablesMoved) EventType() uint8 {
+	return SSTablesMovedEventType
+}
+
+// decodeSSTablesMoved decodes the SSTablesMoved event from the byte slice.
+func decodeSSTablesMoved(buffer []byte) (*SSTablesMoved, int) {
+	fromLevel := int(binary.LittleEndian.Uint64(buffer[:]))
+	toLevel := int(binary.LittleEndian.Uint64(buffer[idSize:]))
+	numberOfSSTableIds := int(binary.LittleEndian.Uint64(buffer[2*idSize:]))
+
+	offset := 3 * int(idSize)
+	ssTableIds := make([]uint64, 0, numberOfSSTableIds)
+	for count := 0; count < numberOfSSTableIds; count++ {
+		ssTableIds = append(ssTableIds, binary.LittleEndian.Uint64(buffer[offset:]))
+		offset += int(idSize)
+	}
+	return NewSSTablesMoved(ssTableIds, fromLevel, toLevel), offset
+}
+
 // decodeEventsFrom decodes all the events from the Manifest file. The passed buffer is the whole file.
 func decodeEventsFrom(buffer []byte) []Event {
 	var events []Event
@@ -158,6 +435,22 @@ func decodeEventsFrom(buffer []byte) []Event {
 			compactionDone, n := decodeCompactionDone(buffer[eventTypeSize:])
 			events = append(events, compactionDone)
 			buffer = buffer[n+int(eventTypeSize):]
+		case SSTablesIngestedEventType:
+			ssTablesIngested, n := decodeSSTablesIngested(buffer[eventTypeSize:])
+			events = append(events, ssTablesIngested)
+			buffer = buffer[n+int(eventTypeSize):]
+		case StateSnapshotEventType:
+			stateSnapshot, n := decodeStateSnapshot(buffer[eventTypeSize:])
+			events = append(events, stateSnapshot)
+			buffer = buffer[n+int(eventTypeSize):]
+		case SortedRunsCompactedEventType:
+			sortedRunsCompacted, n := decodeSortedRunsCompacted(buffer[eventTypeSize:])
+			events = append(events, sortedRunsCompacted)
+			buffer = buffer[n+int(eventTypeSize):]
+		case SSTablesMovedEventType:
+			ssTablesMoved, n := decodeSSTablesMoved(buffer[eventTypeSize:])
+			events = append(events, ssTablesMoved)
+			buffer = buffer[n+int(eventTypeSize):]
 		}
 	}
 	return events
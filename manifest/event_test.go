@@ -111,3 +111,127 @@ func TestDecodeNewMemtableCreatedAndCompactionDoneEvents(t *testing.T) {
 	assert.Equal(t, uint64(10), events[0].(*MemtableCreated).MemtableId)
 	assert.Equal(t, []uint64{10, 11}, events[1].(*CompactionDone).NewSSTableIds)
 }
+
+func TestNewSSTablesIngestedEventEncodeAndDecode(t *testing.T) {
+	ssTablesIngested := NewSSTablesIngested([]uint64{10, 14}, 2, 25)
+	buffer, _ := ssTablesIngested.encode()
+
+	decoded, _ := decodeSSTablesIngested(buffer[1:])
+	assert.Equal(t, []uint64{10, 14}, decoded.SSTableIds)
+	assert.Equal(t, 2, decoded.Level)
+	assert.Equal(t, uint64(25), decoded.CommitTimestamp)
+	assert.Equal(t, SSTablesIngestedEventType, decoded.EventType())
+}
+
+func TestDecodeSSTablesIngestedFollowedBySSTableFlushedEvents(t *testing.T) {
+	ssTablesIngested := NewSSTablesIngested([]uint64{10, 14}, 0, 25)
+	ssTableFlushed := NewSSTableFlushed(20)
+
+	ssTablesIngestedBuffer, _ := ssTablesIngested.encode()
+	ssTableFlushedBuffer, _ := ssTableFlushed.encode()
+
+	var buffer []byte
+	buffer = append(buffer, ssTablesIngestedBuffer...)
+	buffer = append(buffer, ssTableFlushedBuffer...)
+
+	events := decodeEventsFrom(buffer)
+	assert.Equal(t, 2, len(events))
+	assert.Equal(t, []uint64{10, 14}, events[0].(*SSTablesIngested).SSTableIds)
+	assert.Equal(t, uint64(20), events[1].(*SSTableFlushed).SsTableId)
+}
+
+func TestDecodeStateSnapshotFollowedByMemtableCreatedEvents(t *testing.T) {
+	stateSnapshot := NewStateSnapshot([]uint64{5, 7}, [][]uint64{{10, 11}, {}, {14}}, 30)
+	memtableCreated := NewMemtableCreated(20)
+
+	stateSnapshotBuffer, _ := stateSnapshot.encode()
+	memtableCreatedBuffer, _ := memtableCreated.encode()
+
+	var buffer []byte
+	buffer = append(buffer, stateSnapshotBuffer...)
+	buffer = append(buffer, memtableCreatedBuffer...)
+
+	events := decodeEventsFrom(buffer)
+	assert.Equal(t, 2, len(events))
+
+	decoded := events[0].(*StateSnapshot)
+	assert.Equal(t, []uint64{5, 7}, decoded.L0SSTableIds)
+	assert.Equal(t, [][]uint64{{10, 11}, {}, {14}}, decoded.LevelSSTableIds)
+	assert.Equal(t, uint64(30), decoded.LastCommitTimestamp)
+	assert.Equal(t, uint64(20), events[1].(*MemtableCreated).MemtableId)
+}
+
+func TestDecodeSortedRunsCompactedFollowedBySSTableFlushedEvents(t *testing.T) {
+	sortedRunsCompacted := NewSortedRunsCompacted([]uint64{30, 31}, meta.SortedRunsCompactionDescription{
+		SortedRuns: []meta.SortedRun{
+			{Level: 0, SSTableIds: []uint64{12}},
+			{Level: 0, SSTableIds: []uint64{11}},
+			{Level: 2, SSTableIds: []uint64{5, 6}},
+		},
+		OutputLevel: 2,
+	})
+	ssTableFlushed := NewSSTableFlushed(40)
+
+	sortedRunsCompactedBuffer, _ := sortedRunsCompacted.encode()
+	ssTableFlushedBuffer, _ := ssTableFlushed.encode()
+
+	var buffer []byte
+	buffer = append(buffer, sortedRunsCompactedBuffer...)
+	buffer = append(buffer, ssTableFlushedBuffer...)
+
+	events := decodeEventsFrom(buffer)
+	assert.Equal(t, 2, len(events))
+
+	decoded := events[0].(*SortedRunsCompacted)
+	assert.Equal(t, []uint64{30, 31}, decoded.NewSSTableIds)
+	assert.Equal(t, sortedRunsCompacted.Description, decoded.Description)
+	assert.Equal(t, uint64(40), events[1].(*SSTableFlushed).SsTableId)
+}
+
+func TestDecodeSortedRunsCompactedWhichDropsSSTables(t *testing.T) {
+	sortedRunsCompacted := NewSortedRunsCompacted(nil, meta.SortedRunsCompactionDescription{
+		SortedRuns:  []meta.SortedRun{{Level: 0, SSTableIds: []uint64{2, 1}}},
+		OutputLevel: 0,
+	})
+	buffer, _ := sortedRunsCompacted.encode()
+
+	events := decodeEventsFrom(buffer)
+	assert.Equal(t, 1, len(events))
+
+	decoded := events[0].(*SortedRunsCompacted)
+	assert.Equal(t, 0, len(decoded.NewSSTableIds))
+	assert.True(t, decoded.Description.DropsSSTables())
+	assert.Equal(t, []uint64{2, 1}, decoded.Description.AllSSTableIds())
+}
+
+func TestNewSSTablesMovedEventEncodeAndDecode(t *testing.T) {
+	ssTablesMoved := NewSSTablesMoved([]uint64{10, 14}, 0, 1)
+	buffer, _ := ssTablesMoved.encode()
+
+	decoded, _ := decodeSSTablesMoved(buffer[1:])
+	assert.Equal(t, []uint64{10, 14}, decoded.SSTableIds)
+	assert.Equal(t, 0, decoded.FromLevel)
+	assert.Equal(t, 1, decoded.ToLevel)
+	assert.Equal(t, SSTablesMovedEventType, decoded.EventType())
+}
+
+func TestDecodeSSTablesMovedFollowedBySSTableFlushedEvents(t *testing.T) {
+	ssTablesMoved := NewSSTablesMoved([]uint64{10}, 2, 3)
+	ssTableFlushed := NewSSTableFlushed(20)
+
+	ssTablesMovedBuffer, _ := ssTablesMoved.encode()
+	ssTableFlushedBuffer, _ := ssTableFlushed.encode()
+
+	var buffer []byte
+	buffer = append(buffer, ssTablesMovedBuffer...)
+	buffer = append(buffer, ssTableFlushedBuffer...)
+
+	events := decodeEventsFrom(buffer)
+	assert.Equal(t, 2, len(events))
+
+	decoded := events[0].(*SSTablesMoved)
+	assert.Equal(t, []uint64{10}, decoded.SSTableIds)
+	assert.Equal(t, 2, decoded.FromLevel)
+	assert.Equal(t, 3, decoded.ToLevel)
+	assert.Equal(t, uint64(20), events[1].(*SSTableFlushed).SsTableId)
+}
@@ -19,7 +19,7 @@ type Manifest struct {
 
 // CreateNewOrRecoverFrom either creates a new Manifest or recovers from an existing manifest file.
 func CreateNewOrRecoverFrom(directoryPath string) (*Manifest, []Event, error) {
-	path := filepath.Join(directoryPath, "manifest")
+	path := FilePath(directoryPath)
 	if _, err := os.Stat(path); os.IsNotExist(err) {
 		_, err := os.Create(path)
 		if err != nil {
@@ -43,6 +43,21 @@ func CreateNewOrRecoverFrom(directoryPath string) (*Manifest, []Event, error) {
 	return manifest, events, nil
 }
 
+// ReadEventsFrom reads all the events from the existing manifest file in the directoryPath, without opening it for writes.
+// It is used to inspect the manifest of a backup (Refer to go_lsm_workshop.BackupEngine).
+func ReadEventsFrom(directoryPath string) ([]Event, error) {
+	buffer, err := os.ReadFile(FilePath(directoryPath))
+	if err != nil {
+		return nil, err
+	}
+	return decodeEventsFrom(buffer), nil
+}
+
+// FilePath returns the path of the manifest file in the directoryPath.
+func FilePath(directoryPath string) string {
+	return filepath.Join(directoryPath, "manifest")
+}
+
 // Add adds the event to the manifest file.
 func (manifest *Manifest) Add(event Event) error {
 	manifest.writeLock.Lock()
@@ -60,6 +75,14 @@ func (manifest *Manifest) Add(event Event) error {
 	return manifest.file.Sync()
 }
 
+// Close closes the Manifest file.
+func (manifest *Manifest) Close() error {
+	manifest.writeLock.Lock()
+	defer manifest.writeLock.Unlock()
+
+	return manifest.file.Close()
+}
+
 // attemptRecovery attempts recovery of events from the Manifest file.
 // This implementation reads the whole file and passes the byte slice to decodeEventsFrom() method.
 // This implementation does not perform truncation (or compaction) of Manifest file, which means if the system runs for some time,
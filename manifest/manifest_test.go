@@ -76,3 +76,24 @@ func TestRecoversAnExistingManifest(t *testing.T) {
 	assert.Equal(t, []uint64{20, 30}, events[3].(*CompactionDone).Description.UpperLevelSSTableIds)
 	assert.Equal(t, []uint64{50, 60}, events[3].(*CompactionDone).Description.LowerLevelSSTableIds)
 }
+
+func TestReadEventsFromAnExistingManifest(t *testing.T) {
+	manifestDirectoryPath := filepath.Join(".", "TestReadEventsFromAnExistingManifest")
+	assert.Nil(t, os.MkdirAll(manifestDirectoryPath, os.ModePerm))
+
+	manifest, _, err := CreateNewOrRecoverFrom(manifestDirectoryPath)
+	defer func() {
+		_ = os.RemoveAll(manifestDirectoryPath)
+	}()
+
+	assert.Nil(t, err)
+	assert.Nil(t, manifest.Add(NewStateSnapshot([]uint64{10}, [][]uint64{{20, 30}}, 15)))
+	assert.Nil(t, manifest.Add(NewMemtableCreated(40)))
+	assert.Nil(t, manifest.Close())
+
+	events, err := ReadEventsFrom(manifestDirectoryPath)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(events))
+	assert.Equal(t, [][]uint64{{20, 30}}, events[0].(*StateSnapshot).LevelSSTableIds)
+	assert.Equal(t, uint64(40), events[1].(*MemtableCreated).MemtableId)
+}
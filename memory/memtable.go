@@ -5,8 +5,12 @@ import (
 	"go-lsm-workshop/kv"
 	"go-lsm-workshop/log"
 	"go-lsm-workshop/memory/external"
+	"unsafe"
 )
 
+// maxNodeSizeInBytes is the maximum size of a Skiplist node, padded for pointer alignment.
+const maxNodeSizeInBytes = int64(external.MaxNodeSize) + int64(unsafe.Sizeof(uint64(0)))
+
 // Memtable is an in-memory data structure which holds versioned key kv.Key and kv.Value pairs.
 // Memtable uses [Skiplist](https://tech-lessons.in/en/blog/serializable_snapshot_isolation/#skiplist-and-mvcc) as its
 // data structure.
@@ -153,6 +157,14 @@ func (memtable *Memtable) DeleteWAL() {
 	}
 }
 
+// ArchiveWAL archives the WAL (/WAL file) in the log.WALArchive, instead of deleting it.
+func (memtable *Memtable) ArchiveWAL(archive *log.WALArchive) error {
+	if memtable.wal != nil {
+		return archive.Archive(memtable.wal)
+	}
+	return nil
+}
+
 // IsEmpty returns true if the Memtable is empty.
 func (memtable *Memtable) IsEmpty() bool {
 	return memtable.entries.Empty()
@@ -168,6 +180,19 @@ func (memtable *Memtable) CanFit(requiredSizeInBytes int64) bool {
 	return memtable.SizeInBytes()+requiredSizeInBytes+int64(external.MaxNodeSize) < memtable.memTableSizeInBytes
 }
 
+// MaxSizeInBytesOf returns the maximum size (in bytes) that a key/value pair occupies in a Memtable.
+// It includes the timestamp of the key, and the Skiplist node (padded for pointer alignment) holding the pair.
+func MaxSizeInBytesOf(rawKeySizeInBytes, valueSizeInBytes int) int64 {
+	return int64(rawKeySizeInBytes+kv.TimestampSize+valueSizeInBytes) + maxNodeSizeInBytes
+}
+
+// MaxSizeInBytesForEntries returns the maximum size (in bytes) available for the key/value pairs in an empty Memtable of the
+// given memTableSizeInBytes. An empty Memtable already has the head node of the Skiplist, and the offset 0 of the arena is
+// reserved.
+func MaxSizeInBytesForEntries(memTableSizeInBytes int64) int64 {
+	return memTableSizeInBytes - maxNodeSizeInBytes - 2
+}
+
 // Id returns the id of Memtable.
 func (memtable *Memtable) Id() uint64 {
 	return memtable.id
@@ -1,6 +1,7 @@
 package memory
 
 import (
+	"fmt"
 	"go-lsm-workshop/kv"
 	"testing"
 
@@ -203,3 +204,19 @@ func TestMemtableAllEntriesWithSameRawKeyWithDifferentTimestamps(t *testing.T) {
 		kv.NewStringValue("distributed"),
 	}, values)
 }
+
+func TestMemtableFitsTheEntriesWithinMaxSizeInBytesForEntries(t *testing.T) {
+	memTable := newMemtableWithoutWAL(1, testMemtableSize)
+
+	sizeInBytes := int64(0)
+	for count := 0; ; count++ {
+		key, value := fmt.Sprintf("key-%03d", count), fmt.Sprintf("value-%03d", count)
+		entrySizeInBytes := MaxSizeInBytesOf(len(key), len(value))
+		if sizeInBytes+entrySizeInBytes > MaxSizeInBytesForEntries(testMemtableSize) {
+			break
+		}
+		assert.NoError(t, memTable.Set(kv.NewStringKeyWithTimestamp(key, 5), kv.NewStringValue(value)))
+		sizeInBytes += entrySizeInBytes
+	}
+	assert.LessOrEqual(t, memTable.SizeInBytes(), int64(testMemtableSize))
+}
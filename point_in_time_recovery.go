@@ -0,0 +1,121 @@
+package go_lsm_workshop
+
+import (
+	"context"
+	"errors"
+	"go-lsm-workshop/log"
+	"go-lsm-workshop/manifest"
+	"go-lsm-workshop/state"
+	"go-lsm-workshop/table"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var PointInTimeRecoveryDirectoryExistsErr = errors.New("point-in-time recovery directory already exists")
+var PointInTimeRecoveryArchiveInWALDirectoriesErr = errors.New("WAL archive directory of the recovered Db is one of the WAL directories to replay")
+
+// PointInTimeRecoveryOptions represents the options for RecoverToPointInTime.
+// CheckpointDirectory is the directory of the base checkpoint (Refer to Db.Checkpoint), WALDirectoryPaths are the directories
+// which contain the WAL files written after the checkpoint (typically, the archive directory (Refer to state.WALArchiveOptions)
+// and the WAL directory of the source Db), and TargetCommitTimestamp is the commit-timestamp to recover to.
+type PointInTimeRecoveryOptions struct {
+	CheckpointDirectory   string
+	WALDirectoryPaths     []string
+	TargetCommitTimestamp uint64
+}
+
+// LatestCommitTimestamp returns the latest commit-timestamp of the Db, after waiting for all the commits till that
+// timestamp to be applied. It can be used as the target commit-timestamp of RecoverToPointInTime.
+// It returns ctx.Err() if the ctx is done before the wait is over.
+func (db *Db) LatestCommitTimestamp(ctx context.Context) (uint64, error) {
+	if db.stopped.Load() {
+		return 0, DbAlreadyStoppedErr
+	}
+	return db.oracle.LatestCommitTimestamp(ctx)
+}
+
+// RecoverToPointInTime recovers a Db in options.Path (which must not exist) to the target commit-timestamp.
+// It involves the following:
+// 1) The base checkpoint is restored in options.Path: the SSTables are hard-linked (or copied), the WAL files are copied,
+// and the manifest is copied last.
+// 2) The restored directory is opened as state.StorageState, and the WAL files are replayed up to the target commit-timestamp
+// (Refer to state.StorageState.ReplayWALs).
+// The recovered Db contains all the commits till the target commit-timestamp, and none after. If an archived WAL file
+// required for that is missing, it fails with state.MissingWALInReplayWindowErr.
+// options.Path is removed if the recovery fails, so that the recovery can be retried.
+// It returns PointInTimeRecoveryArchiveInWALDirectoriesErr if options.WALArchiveOptions.Path is one of the
+// recoveryOptions.WALDirectoryPaths, as the recovered Db would archive its WAL files in the directory being replayed.
+func RecoverToPointInTime(options state.StorageOptions, recoveryOptions PointInTimeRecoveryOptions) (_ *Db, err error) {
+	if _, err := os.Stat(options.Path); err == nil {
+		return nil, PointInTimeRecoveryDirectoryExistsErr
+	}
+	if len(options.WALArchiveOptions.Path) > 0 {
+		for _, walDirectoryPath := range recoveryOptions.WALDirectoryPaths {
+			if sameDirectory(options.WALArchiveOptions.Path, walDirectoryPath) {
+				return nil, PointInTimeRecoveryArchiveInWALDirectoriesErr
+			}
+		}
+	}
+	defer func() {
+		if err != nil {
+			_ = os.RemoveAll(options.Path)
+		}
+	}()
+	if err := restoreCheckpoint(recoveryOptions.CheckpointDirectory, options.Path); err != nil {
+		return nil, err
+	}
+	storageState, err := state.NewStorageStateWithOptions(options)
+	if err != nil {
+		return nil, err
+	}
+	if err := storageState.ReplayWALs(recoveryOptions.WALDirectoryPaths, recoveryOptions.TargetCommitTimestamp); err != nil {
+		storageState.Close()
+		return nil, err
+	}
+	return newDb(storageState), nil
+}
+
+// sameDirectory returns true if one and other are the paths of the same directory.
+func sameDirectory(one, other string) bool {
+	oneStat, oneErr := os.Stat(one)
+	otherStat, otherErr := os.Stat(other)
+	if oneErr == nil && otherErr == nil {
+		return os.SameFile(oneStat, otherStat)
+	}
+	oneAbsolute, oneErr := filepath.Abs(one)
+	otherAbsolute, otherErr := filepath.Abs(other)
+	return oneErr == nil && otherErr == nil && oneAbsolute == otherAbsolute
+}
+
+// restoreCheckpoint restores the checkpoint in checkpointDirectory to the directory.
+func restoreCheckpoint(checkpointDirectory string, directory string) error {
+	entries, err := os.ReadDir(checkpointDirectory)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".sst") {
+			continue
+		}
+		if err := table.LinkOrCopyFile(
+			filepath.Join(checkpointDirectory, entry.Name()),
+			filepath.Join(directory, entry.Name()),
+		); err != nil {
+			return err
+		}
+	}
+	checkpointWALPath, walPath := log.NewWALPath(checkpointDirectory), log.NewWALPath(directory)
+	if _, err := os.Stat(checkpointWALPath.DirectoryPath); err == nil {
+		walFilePaths, err := log.WALFilePathsIn(checkpointWALPath.DirectoryPath)
+		if err != nil {
+			return err
+		}
+		for _, walFilePath := range walFilePaths {
+			if err := table.CopyFile(walFilePath, filepath.Join(walPath.DirectoryPath, filepath.Base(walFilePath))); err != nil {
+				return err
+			}
+		}
+	}
+	return table.CopyFile(manifest.FilePath(checkpointDirectory), manifest.FilePath(directory))
+}
@@ -0,0 +1,58 @@
+package go_lsm_workshop
+
+import (
+	"math"
+	"math/rand/v2"
+	"time"
+)
+
+// RetryPolicy defines how Db.Update retries a Readwrite transaction which fails with txn.ConflictErr.
+// MaxAttempts is the maximum number of times the callback is run (including the first attempt).
+// A value of 0 is treated as 1.
+// InitialBackoff is the upper bound of the wait before the first retry, and it doubles after every retry
+// till it reaches MaxBackoff.
+// The actual wait before a retry is chosen randomly between 0 and the current upper bound (full-jitter).
+// Jitter spreads the retries of transactions conflicting on the same (hot) key(s), and avoids them conflicting again.
+type RetryPolicy struct {
+	MaxAttempts    uint
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+}
+
+// DefaultRetryPolicy returns a RetryPolicy with 5 attempts, 1 millisecond initial backoff and 100 milliseconds maximum backoff.
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:    5,
+		InitialBackoff: 1 * time.Millisecond,
+		MaxBackoff:     100 * time.Millisecond,
+	}
+}
+
+// maxAttempts returns the maximum number of attempts, it is at least 1.
+func (policy RetryPolicy) maxAttempts() uint {
+	return max(policy.MaxAttempts, 1)
+}
+
+// backoff returns the wait duration before the given retry. The first retry is denoted by retry = 1.
+// It uses exponential backoff with full-jitter: a random duration between 0 and min(MaxBackoff, InitialBackoff * 2^(retry-1)).
+// Without a MaxBackoff, the upper bound saturates just below math.MaxInt64 instead of overflowing.
+func (policy RetryPolicy) backoff(retry uint) time.Duration {
+	if policy.InitialBackoff <= 0 {
+		return 0
+	}
+	upperBound := policy.InitialBackoff
+	for attempt := uint(1); attempt < retry; attempt++ {
+		if policy.MaxBackoff > 0 && upperBound >= policy.MaxBackoff {
+			break
+		}
+		if upperBound > math.MaxInt64/2 {
+			upperBound = math.MaxInt64 - 1
+			break
+		}
+		upperBound = upperBound * 2
+	}
+	if policy.MaxBackoff > 0 && upperBound > policy.MaxBackoff {
+		upperBound = policy.MaxBackoff
+	}
+	return time.Duration(rand.Int64N(int64(upperBound) + 1))
+}
@@ -0,0 +1,97 @@
+package state
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"syscall"
+	"time"
+)
+
+// maxBackgroundRetryBackoff is the maximum wait before retrying a failed background operation.
+const maxBackgroundRetryBackoff = 5 * time.Second
+
+// BackgroundOperation identifies the background operation (flush or compaction) which failed.
+type BackgroundOperation string
+
+const (
+	FlushOperation      BackgroundOperation = "flush"
+	CompactionOperation BackgroundOperation = "compaction"
+)
+
+// BackgroundError represents an error in a background operation, classified as either retryable or Fatal (Refer to IsFatalError).
+// A retryable error is retried with backoff (Refer to BackgroundRetryBackoff), while a fatal error stops the background
+// operation, and puts the database in read-only mode.
+type BackgroundError struct {
+	Operation BackgroundOperation
+	Err       error
+	Fatal     bool
+}
+
+// BackgroundErrorHandler is invoked with the outcome of every background operation: a BackgroundError if the operation
+// failed, or nil if the operation succeeded.
+type BackgroundErrorHandler func(operation BackgroundOperation, backgroundError *BackgroundError)
+
+// NewBackgroundError creates a new instance of BackgroundError, classifying the err using IsFatalError.
+func NewBackgroundError(operation BackgroundOperation, err error) *BackgroundError {
+	return &BackgroundError{
+		Operation: operation,
+		Err:       err,
+		Fatal:     IsFatalError(err),
+	}
+}
+
+// Error returns the error message.
+func (backgroundError *BackgroundError) Error() string {
+	if backgroundError.Fatal {
+		return fmt.Sprintf("fatal error in %v: %v", backgroundError.Operation, backgroundError.Err)
+	}
+	return fmt.Sprintf("retryable error in %v: %v", backgroundError.Operation, backgroundError.Err)
+}
+
+// Unwrap returns the underlying error.
+func (backgroundError *BackgroundError) Unwrap() error {
+	return backgroundError.Err
+}
+
+// IsFatalError returns true if the err is a fatal I/O error, which is not expected to go away by retrying: an I/O error
+// reported by the device (EIO), a read-only file system (EROFS), no space left on the device (ENOSPC), or a permission
+// error. All the other errors are considered retryable.
+func IsFatalError(err error) bool {
+	return errors.Is(err, syscall.EIO) ||
+		errors.Is(err, syscall.EROFS) ||
+		errors.Is(err, syscall.ENOSPC) ||
+		errors.Is(err, os.ErrPermission)
+}
+
+// BackgroundRetryBackoff returns the wait before retrying a background operation which has failed the given number of
+// consecutive times. It doubles the interval for every failure, and is capped at maxBackgroundRetryBackoff (or the
+// interval, if the interval is larger).
+func BackgroundRetryBackoff(interval time.Duration, failures uint) time.Duration {
+	maxBackoff := max(interval, maxBackgroundRetryBackoff)
+	backoff := interval
+	for failure := uint(0); failure < failures && backoff < maxBackoff; failure++ {
+		backoff = backoff * 2
+	}
+	return min(backoff, maxBackoff)
+}
+
+// SetBackgroundErrorHandler sets the BackgroundErrorHandler, which is invoked with the outcome of the background operations.
+func (storageState *StorageState) SetBackgroundErrorHandler(handler BackgroundErrorHandler) {
+	storageState.backgroundErrorHandler.Store(&handler)
+}
+
+// ReportBackgroundOutcome reports the outcome of a background operation (err is nil if the operation succeeded) to the
+// BackgroundErrorHandler. It returns the classified BackgroundError, or nil if err is nil.
+func (storageState *StorageState) ReportBackgroundOutcome(operation BackgroundOperation, err error) *BackgroundError {
+	var backgroundError *BackgroundError
+	if err != nil {
+		backgroundError = NewBackgroundError(operation, err)
+		slog.Error(backgroundError.Error())
+	}
+	if handler := storageState.backgroundErrorHandler.Load(); handler != nil {
+		(*handler)(operation, backgroundError)
+	}
+	return backgroundError
+}
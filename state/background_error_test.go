@@ -0,0 +1,59 @@
+package state
+
+import (
+	"errors"
+	"go-lsm-workshop/test_utility"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClassifyFatalBackgroundErrors(t *testing.T) {
+	assert.True(t, IsFatalError(&os.PathError{Op: "write", Path: "1.sst", Err: syscall.EIO}))
+	assert.True(t, IsFatalError(&os.PathError{Op: "write", Path: "1.sst", Err: syscall.ENOSPC}))
+	assert.True(t, IsFatalError(&os.PathError{Op: "open", Path: "1.sst", Err: syscall.EROFS}))
+	assert.True(t, IsFatalError(os.ErrPermission))
+}
+
+func TestClassifyRetryableBackgroundErrors(t *testing.T) {
+	assert.False(t, IsFatalError(errors.New("transient")))
+	assert.False(t, IsFatalError(&os.PathError{Op: "open", Path: "1.sst", Err: syscall.ENOENT}))
+}
+
+func TestBackgroundRetryBackoff(t *testing.T) {
+	assert.Equal(t, 10*time.Millisecond, BackgroundRetryBackoff(10*time.Millisecond, 0))
+	assert.Equal(t, 20*time.Millisecond, BackgroundRetryBackoff(10*time.Millisecond, 1))
+	assert.Equal(t, 80*time.Millisecond, BackgroundRetryBackoff(10*time.Millisecond, 3))
+	assert.Equal(t, maxBackgroundRetryBackoff, BackgroundRetryBackoff(10*time.Millisecond, 100))
+	assert.Equal(t, 1*time.Minute, BackgroundRetryBackoff(1*time.Minute, 3))
+}
+
+func TestReportBackgroundOutcomeToTheHandler(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	storageState, _ := NewStorageState(rootPath)
+
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+		storageState.Close()
+	}()
+
+	var reportedOperations []BackgroundOperation
+	var reportedErrors []*BackgroundError
+	storageState.SetBackgroundErrorHandler(func(operation BackgroundOperation, backgroundError *BackgroundError) {
+		reportedOperations = append(reportedOperations, operation)
+		reportedErrors = append(reportedErrors, backgroundError)
+	})
+
+	fatalError := &os.PathError{Op: "write", Path: "1.sst", Err: syscall.EIO}
+	backgroundError := storageState.ReportBackgroundOutcome(FlushOperation, fatalError)
+	assert.True(t, backgroundError.Fatal)
+	assert.ErrorIs(t, backgroundError, syscall.EIO)
+
+	assert.Nil(t, storageState.ReportBackgroundOutcome(CompactionOperation, nil))
+
+	assert.Equal(t, []BackgroundOperation{FlushOperation, CompactionOperation}, reportedOperations)
+	assert.Equal(t, []*BackgroundError{backgroundError, nil}, reportedErrors)
+}
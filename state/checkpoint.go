@@ -0,0 +1,179 @@
+package state
+
+import (
+	"errors"
+	"go-lsm-workshop/log"
+	"go-lsm-workshop/manifest"
+	"go-lsm-workshop/memory"
+	"go-lsm-workshop/table"
+	"os"
+)
+
+var CheckpointDirectoryExistsErr = errors.New("checkpoint directory already exists")
+
+// Checkpoint is a consistent point-in-time view of the StorageState, which can be written to a directory.
+// The directory can then be opened as a database (Refer to NewStorageStateWithOptions), which yields exactly the view.
+// A Checkpoint pins all its table.SSTable(s) (by incrementing their references), so that the table.SSTableCleaner does not
+// remove them (after a compaction) before they are linked (or copied) to the checkpoint directory. Release must be invoked
+// to unpin the SSTables.
+type Checkpoint struct {
+	storageState        *StorageState
+	directory           string
+	l0SSTableIds        []uint64
+	levelSSTableIds     [][]uint64
+	memtableIds         []uint64
+	ssTables            []*table.SSTable
+	lastCommitTimestamp uint64
+}
+
+// BeginCheckpoint begins a Checkpoint in the given directory (which must not exist).
+// It involves the following:
+// 1) If flushMemtables is true, the current memtable is frozen, and all the immutable memtables are flushed to level0
+// SSTables. Else, the WAL of the current and the immutable memtables are copied to the checkpoint directory.
+// 2) The SSTable ids at level0 and other levels are collected, and the SSTables are pinned.
+// The caller must ensure that no commit is applied, and compaction does not run till BeginCheckpoint returns (Refer to
+// txn.Oracle.ExecuteAtCommitTimestamp). The SSTables are linked (or copied) later (Refer to WriteSSTablesAndManifest).
+// lastCommitTimestamp is the timestamp till which all the commits are applied, it is recorded in the manifest of the checkpoint.
+func (storageState *StorageState) BeginCheckpoint(directory string, lastCommitTimestamp uint64, flushMemtables bool) (*Checkpoint, error) {
+	if _, err := os.Stat(directory); err == nil {
+		return nil, CheckpointDirectoryExistsErr
+	}
+	if flushMemtables {
+		if err := storageState.flushAllMemtables(); err != nil {
+			return nil, err
+		}
+	}
+	if err := os.MkdirAll(directory, os.ModePerm); err != nil {
+		return nil, err
+	}
+
+	storageState.stateLock.RLock()
+	defer storageState.stateLock.RUnlock()
+
+	checkpoint := &Checkpoint{
+		storageState:        storageState,
+		directory:           directory,
+		l0SSTableIds:        append([]uint64{}, storageState.l0SSTableIds...),
+		levelSSTableIds:     make([][]uint64, 0, len(storageState.levels)),
+		lastCommitTimestamp: lastCommitTimestamp,
+	}
+	for _, ssTableId := range checkpoint.l0SSTableIds {
+		checkpoint.ssTables = append(checkpoint.ssTables, storageState.ssTables[ssTableId])
+	}
+	for _, level := range storageState.levels {
+		checkpoint.levelSSTableIds = append(checkpoint.levelSSTableIds, append([]uint64{}, level.SSTableIds...))
+		for _, ssTableId := range level.SSTableIds {
+			checkpoint.ssTables = append(checkpoint.ssTables, storageState.ssTables[ssTableId])
+		}
+	}
+	if !flushMemtables {
+		walPath := log.NewWALPath(directory)
+		memtables := make([]*memory.Memtable, 0, len(storageState.immutableMemtables)+1)
+		memtables = append(memtables, storageState.immutableMemtables...)
+		memtables = append(memtables, storageState.currentMemtable)
+		for _, memtable := range memtables {
+			if memtable.IsEmpty() {
+				continue
+			}
+			sourcePath, err := memtable.WalPath()
+			if err != nil {
+				return nil, err
+			}
+			if err := table.CopyFile(sourcePath, log.CreateWalPathFor(memtable.Id(), walPath.DirectoryPath)); err != nil {
+				return nil, err
+			}
+			checkpoint.memtableIds = append(checkpoint.memtableIds, memtable.Id())
+		}
+	}
+	table.IncrementReferenceFor(checkpoint.ssTables)
+	return checkpoint, nil
+}
+
+// WriteSSTablesAndManifest hard-links (or copies, if linking fails) all the SSTables of the Checkpoint to the checkpoint
+// directory, and writes the manifest (Refer to WriteManifest).
+// The manifest is written last, so a checkpoint directory without a manifest is incomplete.
+func (checkpoint *Checkpoint) WriteSSTablesAndManifest() error {
+	for _, ssTable := range checkpoint.ssTables {
+		if err := table.LinkOrCopyFile(
+			table.SSTableFilePath(ssTable.Id(), checkpoint.storageState.options.Path),
+			table.SSTableFilePath(ssTable.Id(), checkpoint.directory),
+		); err != nil {
+			return err
+		}
+	}
+	return checkpoint.WriteManifest()
+}
+
+// CopySSTablesTo copies (Refer to table.CopyFile) the SSTables of the Checkpoint to the given directory, skipping the
+// SSTables for which skip returns true. skip receives the id and the file path (in the storage directory) of the SSTable.
+// The SSTables are always copied (never hard-linked) and synced, so the directory does not share the files with the
+// storage (Refer to go_lsm_workshop.BackupEngine).
+func (checkpoint *Checkpoint) CopySSTablesTo(directory string, skip func(ssTableId uint64, filePath string) (bool, error)) error {
+	for _, ssTable := range checkpoint.ssTables {
+		filePath := table.SSTableFilePath(ssTable.Id(), checkpoint.storageState.options.Path)
+		skipSSTable, err := skip(ssTable.Id(), filePath)
+		if err != nil {
+			return err
+		}
+		if skipSSTable {
+			continue
+		}
+		if err := table.CopyFile(filePath, table.SSTableFilePath(ssTable.Id(), directory)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteManifest writes a compacted manifest in the checkpoint directory: a manifest.StateSnapshotEventType event followed
+// by manifest.MemtableCreatedEventType events for the memtables whose WAL is copied.
+func (checkpoint *Checkpoint) WriteManifest() error {
+	checkpointManifest, _, err := manifest.CreateNewOrRecoverFrom(checkpoint.directory)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = checkpointManifest.Close()
+	}()
+
+	if err := checkpointManifest.Add(manifest.NewStateSnapshot(checkpoint.l0SSTableIds, checkpoint.levelSSTableIds, checkpoint.lastCommitTimestamp)); err != nil {
+		return err
+	}
+	for _, memtableId := range checkpoint.memtableIds {
+		if err := checkpointManifest.Add(manifest.NewMemtableCreated(memtableId)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Release unpins the SSTables of the Checkpoint.
+func (checkpoint *Checkpoint) Release() {
+	table.DecrementReferenceFor(checkpoint.ssTables)
+}
+
+// flushAllMemtables freezes the current memtable (if it is not empty) and flushes all the immutable memtables to level0 SSTables.
+func (storageState *StorageState) flushAllMemtables() error {
+	storageState.flushLock.Lock()
+	defer storageState.flushLock.Unlock()
+
+	if !storageState.currentMemtable.IsEmpty() {
+		if err := storageState.freezeCurrentMemtable(); err != nil {
+			return err
+		}
+	}
+	for storageState.hasImmutableMemtables() {
+		if err := storageState.flushNextImmutableMemtable(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// hasImmutableMemtables returns true if there are immutable memtables.
+func (storageState *StorageState) hasImmutableMemtables() bool {
+	storageState.stateLock.RLock()
+	defer storageState.stateLock.RUnlock()
+
+	return len(storageState.immutableMemtables) > 0
+}
@@ -0,0 +1,66 @@
+package state
+
+import (
+	"go-lsm-workshop/kv"
+	"go-lsm-workshop/table"
+	"go-lsm-workshop/test_utility"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStorageStateBeginCheckpointPinsTheSSTables(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	storageState, _ := NewStorageState(rootPath)
+
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+		storageState.Close()
+	}()
+
+	ssTableBuilder := table.NewSSTableBuilder(4096)
+	ssTableBuilder.Add(kv.NewStringKeyWithTimestamp("consensus", 3), kv.NewStringValue("paxos"))
+	ssTable, err := ssTableBuilder.Build(100, rootPath)
+	assert.Nil(t, err)
+	storageState.SetSSTableAtLevel(ssTable, level1)
+
+	checkpoint, err := storageState.BeginCheckpoint(filepath.Join(rootPath, "checkpoint"), 5, true)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), ssTable.TotalReferences())
+
+	checkpoint.Release()
+	assert.Equal(t, int64(0), ssTable.TotalReferences())
+}
+
+func TestStorageStateCheckpointWithMemtableFlush(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	storageState, _ := NewStorageState(rootPath)
+
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+		storageState.Close()
+	}()
+
+	batch := kv.NewBatch()
+	_ = batch.Put([]byte("consensus"), []byte("raft"))
+	assert.Nil(t, storageState.Set(kv.NewTimestampedBatchFrom(*batch, 5)))
+
+	checkpointDirectory := filepath.Join(rootPath, "checkpoint")
+	checkpoint, err := storageState.BeginCheckpoint(checkpointDirectory, 6, true)
+	assert.Nil(t, err)
+	assert.False(t, storageState.HasImmutableMemtables())
+	assert.Equal(t, 1, storageState.TotalSSTablesAtLevel(level0))
+
+	assert.Nil(t, checkpoint.WriteSSTablesAndManifest())
+	checkpoint.Release()
+
+	checkpointStorageState, err := NewStorageState(checkpointDirectory)
+	assert.Nil(t, err)
+	defer checkpointStorageState.Close()
+
+	assert.Equal(t, uint64(6), checkpointStorageState.LastCommitTimestamp())
+	value, ok := checkpointStorageState.Get(kv.NewStringKeyWithTimestamp("consensus", 6))
+	assert.True(t, ok)
+	assert.Equal(t, kv.NewStringValue("raft"), value)
+}
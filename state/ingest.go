@@ -0,0 +1,213 @@
+package state
+
+import (
+	"errors"
+	"fmt"
+	"go-lsm-workshop/kv"
+	"go-lsm-workshop/manifest"
+	"go-lsm-workshop/memory"
+	"go-lsm-workshop/table"
+	"go-lsm-workshop/table/block"
+	"math"
+	"slices"
+)
+
+var NoSSTablesToIngestErr = errors.New("no SSTables to ingest")
+var EmptySSTableToIngestErr = errors.New("SSTable to ingest has no keys")
+var UnorderedKeysInSSTableToIngestErr = errors.New("keys in the SSTable to ingest are not in strictly increasing order")
+var OverlappingSSTablesToIngestErr = errors.New("SSTables to ingest have overlapping key ranges")
+var IngestionOverlapsMemtablesErr = errors.New("SSTables to ingest overlap with the keys in memtables, retry after the memtables are flushed")
+
+// IngestedSSTable represents an externally built SSTable rewritten for ingestion, along with its (raw) key range.
+type IngestedSSTable struct {
+	ssTable  *table.SSTable
+	keyRange kv.InclusiveKeyRange[kv.Key]
+}
+
+// IngestSSTables ingests the externally built SSTables (identified by filePaths), with all the keys at the given
+// commitTimestamp. The SSTables are expected to be built using table.SSTableBuilder, the timestamps of their keys are disregarded.
+// It involves the following:
+// 1) Every SSTable is rewritten in the database directory with a new id (from SSTableIdGenerator), and all of its keys get
+// the commitTimestamp. The keys must be in strictly increasing order (no two versions of the same key), else
+// UnorderedKeysInSSTableToIngestErr is returned. The external files are left untouched.
+// 2) The key ranges of the SSTables must not overlap with each other, else OverlappingSSTablesToIngestErr is returned.
+// 3) The manifest.SSTablesIngestedEventType event is recorded in manifest.Manifest, and the SSTables are placed at a level
+// (Refer to levelForIngestion), both under the stateLock. If the event can not be recorded, the SSTables are not placed
+// and the rewritten SSTables are removed.
+// The ingestion is also recorded in the log.WALArchive (if WAL archiving is enabled), before the SSTables are placed, so that
+// point-in-time recovery detects the ingestions it can not replay (Refer to ReplayWALs).
+// It returns the level at which the SSTables are placed.
+// The caller must ensure that all the commits before the commitTimestamp are applied, and no commit after the commitTimestamp
+// is applied before IngestSSTables returns (Refer to txn.Oracle.ExecuteAtCommitTimestamp). The caller must also ensure that
+// compaction does not run concurrently.
+func (storageState *StorageState) IngestSSTables(filePaths []string, commitTimestamp uint64) (int, error) {
+	if len(filePaths) == 0 {
+		return 0, NoSSTablesToIngestErr
+	}
+	var ingestedSSTables []IngestedSSTable
+	removeIngestedSSTables := func() {
+		for _, ingestedSSTable := range ingestedSSTables {
+			_ = ingestedSSTable.ssTable.Remove()
+		}
+	}
+	for _, filePath := range filePaths {
+		ingestedSSTable, err := storageState.rewriteForIngestion(filePath, commitTimestamp)
+		if err != nil {
+			removeIngestedSSTables()
+			return 0, err
+		}
+		ingestedSSTables = append(ingestedSSTables, ingestedSSTable)
+	}
+	slices.SortFunc(ingestedSSTables, func(one, other IngestedSSTable) int {
+		return one.keyRange.Start().CompareKeysWithDescendingTimestamp(other.keyRange.Start())
+	})
+	for index := 1; index < len(ingestedSSTables); index++ {
+		if !ingestedSSTables[index].keyRange.Start().IsRawKeyGreaterThan(ingestedSSTables[index-1].keyRange.End()) {
+			removeIngestedSSTables()
+			return 0, OverlappingSSTablesToIngestErr
+		}
+	}
+
+	if storageState.walArchive != nil {
+		if err := storageState.walArchive.RecordIngestion(commitTimestamp); err != nil {
+			removeIngestedSSTables()
+			return 0, err
+		}
+	}
+	level, err := storageState.placeIngestedSSTables(ingestedSSTables, commitTimestamp)
+	if err != nil {
+		if storageState.walArchive != nil {
+			storageState.walArchive.RemoveIngestion(commitTimestamp)
+		}
+		removeIngestedSSTables()
+		return 0, err
+	}
+	return level, nil
+}
+
+// rewriteForIngestion rewrites the SSTable at filePath in the database directory, with all the keys at the commitTimestamp.
+// It validates that the keys are in strictly increasing order.
+func (storageState *StorageState) rewriteForIngestion(filePath string, commitTimestamp uint64) (IngestedSSTable, error) {
+	externalSSTable, err := table.LoadFromFilePath(0, filePath, block.DefaultBlockSize)
+	if err != nil {
+		return IngestedSSTable{}, err
+	}
+	defer func() {
+		_ = externalSSTable.Close()
+	}()
+
+	iterator, err := externalSSTable.SeekToFirst()
+	if err != nil {
+		return IngestedSSTable{}, err
+	}
+	defer iterator.Close()
+
+	if !iterator.IsValid() {
+		return IngestedSSTable{}, fmt.Errorf("%w: %s", EmptySSTableToIngestErr, filePath)
+	}
+	ssTableBuilder := table.NewSSTableBuilderWithDefaultBlockSize()
+	startingKey := kv.NewKey(iterator.Key().RawBytes(), commitTimestamp)
+	endingKey := startingKey
+	for index := 0; iterator.IsValid(); index++ {
+		key := kv.NewKey(iterator.Key().RawBytes(), commitTimestamp)
+		if index > 0 && !key.IsRawKeyGreaterThan(endingKey) {
+			return IngestedSSTable{}, fmt.Errorf("%w: %s", UnorderedKeysInSSTableToIngestErr, filePath)
+		}
+		ssTableBuilder.Add(key, iterator.Value())
+		endingKey = key
+		if err := iterator.Next(); err != nil {
+			return IngestedSSTable{}, err
+		}
+	}
+	ssTable, err := ssTableBuilder.Build(storageState.idGenerator.NextId(), storageState.options.Path)
+	if err != nil {
+		return IngestedSSTable{}, err
+	}
+	return IngestedSSTable{
+		ssTable:  ssTable,
+		keyRange: kv.NewInclusiveKeyRange(startingKey, endingKey),
+	}, nil
+}
+
+// placeIngestedSSTables places the ingested SSTables at the level identified by levelForIngestion, under the stateLock.
+// It returns IngestionOverlapsMemtablesErr if any of the memtables contains a key in the range of the ingested SSTables.
+// The manifest.SSTablesIngestedEventType event is recorded before the SSTables are placed, so a failure to record the event
+// leaves the StorageState unchanged (and the caller removes the ingested SSTables).
+func (storageState *StorageState) placeIngestedSSTables(ingestedSSTables []IngestedSSTable, commitTimestamp uint64) (int, error) {
+	storageState.stateLock.Lock()
+	defer storageState.stateLock.Unlock()
+
+	for _, ingestedSSTable := range ingestedSSTables {
+		if storageState.memtablesOverlap(ingestedSSTable.keyRange) {
+			return 0, IngestionOverlapsMemtablesErr
+		}
+	}
+	level := storageState.levelForIngestion(ingestedSSTables)
+	ssTableIds := make([]uint64, 0, len(ingestedSSTables))
+	for _, ingestedSSTable := range ingestedSSTables {
+		ssTableIds = append(ssTableIds, ingestedSSTable.ssTable.Id())
+	}
+	if err := storageState.manifest.Add(manifest.NewSSTablesIngested(ssTableIds, level, commitTimestamp)); err != nil {
+		return 0, err
+	}
+	for _, ingestedSSTable := range ingestedSSTables {
+		storageState.ssTables[ingestedSSTable.ssTable.Id()] = ingestedSSTable.ssTable
+	}
+	if level == 0 {
+		storageState.l0SSTableIds = append(storageState.l0SSTableIds, ssTableIds...)
+	} else {
+		storageState.levels[level-1].appendSSTableIds(ssTableIds)
+	}
+	return level, nil
+}
+
+// levelForIngestion returns the lowest level at which the ingested SSTables can be placed.
+// Reads consult the memtables, level0 and then the levels 1..n in that order, and the ingested keys are the latest versions.
+// So, the ingested SSTables can be placed at level N only if none of the SSTables at level0 and the levels 1..N overlap with
+// the ingested SSTables (the levels below N may contain older versions of the ingested keys).
+// It falls back to level0 (as the latest level0 SSTables) if level0 or level1 overlaps.
+func (storageState *StorageState) levelForIngestion(ingestedSSTables []IngestedSSTable) int {
+	overlaps := func(ssTableIds []uint64) bool {
+		for _, ssTableId := range ssTableIds {
+			for _, ingestedSSTable := range ingestedSSTables {
+				if storageState.ssTables[ssTableId].ContainsInclusive(ingestedSSTable.keyRange) {
+					return true
+				}
+			}
+		}
+		return false
+	}
+	if overlaps(storageState.l0SSTableIds) {
+		return 0
+	}
+	level := 0
+	for _, existingLevel := range storageState.levels {
+		if overlaps(existingLevel.SSTableIds) {
+			break
+		}
+		level = existingLevel.LevelNumber
+	}
+	return level
+}
+
+// memtablesOverlap returns true if the current memtable or any of the immutable memtables contains (any version of) a key
+// in the keyRange.
+func (storageState *StorageState) memtablesOverlap(keyRange kv.InclusiveKeyRange[kv.Key]) bool {
+	versionedKeyRange := kv.NewInclusiveKeyRange(
+		kv.NewKey(keyRange.Start().RawBytes(), math.MaxUint64),
+		kv.NewKey(keyRange.End().RawBytes(), math.MaxUint64),
+	)
+	overlaps := func(iterator *memory.MemtableIterator) bool {
+		defer iterator.Close()
+		return iterator.IsValid()
+	}
+	if overlaps(storageState.currentMemtable.Scan(versionedKeyRange)) {
+		return true
+	}
+	for _, immutableMemtable := range storageState.immutableMemtables {
+		if overlaps(immutableMemtable.Scan(versionedKeyRange)) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,210 @@
+package state
+
+import (
+	"errors"
+	"go-lsm-workshop/kv"
+	"go-lsm-workshop/table"
+	"go-lsm-workshop/test_utility"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func buildExternalSSTable(t *testing.T, rootPath string, id uint64, keyValuePairs ...string) string {
+	externalPath := filepath.Join(rootPath, "external")
+	assert.Nil(t, os.MkdirAll(externalPath, os.ModePerm))
+
+	ssTableBuilder := table.NewSSTableBuilder(4096)
+	for index := 0; index < len(keyValuePairs); index += 2 {
+		ssTableBuilder.Add(kv.NewStringKeyWithTimestamp(keyValuePairs[index], 0), kv.NewStringValue(keyValuePairs[index+1]))
+	}
+	_, err := ssTableBuilder.Build(id, externalPath)
+	assert.Nil(t, err)
+	return table.SSTableFilePath(id, externalPath)
+}
+
+func TestStorageStateIngestSSTablesAtTheLowestLevelGivenNoOverlap(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	storageState, _ := NewStorageState(rootPath)
+
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+		storageState.Close()
+	}()
+
+	filePath := buildExternalSSTable(t, rootPath, 1, "consensus", "raft", "distributed", "etcd")
+	level, err := storageState.IngestSSTables([]string{filePath}, 10)
+	assert.Nil(t, err)
+	assert.Equal(t, totalLevels, level)
+	assert.Equal(t, 1, storageState.TotalSSTablesAtLevel(totalLevels))
+
+	value, ok := storageState.Get(kv.NewStringKeyWithTimestamp("consensus", 10))
+	assert.True(t, ok)
+	assert.Equal(t, kv.NewStringValue("raft"), value)
+
+	_, ok = storageState.Get(kv.NewStringKeyWithTimestamp("distributed", 9))
+	assert.False(t, ok)
+}
+
+func TestStorageStateIngestSSTablesAboveTheFirstOverlappingLevel(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	storageState, _ := NewStorageState(rootPath)
+
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+		storageState.Close()
+	}()
+
+	ssTableBuilder := table.NewSSTableBuilder(4096)
+	ssTableBuilder.Add(kv.NewStringKeyWithTimestamp("consensus", 3), kv.NewStringValue("paxos"))
+	ssTable, err := ssTableBuilder.Build(100, rootPath)
+	assert.Nil(t, err)
+	storageState.SetSSTableAtLevel(ssTable, level2)
+
+	filePath := buildExternalSSTable(t, rootPath, 1, "consensus", "raft", "distributed", "etcd")
+	level, err := storageState.IngestSSTables([]string{filePath}, 10)
+	assert.Nil(t, err)
+	assert.Equal(t, level1, level)
+
+	value, ok := storageState.Get(kv.NewStringKeyWithTimestamp("consensus", 10))
+	assert.True(t, ok)
+	assert.Equal(t, kv.NewStringValue("raft"), value)
+
+	value, ok = storageState.Get(kv.NewStringKeyWithTimestamp("consensus", 9))
+	assert.True(t, ok)
+	assert.Equal(t, kv.NewStringValue("paxos"), value)
+}
+
+func TestStorageStateIngestSSTablesAtLevel0GivenLevel1Overlaps(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	storageState, _ := NewStorageState(rootPath)
+
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+		storageState.Close()
+	}()
+
+	ssTableBuilder := table.NewSSTableBuilder(4096)
+	ssTableBuilder.Add(kv.NewStringKeyWithTimestamp("distributed", 3), kv.NewStringValue("TiKV"))
+	ssTable, err := ssTableBuilder.Build(100, rootPath)
+	assert.Nil(t, err)
+	storageState.SetSSTableAtLevel(ssTable, level1)
+
+	filePath := buildExternalSSTable(t, rootPath, 1, "consensus", "raft", "etcd", "bbolt")
+	level, err := storageState.IngestSSTables([]string{filePath}, 10)
+	assert.Nil(t, err)
+	assert.Equal(t, level0, level)
+	assert.Equal(t, 1, storageState.TotalSSTablesAtLevel(level0))
+
+	value, ok := storageState.Get(kv.NewStringKeyWithTimestamp("etcd", 10))
+	assert.True(t, ok)
+	assert.Equal(t, kv.NewStringValue("bbolt"), value)
+}
+
+func TestStorageStateIngestSSTablesWithUnorderedKeys(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	storageState, _ := NewStorageState(rootPath)
+
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+		storageState.Close()
+	}()
+
+	externalPath := filepath.Join(rootPath, "external")
+	assert.Nil(t, os.MkdirAll(externalPath, os.ModePerm))
+
+	ssTableBuilder := table.NewSSTableBuilder(4096)
+	ssTableBuilder.Add(kv.NewStringKeyWithTimestamp("consensus", 2), kv.NewStringValue("raft"))
+	ssTableBuilder.Add(kv.NewStringKeyWithTimestamp("consensus", 1), kv.NewStringValue("paxos"))
+	_, err := ssTableBuilder.Build(1, externalPath)
+	assert.Nil(t, err)
+
+	_, err = storageState.IngestSSTables([]string{table.SSTableFilePath(1, externalPath)}, 10)
+	assert.True(t, errors.Is(err, UnorderedKeysInSSTableToIngestErr))
+	assert.Equal(t, 0, storageState.TotalSSTablesAtLevel(level0))
+}
+
+func TestStorageStateIngestOverlappingSSTables(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	storageState, _ := NewStorageState(rootPath)
+
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+		storageState.Close()
+	}()
+
+	filePath := buildExternalSSTable(t, rootPath, 1, "consensus", "raft", "etcd", "bbolt")
+	otherFilePath := buildExternalSSTable(t, rootPath, 2, "distributed", "TiKV")
+
+	_, err := storageState.IngestSSTables([]string{filePath, otherFilePath}, 10)
+	assert.Equal(t, OverlappingSSTablesToIngestErr, err)
+}
+
+func TestStorageStateIngestSSTablesOverlappingMemtables(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	storageState, _ := NewStorageState(rootPath)
+
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+		storageState.Close()
+	}()
+
+	batch := kv.NewBatch()
+	_ = batch.Put([]byte("distributed"), []byte("TiKV"))
+	assert.Nil(t, storageState.Set(kv.NewTimestampedBatchFrom(*batch, 5)))
+
+	filePath := buildExternalSSTable(t, rootPath, 1, "consensus", "raft", "etcd", "bbolt")
+	_, err := storageState.IngestSSTables([]string{filePath}, 10)
+	assert.Equal(t, IngestionOverlapsMemtablesErr, err)
+}
+
+func TestStorageStateDoesNotPlaceIngestedSSTablesGivenTheManifestEventCanNotBeRecorded(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	storageState, _ := NewStorageState(rootPath)
+
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+		storageState.Close()
+	}()
+
+	filePath := buildExternalSSTable(t, rootPath, 1, "consensus", "raft")
+	assert.Nil(t, storageState.manifest.Close())
+
+	_, err := storageState.IngestSSTables([]string{filePath}, 10)
+	assert.Error(t, err)
+	for level := 0; level <= totalLevels; level++ {
+		assert.Equal(t, 0, storageState.TotalSSTablesAtLevel(level))
+	}
+	_, ok := storageState.Get(kv.NewStringKeyWithTimestamp("consensus", 10))
+	assert.False(t, ok)
+
+	rewrittenFilePaths, _ := filepath.Glob(filepath.Join(rootPath, "*.sst"))
+	assert.Empty(t, rewrittenFilePaths)
+}
+
+func TestStorageStateRecoversIngestedSSTables(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	storageState, _ := NewStorageState(rootPath)
+
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+	}()
+
+	filePath := buildExternalSSTable(t, rootPath, 1, "consensus", "raft")
+	otherFilePath := buildExternalSSTable(t, rootPath, 2, "distributed", "TiKV")
+	_, err := storageState.IngestSSTables([]string{filePath, otherFilePath}, 10)
+	assert.Nil(t, err)
+	storageState.Close()
+
+	loadedStorageState, _ := NewStorageState(rootPath)
+	defer loadedStorageState.Close()
+
+	assert.Equal(t, 2, loadedStorageState.TotalSSTablesAtLevel(totalLevels))
+	assert.Equal(t, uint64(10), loadedStorageState.LastCommitTimestamp())
+
+	value, ok := loadedStorageState.Get(kv.NewStringKeyWithTimestamp("distributed", 10))
+	assert.True(t, ok)
+	assert.Equal(t, kv.NewStringValue("TiKV"), value)
+}
@@ -1,5 +1,7 @@
 package state
 
+import "slices"
+
 const totalLevels = 6
 
 // Level represents a level in LSM.
@@ -18,3 +20,16 @@ func (level *Level) clearSSTableIds() {
 func (level *Level) appendSSTableIds(ssTableIds []uint64) {
 	level.SSTableIds = append(level.SSTableIds, ssTableIds...)
 }
+
+// removeSSTableIds removes the given ssTableIds from the existing ssTableIds.
+// It does not modify the existing ssTableIds in place, because they may be shared (for example, with a compaction description
+// which is yet to be recorded in the manifest).
+func (level *Level) removeSSTableIds(ssTableIds []uint64) {
+	remainingSSTableIds := make([]uint64, 0, len(level.SSTableIds))
+	for _, ssTableId := range level.SSTableIds {
+		if !slices.Contains(ssTableIds, ssTableId) {
+			remainingSSTableIds = append(remainingSSTableIds, ssTableId)
+		}
+	}
+	level.SSTableIds = remainingSSTableIds
+}
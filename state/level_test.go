@@ -18,3 +18,20 @@ func TestAppendSStableIds(t *testing.T) {
 
 	assert.Equal(t, []uint64{1, 2, 3, 4, 5}, level.SSTableIds)
 }
+
+func TestRemoveSStableIds(t *testing.T) {
+	level := &Level{LevelNumber: 1, SSTableIds: []uint64{1, 2, 3, 4}}
+	level.removeSSTableIds([]uint64{2, 4, 5})
+
+	assert.Equal(t, []uint64{1, 3}, level.SSTableIds)
+}
+
+func TestRemoveAllSStableIdsWithoutModifyingTheSharedSSTableIds(t *testing.T) {
+	level := &Level{LevelNumber: 1, SSTableIds: []uint64{1, 2, 3}}
+	sharedSSTableIds := level.SSTableIds
+	level.removeSSTableIds(sharedSSTableIds)
+	level.appendSSTableIds([]uint64{4})
+
+	assert.Equal(t, []uint64{4}, level.SSTableIds)
+	assert.Equal(t, []uint64{1, 2, 3}, sharedSSTableIds)
+}
@@ -0,0 +1,122 @@
+package state
+
+import (
+	"errors"
+	"fmt"
+	"go-lsm-workshop/kv"
+	"go-lsm-workshop/log"
+)
+
+var TargetCommitTimestampBeforeLastCommitTimestampErr = errors.New("target commit-timestamp is before the last commit-timestamp of the storage state")
+var IngestionInReplayWindowErr = errors.New("SSTables were ingested between the last commit-timestamp of the storage state and the target commit-timestamp, ingestions can not be replayed from WAL")
+var MissingWALInReplayWindowErr = errors.New("an archived WAL file with the entries between the last commit-timestamp of the storage state and the target commit-timestamp is missing")
+
+// ReplayWALs replays the WAL files in walDirectoryPaths (typically, the log.WALArchive directory and the WAL directory of the
+// source database), to recover the StorageState to the targetCommitTimestamp.
+// It involves the following:
+// 1) All the WAL files are collected and ordered by their (memtable) ids, which is the order in which they were written.
+// 2) The entries of a commit are contiguous in a WAL file, so the consecutive entries with the same commit-timestamp are
+// grouped in a kv.TimestampedBatch and applied using Set.
+// 3) Only the entries with LastCommitTimestamp < commit-timestamp <= targetCommitTimestamp are applied, so the entries already
+// present in the StorageState (from the base checkpoint) and the entries present in more than one WAL file are skipped.
+// The lastCommitTimestamp is updated to the commit-timestamp of the last applied batch.
+// It returns TargetCommitTimestampBeforeLastCommitTimestampErr if the targetCommitTimestamp is before the LastCommitTimestamp.
+// The ingested SSTables are not written to any WAL, so it returns IngestionInReplayWindowErr (without replaying anything) if
+// an ingestion recorded in walDirectoryPaths (Refer to log.WALArchive.RecordIngestion) has
+// LastCommitTimestamp < commit-timestamp <= targetCommitTimestamp.
+// It also returns MissingWALInReplayWindowErr (without replaying anything) if a WAL file recorded as archived in
+// walDirectoryPaths (Refer to log.ArchivedWALsIn), with entries in the same window, is not present in any of the
+// walDirectoryPaths (for example, removed by the retention limits of log.WALArchive).
+func (storageState *StorageState) ReplayWALs(walDirectoryPaths []string, targetCommitTimestamp uint64) error {
+	if targetCommitTimestamp < storageState.lastCommitTimestamp {
+		return TargetCommitTimestampBeforeLastCommitTimestampErr
+	}
+	ingestionCommitTimestamps, err := log.IngestionCommitTimestampsIn(walDirectoryPaths...)
+	if err != nil {
+		return err
+	}
+	for _, ingestionCommitTimestamp := range ingestionCommitTimestamps {
+		if ingestionCommitTimestamp > storageState.lastCommitTimestamp && ingestionCommitTimestamp <= targetCommitTimestamp {
+			return fmt.Errorf("%w: ingestion at %v", IngestionInReplayWindowErr, ingestionCommitTimestamp)
+		}
+	}
+	walFilePaths, err := log.WALFilePathsIn(walDirectoryPaths...)
+	if err != nil {
+		return err
+	}
+	if err := storageState.ensureNoMissingWALs(walDirectoryPaths, walFilePaths, targetCommitTimestamp); err != nil {
+		return err
+	}
+
+	batch, batchTimestamp := kv.NewBatch(), uint64(0)
+	applyBatch := func() error {
+		if batch.IsEmpty() {
+			return nil
+		}
+		if err := storageState.Set(kv.NewTimestampedBatchFrom(*batch, batchTimestamp)); err != nil {
+			return err
+		}
+		storageState.lastCommitTimestamp = batchTimestamp
+		batch = kv.NewBatch()
+		return nil
+	}
+	for _, walFilePath := range walFilePaths {
+		var applyErr error
+		wal, err := log.Recover(walFilePath, func(key kv.Key, value kv.Value) {
+			if applyErr != nil {
+				return
+			}
+			timestamp := key.Timestamp()
+			if timestamp <= storageState.lastCommitTimestamp || timestamp > targetCommitTimestamp {
+				return
+			}
+			if timestamp != batchTimestamp {
+				applyErr = applyBatch()
+				batchTimestamp = timestamp
+			}
+			if value.IsEmpty() {
+				batch.Delete(key.RawBytes())
+			} else {
+				_ = batch.Put(key.RawBytes(), value.Bytes())
+			}
+		})
+		if err != nil {
+			return err
+		}
+		wal.Close()
+		if applyErr != nil {
+			return applyErr
+		}
+	}
+	return applyBatch()
+}
+
+// ensureNoMissingWALs returns MissingWALInReplayWindowErr if a WAL file recorded as archived in walDirectoryPaths is not
+// one of the walFilePaths, and has entries with LastCommitTimestamp < commit-timestamp <= targetCommitTimestamp.
+func (storageState *StorageState) ensureNoMissingWALs(walDirectoryPaths []string, walFilePaths []string, targetCommitTimestamp uint64) error {
+	archivedWALs, err := log.ArchivedWALsIn(walDirectoryPaths...)
+	if err != nil {
+		return err
+	}
+	walIds := make(map[uint64]struct{})
+	for _, walFilePath := range walFilePaths {
+		if walId, ok := log.WALIdOf(walFilePath); ok {
+			walIds[walId] = struct{}{}
+		}
+	}
+	for _, archivedWAL := range archivedWALs {
+		if _, ok := walIds[archivedWAL.Id]; ok {
+			continue
+		}
+		if archivedWAL.LastCommitTimestamp > storageState.lastCommitTimestamp && archivedWAL.FirstCommitTimestamp <= targetCommitTimestamp {
+			return fmt.Errorf(
+				"%w: WAL %v with commit-timestamps %v to %v",
+				MissingWALInReplayWindowErr,
+				archivedWAL.Id,
+				archivedWAL.FirstCommitTimestamp,
+				archivedWAL.LastCommitTimestamp,
+			)
+		}
+	}
+	return nil
+}
@@ -11,11 +11,18 @@ var NoStorageStateChanges = StorageStateChangeEvent{anyChanges: false}
 
 // StorageStateChangeEvent represents a state change event for StorageState.
 // It is generated after compaction runs, and it compacts table.SSTable files from adjacent levels.
+// The compaction is described either by meta.SimpleLeveledCompactionDescription (between two adjacent levels), or by
+// meta.SortedRunsCompactionDescription (between any number of sorted runs).
+// A trivial move (Refer to NewSSTablesMovedStorageStateChangeEvent) moves the upper level SSTables to the lower level
+// without rewriting them.
 type StorageStateChangeEvent struct {
-	NewSSTables   []*table.SSTable
-	NewSSTableIds []uint64
-	description   meta.SimpleLeveledCompactionDescription
-	anyChanges    bool
+	NewSSTables           []*table.SSTable
+	NewSSTableIds         []uint64
+	description           meta.SimpleLeveledCompactionDescription
+	sortedRunsDescription meta.SortedRunsCompactionDescription
+	ofSortedRuns          bool
+	moved                 bool
+	anyChanges            bool
 }
 
 // NewStorageStateChangeEvent creates a new instance of StorageStateChangeEvent.
@@ -34,20 +41,68 @@ func NewStorageStateChangeEvent(newSSTables []*table.SSTable, description meta.S
 
 // NewStorageStateChangeEventByOpeningSSTables creates a new instance of StorageStateChangeEvent, by opening the newSSTableIds.
 func NewStorageStateChangeEventByOpeningSSTables(newSSTableIds []uint64, description meta.SimpleLeveledCompactionDescription, rootPath string) (StorageStateChangeEvent, error) {
+	newSSTables, err := openSSTables(newSSTableIds, rootPath)
+	if err != nil {
+		return NoStorageStateChanges, err
+	}
+	return StorageStateChangeEvent{
+		NewSSTables:   newSSTables,
+		NewSSTableIds: newSSTableIds,
+		description:   description,
+		anyChanges:    true,
+	}, nil
+}
+
+// NewSortedRunsStorageStateChangeEvent creates a new instance of StorageStateChangeEvent for the compaction of sorted runs.
+func NewSortedRunsStorageStateChangeEvent(newSSTables []*table.SSTable, description meta.SortedRunsCompactionDescription) StorageStateChangeEvent {
+	event := NewStorageStateChangeEvent(newSSTables, meta.NothingToCompactDescription)
+	event.sortedRunsDescription = description
+	event.ofSortedRuns = true
+	return event
+}
+
+// NewSortedRunsStorageStateChangeEventByOpeningSSTables creates a new instance of StorageStateChangeEvent for the compaction
+// of sorted runs, by opening the newSSTableIds.
+// It is used while replaying the manifest, where a new table.SSTable may have been compacted (and deleted) by a later
+// compaction. So, only the existing table.SSTable files are opened, and all the newSSTableIds are retained in the event.
+func NewSortedRunsStorageStateChangeEventByOpeningSSTables(newSSTableIds []uint64, description meta.SortedRunsCompactionDescription, rootPath string) StorageStateChangeEvent {
 	newSSTables := make([]*table.SSTable, 0, len(newSSTableIds))
 	for _, ssTableId := range newSSTableIds {
 		ssTable, err := table.Load(ssTableId, rootPath, block.DefaultBlockSize)
-		if err != nil {
-			return NoStorageStateChanges, err
+		if err == nil {
+			newSSTables = append(newSSTables, ssTable)
 		}
-		newSSTables = append(newSSTables, ssTable)
 	}
+	event := NewSortedRunsStorageStateChangeEvent(newSSTables, description)
+	event.NewSSTableIds = newSSTableIds
+	return event
+}
+
+// NewSSTablesMovedStorageStateChangeEvent creates a new instance of StorageStateChangeEvent for a trivial move: the
+// UpperLevelSSTableIds of the description are moved to the lower level as is, because they do not overlap with any
+// SSTable of the lower level. The description must not have any LowerLevelSSTableIds.
+// The moved SSTables are neither compacted nor removed, so the ssTables may be nil (for example, while replaying the manifest).
+func NewSSTablesMovedStorageStateChangeEvent(ssTables []*table.SSTable, description meta.SimpleLeveledCompactionDescription) StorageStateChangeEvent {
 	return StorageStateChangeEvent{
-		NewSSTables:   newSSTables,
-		NewSSTableIds: newSSTableIds,
+		NewSSTables:   ssTables,
+		NewSSTableIds: description.UpperLevelSSTableIds,
 		description:   description,
+		moved:         true,
 		anyChanges:    true,
-	}, nil
+	}
+}
+
+// openSSTables opens the SSTables identified by ssTableIds.
+func openSSTables(ssTableIds []uint64, rootPath string) ([]*table.SSTable, error) {
+	ssTables := make([]*table.SSTable, 0, len(ssTableIds))
+	for _, ssTableId := range ssTableIds {
+		ssTable, err := table.Load(ssTableId, rootPath, block.DefaultBlockSize)
+		if err != nil {
+			return nil, err
+		}
+		ssTables = append(ssTables, ssTable)
+	}
+	return ssTables, nil
 }
 
 // CompactionUpperLevel returns the upper level present in meta.SimpleLeveledCompactionDescription.
@@ -75,6 +130,17 @@ func (event StorageStateChangeEvent) CompactionDescription() meta.SimpleLeveledC
 	return event.description
 }
 
+// SortedRunsCompactionDescription returns the instance of meta.SortedRunsCompactionDescription, and true if the event
+// is generated by the compaction of sorted runs.
+func (event StorageStateChangeEvent) SortedRunsCompactionDescription() (meta.SortedRunsCompactionDescription, bool) {
+	return event.sortedRunsDescription, event.ofSortedRuns
+}
+
+// MovesSSTables returns true if the event is a trivial move of the upper level SSTables to the lower level.
+func (event StorageStateChangeEvent) MovesSSTables() bool {
+	return event.moved
+}
+
 // MaxSSTableId returns the max SSTableId from NewSSTableIds.
 func (event StorageStateChangeEvent) MaxSSTableId() uint64 {
 	return slices.Max(event.NewSSTableIds)
@@ -2,6 +2,7 @@ package state
 
 import (
 	"fmt"
+	"go-lsm-workshop/compact/meta"
 	"go-lsm-workshop/iterator"
 	"go-lsm-workshop/kv"
 	"go-lsm-workshop/log"
@@ -10,17 +11,71 @@ import (
 	"go-lsm-workshop/table"
 	"go-lsm-workshop/table/block"
 	"log/slog"
+	"maps"
 	"os"
+	"slices"
 	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
-// CompactionOptions represents a combination of SimpleLeveledCompactionOptions and
+// CompactionStyle identifies the built-in strategy used by the compaction goroutine to pick the table.SSTable files to compact.
+type CompactionStyle uint8
+
+const (
+	SimpleLeveledCompactionStrategy CompactionStyle = iota
+	LeveledCompactionStrategy
+	TieredCompactionStrategy
+	FIFOCompactionStrategy
+)
+
+// CompactionStrategy represents a pluggable compaction strategy, which picks the table.SSTable files to compact.
+// A strategy describes a compaction generically with meta.SortedRunsCompactionDescription: the input table.SSTable files
+// per level (as meta.SortedRun), and the output level. The compaction of such a description is applied by StorageState
+// (Refer to apply) and recorded in the manifest (Refer to manifest.SortedRunsCompacted), independent of the strategy.
+// CompactionDescription returns false if there is nothing to compact. MaxLevels returns the number of levels (excluding
+// level0) used by the strategy.
+type CompactionStrategy interface {
+	CompactionDescription(snapshot StorageStateSnapshot) (meta.SortedRunsCompactionDescription, bool)
+	MaxLevels() uint
+}
+
+// CompactionOptions represents a combination of the compaction Strategy, its options and
 // the duration at which compaction goroutine should run.
+// StrategyOptions is used with SimpleLeveledCompactionStrategy (the default), LeveledStrategyOptions is used with
+// LeveledCompactionStrategy, TieredStrategyOptions is used with TieredCompactionStrategy, and FIFOStrategyOptions is used
+// with FIFOCompactionStrategy.
+// CustomStrategy registers a custom CompactionStrategy, and it takes precedence over the Strategy (if set).
+// MaxSubcompactions is the maximum number of key-range shards (subcompactions) which a compaction is split into, and which
+// run concurrently (Refer to compact.Compaction). Zero or one means that a compaction runs on a single goroutine.
 type CompactionOptions struct {
-	StrategyOptions SimpleLeveledCompactionOptions
-	Duration        time.Duration
+	Strategy               CompactionStyle
+	StrategyOptions        SimpleLeveledCompactionOptions
+	LeveledStrategyOptions LeveledCompactionOptions
+	TieredStrategyOptions  TieredCompactionOptions
+	FIFOStrategyOptions    FIFOCompactionOptions
+	CustomStrategy         CompactionStrategy
+	MaxSubcompactions      uint
+	Duration               time.Duration
+}
+
+// MaxLevels returns the maximum number of levels (excluding level0) of the configured compaction Strategy (or the
+// CustomStrategy).
+func (options CompactionOptions) MaxLevels() uint {
+	if options.CustomStrategy != nil {
+		return options.CustomStrategy.MaxLevels()
+	}
+	switch options.Strategy {
+	case LeveledCompactionStrategy:
+		return options.LeveledStrategyOptions.MaxLevels
+	case TieredCompactionStrategy:
+		return options.TieredStrategyOptions.MaxLevels
+	case FIFOCompactionStrategy:
+		return 0
+	default:
+		return options.StrategyOptions.MaxLevels
+	}
 }
 
 // SimpleLeveledCompactionOptions represents the configurable options for simple-leveled compaction.
@@ -31,7 +86,106 @@ type SimpleLeveledCompactionOptions struct {
 	Level0FilesCompactionTrigger    uint
 }
 
+// LeveledCompactionOptions represents the configurable options for size-based leveled compaction.
+// Read more about the logic behind leveled compaction in compact.LeveledCompaction.
+// BaseLevelSizeInBytes is the target size of the base level (the level into which level0 is compacted), and every level
+// below the base level has a target size LevelSizeMultiplier times the level above.
+type LeveledCompactionOptions struct {
+	Level0FilesCompactionTrigger uint
+	BaseLevelSizeInBytes         int64
+	LevelSizeMultiplier          uint
+	MaxLevels                    uint
+}
+
+// TargetSizes returns the base level and the target size of every level (index 0 denotes level0, which has no target size),
+// given the total size of the table.SSTable files at every level (index 0 denotes level0).
+// The target size of the last level is its actual size (but at least BaseLevelSizeInBytes), and the target size of every
+// level above is the target size of the level below divided by LevelSizeMultiplier, up to the base level. The levels above
+// the base level have a zero target size.
+func (options LeveledCompactionOptions) TargetSizes(levelSizes []int64) (int, []int64) {
+	maxLevels := int(options.MaxLevels)
+	multiplier := int64(max(options.LevelSizeMultiplier, 1))
+	targetSizes := make([]int64, maxLevels+1)
+
+	baseLevel := maxLevels
+	targetSizes[baseLevel] = max(levelSizes[maxLevels], options.BaseLevelSizeInBytes)
+	for baseLevel > 1 && targetSizes[baseLevel] > options.BaseLevelSizeInBytes {
+		targetSizes[baseLevel-1] = targetSizes[baseLevel] / multiplier
+		baseLevel--
+	}
+	return baseLevel, targetSizes
+}
+
+// TieredCompactionOptions represents the configurable options for size-tiered (universal) compaction.
+// Read more about the logic behind tiered compaction in compact.TieredCompaction.
+// MaxLevels is the number of levels available to place the sorted runs (other than the level0 SSTables).
+type TieredCompactionOptions struct {
+	SortedRunsCompactionTrigger uint
+	MaxSizeAmplificationPercent uint
+	SizeRatioPercent            uint
+	MinMergeWidth               uint
+	MaxLevels                   uint
+}
+
+// FIFOCompactionOptions represents the configurable options for FIFO compaction, which keeps all the SSTables at level0.
+// Read more about the logic behind FIFO compaction in compact.FIFOCompaction.
+// MaxTotalSizeInBytes limits the total size of the SSTables, and MaxAge limits the age of the SSTables, zero means no limit.
+type FIFOCompactionOptions struct {
+	MaxTotalSizeInBytes int64
+	MaxAge              time.Duration
+}
+
+// CompactionFilterDecision represents the decision of a CompactionFilter for a key/value pair.
+type CompactionFilterDecision uint8
+
+const (
+	CompactionFilterKeep CompactionFilterDecision = iota
+	CompactionFilterDrop
+	CompactionFilterChangeValue
+)
+
+// CompactionFilter represents a user-defined hook to purge or rewrite the key/value pairs during compaction, such as
+// stale schema versions or soft-deleted records.
+// Filter is called for every key/value pair which is written to the new table.SSTable files, along with the level of the
+// new table.SSTable files. It returns CompactionFilterKeep to keep the value, CompactionFilterDrop to drop the key (and its
+// older versions), or CompactionFilterChangeValue along with the new value to replace the value.
+// Filter is never called for deleted keys, or for the versions which are still visible to the reads with read-timestamp
+// above txn.Oracle's MaxBeginTimestamp (Refer to compact.Compaction).
+// Filter may be called concurrently if a compaction is split into subcompactions (Refer to CompactionOptions).
+type CompactionFilter interface {
+	Filter(key kv.Key, value kv.Value, level int) (CompactionFilterDecision, kv.Value)
+}
+
+// WALArchiveOptions represents the configurable options for archiving the WAL files of the flushed memtables, which are
+// needed for point-in-time recovery. Archiving is disabled if the Path is empty.
+// MaxFiles and MaxAge limit the retention of the archived WAL files (Refer to log.WALArchive), zero means no limit.
+type WALArchiveOptions struct {
+	Path     string
+	MaxFiles uint
+	MaxAge   time.Duration
+}
+
+// WriteStallOptions represents the configurable thresholds for stalling the writes (Refer to WriteStallCondition), when the
+// flush and the compaction fall behind the writes.
+// The writes are slowed down (each commit is delayed by SlowdownDelay) once any slowdown threshold is reached, and stopped
+// (the commits are blocked) once any stop threshold is reached. The thresholds are on the number of immutable memtables,
+// the number of level0 SSTables and the pending compaction bytes, zero means no threshold.
+// StopImmutableMemtables must be greater than MaximumMemtables, and StopLevel0SSTables must be greater than the level0
+// compaction trigger, else the writes would stop before the flush (or the compaction) is triggered.
+type WriteStallOptions struct {
+	SlowdownImmutableMemtables     uint
+	StopImmutableMemtables         uint
+	SlowdownLevel0SSTables         uint
+	StopLevel0SSTables             uint
+	SlowdownPendingCompactionBytes int64
+	StopPendingCompactionBytes     int64
+	SlowdownDelay                  time.Duration
+}
+
 // StorageOptions represents the configuration options for StorageState.
+// CompactionFilter is optional, and it is called by compaction for every key/value pair (Refer to CompactionFilter).
+// LockTimeout is the maximum wait of a transaction for a key lock (Refer to txn.LockManager), zero means
+// txn.DefaultLockTimeout.
 type StorageOptions struct {
 	MemTableSizeInBytes   int64
 	SSTableSizeInBytes    int64
@@ -39,6 +193,10 @@ type StorageOptions struct {
 	MaximumMemtables      uint
 	FlushMemtableDuration time.Duration
 	CompactionOptions     CompactionOptions
+	WALArchiveOptions     WALArchiveOptions
+	CompactionFilter      CompactionFilter
+	WriteStallOptions     WriteStallOptions
+	LockTimeout           time.Duration
 }
 
 // StorageState represents the core abstraction to manage the in-memory state of the key/value storage engine.
@@ -57,21 +215,32 @@ type StorageState struct {
 	flushMemtableCompletionChannel chan struct{}
 	options                        StorageOptions
 	walPath                        log.WALPath
+	walArchive                     *log.WALArchive
 	lastCommitTimestamp            uint64
 	//stateLock is needed because compaction might cause a change in the StorageState (Refer to the Apply() method).
 	//Had compaction not been there, stateLock was not needed because the transaction isolation is serialized-snapshot, which means
 	//all the writes are written serially, and reads are based on read-timestamp, which means both these operations can run
 	//concurrently.
 	stateLock sync.RWMutex
+	//flushLock ensures that an immutable memtable is flushed only once, when the memtables are flushed by the flush goroutine
+	//and by a checkpoint (Refer to BeginCheckpoint) concurrently.
+	flushLock sync.Mutex
+	//backgroundErrorHandler is invoked with the outcome of the background operations (Refer to ReportBackgroundOutcome).
+	backgroundErrorHandler atomic.Pointer[BackgroundErrorHandler]
 }
 
 // NewStorageStateWithOptions creates new instance of StorageState, or loads the existing state from manifest.Manifest.
+// It returns an error if the WriteStallOptions are inconsistent with the flush and the compaction thresholds (Refer to
+// validateWriteStallOptions).
 func NewStorageStateWithOptions(options StorageOptions) (*StorageState, error) {
+	if err := options.validateWriteStallOptions(); err != nil {
+		return nil, err
+	}
 	if _, err := os.Stat(options.Path); os.IsNotExist(err) {
 		_ = os.MkdirAll(options.Path, os.ModePerm)
 	}
-	levels := make([]*Level, options.CompactionOptions.StrategyOptions.MaxLevels)
-	for level := 1; level <= int(options.CompactionOptions.StrategyOptions.MaxLevels); level++ {
+	levels := make([]*Level, options.CompactionOptions.MaxLevels())
+	for level := 1; level <= int(options.CompactionOptions.MaxLevels()); level++ {
 		levels[level-1] = &Level{LevelNumber: level}
 	}
 	manifestRecorder, events, err := manifest.CreateNewOrRecoverFrom(options.Path)
@@ -91,6 +260,17 @@ func NewStorageStateWithOptions(options StorageOptions) (*StorageState, error) {
 		walPath:                        log.NewWALPath(options.Path),
 		lastCommitTimestamp:            0,
 	}
+	if len(options.WALArchiveOptions.Path) > 0 {
+		walArchive, err := log.NewWALArchive(
+			options.WALArchiveOptions.Path,
+			options.WALArchiveOptions.MaxFiles,
+			options.WALArchiveOptions.MaxAge,
+		)
+		if err != nil {
+			return nil, err
+		}
+		storageState.walArchive = walArchive
+	}
 	if err := storageState.mayBeLoadExisting(events); err != nil {
 		return nil, err
 	}
@@ -231,13 +411,37 @@ func (storageState *StorageState) Scan(inclusiveRange kv.InclusiveKeyRange[kv.Ke
 // Applying StorageStateChangeEvent is exclusive, as it requires a write-lock.
 // As a part of applying the StorageStateChangeEvent, all the table.SSTable(s) which are to be removed are submitted to
 // table.SSTableCleaner.
+// A trivial move is recorded as manifest.SSTablesMoved, and it does not remove any table.SSTable.
+// Applying is all-or-nothing: the event is recorded in manifest.Manifest (with the write-lock held) before the StorageState
+// is changed. If the event can not be recorded, the new table.SSTable(s) of the compaction are removed, and the StorageState
+// is left unchanged.
 func (storageState *StorageState) Apply(event StorageStateChangeEvent, recovery bool) error {
-	ssTablesToRemove := storageState.apply(event)
+	storageState.stateLock.Lock()
 	if !recovery {
-		if err := storageState.manifest.Add(manifest.NewCompactionDone(event.NewSSTableIds, event.CompactionDescription())); err != nil {
+		var compactionEvent manifest.Event = manifest.NewCompactionDone(event.NewSSTableIds, event.CompactionDescription())
+		if sortedRunsDescription, ok := event.SortedRunsCompactionDescription(); ok {
+			compactionEvent = manifest.NewSortedRunsCompacted(event.NewSSTableIds, sortedRunsDescription)
+		}
+		if event.MovesSSTables() {
+			compactionEvent = manifest.NewSSTablesMoved(
+				event.CompactionUpperLevelSSTableIds(),
+				max(event.CompactionUpperLevel(), 0),
+				event.CompactionLowerLevel(),
+			)
+		}
+		if err := storageState.manifest.Add(compactionEvent); err != nil {
+			storageState.stateLock.Unlock()
+			if !event.MovesSSTables() {
+				for _, ssTable := range event.NewSSTables {
+					_ = ssTable.Remove()
+				}
+			}
 			return err
 		}
 	}
+	ssTablesToRemove := storageState.apply(event)
+	storageState.stateLock.Unlock()
+
 	storageState.ssTableCleaner.Submit(ssTablesToRemove)
 	return nil
 }
@@ -263,14 +467,20 @@ func (storageState *StorageState) LastCommitTimestamp() uint64 {
 }
 
 // Snapshot returns the point-in-time state of StorageState.
+// The snapshot does not share any state with StorageState: the ssTables map, the levels and their SSTableIds are copied
+// under the stateLock, so the snapshot can be read (for example, by compaction) while flush or compaction changes the state.
 func (storageState *StorageState) Snapshot() StorageStateSnapshot {
 	storageState.stateLock.RLock()
 	defer storageState.stateLock.RUnlock()
 
+	levels := make([]*Level, 0, len(storageState.levels))
+	for _, level := range storageState.levels {
+		levels = append(levels, &Level{LevelNumber: level.LevelNumber, SSTableIds: slices.Clone(level.SSTableIds)})
+	}
 	return StorageStateSnapshot{
 		L0SSTableIds: storageState.orderedLevel0SSTableIds(),
-		Levels:       storageState.levels,
-		SSTables:     storageState.ssTables,
+		Levels:       levels,
+		SSTables:     maps.Clone(storageState.ssTables),
 	}
 }
 
@@ -286,7 +496,21 @@ func (storageState *StorageState) Close() {
 // forceFlushNextImmutableMemtable flushes the next immutable memtable to level0 table.SSTable.
 // It picks the oldest memtable from immutableMemtables fields to be flushed and records the manifest.SSTableFlushedEventType
 // event in manifest.Manifest.
+// The WAL of the flushed memtable is archived if WAL archiving is enabled (Refer to WALArchiveOptions), else it is deleted.
+// The flush is already recorded when the WAL is archived, so a failure to archive the WAL does not fail the flush: it is
+// logged, and the WAL file is left in the WAL directory (which is also replayed in point-in-time recovery).
 func (storageState *StorageState) forceFlushNextImmutableMemtable() error {
+	storageState.flushLock.Lock()
+	defer storageState.flushLock.Unlock()
+
+	return storageState.flushNextImmutableMemtable()
+}
+
+// flushNextImmutableMemtable flushes the next immutable memtable to level0 table.SSTable, it is invoked with the flushLock held.
+// The flush is all-or-nothing: manifest.SSTableFlushedEventType is recorded (with the stateLock held) before the memtable is
+// replaced by the table.SSTable in the StorageState. If the event can not be recorded, the table.SSTable is removed and
+// the StorageState is left unchanged, so a retry flushes the same memtable.
+func (storageState *StorageState) flushNextImmutableMemtable() error {
 	flushEligibleMemtable := func() *memory.Memtable {
 		storageState.stateLock.Lock()
 		defer storageState.stateLock.Unlock()
@@ -321,13 +545,21 @@ func (storageState *StorageState) forceFlushNextImmutableMemtable() error {
 	}
 
 	storageState.stateLock.Lock()
+	if err := storageState.manifest.Add(manifest.NewSSTableFlushed(ssTable.Id())); err != nil {
+		storageState.stateLock.Unlock()
+		_ = ssTable.Remove()
+		return err
+	}
 	storageState.immutableMemtables = storageState.immutableMemtables[1:]
 	storageState.l0SSTableIds = append(storageState.l0SSTableIds, memtableToFlush.Id())
 	storageState.ssTables[memtableToFlush.Id()] = ssTable
 	storageState.stateLock.Unlock()
 
-	if err := storageState.manifest.Add(manifest.NewSSTableFlushed(ssTable.Id())); err != nil {
-		return err
+	if storageState.walArchive != nil {
+		if err := memtableToFlush.ArchiveWAL(storageState.walArchive); err != nil {
+			slog.Warn(fmt.Sprintf("error while archiving the WAL of the flushed memtable %v: %v", memtableToFlush.Id(), err))
+		}
+		return nil
 	}
 	memtableToFlush.DeleteWAL()
 
@@ -338,19 +570,34 @@ func (storageState *StorageState) forceFlushNextImmutableMemtable() error {
 // It may result in creation of a new memtable which is then recorded as manifest.MemtableCreatedEventType in manifest.Manifest.
 func (storageState *StorageState) mayBeFreezeCurrentMemtable(requiredSizeInBytes int64) error {
 	if !storageState.currentMemtable.CanFit(requiredSizeInBytes) {
-		storageState.stateLock.Lock()
-		storageState.immutableMemtables = append(storageState.immutableMemtables, storageState.currentMemtable)
-		storageState.currentMemtable = memory.NewMemtable(
-			storageState.idGenerator.NextId(),
-			storageState.options.MemTableSizeInBytes,
-			storageState.walPath,
-		)
-		storageState.stateLock.Unlock()
-		return storageState.manifest.Add(manifest.NewMemtableCreated(storageState.currentMemtable.Id()))
+		return storageState.freezeCurrentMemtable()
 	}
 	return nil
 }
 
+// freezeCurrentMemtable freezes the current memtable (makes it the latest immutable memtable) and creates a new memtable which
+// is then recorded as manifest.MemtableCreatedEventType in manifest.Manifest.
+// The new memtable is recorded before it replaces the current memtable, and if it can not be recorded, its WAL is deleted
+// and the StorageState is left unchanged.
+func (storageState *StorageState) freezeCurrentMemtable() error {
+	newMemtable := memory.NewMemtable(
+		storageState.idGenerator.NextId(),
+		storageState.options.MemTableSizeInBytes,
+		storageState.walPath,
+	)
+
+	storageState.stateLock.Lock()
+	defer storageState.stateLock.Unlock()
+
+	if err := storageState.manifest.Add(manifest.NewMemtableCreated(newMemtable.Id())); err != nil {
+		newMemtable.DeleteWAL()
+		return err
+	}
+	storageState.immutableMemtables = append(storageState.immutableMemtables, storageState.currentMemtable)
+	storageState.currentMemtable = newMemtable
+	return nil
+}
+
 // l0SSTableIterators returns all a slice of iterator.Iterator from level0 table.SSTable(s), along with a slice of
 // all the table.SSTable(s) in use.
 // Iterators are created from the latest memtable to the oldest (from index = len(storageState.l0SSTableIds) to index = 0).
@@ -398,6 +645,8 @@ func (storageState *StorageState) otherLevelSSTableIterators(seekTo kv.Key, ssTa
 
 // spawnMemtableFlush creates a goroutine which flushes the oldest immutable to level0 table.SSTable, if the number of
 // immutable memtables is greater or equal to the MaximumMemtables.
+// The outcome of every flush is reported (Refer to ReportBackgroundOutcome). A flush failing with a retryable error is
+// retried with backoff, and the flushes are stopped on a fatal error.
 func (storageState *StorageState) spawnMemtableFlush() {
 	hasImmutableMemtablesGoneBeyondMaximumAllowed := func() bool {
 		storageState.stateLock.RLock()
@@ -408,15 +657,26 @@ func (storageState *StorageState) spawnMemtableFlush() {
 
 	timer := time.NewTimer(storageState.options.FlushMemtableDuration)
 	go func() {
+		var failures uint
+		var stopped bool
 		for {
 			select {
 			case <-timer.C:
-				if hasImmutableMemtablesGoneBeyondMaximumAllowed() {
-					if err := storageState.forceFlushNextImmutableMemtable(); err != nil {
-						slog.Error(fmt.Sprintf("could not flush memtable, error: %v", err))
+				delay := storageState.options.FlushMemtableDuration
+				storageState.flushLock.Lock()
+				if !stopped && hasImmutableMemtablesGoneBeyondMaximumAllowed() {
+					err := storageState.flushNextImmutableMemtable()
+					if backgroundError := storageState.ReportBackgroundOutcome(FlushOperation, err); backgroundError == nil {
+						failures = 0
+					} else if backgroundError.Fatal {
+						stopped = true
+					} else {
+						failures++
+						delay = BackgroundRetryBackoff(delay, failures)
 					}
 				}
-				timer.Reset(storageState.options.FlushMemtableDuration)
+				storageState.flushLock.Unlock()
+				timer.Reset(delay)
 			case <-storageState.closeChannel:
 				close(storageState.flushMemtableCompletionChannel)
 				timer.Stop()
@@ -431,6 +691,13 @@ func (storageState *StorageState) spawnMemtableFlush() {
 // If the event is manifest.MemtableCreatedEventType -> it collects the id of the memtable.
 // If the event is manifest.SSTableFlushedEventType -> it removes the id from the collection of memtable, stores the id in l0SSTableIds field.
 // If the event is manifest.CompactionDoneEventType -> it creates StorageStateChangeEvent and applies it to the StorageState.
+// If the event is manifest.SortedRunsCompactedEventType -> it creates StorageStateChangeEvent and applies it to the StorageState.
+// If the event is manifest.SSTablesMovedEventType -> it moves the ids from the upper level (l0SSTableIds or a level) to the
+// lower level, without removing any SSTable.
+// If the event is manifest.SSTablesIngestedEventType -> it stores the ids either in l0SSTableIds or in the level, and
+// tracks the commit-timestamp of the ingestion as the lastCommitTimestamp (if greater).
+// If the event is manifest.StateSnapshotEventType -> it stores the ids in l0SSTableIds and the levels, and tracks the
+// last commit-timestamp of the snapshot as the lastCommitTimestamp (if greater).
 func (storageState *StorageState) mayBeLoadExisting(events []manifest.Event) error {
 	if len(events) > 0 {
 		memtableIds := make(map[uint64]struct{})
@@ -468,11 +735,89 @@ func (storageState *StorageState) mayBeLoadExisting(events []manifest.Event) err
 					return err
 				}
 				storageState.idGenerator.setIdIfGreaterThanExisting(storageChangeEvent.MaxSSTableId())
+			case manifest.SortedRunsCompactedEventType:
+				sortedRunsCompacted := event.(*manifest.SortedRunsCompacted)
+				storageChangeEvent := NewSortedRunsStorageStateChangeEventByOpeningSSTables(
+					sortedRunsCompacted.NewSSTableIds,
+					sortedRunsCompacted.Description,
+					storageState.options.Path,
+				)
+				for _, ssTableId := range sortedRunsCompacted.Description.AllSSTableIds() {
+					ssTable, err := table.Load(ssTableId, storageState.options.Path, block.DefaultBlockSize)
+					if err == nil {
+						storageState.ssTables[ssTable.Id()] = ssTable
+					}
+				}
+				if err := storageState.Apply(storageChangeEvent, true); err != nil {
+					return err
+				}
+				for _, ssTableId := range sortedRunsCompacted.NewSSTableIds {
+					storageState.idGenerator.setIdIfGreaterThanExisting(ssTableId)
+				}
+			case manifest.SSTablesMovedEventType:
+				ssTablesMoved := event.(*manifest.SSTablesMoved)
+				upperLevel := ssTablesMoved.FromLevel
+				if upperLevel == 0 {
+					upperLevel = -1
+				}
+				storageChangeEvent := NewSSTablesMovedStorageStateChangeEvent(nil, meta.SimpleLeveledCompactionDescription{
+					UpperLevel:           upperLevel,
+					LowerLevel:           ssTablesMoved.ToLevel,
+					UpperLevelSSTableIds: ssTablesMoved.SSTableIds,
+				})
+				if err := storageState.Apply(storageChangeEvent, true); err != nil {
+					return err
+				}
+			case manifest.SSTablesIngestedEventType:
+				ssTablesIngested := event.(*manifest.SSTablesIngested)
+				if ssTablesIngested.Level == 0 {
+					storageState.l0SSTableIds = append(storageState.l0SSTableIds, ssTablesIngested.SSTableIds...)
+				} else {
+					for _, ssTableId := range ssTablesIngested.SSTableIds {
+						ssTable, err := table.Load(ssTableId, storageState.options.Path, block.DefaultBlockSize)
+						if err == nil {
+							storageState.ssTables[ssTable.Id()] = ssTable
+						}
+					}
+					storageState.levels[ssTablesIngested.Level-1].appendSSTableIds(ssTablesIngested.SSTableIds)
+				}
+				for _, ssTableId := range ssTablesIngested.SSTableIds {
+					storageState.idGenerator.setIdIfGreaterThanExisting(ssTableId)
+				}
+				storageState.lastCommitTimestamp = max(storageState.lastCommitTimestamp, ssTablesIngested.CommitTimestamp)
+			case manifest.StateSnapshotEventType:
+				stateSnapshot := event.(*manifest.StateSnapshot)
+				if len(stateSnapshot.LevelSSTableIds) > len(storageState.levels) {
+					return fmt.Errorf(
+						"manifest has SSTables at %d levels, but only %d levels are configured",
+						len(stateSnapshot.LevelSSTableIds),
+						len(storageState.levels),
+					)
+				}
+				storageState.l0SSTableIds = append(storageState.l0SSTableIds, stateSnapshot.L0SSTableIds...)
+				for _, ssTableId := range stateSnapshot.L0SSTableIds {
+					storageState.idGenerator.setIdIfGreaterThanExisting(ssTableId)
+				}
+				for index, ssTableIds := range stateSnapshot.LevelSSTableIds {
+					for _, ssTableId := range ssTableIds {
+						ssTable, err := table.Load(ssTableId, storageState.options.Path, block.DefaultBlockSize)
+						if err != nil {
+							return err
+						}
+						storageState.ssTables[ssTable.Id()] = ssTable
+						storageState.idGenerator.setIdIfGreaterThanExisting(ssTableId)
+					}
+					storageState.levels[index].appendSSTableIds(ssTableIds)
+				}
+				storageState.lastCommitTimestamp = max(storageState.lastCommitTimestamp, stateSnapshot.LastCommitTimestamp)
 			}
 		}
 		if err := storageState.recoverL0SSTables(); err != nil {
 			return err
 		}
+		if err := storageState.recoverLevelSSTables(); err != nil {
+			return err
+		}
 		if err := storageState.recoverMemtables(memtableIds); err != nil {
 			return err
 		}
@@ -510,7 +855,7 @@ func (storageState *StorageState) recoverMemtables(memtableIds map[uint64]struct
 	sort.Slice(immutableMemtables, func(i, j int) bool {
 		return immutableMemtables[i].Id() < immutableMemtables[j].Id()
 	})
-	storageState.lastCommitTimestamp = maxTimestamp
+	storageState.lastCommitTimestamp = max(storageState.lastCommitTimestamp, maxTimestamp)
 	storageState.immutableMemtables = immutableMemtables
 	return nil
 }
@@ -529,35 +874,76 @@ func (storageState *StorageState) recoverL0SSTables() error {
 	return nil
 }
 
+// recoverLevelSSTables recovers the SSTables (at all the levels other than level0) which are not loaded while replaying the
+// manifest events (Refer to NewSortedRunsStorageStateChangeEventByOpeningSSTables).
+func (storageState *StorageState) recoverLevelSSTables() error {
+	for _, level := range storageState.levels {
+		for _, ssTableId := range level.SSTableIds {
+			if _, ok := storageState.ssTables[ssTableId]; ok {
+				continue
+			}
+			ssTable, err := table.Load(ssTableId, storageState.options.Path, block.DefaultBlockSize)
+			if err != nil {
+				return err
+			}
+			storageState.ssTables[ssTable.Id()] = ssTable
+		}
+	}
+	return nil
+}
+
 // apply applies the StorageStateChangeEvent to the StorageState.
-// It involves the following:
-// 1) Getting an exclusive lock.
-// 2) Setting the mapping between ssTableId and the corresponding ssTable.
-// 3) Identifying all the ssTableIds to be removed.
-// 4) Updating either l0SSTableIds or the level field.
-// 5) Deleting the mapping from ssTables fields for the ssTableIds to be removed.
+// It is invoked with the stateLock held, and it involves the following:
+// 1) Setting the mapping between ssTableId and the corresponding ssTable.
+// 2) Identifying all the ssTableIds to be removed.
+// 3) Removing the compacted ssTableIds from either l0SSTableIds or the upper level, and from the lower level, and appending the
+// new ssTableIds to the lower level. Only the compacted ssTableIds are removed, so a compaction may involve a subset of the
+// SSTables of a level (Refer to compact.LeveledCompaction).
+// With a trivial move (Refer to NewSSTablesMovedStorageStateChangeEvent), the moved ssTableIds are the new ssTableIds of the
+// lower level, so nothing is removed.
+// 4) Deleting the mapping from ssTables fields for the ssTableIds to be removed.
 func (storageState *StorageState) apply(event StorageStateChangeEvent) []*table.SSTable {
-	storageState.stateLock.Lock()
-	defer storageState.stateLock.Unlock()
-
 	type SSTablesToRemove = []*table.SSTable
 	setSSTableMapping := func() {
 		for _, ssTable := range event.NewSSTables {
 			storageState.ssTables[ssTable.Id()] = ssTable
 		}
 	}
+	updateLevelsForSortedRuns := func(description meta.SortedRunsCompactionDescription) []uint64 {
+		ssTableIdsToRemove := description.AllSSTableIds()
+		for _, sortedRun := range description.SortedRuns {
+			if sortedRun.Level == 0 {
+				storageState.l0SSTableIds = slices.DeleteFunc(storageState.l0SSTableIds, func(ssTableId uint64) bool {
+					return slices.Contains(sortedRun.SSTableIds, ssTableId)
+				})
+			} else {
+				storageState.levels[sortedRun.Level-1].removeSSTableIds(sortedRun.SSTableIds)
+			}
+		}
+		if !description.DropsSSTables() {
+			storageState.levels[description.OutputLevel-1].appendSSTableIds(event.NewSSTableIds)
+		}
+		return ssTableIdsToRemove
+	}
 	updateLevels := func() []uint64 {
+		if sortedRunsDescription, ok := event.SortedRunsCompactionDescription(); ok {
+			return updateLevelsForSortedRuns(sortedRunsDescription)
+		}
 		var ssTableIdsToRemove []uint64
 		if event.CompactionUpperLevel() == -1 {
 			ssTableIdsToRemove = append(ssTableIdsToRemove, event.CompactionUpperLevelSSTableIds()...)
 			storageState.l0SSTableIds = event.allSSTableIdsExcludingTheOnesPresentInUpperLevelSSTableIds(storageState.l0SSTableIds)
 		} else {
-			ssTableIdsToRemove = append(ssTableIdsToRemove, storageState.levels[event.CompactionUpperLevel()-1].SSTableIds...)
-			storageState.levels[event.CompactionUpperLevel()-1].clearSSTableIds()
+			ssTableIdsToRemove = append(ssTableIdsToRemove, event.CompactionUpperLevelSSTableIds()...)
+			storageState.levels[event.CompactionUpperLevel()-1].removeSSTableIds(event.CompactionUpperLevelSSTableIds())
 		}
 		ssTableIdsToRemove = append(ssTableIdsToRemove, event.CompactionLowerLevelSSTableIds()...)
+		storageState.levels[event.CompactionLowerLevel()-1].removeSSTableIds(event.CompactionLowerLevelSSTableIds())
 		storageState.levels[event.CompactionLowerLevel()-1].appendSSTableIds(event.NewSSTableIds)
 
+		if event.MovesSSTables() {
+			return nil
+		}
 		return ssTableIdsToRemove
 	}
 	unsetSSTableMapping := func(ssTableIdsToRemove []uint64) SSTablesToRemove {
@@ -5,6 +5,7 @@ import (
 	"go-lsm-workshop/kv"
 	"go-lsm-workshop/table"
 	"go-lsm-workshop/test_utility"
+	"os"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -180,3 +181,269 @@ func TestApplyStorageStateChangeEventWhichCompactsAllTheTablesAtLevel1(t *testin
 	assert.Equal(t, 0, len(storageState.levels[level1-1].SSTableIds))
 	assert.Equal(t, newSSTable.Id(), storageState.levels[level2-1].SSTableIds[0])
 }
+
+func TestApplyStorageStateChangeEventWhichCompactsASubsetOfTheTablesAtLevel1AndLevel2(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	storageState, _ := NewStorageState(rootPath)
+
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+		storageState.Close()
+	}()
+
+	buildSSTableAtLevel := func(id uint64, level int) *table.SSTable {
+		ssTableBuilder := table.NewSSTableBuilder(4096)
+		ssTableBuilder.Add(kv.NewStringKeyWithTimestamp("consensus", 6), kv.NewStringValue("paxos"))
+		ssTable, err := ssTableBuilder.Build(id, rootPath)
+		assert.Nil(t, err)
+
+		if level > 0 {
+			storageState.ssTables[id] = ssTable
+			storageState.levels[level-1].SSTableIds = append(storageState.levels[level-1].SSTableIds, id)
+		}
+		return ssTable
+	}
+
+	l1SSTable := buildSSTableAtLevel(storageState.SSTableIdGenerator().NextId(), level1)
+	anotherL1SSTable := buildSSTableAtLevel(storageState.SSTableIdGenerator().NextId(), level1)
+	l2SSTable := buildSSTableAtLevel(storageState.SSTableIdGenerator().NextId(), level2)
+	anotherL2SSTable := buildSSTableAtLevel(storageState.SSTableIdGenerator().NextId(), level2)
+	newSSTable := buildSSTableAtLevel(storageState.SSTableIdGenerator().NextId(), level0)
+
+	event := StorageStateChangeEvent{
+		description: meta.SimpleLeveledCompactionDescription{
+			UpperLevel:           1,
+			UpperLevelSSTableIds: []uint64{l1SSTable.Id()},
+			LowerLevel:           2,
+			LowerLevelSSTableIds: []uint64{l2SSTable.Id()},
+		},
+		NewSSTables:   []*table.SSTable{newSSTable},
+		NewSSTableIds: []uint64{newSSTable.Id()},
+	}
+	err := storageState.Apply(event, false)
+
+	assert.Nil(t, err)
+	assert.False(t, storageState.hasSSTableWithId(l1SSTable.Id()))
+	assert.False(t, storageState.hasSSTableWithId(l2SSTable.Id()))
+	assert.True(t, storageState.hasSSTableWithId(newSSTable.Id()))
+
+	assert.Equal(t, []uint64{anotherL1SSTable.Id()}, storageState.levels[level1-1].SSTableIds)
+	assert.Equal(t, []uint64{anotherL2SSTable.Id(), newSSTable.Id()}, storageState.levels[level2-1].SSTableIds)
+}
+
+func TestApplyStorageStateChangeEventWhichCompactsSortedRuns(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	storageState, _ := NewStorageState(rootPath)
+
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+		storageState.Close()
+	}()
+
+	buildSSTableAtLevel := func(id uint64, level int) *table.SSTable {
+		ssTableBuilder := table.NewSSTableBuilder(4096)
+		ssTableBuilder.Add(kv.NewStringKeyWithTimestamp("consensus", 6), kv.NewStringValue("paxos"))
+		ssTable, err := ssTableBuilder.Build(id, rootPath)
+		assert.Nil(t, err)
+
+		storageState.ssTables[id] = ssTable
+		if level == 0 {
+			storageState.l0SSTableIds = append(storageState.l0SSTableIds, id)
+		} else {
+			storageState.levels[level-1].SSTableIds = append(storageState.levels[level-1].SSTableIds, id)
+		}
+		return ssTable
+	}
+
+	l1SSTable := buildSSTableAtLevel(storageState.SSTableIdGenerator().NextId(), level1)
+	l0SSTable := buildSSTableAtLevel(storageState.SSTableIdGenerator().NextId(), level0)
+	anotherL0SSTable := buildSSTableAtLevel(storageState.SSTableIdGenerator().NextId(), level0)
+
+	ssTableBuilder := table.NewSSTableBuilder(4096)
+	ssTableBuilder.Add(kv.NewStringKeyWithTimestamp("consensus", 6), kv.NewStringValue("raft"))
+	newSSTable, err := ssTableBuilder.Build(storageState.SSTableIdGenerator().NextId(), rootPath)
+	assert.Nil(t, err)
+
+	event := NewSortedRunsStorageStateChangeEvent([]*table.SSTable{newSSTable}, meta.SortedRunsCompactionDescription{
+		SortedRuns: []meta.SortedRun{
+			{Level: level0, SSTableIds: []uint64{l0SSTable.Id()}},
+			{Level: level1, SSTableIds: []uint64{l1SSTable.Id()}},
+		},
+		OutputLevel: level2,
+	})
+	err = storageState.Apply(event, false)
+
+	assert.Nil(t, err)
+	assert.False(t, storageState.hasSSTableWithId(l0SSTable.Id()))
+	assert.False(t, storageState.hasSSTableWithId(l1SSTable.Id()))
+	assert.True(t, storageState.hasSSTableWithId(anotherL0SSTable.Id()))
+	assert.True(t, storageState.hasSSTableWithId(newSSTable.Id()))
+
+	assert.Equal(t, []uint64{anotherL0SSTable.Id()}, storageState.l0SSTableIds)
+	assert.Equal(t, 0, len(storageState.levels[level1-1].SSTableIds))
+	assert.Equal(t, []uint64{newSSTable.Id()}, storageState.levels[level2-1].SSTableIds)
+}
+
+func TestApplyStorageStateChangeEventWhichDropsSSTables(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	storageState, _ := NewStorageState(rootPath)
+
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+		storageState.Close()
+	}()
+
+	buildL0SSTable := func(id uint64) *table.SSTable {
+		ssTableBuilder := table.NewSSTableBuilder(4096)
+		ssTableBuilder.Add(kv.NewStringKeyWithTimestamp("consensus", 6), kv.NewStringValue("paxos"))
+		ssTable, err := ssTableBuilder.Build(id, rootPath)
+		assert.Nil(t, err)
+
+		storageState.ssTables[id] = ssTable
+		storageState.l0SSTableIds = append(storageState.l0SSTableIds, id)
+		return ssTable
+	}
+
+	oldestL0SSTable := buildL0SSTable(storageState.SSTableIdGenerator().NextId())
+	l0SSTable := buildL0SSTable(storageState.SSTableIdGenerator().NextId())
+
+	event := NewSortedRunsStorageStateChangeEvent(nil, meta.SortedRunsCompactionDescription{
+		SortedRuns:  []meta.SortedRun{{Level: level0, SSTableIds: []uint64{oldestL0SSTable.Id()}}},
+		OutputLevel: 0,
+	})
+	err := storageState.Apply(event, false)
+
+	assert.Nil(t, err)
+	assert.False(t, storageState.hasSSTableWithId(oldestL0SSTable.Id()))
+	assert.True(t, storageState.hasSSTableWithId(l0SSTable.Id()))
+	assert.Equal(t, []uint64{l0SSTable.Id()}, storageState.l0SSTableIds)
+}
+
+func TestApplyStorageStateChangeEventWhichMovesSSTablesFromLevel0(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	storageState, _ := NewStorageState(rootPath)
+
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+		storageState.Close()
+	}()
+
+	buildL0SSTable := func(id uint64, key string) *table.SSTable {
+		ssTableBuilder := table.NewSSTableBuilder(4096)
+		ssTableBuilder.Add(kv.NewStringKeyWithTimestamp(key, 6), kv.NewStringValue("paxos"))
+		ssTable, err := ssTableBuilder.Build(id, rootPath)
+		assert.Nil(t, err)
+
+		storageState.ssTables[id] = ssTable
+		storageState.l0SSTableIds = append(storageState.l0SSTableIds, id)
+		return ssTable
+	}
+
+	ssTable := buildL0SSTable(storageState.SSTableIdGenerator().NextId(), "consensus")
+	anotherSSTable := buildL0SSTable(storageState.SSTableIdGenerator().NextId(), "raft")
+	newestL0SSTable := buildL0SSTable(storageState.SSTableIdGenerator().NextId(), "zab")
+
+	event := NewSSTablesMovedStorageStateChangeEvent(
+		[]*table.SSTable{anotherSSTable, ssTable},
+		meta.SimpleLeveledCompactionDescription{
+			UpperLevel:           -1,
+			LowerLevel:           level1,
+			UpperLevelSSTableIds: []uint64{anotherSSTable.Id(), ssTable.Id()},
+		},
+	)
+	err := storageState.Apply(event, false)
+
+	assert.Nil(t, err)
+	assert.True(t, storageState.hasSSTableWithId(ssTable.Id()))
+	assert.True(t, storageState.hasSSTableWithId(anotherSSTable.Id()))
+	assert.Equal(t, []uint64{newestL0SSTable.Id()}, storageState.l0SSTableIds)
+	assert.Equal(t, []uint64{anotherSSTable.Id(), ssTable.Id()}, storageState.levels[level1-1].SSTableIds)
+
+	value, ok := storageState.Get(kv.NewStringKeyWithTimestamp("consensus", 8))
+	assert.True(t, ok)
+	assert.Equal(t, kv.NewStringValue("paxos"), value)
+}
+
+func TestApplyStorageStateChangeEventWhichMovesSSTablesBetweenLevels(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	storageState, _ := NewStorageState(rootPath)
+
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+		storageState.Close()
+	}()
+
+	buildSSTableAtLevel := func(id uint64, key string, level int) *table.SSTable {
+		ssTableBuilder := table.NewSSTableBuilder(4096)
+		ssTableBuilder.Add(kv.NewStringKeyWithTimestamp(key, 6), kv.NewStringValue("paxos"))
+		ssTable, err := ssTableBuilder.Build(id, rootPath)
+		assert.Nil(t, err)
+
+		storageState.ssTables[id] = ssTable
+		storageState.levels[level-1].appendSSTableIds([]uint64{id})
+		return ssTable
+	}
+
+	ssTable := buildSSTableAtLevel(storageState.SSTableIdGenerator().NextId(), "consensus", level1)
+	anotherSSTable := buildSSTableAtLevel(storageState.SSTableIdGenerator().NextId(), "raft", level1)
+	l2SSTable := buildSSTableAtLevel(storageState.SSTableIdGenerator().NextId(), "zab", level2)
+
+	event := NewSSTablesMovedStorageStateChangeEvent(
+		[]*table.SSTable{ssTable},
+		meta.SimpleLeveledCompactionDescription{
+			UpperLevel:           level1,
+			LowerLevel:           level2,
+			UpperLevelSSTableIds: []uint64{ssTable.Id()},
+		},
+	)
+	err := storageState.Apply(event, false)
+
+	assert.Nil(t, err)
+	assert.True(t, storageState.hasSSTableWithId(ssTable.Id()))
+	assert.Equal(t, []uint64{anotherSSTable.Id()}, storageState.levels[level1-1].SSTableIds)
+	assert.Equal(t, []uint64{l2SSTable.Id(), ssTable.Id()}, storageState.levels[level2-1].SSTableIds)
+}
+
+func TestApplyStorageStateChangeEventGivenTheManifestEventCanNotBeRecorded(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	storageState, _ := NewStorageState(rootPath)
+
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+		storageState.Close()
+	}()
+
+	buildSSTable := func(id uint64) *table.SSTable {
+		ssTableBuilder := table.NewSSTableBuilder(4096)
+		ssTableBuilder.Add(kv.NewStringKeyWithTimestamp("consensus", 6), kv.NewStringValue("paxos"))
+		ssTable, err := ssTableBuilder.Build(id, rootPath)
+		assert.Nil(t, err)
+
+		return ssTable
+	}
+
+	ssTable := buildSSTable(storageState.SSTableIdGenerator().NextId())
+	storageState.SetSSTableAtLevel(ssTable, level1)
+	newSSTable := buildSSTable(storageState.SSTableIdGenerator().NextId())
+
+	event := StorageStateChangeEvent{
+		description: meta.SimpleLeveledCompactionDescription{
+			UpperLevel:           1,
+			UpperLevelSSTableIds: []uint64{ssTable.Id()},
+			LowerLevel:           2,
+			LowerLevelSSTableIds: []uint64{},
+		},
+		NewSSTables:   []*table.SSTable{newSSTable},
+		NewSSTableIds: []uint64{newSSTable.Id()},
+	}
+	assert.Nil(t, storageState.manifest.Close())
+
+	assert.Error(t, storageState.Apply(event, false))
+	assert.True(t, storageState.hasSSTableWithId(ssTable.Id()))
+	assert.False(t, storageState.hasSSTableWithId(newSSTable.Id()))
+	assert.Equal(t, []uint64{ssTable.Id()}, storageState.levels[level1-1].SSTableIds)
+	assert.Equal(t, 0, len(storageState.levels[level2-1].SSTableIds))
+
+	_, err := os.Stat(table.SSTableFilePath(newSSTable.Id(), rootPath))
+	assert.True(t, os.IsNotExist(err))
+}
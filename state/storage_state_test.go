@@ -1065,3 +1065,94 @@ func TestStorageStateWithForceFlushNextImmutableMemtableAndReadFromSSTableAtFixe
 	_ = iterator.Next()
 	assert.False(t, iterator.IsValid())
 }
+
+func TestStorageStateSnapshotDoesNotChangeWithTheStorageState(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	storageState, _ := NewStorageState(rootPath)
+
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+		storageState.Close()
+	}()
+
+	ssTableBuilder := table.NewSSTableBuilder(4096)
+	ssTableBuilder.Add(kv.NewStringKeyWithTimestamp("consensus", 1), kv.NewStringValue("paxos"))
+	ssTable, err := ssTableBuilder.Build(100, rootPath)
+	assert.Nil(t, err)
+	storageState.SetSSTableAtLevel(ssTable, 1)
+
+	batch := kv.NewBatch()
+	_ = batch.Put([]byte("distributed"), []byte("TiKV"))
+	assert.Nil(t, storageState.Set(kv.NewTimestampedBatchFrom(*batch, 6)))
+	storageState.forceFreezeCurrentMemtable()
+
+	snapshot := storageState.Snapshot()
+	assert.Nil(t, storageState.ForceFlushNextImmutableMemtable())
+	storageState.levels[0].appendSSTableIds([]uint64{200})
+
+	assert.Empty(t, snapshot.L0SSTableIds)
+	assert.Equal(t, []uint64{100}, snapshot.SSTableIdsAt(1))
+	assert.Equal(t, 1, len(snapshot.SSTables))
+	assert.Equal(t, 1, storageState.TotalSSTablesAtLevel(0))
+	assert.Equal(t, 2, storageState.TotalSSTablesAtLevel(1))
+}
+
+func TestLeveledCompactionOptionsTargetSizesWithDynamicBaseLevel(t *testing.T) {
+	options := LeveledCompactionOptions{
+		BaseLevelSizeInBytes: 100,
+		LevelSizeMultiplier:  10,
+		MaxLevels:            3,
+	}
+
+	baseLevel, targetSizes := options.TargetSizes([]int64{0, 0, 0, 10000})
+	assert.Equal(t, 1, baseLevel)
+	assert.Equal(t, []int64{0, 100, 1000, 10000}, targetSizes)
+
+	baseLevel, targetSizes = options.TargetSizes([]int64{0, 0, 0, 500})
+	assert.Equal(t, 2, baseLevel)
+	assert.Equal(t, []int64{0, 0, 50, 500}, targetSizes)
+
+	baseLevel, targetSizes = options.TargetSizes([]int64{0, 0, 0, 0})
+	assert.Equal(t, 3, baseLevel)
+	assert.Equal(t, []int64{0, 0, 0, 100}, targetSizes)
+}
+
+func TestStorageStateDoesNotFlushTheImmutableMemtableGivenTheManifestEventCanNotBeRecorded(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	storageState, _ := NewStorageStateWithOptions(testStorageStateOptionsWithMemTableSizeAndDirectory(250, rootPath))
+
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+		storageState.Close()
+	}()
+
+	batch := kv.NewBatch()
+	_ = batch.Put([]byte("consensus"), []byte("raft"))
+	assert.Nil(t, storageState.Set(kv.NewTimestampedBatchFrom(*batch, 10)))
+	storageState.forceFreezeCurrentMemtable()
+	assert.Nil(t, storageState.manifest.Close())
+
+	assert.Error(t, storageState.ForceFlushNextImmutableMemtable())
+	assert.Equal(t, 1, storageState.TotalImmutableMemtables())
+	assert.Equal(t, 0, storageState.TotalSSTablesAtLevel(0))
+
+	ssTableFilePaths, _ := filepath.Glob(filepath.Join(rootPath, "*.sst"))
+	assert.Empty(t, ssTableFilePaths)
+}
+
+func TestStorageStateDoesNotFreezeTheCurrentMemtableGivenTheManifestEventCanNotBeRecorded(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	storageState, _ := NewStorageStateWithOptions(testStorageStateOptionsWithMemTableSizeAndDirectory(250, rootPath))
+
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+		storageState.Close()
+	}()
+
+	currentMemtableId := storageState.currentMemtable.Id()
+	assert.Nil(t, storageState.manifest.Close())
+
+	assert.Error(t, storageState.freezeCurrentMemtable())
+	assert.Equal(t, 0, storageState.TotalImmutableMemtables())
+	assert.Equal(t, currentMemtableId, storageState.currentMemtable.Id())
+}
@@ -43,16 +43,26 @@ func (storageState *StorageState) DeleteWALDirectory() {
 
 // HasImmutableMemtables returns true if there are immutable tables, it is only for testing.
 func (storageState *StorageState) HasImmutableMemtables() bool {
+	storageState.stateLock.RLock()
+	defer storageState.stateLock.RUnlock()
+
 	return len(storageState.immutableMemtables) > 0
 }
 
 // TotalImmutableMemtables returns the total number of immutable memtables, it is only for testing.
 func (storageState *StorageState) TotalImmutableMemtables() int {
+	storageState.stateLock.RLock()
+	defer storageState.stateLock.RUnlock()
+
 	return len(storageState.immutableMemtables)
 }
 
 // TotalSSTablesAtLevel returns the total number of SSTables at the given level, it is only for testing.
+// It holds the stateLock, so it can be invoked while the flush and the compaction goroutines change the levels.
 func (storageState *StorageState) TotalSSTablesAtLevel(level int) int {
+	storageState.stateLock.RLock()
+	defer storageState.stateLock.RUnlock()
+
 	if level == 0 {
 		return len(storageState.l0SSTableIds)
 	}
@@ -61,6 +71,9 @@ func (storageState *StorageState) TotalSSTablesAtLevel(level int) int {
 
 // SSTableReferenceCountAtLevel returns a slice of references of table.SSTable at the given level, it is only for testing.
 func (storageState *StorageState) SSTableReferenceCountAtLevel(level int) ([]int64, int) {
+	storageState.stateLock.RLock()
+	defer storageState.stateLock.RUnlock()
+
 	var totalReferenceCount []int64
 	if level == 0 {
 		for _, ssTableId := range storageState.l0SSTableIds {
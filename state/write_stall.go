@@ -0,0 +1,170 @@
+package state
+
+import "errors"
+
+var StopImmutableMemtablesNotAboveMaximumMemtablesErr = errors.New("StopImmutableMemtables must be greater than MaximumMemtables, the immutable memtables are flushed only after their number reaches MaximumMemtables")
+var StopLevel0SSTablesNotAboveCompactionTriggerErr = errors.New("StopLevel0SSTables must be greater than the level0 compaction trigger of the compaction strategy")
+
+// WriteStallCondition represents the condition of the writes, based on the thresholds in WriteStallOptions.
+type WriteStallCondition uint8
+
+const (
+	NoWriteStall WriteStallCondition = iota
+	WriteSlowdown
+	WriteStop
+)
+
+// WriteStallCondition returns the current WriteStallCondition of the StorageState.
+// It returns WriteStop if any stop threshold is reached, WriteSlowdown if any slowdown threshold is reached, else
+// NoWriteStall. It is used by txn.Executor to delay or block the commits, which gives the flush goroutine and the
+// compaction goroutine a chance to catch up with the writes.
+func (storageState *StorageState) WriteStallCondition() WriteStallCondition {
+	storageState.stateLock.RLock()
+	defer storageState.stateLock.RUnlock()
+
+	options := storageState.options.WriteStallOptions
+	immutableMemtables := uint(len(storageState.immutableMemtables))
+	level0SSTables := uint(len(storageState.l0SSTableIds))
+	pendingCompactionBytes := storageState.pendingCompactionBytes()
+
+	reaches := func(value, threshold uint) bool {
+		return threshold > 0 && value >= threshold
+	}
+	reachesBytes := func(value, threshold int64) bool {
+		return threshold > 0 && value >= threshold
+	}
+	if reaches(immutableMemtables, options.StopImmutableMemtables) ||
+		reaches(level0SSTables, options.StopLevel0SSTables) ||
+		reachesBytes(pendingCompactionBytes, options.StopPendingCompactionBytes) {
+		return WriteStop
+	}
+	if reaches(immutableMemtables, options.SlowdownImmutableMemtables) ||
+		reaches(level0SSTables, options.SlowdownLevel0SSTables) ||
+		reachesBytes(pendingCompactionBytes, options.SlowdownPendingCompactionBytes) {
+		return WriteSlowdown
+	}
+	return NoWriteStall
+}
+
+// validateWriteStallOptions returns an error if a stop threshold in WriteStallOptions does not exceed the threshold which
+// triggers the flush (or the compaction) that brings the value back down. Such a stop threshold is reached without the
+// flush (or the compaction) ever running, and then every commit blocks forever.
+// The immutable memtables are flushed only after their number reaches MaximumMemtables (Refer to spawnMemtableFlush), and
+// the level0 SSTables are compacted only after their number reaches the level0 compaction trigger of the compaction
+// strategy (Refer to CompactionOptions.level0CompactionTrigger).
+func (options StorageOptions) validateWriteStallOptions() error {
+	writeStallOptions := options.WriteStallOptions
+	if writeStallOptions.StopImmutableMemtables > 0 && writeStallOptions.StopImmutableMemtables <= options.MaximumMemtables {
+		return StopImmutableMemtablesNotAboveMaximumMemtablesErr
+	}
+	if writeStallOptions.StopLevel0SSTables > 0 {
+		if trigger, ok := options.CompactionOptions.level0CompactionTrigger(); ok && writeStallOptions.StopLevel0SSTables <= trigger {
+			return StopLevel0SSTablesNotAboveCompactionTriggerErr
+		}
+	}
+	return nil
+}
+
+// level0CompactionTrigger returns the number of level0 SSTables which triggers the compaction of level0 with the
+// configured compaction Strategy, and true. It returns false with FIFOCompactionStrategy (which drops the SSTables by
+// size or age) and with a CustomStrategy, whose trigger is not known.
+func (options CompactionOptions) level0CompactionTrigger() (uint, bool) {
+	if options.CustomStrategy != nil {
+		return 0, false
+	}
+	switch options.Strategy {
+	case LeveledCompactionStrategy:
+		return max(options.LeveledStrategyOptions.Level0FilesCompactionTrigger, 1), true
+	case TieredCompactionStrategy:
+		return max(options.TieredStrategyOptions.SortedRunsCompactionTrigger, 2), true
+	case FIFOCompactionStrategy:
+		return 0, false
+	default:
+		return options.StrategyOptions.Level0FilesCompactionTrigger, true
+	}
+}
+
+// PendingCompactionBytes returns the estimated number of bytes which are yet to be compacted (Refer to pendingCompactionBytes).
+func (storageState *StorageState) PendingCompactionBytes() int64 {
+	storageState.stateLock.RLock()
+	defer storageState.stateLock.RUnlock()
+
+	return storageState.pendingCompactionBytes()
+}
+
+// pendingCompactionBytes returns the estimated number of bytes which the compaction strategy has to compact to bring the
+// levels back within their targets. It is measured relative to the targets of the strategy (and not as all the bytes above
+// the last level, which grow with the size of the data), so it drops back to zero once the compaction catches up:
+// 1) SimpleLeveledCompactionStrategy: the size of every level whose ratio (the number of SSTables at the next level / the
+// number of SSTables at the level) is below NumberOfSSTablesRatioPercentage. Level0 is considered only if the number of
+// level0 SSTables >= Level0FilesCompactionTrigger.
+// 2) LeveledCompactionStrategy: the size of level0 if the number of level0 SSTables >= Level0FilesCompactionTrigger, plus the
+// size of every level (other than the last) beyond its target size (Refer to LeveledCompactionOptions.TargetSizes).
+// 3) TieredCompactionStrategy: the size of all the sorted runs except the oldest, if the number of sorted runs >=
+// SortedRunsCompactionTrigger.
+// It returns 0 with FIFOCompactionStrategy, which does not compact the SSTables, and with a CustomStrategy, whose targets
+// are not known.
+// It is invoked with the stateLock held.
+func (storageState *StorageState) pendingCompactionBytes() int64 {
+	options := storageState.options.CompactionOptions
+	maxLevels := int(options.MaxLevels())
+	if maxLevels == 0 || options.CustomStrategy != nil {
+		return 0
+	}
+	ssTableIdsAt := func(level int) []uint64 {
+		if level == 0 {
+			return storageState.l0SSTableIds
+		}
+		return storageState.levels[level-1].SSTableIds
+	}
+	levelSizes := make([]int64, maxLevels+1)
+	for level := range levelSizes {
+		for _, ssTableId := range ssTableIdsAt(level) {
+			if ssTable, ok := storageState.ssTables[ssTableId]; ok {
+				levelSizes[level] += ssTable.SizeInBytes()
+			}
+		}
+	}
+
+	var pendingCompactionBytes int64
+	switch options.Strategy {
+	case LeveledCompactionStrategy:
+		if len(storageState.l0SSTableIds) >= int(max(options.LeveledStrategyOptions.Level0FilesCompactionTrigger, 1)) {
+			pendingCompactionBytes += levelSizes[0]
+		}
+		_, targetSizes := options.LeveledStrategyOptions.TargetSizes(levelSizes)
+		for level := 1; level < maxLevels; level++ {
+			pendingCompactionBytes += max(levelSizes[level]-targetSizes[level], 0)
+		}
+	case TieredCompactionStrategy:
+		sortedRuns, oldestSortedRunSize := len(storageState.l0SSTableIds), int64(0)
+		if sortedRuns > 0 {
+			if ssTable, ok := storageState.ssTables[storageState.l0SSTableIds[0]]; ok {
+				oldestSortedRunSize = ssTable.SizeInBytes()
+			}
+		}
+		for level := 1; level <= maxLevels; level++ {
+			if len(ssTableIdsAt(level)) > 0 {
+				sortedRuns, oldestSortedRunSize = sortedRuns+1, levelSizes[level]
+			}
+		}
+		if sortedRuns >= int(max(options.TieredStrategyOptions.SortedRunsCompactionTrigger, 2)) {
+			for _, size := range levelSizes {
+				pendingCompactionBytes += size
+			}
+			pendingCompactionBytes -= oldestSortedRunSize
+		}
+	default:
+		for level := 0; level < maxLevels; level++ {
+			upperLevelSSTables, lowerLevelSSTables := len(ssTableIdsAt(level)), len(ssTableIdsAt(level+1))
+			if upperLevelSSTables == 0 ||
+				(level == 0 && upperLevelSSTables < int(options.StrategyOptions.Level0FilesCompactionTrigger)) {
+				continue
+			}
+			if lowerLevelSSTables*100 < int(options.StrategyOptions.NumberOfSSTablesRatioPercentage)*upperLevelSSTables {
+				pendingCompactionBytes += levelSizes[level]
+			}
+		}
+	}
+	return pendingCompactionBytes
+}
@@ -0,0 +1,240 @@
+package state
+
+import (
+	"go-lsm-workshop/kv"
+	"go-lsm-workshop/table"
+	"go-lsm-workshop/test_utility"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func setKeysInStorageState(t *testing.T, storageState *StorageState, keys ...string) {
+	for index, key := range keys {
+		batch := kv.NewBatch()
+		_ = batch.Put([]byte(key), []byte("value-of-"+key))
+		assert.Nil(t, storageState.Set(kv.NewTimestampedBatchFrom(*batch, uint64(index+1))))
+	}
+}
+
+func setSSTableWithKeyInStorageState(t *testing.T, storageState *StorageState, rootPath string, key string, level int) *table.SSTable {
+	ssTableBuilder := table.NewSSTableBuilder(4096)
+	ssTableBuilder.Add(kv.NewStringKeyWithTimestamp(key, 5), kv.NewStringValue("value-of-"+key))
+	ssTable, err := ssTableBuilder.Build(storageState.SSTableIdGenerator().NextId(), rootPath)
+	assert.Nil(t, err)
+
+	storageState.SetSSTableAtLevel(ssTable, level)
+	return ssTable
+}
+
+func TestWriteStallConditionWithoutThresholds(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	storageState, _ := NewStorageStateWithOptions(testStorageStateOptionsWithMemTableSizeAndDirectory(250, rootPath))
+
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+		storageState.Close()
+	}()
+
+	setKeysInStorageState(t, storageState, "consensus", "storage", "data-structure")
+
+	assert.Equal(t, 2, len(storageState.immutableMemtables))
+	assert.Equal(t, NoWriteStall, storageState.WriteStallCondition())
+}
+
+func TestWriteStallConditionWithSlowdownOnImmutableMemtables(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	options := testStorageStateOptionsWithMemTableSizeAndDirectory(250, rootPath)
+	options.MaximumMemtables = 1
+	options.WriteStallOptions = WriteStallOptions{
+		SlowdownImmutableMemtables: 2,
+		StopImmutableMemtables:     4,
+	}
+	storageState, _ := NewStorageStateWithOptions(options)
+
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+		storageState.Close()
+	}()
+
+	setKeysInStorageState(t, storageState, "consensus", "storage")
+	assert.Equal(t, 1, len(storageState.immutableMemtables))
+	assert.Equal(t, NoWriteStall, storageState.WriteStallCondition())
+
+	setKeysInStorageState(t, storageState, "data-structure")
+	assert.Equal(t, WriteSlowdown, storageState.WriteStallCondition())
+}
+
+func TestWriteStallConditionWithStopOnImmutableMemtables(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	options := testStorageStateOptionsWithMemTableSizeAndDirectory(250, rootPath)
+	options.MaximumMemtables = 1
+	options.WriteStallOptions = WriteStallOptions{
+		SlowdownImmutableMemtables: 1,
+		StopImmutableMemtables:     3,
+	}
+	storageState, _ := NewStorageStateWithOptions(options)
+
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+		storageState.Close()
+	}()
+
+	setKeysInStorageState(t, storageState, "consensus", "storage", "data-structure", "isolation")
+	assert.Equal(t, WriteStop, storageState.WriteStallCondition())
+
+	assert.Nil(t, storageState.ForceFlushNextImmutableMemtable())
+	assert.Equal(t, WriteSlowdown, storageState.WriteStallCondition())
+}
+
+func TestStorageStateWithStopImmutableMemtablesNotAboveMaximumMemtables(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	defer test_utility.CleanupDirectoryWithTestName(t)
+
+	options := testStorageStateOptionsWithMemTableSizeAndDirectory(250, rootPath)
+	options.WriteStallOptions = WriteStallOptions{
+		StopImmutableMemtables: options.MaximumMemtables,
+	}
+	_, err := NewStorageStateWithOptions(options)
+	assert.ErrorIs(t, err, StopImmutableMemtablesNotAboveMaximumMemtablesErr)
+}
+
+func TestStorageStateWithStopLevel0SSTablesNotAboveTheLevel0CompactionTrigger(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	defer test_utility.CleanupDirectoryWithTestName(t)
+
+	options := testStorageStateOptionsWithDirectoryAndCompactionOptions(rootPath, SimpleLeveledCompactionOptions{
+		NumberOfSSTablesRatioPercentage: 200,
+		MaxLevels:                       3,
+		Level0FilesCompactionTrigger:    4,
+	})
+	options.WriteStallOptions = WriteStallOptions{
+		StopLevel0SSTables: 4,
+	}
+	_, err := NewStorageStateWithOptions(options)
+	assert.ErrorIs(t, err, StopLevel0SSTablesNotAboveCompactionTriggerErr)
+
+	options.CompactionOptions = CompactionOptions{
+		Strategy: LeveledCompactionStrategy,
+		LeveledStrategyOptions: LeveledCompactionOptions{
+			Level0FilesCompactionTrigger: 5,
+			BaseLevelSizeInBytes:         1 << 20,
+			LevelSizeMultiplier:          10,
+			MaxLevels:                    3,
+		},
+	}
+	_, err = NewStorageStateWithOptions(options)
+	assert.ErrorIs(t, err, StopLevel0SSTablesNotAboveCompactionTriggerErr)
+}
+
+func TestStorageStateWithStopLevel0SSTablesAndFIFOCompaction(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	options := testStorageStateOptionsWithMemTableSizeAndDirectory(250, rootPath)
+	options.CompactionOptions = CompactionOptions{
+		Strategy:            FIFOCompactionStrategy,
+		FIFOStrategyOptions: FIFOCompactionOptions{MaxAge: time.Hour},
+	}
+	options.WriteStallOptions = WriteStallOptions{
+		StopLevel0SSTables: 1,
+	}
+	storageState, err := NewStorageStateWithOptions(options)
+
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+		storageState.Close()
+	}()
+
+	assert.Nil(t, err)
+}
+
+func TestPendingCompactionBytesWithSimpleLeveledCompaction(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	storageState, _ := NewStorageStateWithOptions(testStorageStateOptionsWithDirectoryAndCompactionOptions(
+		rootPath,
+		SimpleLeveledCompactionOptions{
+			NumberOfSSTablesRatioPercentage: 200,
+			MaxLevels:                       2,
+			Level0FilesCompactionTrigger:    2,
+		},
+	))
+
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+		storageState.Close()
+	}()
+
+	setSSTableWithKeyInStorageState(t, storageState, rootPath, "consensus", 0)
+	assert.Equal(t, int64(0), storageState.PendingCompactionBytes())
+
+	l1SSTable := setSSTableWithKeyInStorageState(t, storageState, rootPath, "consensus", 1)
+	assert.Equal(t, l1SSTable.SizeInBytes(), storageState.PendingCompactionBytes())
+
+	setSSTableWithKeyInStorageState(t, storageState, rootPath, "consensus", 2)
+	setSSTableWithKeyInStorageState(t, storageState, rootPath, "storage", 2)
+	assert.Equal(t, int64(0), storageState.PendingCompactionBytes())
+}
+
+func TestPendingCompactionBytesWithLeveledCompaction(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	options := testStorageStateOptionsWithMemTableSizeAndDirectory(250, rootPath)
+	options.CompactionOptions = CompactionOptions{
+		Strategy: LeveledCompactionStrategy,
+		LeveledStrategyOptions: LeveledCompactionOptions{
+			Level0FilesCompactionTrigger: 2,
+			BaseLevelSizeInBytes:         1,
+			LevelSizeMultiplier:          2,
+			MaxLevels:                    3,
+		},
+	}
+	storageState, _ := NewStorageStateWithOptions(options)
+
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+		storageState.Close()
+	}()
+
+	var l2Size, l3Size int64
+	for _, key := range []string{"consensus", "storage"} {
+		l3Size += setSSTableWithKeyInStorageState(t, storageState, rootPath, key, 3).SizeInBytes()
+	}
+	assert.Equal(t, int64(0), storageState.PendingCompactionBytes())
+
+	for _, key := range []string{"consensus", "isolation", "storage"} {
+		l2Size += setSSTableWithKeyInStorageState(t, storageState, rootPath, key, 2).SizeInBytes()
+	}
+	l0SSTable := setSSTableWithKeyInStorageState(t, storageState, rootPath, "consensus", 0)
+	assert.Equal(t, l2Size-l3Size/2, storageState.PendingCompactionBytes())
+
+	anotherL0SSTable := setSSTableWithKeyInStorageState(t, storageState, rootPath, "storage", 0)
+	assert.Equal(
+		t,
+		l2Size-l3Size/2+l0SSTable.SizeInBytes()+anotherL0SSTable.SizeInBytes(),
+		storageState.PendingCompactionBytes(),
+	)
+}
+
+func TestPendingCompactionBytesWithTieredCompaction(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	options := testStorageStateOptionsWithMemTableSizeAndDirectory(250, rootPath)
+	options.CompactionOptions = CompactionOptions{
+		Strategy: TieredCompactionStrategy,
+		TieredStrategyOptions: TieredCompactionOptions{
+			SortedRunsCompactionTrigger: 3,
+			MaxLevels:                   3,
+		},
+	}
+	storageState, _ := NewStorageStateWithOptions(options)
+
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+		storageState.Close()
+	}()
+
+	setSSTableWithKeyInStorageState(t, storageState, rootPath, "consensus", 3)
+	l0SSTable := setSSTableWithKeyInStorageState(t, storageState, rootPath, "consensus", 0)
+	assert.Equal(t, int64(0), storageState.PendingCompactionBytes())
+
+	anotherL0SSTable := setSSTableWithKeyInStorageState(t, storageState, rootPath, "storage", 0)
+	assert.Equal(t, l0SSTable.SizeInBytes()+anotherL0SSTable.SizeInBytes(), storageState.PendingCompactionBytes())
+}
@@ -0,0 +1,49 @@
+package go_lsm_workshop
+
+import (
+	"go-lsm-workshop/state"
+	"go-lsm-workshop/txn"
+	"sync/atomic"
+	"time"
+)
+
+// Stats is a point-in-time view of the counters maintained by Db.
+// TransactionRetries is the total number of times a Readwrite transaction was re-run by Db.Update because of txn.ConflictErr.
+// TransactionConflicts is the total number of txn.ConflictErr seen by Db.Update.
+// TransactionsAborted is the total number of Db.Update invocations which gave up after exhausting the RetryPolicy.
+// A steady growth in TransactionRetries (relative to the number of updates) usually indicates hot key(s).
+// WriteStallCondition is the current state.WriteStallCondition, and DelayedWrites, StoppedWrites and WriteStallDuration are
+// the write stalls applied so far (Refer to txn.WriteStallStats). PendingCompactionBytes is the estimated number of bytes
+// which are yet to be compacted.
+type Stats struct {
+	TransactionRetries     uint64
+	TransactionConflicts   uint64
+	TransactionsAborted    uint64
+	WriteStallCondition    state.WriteStallCondition
+	DelayedWrites          uint64
+	StoppedWrites          uint64
+	WriteStallDuration     time.Duration
+	PendingCompactionBytes int64
+}
+
+// stats maintains the counters of Db, which are exposed as Stats.
+type stats struct {
+	transactionRetries   atomic.Uint64
+	transactionConflicts atomic.Uint64
+	transactionsAborted  atomic.Uint64
+}
+
+// snapshot returns the Stats, along with the write stalls of the storageState and the oracle.
+func (stats *stats) snapshot(storageState *state.StorageState, oracle *txn.Oracle) Stats {
+	writeStallStats := oracle.WriteStallStats()
+	return Stats{
+		TransactionRetries:     stats.transactionRetries.Load(),
+		TransactionConflicts:   stats.transactionConflicts.Load(),
+		TransactionsAborted:    stats.transactionsAborted.Load(),
+		WriteStallCondition:    storageState.WriteStallCondition(),
+		DelayedWrites:          writeStallStats.DelayedWrites,
+		StoppedWrites:          writeStallStats.StoppedWrites,
+		WriteStallDuration:     writeStallStats.StallDuration,
+		PendingCompactionBytes: storageState.PendingCompactionBytes(),
+	}
+}
@@ -86,6 +86,15 @@ func (metaList *MetaList) Length() int {
 	return len(metaList.list)
 }
 
+// StartingKeys returns the starting keys of all the blocks.
+func (metaList *MetaList) StartingKeys() []kv.Key {
+	startingKeys := make([]kv.Key, 0, len(metaList.list))
+	for _, blockMeta := range metaList.list {
+		startingKeys = append(startingKeys, blockMeta.StartingKey)
+	}
+	return startingKeys
+}
+
 // MaybeBlockMetaContaining returns the block meta that may contain the given key.
 // It compares the key with the StartingKey of the block meta.
 // It returns the instance of Meta where the given key is greater than or equal to the starting key.
@@ -123,6 +123,11 @@ func (builder SSTableBuilder) EstimatedSize() int {
 	return len(builder.allBlocksData)
 }
 
+// IsEmpty returns true if no key/value pair has been added to the SSTableBuilder.
+func (builder SSTableBuilder) IsEmpty() bool {
+	return builder.startingKey.IsRawKeyEmpty()
+}
+
 // finishBlock finishes the current block. It involves:
 // 1) Encoding the current block.
 // 2) Storing the block.Meta in the block meta-list.
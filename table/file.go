@@ -1,8 +1,11 @@
 package table
 
 import (
+	"hash/crc32"
 	"io"
 	"os"
+	"path/filepath"
+	"time"
 )
 
 // File represents SSTable file.
@@ -56,6 +59,80 @@ func (file *File) Size() int64 {
 	return file.size
 }
 
+// ModifiedTime returns the last modified time of the file.
+// SSTable files are immutable, so it is the time at which the file was written.
+func (file *File) ModifiedTime() (time.Time, error) {
+	stat, err := file.file.Stat()
+	if err != nil {
+		return time.Time{}, err
+	}
+	return stat.ModTime(), nil
+}
+
+// CopyFile copies the file at sourcePath to targetPath (creating the parent directory of targetPath, if needed).
+// The data is copied to a temporary file which is synced and then renamed to targetPath, so targetPath is either absent or
+// complete, even if the copy is interrupted.
+// It is used in checkpoints and backups.
+func CopyFile(sourcePath, targetPath string) error {
+	source, err := os.Open(sourcePath)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = source.Close()
+	}()
+
+	if err := os.MkdirAll(filepath.Dir(targetPath), os.ModePerm); err != nil {
+		return err
+	}
+	temporaryPath := targetPath + ".tmp"
+	target, err := os.Create(temporaryPath)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(target, source); err != nil {
+		_ = target.Close()
+		return err
+	}
+	if err := target.Sync(); err != nil {
+		_ = target.Close()
+		return err
+	}
+	if err := target.Close(); err != nil {
+		return err
+	}
+	return os.Rename(temporaryPath, targetPath)
+}
+
+// LinkOrCopyFile hard-links the file at sourcePath to targetPath, and falls back to CopyFile if linking fails (for example,
+// across file systems).
+// Hard-linking is safe for the files which are never modified after creation (like SSTables).
+func LinkOrCopyFile(sourcePath, targetPath string) error {
+	if err := os.Link(sourcePath, targetPath); err != nil {
+		return CopyFile(sourcePath, targetPath)
+	}
+	return nil
+}
+
+// Checksum returns the CRC-32 (IEEE) checksum and the size of the file at filePath.
+// It is used in backups to verify the SSTables which are shared between the backups.
+func Checksum(filePath string) (uint32, int64, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+
+	hash := crc32.NewIEEE()
+	size, err := io.Copy(hash, file)
+	if err != nil {
+		return 0, 0, err
+	}
+	return hash.Sum32(), size, nil
+}
+
 // syncWrite performs fsync operation after writing the data to the file.
 // The file is closed after syncWrite.
 func syncWrite(path string, data []byte) error {
@@ -8,6 +8,7 @@ import (
 	"go-lsm-workshop/table/bloom"
 	"os"
 	"sync/atomic"
+	"time"
 )
 
 // SSTable is an in-memory representation of the file on disk. An SSTable contains the data sorted by key.
@@ -27,7 +28,13 @@ type SSTable struct {
 // Load loads the entire SSTable from the given rootPath.
 // Please take a look at table.SSTableBuilder to understand the encoding of SSTable.
 func Load(id uint64, rootPath string, blockSize uint) (*SSTable, error) {
-	file, err := Open(SSTableFilePath(id, rootPath))
+	return LoadFromFilePath(id, SSTableFilePath(id, rootPath), blockSize)
+}
+
+// LoadFromFilePath loads the entire SSTable from the given filePath, the file need not be named by the id.
+// It is used to load the SSTables which are built outside the database (Refer to state.StorageState.IngestSSTables).
+func LoadFromFilePath(id uint64, filePath string, blockSize uint) (*SSTable, error) {
+	file, err := Open(filePath)
 	if err != nil {
 		return nil, err
 	}
@@ -185,11 +192,42 @@ func (table *SSTable) Id() uint64 {
 	return table.id
 }
 
+// StartingKey returns the starting (smallest) key of the SSTable.
+func (table *SSTable) StartingKey() kv.Key {
+	return table.startingKey
+}
+
+// EndingKey returns the ending (largest) key of the SSTable.
+func (table *SSTable) EndingKey() kv.Key {
+	return table.endingKey
+}
+
+// BlockStartingKeys returns the starting keys of all the blocks of the SSTable (Refer to block.MetaList).
+// It is used in compact.Compaction to split a compaction into subcompactions.
+func (table *SSTable) BlockStartingKeys() []kv.Key {
+	return table.blockMetaList.StartingKeys()
+}
+
+// SizeInBytes returns the size of the SSTable file.
+func (table *SSTable) SizeInBytes() int64 {
+	return table.file.Size()
+}
+
+// CreationTime returns the time at which the SSTable file was written.
+func (table *SSTable) CreationTime() (time.Time, error) {
+	return table.file.ModifiedTime()
+}
+
 // TotalReferences returns the total references to the SSTable.
 func (table *SSTable) TotalReferences() int64 {
 	return table.references.Load()
 }
 
+// Close closes the file of the SSTable, without removing it.
+func (table *SSTable) Close() error {
+	return table.file.file.Close()
+}
+
 // Remove removes the SSTable.
 func (table *SSTable) Remove() error {
 	if err := table.file.file.Close(); err != nil {
@@ -201,6 +239,14 @@ func (table *SSTable) Remove() error {
 	return nil
 }
 
+// IncrementReferenceFor increments the references for all the SSTables.
+// It is used to pin the SSTables, so that they are not removed by SSTableCleaner while in use.
+func IncrementReferenceFor(tables []*SSTable) {
+	for _, table := range tables {
+		table.incrementReference()
+	}
+}
+
 // DecrementReferenceFor decrements the references for all the SSTables.
 func DecrementReferenceFor(tables []*SSTable) {
 	for _, table := range tables {
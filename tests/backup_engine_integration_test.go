@@ -0,0 +1,184 @@
+package tests
+
+import (
+	"context"
+	"fmt"
+	go_lsm_workshop "go-lsm-workshop"
+	"go-lsm-workshop/state"
+	"go-lsm-workshop/table"
+	"go-lsm-workshop/test_utility"
+	"go-lsm-workshop/txn"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func backupTestStorageOptions(directory string) state.StorageOptions {
+	return state.StorageOptions{
+		MemTableSizeInBytes:   1 * 1024,
+		Path:                  directory,
+		MaximumMemtables:      2,
+		FlushMemtableDuration: 1 * time.Millisecond,
+		SSTableSizeInBytes:    4096,
+	}
+}
+
+func writeKeys(t *testing.T, db *go_lsm_workshop.Db, from, to int) {
+	for count := from; count < to; count++ {
+		future, err := db.Write(context.Background(), func(transaction *txn.Transaction) {
+			key, value := fmt.Sprintf("key-%03d", count), fmt.Sprintf("value-%03d", count)
+			assert.Nil(t, transaction.Set([]byte(key), []byte(value)))
+		})
+		assert.Nil(t, err)
+		future.Wait()
+	}
+}
+
+func assertKeysInRestoredDb(t *testing.T, directory string, presentTill, absentTill int) {
+	db, err := go_lsm_workshop.Open(backupTestStorageOptions(directory))
+	assert.Nil(t, err)
+	defer db.Close()
+
+	assert.Nil(t, db.Read(context.Background(), func(transaction *txn.Transaction) {
+		for count := 0; count < presentTill; count++ {
+			value, ok := transaction.Get([]byte(fmt.Sprintf("key-%03d", count)))
+			assert.True(t, ok)
+			assert.Equal(t, fmt.Sprintf("value-%03d", count), value.String())
+		}
+		for count := presentTill; count < absentTill; count++ {
+			_, ok := transaction.Get([]byte(fmt.Sprintf("key-%03d", count)))
+			assert.False(t, ok)
+		}
+	}))
+}
+
+func TestIncrementalBackupsAndRestore(t *testing.T) {
+	directory := test_utility.SetupADirectoryWithTestName(t)
+	db, _ := go_lsm_workshop.Open(backupTestStorageOptions(filepath.Join(directory, "db")))
+	defer func() {
+		db.Close()
+		test_utility.CleanupDirectoryWithTestName(t)
+	}()
+
+	backupEngine, err := go_lsm_workshop.NewBackupEngine(filepath.Join(directory, "backup"))
+	assert.Nil(t, err)
+
+	writeKeys(t, db, 0, 50)
+	firstBackup, err := backupEngine.CreateBackup(context.Background(), db)
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(1), firstBackup.Id)
+	assert.True(t, len(firstBackup.SSTableIds) > 0)
+
+	ssTablesDirectory := filepath.Join(directory, "backup", "sstables")
+	firstBackupSSTableStats := make(map[uint64]os.FileInfo)
+	for _, ssTableId := range firstBackup.SSTableIds {
+		stat, err := os.Stat(table.SSTableFilePath(ssTableId, ssTablesDirectory))
+		assert.Nil(t, err)
+		firstBackupSSTableStats[ssTableId] = stat
+	}
+
+	writeKeys(t, db, 50, 100)
+	secondBackup, err := backupEngine.CreateBackup(context.Background(), db)
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(2), secondBackup.Id)
+	assert.True(t, len(secondBackup.SSTableIds) > len(firstBackup.SSTableIds))
+
+	for ssTableId, firstStat := range firstBackupSSTableStats {
+		stat, err := os.Stat(table.SSTableFilePath(ssTableId, ssTablesDirectory))
+		assert.Nil(t, err)
+		assert.True(t, os.SameFile(firstStat, stat))
+	}
+
+	backups, err := backupEngine.ListBackups()
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(backups))
+	assert.Equal(t, uint64(1), backups[0].Id)
+	assert.Equal(t, uint64(2), backups[1].Id)
+
+	assert.Nil(t, backupEngine.Restore(1, filepath.Join(directory, "restored-1")))
+	assertKeysInRestoredDb(t, filepath.Join(directory, "restored-1"), 50, 100)
+
+	assert.Nil(t, backupEngine.Restore(2, filepath.Join(directory, "restored-2")))
+	assertKeysInRestoredDb(t, filepath.Join(directory, "restored-2"), 100, 100)
+
+	assert.Equal(t, go_lsm_workshop.RestoreDirectoryExistsErr, backupEngine.Restore(2, filepath.Join(directory, "restored-2")))
+	assert.Equal(t, go_lsm_workshop.BackupNotFoundErr, backupEngine.Restore(3, filepath.Join(directory, "restored-3")))
+}
+
+func TestPurgeOldBackups(t *testing.T) {
+	directory := test_utility.SetupADirectoryWithTestName(t)
+	db, _ := go_lsm_workshop.Open(backupTestStorageOptions(filepath.Join(directory, "db")))
+	defer func() {
+		db.Close()
+		test_utility.CleanupDirectoryWithTestName(t)
+	}()
+
+	backupEngine, err := go_lsm_workshop.NewBackupEngine(filepath.Join(directory, "backup"))
+	assert.Nil(t, err)
+
+	for backup := 0; backup < 3; backup++ {
+		writeKeys(t, db, backup*30, (backup+1)*30)
+		_, err := backupEngine.CreateBackup(context.Background(), db)
+		assert.Nil(t, err)
+	}
+	assert.Nil(t, backupEngine.PurgeOldBackups(1))
+
+	backups, err := backupEngine.ListBackups()
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(backups))
+	assert.Equal(t, uint64(3), backups[0].Id)
+
+	ssTableFiles, err := os.ReadDir(filepath.Join(directory, "backup", "sstables"))
+	assert.Nil(t, err)
+	assert.Equal(t, len(backups[0].SSTableIds), len(ssTableFiles))
+
+	assert.Nil(t, backupEngine.Restore(3, filepath.Join(directory, "restored")))
+	assertKeysInRestoredDb(t, filepath.Join(directory, "restored"), 90, 90)
+}
+
+func TestBackupOfADbRestoredFromAnOlderBackupWhichReusesTheSSTableIdsOfANewerBackup(t *testing.T) {
+	directory := test_utility.SetupADirectoryWithTestName(t)
+	db, _ := go_lsm_workshop.Open(backupTestStorageOptions(filepath.Join(directory, "db")))
+	defer func() {
+		db.Close()
+		test_utility.CleanupDirectoryWithTestName(t)
+	}()
+
+	backupEngine, err := go_lsm_workshop.NewBackupEngine(filepath.Join(directory, "backup"))
+	assert.Nil(t, err)
+
+	writeKeys(t, db, 0, 50)
+	firstBackup, err := backupEngine.CreateBackup(context.Background(), db)
+	assert.Nil(t, err)
+
+	writeKeys(t, db, 50, 100)
+	secondBackup, err := backupEngine.CreateBackup(context.Background(), db)
+	assert.Nil(t, err)
+	assert.True(t, len(secondBackup.SSTableIds) > len(firstBackup.SSTableIds))
+
+	assert.Nil(t, backupEngine.Restore(firstBackup.Id, filepath.Join(directory, "restored")))
+	restoredDb, err := go_lsm_workshop.Open(backupTestStorageOptions(filepath.Join(directory, "restored")))
+	assert.Nil(t, err)
+	defer restoredDb.Close()
+
+	for count := 50; count < 100; count++ {
+		future, err := restoredDb.Write(context.Background(), func(transaction *txn.Transaction) {
+			key, value := fmt.Sprintf("key-%03d", count), fmt.Sprintf("restored-value-%03d", count)
+			assert.Nil(t, transaction.Set([]byte(key), []byte(value)))
+		})
+		assert.Nil(t, err)
+		future.Wait()
+	}
+	_, err = backupEngine.CreateBackup(context.Background(), restoredDb)
+	assert.Equal(t, go_lsm_workshop.BackupSSTableMismatchErr, err)
+
+	backups, err := backupEngine.ListBackups()
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(backups))
+
+	assert.Nil(t, backupEngine.Restore(secondBackup.Id, filepath.Join(directory, "restored-2")))
+	assertKeysInRestoredDb(t, filepath.Join(directory, "restored-2"), 100, 100)
+}
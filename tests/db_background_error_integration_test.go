@@ -0,0 +1,76 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	go_lsm_workshop "go-lsm-workshop"
+	"go-lsm-workshop/state"
+	"go-lsm-workshop/test_utility"
+	"go-lsm-workshop/txn"
+	"os"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDbWithRetryableBackgroundError(t *testing.T) {
+	directory := test_utility.SetupADirectoryWithTestName(t)
+	db, _ := go_lsm_workshop.Open(compactRangeTestStorageOptions(directory))
+	defer func() {
+		db.Close()
+		test_utility.CleanupDirectoryWithTestName(t)
+	}()
+
+	db.StorageState().ReportBackgroundOutcome(state.CompactionOperation, errors.New("transient"))
+
+	var backgroundError *state.BackgroundError
+	assert.ErrorAs(t, db.BackgroundError(), &backgroundError)
+	assert.False(t, backgroundError.Fatal)
+	assert.Equal(t, state.CompactionOperation, backgroundError.Operation)
+
+	writeKeys(t, db, 0, 10)
+
+	db.StorageState().ReportBackgroundOutcome(state.FlushOperation, nil)
+	assert.NotNil(t, db.BackgroundError())
+
+	db.StorageState().ReportBackgroundOutcome(state.CompactionOperation, nil)
+	assert.Nil(t, db.BackgroundError())
+}
+
+func TestDbSwitchesToReadonlyModeOnFatalBackgroundError(t *testing.T) {
+	directory := test_utility.SetupADirectoryWithTestName(t)
+	db, _ := go_lsm_workshop.Open(compactRangeTestStorageOptions(directory))
+	defer func() {
+		db.Close()
+		test_utility.CleanupDirectoryWithTestName(t)
+	}()
+
+	writeKeys(t, db, 0, 10)
+
+	db.StorageState().ReportBackgroundOutcome(state.FlushOperation, &os.PathError{Op: "write", Path: "1.sst", Err: syscall.EIO})
+	db.StorageState().ReportBackgroundOutcome(state.FlushOperation, nil)
+	db.StorageState().ReportBackgroundOutcome(state.CompactionOperation, errors.New("transient"))
+
+	var backgroundError *state.BackgroundError
+	assert.ErrorAs(t, db.BackgroundError(), &backgroundError)
+	assert.True(t, backgroundError.Fatal)
+	assert.Equal(t, state.FlushOperation, backgroundError.Operation)
+
+	_, err := db.Write(context.Background(), func(transaction *txn.Transaction) {
+		assert.Nil(t, transaction.Set([]byte("consensus"), []byte("raft")))
+	})
+	assert.ErrorIs(t, err, go_lsm_workshop.DbReadonlyErr)
+	assert.ErrorIs(t, err, syscall.EIO)
+
+	writeBatch := db.NewWriteBatch(context.Background())
+	assert.Nil(t, writeBatch.Set([]byte("consensus"), []byte("raft")))
+	_, err = writeBatch.Flush()
+	assert.ErrorIs(t, err, go_lsm_workshop.DbReadonlyErr)
+
+	assert.Nil(t, db.Read(context.Background(), func(transaction *txn.Transaction) {
+		value, ok := transaction.Get([]byte("key-005"))
+		assert.True(t, ok)
+		assert.Equal(t, "value-005", value.String())
+	}))
+}
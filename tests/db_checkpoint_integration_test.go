@@ -0,0 +1,98 @@
+package tests
+
+import (
+	"context"
+	"fmt"
+	go_lsm_workshop "go-lsm-workshop"
+	"go-lsm-workshop/state"
+	"go-lsm-workshop/test_utility"
+	"go-lsm-workshop/txn"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckpointWithMemtableFlush(t *testing.T) {
+	testCheckpointYieldsTheSnapshot(t, go_lsm_workshop.CheckpointOptions{})
+}
+
+func TestCheckpointWithoutMemtableFlush(t *testing.T) {
+	testCheckpointYieldsTheSnapshot(t, go_lsm_workshop.CheckpointOptions{SkipMemtableFlush: true})
+}
+
+func TestCheckpointInAnExistingDirectory(t *testing.T) {
+	directory := test_utility.SetupADirectoryWithTestName(t)
+	storageOptions := state.StorageOptions{
+		MemTableSizeInBytes:   1 * 1024,
+		Path:                  filepath.Join(directory, "db"),
+		MaximumMemtables:      2,
+		FlushMemtableDuration: 1 * time.Millisecond,
+		SSTableSizeInBytes:    4096,
+	}
+	db, _ := go_lsm_workshop.Open(storageOptions)
+	defer func() {
+		db.Close()
+		test_utility.CleanupDirectoryWithTestName(t)
+	}()
+
+	assert.Equal(t, state.CheckpointDirectoryExistsErr, db.Checkpoint(context.Background(), directory))
+}
+
+func testCheckpointYieldsTheSnapshot(t *testing.T, options go_lsm_workshop.CheckpointOptions) {
+	directory := test_utility.SetupADirectoryWithTestName(t)
+	storageOptions := state.StorageOptions{
+		MemTableSizeInBytes:   1 * 1024,
+		Path:                  filepath.Join(directory, "db"),
+		MaximumMemtables:      2,
+		FlushMemtableDuration: 1 * time.Millisecond,
+		SSTableSizeInBytes:    4096,
+	}
+	db, _ := go_lsm_workshop.Open(storageOptions)
+	defer func() {
+		db.Close()
+		test_utility.CleanupDirectoryWithTestName(t)
+	}()
+
+	const keys = 50
+	for count := 0; count < keys; count++ {
+		future, err := db.Write(context.Background(), func(transaction *txn.Transaction) {
+			key, value := fmt.Sprintf("key-%03d", count), fmt.Sprintf("value-%03d", count)
+			assert.Nil(t, transaction.Set([]byte(key), []byte(value)))
+		})
+		assert.Nil(t, err)
+		future.Wait()
+	}
+
+	checkpointDirectory := filepath.Join(directory, "checkpoint")
+	assert.Nil(t, db.CheckpointWithOptions(context.Background(), checkpointDirectory, options))
+
+	future, err := db.Write(context.Background(), func(transaction *txn.Transaction) {
+		assert.Nil(t, transaction.Set([]byte("key-000"), []byte("updated")))
+		assert.Nil(t, transaction.Set([]byte("key-100"), []byte("value-100")))
+	})
+	assert.Nil(t, err)
+	future.Wait()
+
+	checkpointDb, err := go_lsm_workshop.Open(state.StorageOptions{
+		MemTableSizeInBytes:   1 * 1024,
+		Path:                  checkpointDirectory,
+		MaximumMemtables:      2,
+		FlushMemtableDuration: 1 * time.Millisecond,
+		SSTableSizeInBytes:    4096,
+	})
+	assert.Nil(t, err)
+	defer checkpointDb.Close()
+
+	assert.Nil(t, checkpointDb.Read(context.Background(), func(transaction *txn.Transaction) {
+		_, ok := transaction.Get([]byte("key-100"))
+		assert.False(t, ok)
+
+		for count := 0; count < keys; count++ {
+			value, ok := transaction.Get([]byte(fmt.Sprintf("key-%03d", count)))
+			assert.True(t, ok)
+			assert.Equal(t, fmt.Sprintf("value-%03d", count), value.String())
+		}
+	}))
+}
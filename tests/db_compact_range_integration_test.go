@@ -0,0 +1,167 @@
+package tests
+
+import (
+	"context"
+	"fmt"
+	go_lsm_workshop "go-lsm-workshop"
+	"go-lsm-workshop/kv"
+	"go-lsm-workshop/state"
+	"go-lsm-workshop/test_utility"
+	"go-lsm-workshop/txn"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func compactRangeTestStorageOptions(directory string) state.StorageOptions {
+	return state.StorageOptions{
+		MemTableSizeInBytes:   1 * 1024,
+		Path:                  directory,
+		MaximumMemtables:      2,
+		FlushMemtableDuration: 1 * time.Millisecond,
+		SSTableSizeInBytes:    4096,
+		CompactionOptions: state.CompactionOptions{
+			StrategyOptions: state.SimpleLeveledCompactionOptions{
+				NumberOfSSTablesRatioPercentage: 200,
+				MaxLevels:                       3,
+				Level0FilesCompactionTrigger:    1000,
+			},
+			Duration: 5 * time.Millisecond,
+		},
+	}
+}
+
+// waitForTheFlushes waits till the flush goroutine has flushed the immutable memtables beyond MaximumMemtables.
+func waitForTheFlushes(t *testing.T, db *go_lsm_workshop.Db) {
+	assert.Eventually(t, func() bool {
+		return uint(db.StorageState().TotalImmutableMemtables()) < db.StorageState().Options().MaximumMemtables
+	}, 5*time.Second, 5*time.Millisecond)
+}
+
+func TestCompactRangeIntoTheTargetLevel(t *testing.T) {
+	directory := test_utility.SetupADirectoryWithTestName(t)
+	db, _ := go_lsm_workshop.Open(compactRangeTestStorageOptions(directory))
+	defer func() {
+		db.Close()
+		test_utility.CleanupDirectoryWithTestName(t)
+	}()
+
+	writeKeys(t, db, 0, 200)
+	for count := 0; count < 100; count++ {
+		future, err := db.Write(context.Background(), func(transaction *txn.Transaction) {
+			assert.Nil(t, transaction.Delete([]byte(fmt.Sprintf("key-%03d", count))))
+		})
+		assert.Nil(t, err)
+		future.Wait()
+	}
+	for count := 0; count < 200; count++ {
+		future, err := db.Write(context.Background(), func(transaction *txn.Transaction) {
+			assert.Nil(t, transaction.Set([]byte(fmt.Sprintf("zzz-%03d", count)), []byte("filler")))
+		})
+		assert.Nil(t, err)
+		future.Wait()
+	}
+	assert.Eventually(t, func() bool {
+		return db.StorageState().TotalSSTablesAtLevel(0) > 1
+	}, 5*time.Second, 5*time.Millisecond)
+
+	stats, err := db.CompactRange(context.Background(), kv.NewInclusiveKeyRange(kv.RawKey("key-000"), kv.RawKey("key-999")), 2)
+	assert.Nil(t, err)
+	assert.True(t, stats.InputSSTables > 1)
+	assert.True(t, stats.OutputSSTables > 0)
+	assert.True(t, stats.BytesRead > 0)
+	assert.True(t, stats.BytesWritten > 0)
+
+	assert.Equal(t, stats.OutputSSTables, db.StorageState().TotalSSTablesAtLevel(2))
+
+	assert.Nil(t, db.Read(context.Background(), func(transaction *txn.Transaction) {
+		for count := 0; count < 200; count++ {
+			value, ok := transaction.Get([]byte(fmt.Sprintf("key-%03d", count)))
+			if count < 100 {
+				assert.False(t, ok)
+				continue
+			}
+			assert.True(t, ok)
+			assert.Equal(t, fmt.Sprintf("value-%03d", count), value.String())
+		}
+	}))
+}
+
+func TestCompactRangeKeepsTheDeletedKeysGivenTheOlderVersionsAreAtALowerLevel(t *testing.T) {
+	directory := test_utility.SetupADirectoryWithTestName(t)
+	db, _ := go_lsm_workshop.Open(compactRangeTestStorageOptions(directory))
+	defer func() {
+		db.Close()
+		test_utility.CleanupDirectoryWithTestName(t)
+	}()
+
+	writeFiller := func(prefix string) {
+		for count := 0; count < 200; count++ {
+			future, err := db.Write(context.Background(), func(transaction *txn.Transaction) {
+				assert.Nil(t, transaction.Set([]byte(fmt.Sprintf("%v-%03d", prefix, count)), []byte("filler")))
+			})
+			assert.Nil(t, err)
+			future.Wait()
+		}
+		waitForTheFlushes(t, db)
+	}
+
+	writeKeys(t, db, 0, 200)
+	writeFiller("yyy")
+	_, err := db.CompactRange(context.Background(), kv.NewInclusiveKeyRange(kv.RawKey("key-000"), kv.RawKey("key-999")), 2)
+	assert.Nil(t, err)
+	assert.True(t, db.StorageState().TotalSSTablesAtLevel(2) > 0)
+
+	for count := 0; count < 100; count++ {
+		future, err := db.Write(context.Background(), func(transaction *txn.Transaction) {
+			assert.Nil(t, transaction.Delete([]byte(fmt.Sprintf("key-%03d", count))))
+		})
+		assert.Nil(t, err)
+		future.Wait()
+	}
+	writeFiller("zzz")
+	_, err = db.CompactRange(context.Background(), kv.NewInclusiveKeyRange(kv.RawKey("key-000"), kv.RawKey("key-099")), 1)
+	assert.Nil(t, err)
+	assert.True(t, db.StorageState().TotalSSTablesAtLevel(1) > 0)
+
+	assert.Nil(t, db.Read(context.Background(), func(transaction *txn.Transaction) {
+		for count := 0; count < 200; count++ {
+			value, ok := transaction.Get([]byte(fmt.Sprintf("key-%03d", count)))
+			if count < 100 {
+				assert.False(t, ok)
+				continue
+			}
+			assert.True(t, ok)
+			assert.Equal(t, fmt.Sprintf("value-%03d", count), value.String())
+		}
+	}))
+}
+
+func TestCompactRangeWithNoOverlappingSSTables(t *testing.T) {
+	directory := test_utility.SetupADirectoryWithTestName(t)
+	db, _ := go_lsm_workshop.Open(compactRangeTestStorageOptions(directory))
+	defer func() {
+		db.Close()
+		test_utility.CleanupDirectoryWithTestName(t)
+	}()
+
+	writeKeys(t, db, 0, 50)
+	waitForTheFlushes(t, db)
+
+	stats, err := db.CompactRange(context.Background(), kv.NewInclusiveKeyRange(kv.RawKey("raft"), kv.RawKey("zab")), 1)
+	assert.Nil(t, err)
+	assert.Equal(t, go_lsm_workshop.CompactRangeStats{}, stats)
+}
+
+func TestCompactRangeWithInvalidTargetLevel(t *testing.T) {
+	directory := test_utility.SetupADirectoryWithTestName(t)
+	db, _ := go_lsm_workshop.Open(compactRangeTestStorageOptions(directory))
+	defer func() {
+		db.Close()
+		test_utility.CleanupDirectoryWithTestName(t)
+	}()
+
+	_, err := db.CompactRange(context.Background(), kv.NewInclusiveKeyRange(kv.RawKey("key-000"), kv.RawKey("key-999")), 4)
+	assert.Equal(t, go_lsm_workshop.InvalidTargetLevelErr, err)
+}
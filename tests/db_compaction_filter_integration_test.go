@@ -0,0 +1,72 @@
+package tests
+
+import (
+	"context"
+	"fmt"
+	go_lsm_workshop "go-lsm-workshop"
+	"go-lsm-workshop/kv"
+	"go-lsm-workshop/state"
+	"go-lsm-workshop/test_utility"
+	"go-lsm-workshop/txn"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type schemaUpgradeCompactionFilter struct{}
+
+func (filter schemaUpgradeCompactionFilter) Filter(key kv.Key, value kv.Value, level int) (state.CompactionFilterDecision, kv.Value) {
+	if value.String() == "soft-deleted" {
+		return state.CompactionFilterDrop, kv.EmptyValue
+	}
+	if strings.HasPrefix(value.String(), "value-") {
+		return state.CompactionFilterChangeValue, kv.NewStringValue(strings.Replace(value.String(), "value-", "upgraded-", 1))
+	}
+	return state.CompactionFilterKeep, value
+}
+
+func TestCompactionFilterDuringCompactRange(t *testing.T) {
+	directory := test_utility.SetupADirectoryWithTestName(t)
+	options := compactRangeTestStorageOptions(directory)
+	options.CompactionFilter = schemaUpgradeCompactionFilter{}
+
+	db, _ := go_lsm_workshop.Open(options)
+	defer func() {
+		db.Close()
+		test_utility.CleanupDirectoryWithTestName(t)
+	}()
+
+	writeKeys(t, db, 0, 200)
+	for count := 0; count < 100; count++ {
+		future, err := db.Write(context.Background(), func(transaction *txn.Transaction) {
+			assert.Nil(t, transaction.Set([]byte(fmt.Sprintf("key-%03d", count)), []byte("soft-deleted")))
+		})
+		assert.Nil(t, err)
+		future.Wait()
+	}
+	for count := 0; count < 200; count++ {
+		future, err := db.Write(context.Background(), func(transaction *txn.Transaction) {
+			assert.Nil(t, transaction.Set([]byte(fmt.Sprintf("zzz-%03d", count)), []byte("filler")))
+		})
+		assert.Nil(t, err)
+		future.Wait()
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	_, err := db.CompactRange(context.Background(), kv.NewInclusiveKeyRange(kv.RawKey("key-000"), kv.RawKey("key-999")), 3)
+	assert.Nil(t, err)
+
+	assert.Nil(t, db.Read(context.Background(), func(transaction *txn.Transaction) {
+		for count := 0; count < 200; count++ {
+			value, ok := transaction.Get([]byte(fmt.Sprintf("key-%03d", count)))
+			if count < 100 {
+				assert.False(t, ok)
+				continue
+			}
+			assert.True(t, ok)
+			assert.Equal(t, fmt.Sprintf("upgraded-%03d", count), value.String())
+		}
+	}))
+}
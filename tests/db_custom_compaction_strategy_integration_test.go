@@ -0,0 +1,84 @@
+package tests
+
+import (
+	"context"
+	"fmt"
+	go_lsm_workshop "go-lsm-workshop"
+	"go-lsm-workshop/compact/meta"
+	"go-lsm-workshop/state"
+	"go-lsm-workshop/test_utility"
+	"go-lsm-workshop/txn"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// lastLevelCompactionStrategy compacts all the level0 SSTables along with all the SSTables of the last level into the
+// last level.
+type lastLevelCompactionStrategy struct {
+	maxLevels uint
+}
+
+func (strategy lastLevelCompactionStrategy) CompactionDescription(snapshot state.StorageStateSnapshot) (meta.SortedRunsCompactionDescription, bool) {
+	if len(snapshot.L0SSTableIds) < 2 {
+		return meta.NothingToCompactSortedRunsDescription, false
+	}
+	lastLevel := int(strategy.maxLevels)
+	return meta.SortedRunsCompactionDescription{
+		SortedRuns: []meta.SortedRun{
+			{Level: 0, SSTableIds: snapshot.L0SSTableIds},
+			{Level: lastLevel, SSTableIds: snapshot.SSTableIdsAt(lastLevel)},
+		},
+		OutputLevel: lastLevel,
+	}, true
+}
+
+func (strategy lastLevelCompactionStrategy) MaxLevels() uint {
+	return strategy.maxLevels
+}
+
+func TestGetAfterCompactionWithCustomStrategyAndReopen(t *testing.T) {
+	directory := test_utility.SetupADirectoryWithTestName(t)
+	storageOptions := state.StorageOptions{
+		MemTableSizeInBytes:   1 * 1024,
+		Path:                  directory,
+		MaximumMemtables:      2,
+		FlushMemtableDuration: 1 * time.Millisecond,
+		SSTableSizeInBytes:    1024,
+		CompactionOptions: state.CompactionOptions{
+			CustomStrategy: lastLevelCompactionStrategy{maxLevels: 2},
+			Duration:       5 * time.Millisecond,
+		},
+	}
+	db, _ := go_lsm_workshop.Open(storageOptions)
+	defer test_utility.CleanupDirectoryWithTestName(t)
+
+	const keys = 200
+	for count := 0; count < keys; count++ {
+		future, err := db.Write(context.Background(), func(transaction *txn.Transaction) {
+			key, value := fmt.Sprintf("key-%03d", (count*7)%keys), fmt.Sprintf("value-%03d", count)
+			assert.Nil(t, transaction.Set([]byte(key), []byte(value)))
+		})
+		assert.Nil(t, err)
+		future.Wait()
+	}
+
+	time.Sleep(1 * time.Second)
+
+	assert.True(t, db.StorageState().TotalSSTablesAtLevel(2) > 0)
+	assert.Equal(t, 0, db.StorageState().TotalSSTablesAtLevel(1))
+	db.Close()
+
+	db, _ = go_lsm_workshop.Open(storageOptions)
+	defer db.Close()
+
+	assert.True(t, db.StorageState().TotalSSTablesAtLevel(2) > 0)
+	assert.Nil(t, db.Read(context.Background(), func(transaction *txn.Transaction) {
+		for count := 0; count < keys; count++ {
+			value, ok := transaction.Get([]byte(fmt.Sprintf("key-%03d", (count*7)%keys)))
+			assert.True(t, ok)
+			assert.Equal(t, fmt.Sprintf("value-%03d", count), value.String())
+		}
+	}))
+}
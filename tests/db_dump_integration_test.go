@@ -0,0 +1,157 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	go_lsm_workshop "go-lsm-workshop"
+	"go-lsm-workshop/kv"
+	"go-lsm-workshop/test_utility"
+	"go-lsm-workshop/txn"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExportAndImport(t *testing.T) {
+	directory := test_utility.SetupADirectoryWithTestName(t)
+	db, _ := go_lsm_workshop.Open(backupTestStorageOptions(filepath.Join(directory, "db")))
+	importedDb, _ := go_lsm_workshop.Open(backupTestStorageOptions(filepath.Join(directory, "imported")))
+	defer func() {
+		db.Close()
+		importedDb.Close()
+		test_utility.CleanupDirectoryWithTestName(t)
+	}()
+
+	writeKeys(t, db, 0, 50)
+	future, err := db.Write(context.Background(), func(transaction *txn.Transaction) {
+		assert.Nil(t, transaction.Delete([]byte("key-010")))
+	})
+	assert.Nil(t, err)
+	future.Wait()
+
+	var dump bytes.Buffer
+	assert.Nil(t, db.Export(context.Background(), &dump, kv.NewInclusiveKeyRange(kv.RawKey("key-000"), kv.RawKey("key-039")), 0))
+	assert.Equal(t, 40, strings.Count(dump.String(), "\n"))
+
+	records, err := importedDb.Import(context.Background(), &dump)
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(39), records)
+
+	assert.Nil(t, importedDb.Read(context.Background(), func(transaction *txn.Transaction) {
+		for count := 0; count < 50; count++ {
+			value, ok := transaction.Get([]byte(fmt.Sprintf("key-%03d", count)))
+			if count == 10 || count >= 40 {
+				assert.False(t, ok)
+				continue
+			}
+			assert.True(t, ok)
+			assert.Equal(t, fmt.Sprintf("value-%03d", count), value.String())
+		}
+	}))
+}
+
+func TestExportAsOfTimestamp(t *testing.T) {
+	directory := test_utility.SetupADirectoryWithTestName(t)
+	db, _ := go_lsm_workshop.Open(backupTestStorageOptions(filepath.Join(directory, "db")))
+	defer func() {
+		db.Close()
+		test_utility.CleanupDirectoryWithTestName(t)
+	}()
+
+	writeKeys(t, db, 0, 10)
+	asOfTimestamp, err := db.LatestCommitTimestamp(context.Background())
+	assert.Nil(t, err)
+	writeKeys(t, db, 10, 20)
+
+	var dump bytes.Buffer
+	assert.Nil(t, db.Export(context.Background(), &dump, kv.NewInclusiveKeyRange(kv.RawKey("key-000"), kv.RawKey("key-999")), asOfTimestamp))
+	assert.Equal(t, 11, strings.Count(dump.String(), "\n"))
+
+	assert.Equal(
+		t,
+		go_lsm_workshop.AsOfTimestampAfterLatestCommitTimestampErr,
+		db.Export(context.Background(), &dump, kv.NewInclusiveKeyRange(kv.RawKey("key-000"), kv.RawKey("key-999")), asOfTimestamp+100),
+	)
+}
+
+type writerFunc func(buffer []byte) (int, error)
+
+func (writer writerFunc) Write(buffer []byte) (int, error) {
+	return writer(buffer)
+}
+
+func TestExportPinsItsBeginTimestampTillTheDumpIsWritten(t *testing.T) {
+	directory := test_utility.SetupADirectoryWithTestName(t)
+	db, _ := go_lsm_workshop.Open(backupTestStorageOptions(filepath.Join(directory, "db")))
+	defer func() {
+		db.Close()
+		test_utility.CleanupDirectoryWithTestName(t)
+	}()
+
+	writeKeys(t, db, 0, 10)
+	asOfTimestamp, err := db.LatestCommitTimestamp(context.Background())
+	assert.Nil(t, err)
+
+	var dump bytes.Buffer
+	writer := writerFunc(func(buffer []byte) (int, error) {
+		writeKeys(t, db, 10, 20)
+		for count := 0; count < 2; count++ {
+			assert.Nil(t, db.Read(context.Background(), func(transaction *txn.Transaction) {}))
+		}
+		assert.LessOrEqual(t, db.MaxBeginTimestamp(), asOfTimestamp)
+		return dump.Write(buffer)
+	})
+	assert.Nil(t, db.Export(context.Background(), writer, kv.NewInclusiveKeyRange(kv.RawKey("key-000"), kv.RawKey("key-999")), 0))
+	assert.Equal(t, 11, strings.Count(dump.String(), "\n"))
+
+	assert.Nil(t, db.Read(context.Background(), func(transaction *txn.Transaction) {}))
+	assert.Nil(t, db.Read(context.Background(), func(transaction *txn.Transaction) {}))
+	assert.Less(t, asOfTimestamp, db.MaxBeginTimestamp())
+}
+
+func TestImportWithTombstones(t *testing.T) {
+	directory := test_utility.SetupADirectoryWithTestName(t)
+	db, _ := go_lsm_workshop.Open(backupTestStorageOptions(filepath.Join(directory, "db")))
+	defer func() {
+		db.Close()
+		test_utility.CleanupDirectoryWithTestName(t)
+	}()
+
+	writeKeys(t, db, 0, 2)
+	dump := strings.Join([]string{
+		`{"format":"go-lsm-workshop/dump","version":1}`,
+		`{"key":"a2V5LTAwMA==","deleted":true}`,
+		`{"key":"ZGlzdHJpYnV0ZWQ=","value":"ZXRjZA=="}`,
+	}, "\n")
+
+	records, err := db.Import(context.Background(), strings.NewReader(dump))
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(2), records)
+
+	assert.Nil(t, db.Read(context.Background(), func(transaction *txn.Transaction) {
+		_, ok := transaction.Get([]byte("key-000"))
+		assert.False(t, ok)
+
+		value, ok := transaction.Get([]byte("distributed"))
+		assert.True(t, ok)
+		assert.Equal(t, "etcd", value.String())
+
+		_, ok = transaction.Get([]byte("key-001"))
+		assert.True(t, ok)
+	}))
+}
+
+func TestImportWithUnsupportedFormat(t *testing.T) {
+	directory := test_utility.SetupADirectoryWithTestName(t)
+	db, _ := go_lsm_workshop.Open(backupTestStorageOptions(filepath.Join(directory, "db")))
+	defer func() {
+		db.Close()
+		test_utility.CleanupDirectoryWithTestName(t)
+	}()
+
+	_, err := db.Import(context.Background(), strings.NewReader(`{"format":"go-lsm-workshop/dump","version":2}`))
+	assert.Equal(t, go_lsm_workshop.UnsupportedDumpFormatErr, err)
+}
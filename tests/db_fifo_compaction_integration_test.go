@@ -0,0 +1,74 @@
+package tests
+
+import (
+	"context"
+	"fmt"
+	go_lsm_workshop "go-lsm-workshop"
+	"go-lsm-workshop/state"
+	"go-lsm-workshop/test_utility"
+	"go-lsm-workshop/txn"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFIFOCompactionDropsTheOldestKeysAndRetainsTheLatestKeys(t *testing.T) {
+	directory := test_utility.SetupADirectoryWithTestName(t)
+	const maxTotalSizeInBytes = 16 * 1024
+	storageOptions := state.StorageOptions{
+		MemTableSizeInBytes:   1 * 1024,
+		Path:                  directory,
+		MaximumMemtables:      2,
+		FlushMemtableDuration: 1 * time.Millisecond,
+		SSTableSizeInBytes:    1024,
+		CompactionOptions: state.CompactionOptions{
+			Strategy: state.FIFOCompactionStrategy,
+			FIFOStrategyOptions: state.FIFOCompactionOptions{
+				MaxTotalSizeInBytes: maxTotalSizeInBytes,
+			},
+			Duration: 5 * time.Millisecond,
+		},
+	}
+	db, _ := go_lsm_workshop.Open(storageOptions)
+	defer test_utility.CleanupDirectoryWithTestName(t)
+
+	const keys = 300
+	for count := 0; count < keys; count++ {
+		future, err := db.Write(context.Background(), func(transaction *txn.Transaction) {
+			assert.Nil(t, transaction.Set([]byte(fmt.Sprintf("key-%03d", count)), []byte(fmt.Sprintf("value-%03d", count))))
+		})
+		assert.Nil(t, err)
+		future.Wait()
+
+		assert.Nil(t, db.Read(context.Background(), func(transaction *txn.Transaction) {
+			value, ok := transaction.Get([]byte(fmt.Sprintf("key-%03d", count)))
+			assert.True(t, ok)
+			assert.Equal(t, fmt.Sprintf("value-%03d", count), value.String())
+		}))
+	}
+
+	time.Sleep(500 * time.Millisecond)
+
+	snapshot := db.StorageState().Snapshot()
+	var totalSizeInBytes int64
+	for _, ssTableId := range snapshot.L0SSTableIds {
+		totalSizeInBytes += snapshot.SSTables[ssTableId].SizeInBytes()
+	}
+	assert.True(t, len(snapshot.L0SSTableIds) > 0)
+	assert.True(t, totalSizeInBytes <= maxTotalSizeInBytes)
+	db.Close()
+
+	db, err := go_lsm_workshop.Open(storageOptions)
+	assert.Nil(t, err)
+	defer db.Close()
+
+	assert.Nil(t, db.Read(context.Background(), func(transaction *txn.Transaction) {
+		_, ok := transaction.Get([]byte("key-000"))
+		assert.False(t, ok)
+
+		value, ok := transaction.Get([]byte(fmt.Sprintf("key-%03d", keys-1)))
+		assert.True(t, ok)
+		assert.Equal(t, fmt.Sprintf("value-%03d", keys-1), value.String())
+	}))
+}
@@ -0,0 +1,110 @@
+package tests
+
+import (
+	"context"
+	"fmt"
+	go_lsm_workshop "go-lsm-workshop"
+	"go-lsm-workshop/kv"
+	"go-lsm-workshop/state"
+	"go-lsm-workshop/table"
+	"go-lsm-workshop/test_utility"
+	"go-lsm-workshop/txn"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIngestSSTablesVisibleToTheTransactionsBeginningAfterIngestion(t *testing.T) {
+	directory := test_utility.SetupADirectoryWithTestName(t)
+	storageOptions := state.StorageOptions{
+		MemTableSizeInBytes:   1 * 1024,
+		Path:                  directory,
+		MaximumMemtables:      2,
+		FlushMemtableDuration: 1 * time.Millisecond,
+		SSTableSizeInBytes:    4096,
+	}
+	db, _ := go_lsm_workshop.Open(storageOptions)
+	defer func() {
+		db.Close()
+		test_utility.CleanupDirectoryWithTestName(t)
+	}()
+
+	future, err := db.Write(context.Background(), func(transaction *txn.Transaction) {
+		assert.Nil(t, transaction.Set([]byte("consensus"), []byte("raft")))
+	})
+	assert.Nil(t, err)
+	future.Wait()
+
+	externalPath := filepath.Join(directory, "external")
+	assert.Nil(t, os.MkdirAll(externalPath, os.ModePerm))
+
+	ssTableBuilder := table.NewSSTableBuilderWithDefaultBlockSize()
+	for count := 0; count < 100; count++ {
+		ssTableBuilder.Add(kv.NewStringKeyWithTimestamp(fmt.Sprintf("key-%03d", count), 0), kv.NewStringValue(fmt.Sprintf("value-%03d", count)))
+	}
+	_, err = ssTableBuilder.Build(1, externalPath)
+	assert.Nil(t, err)
+
+	transactionBeforeIngestion, err := db.NewTransaction(context.Background(), true)
+	assert.Nil(t, err)
+	defer transactionBeforeIngestion.Discard()
+
+	assert.Nil(t, db.IngestSSTables(context.Background(), []string{table.SSTableFilePath(1, externalPath)}))
+
+	_, ok := transactionBeforeIngestion.Get([]byte("key-010"))
+	assert.False(t, ok)
+
+	assert.Nil(t, db.Read(context.Background(), func(transaction *txn.Transaction) {
+		value, ok := transaction.Get([]byte("consensus"))
+		assert.True(t, ok)
+		assert.Equal(t, "raft", value.String())
+
+		for count := 0; count < 100; count++ {
+			value, ok := transaction.Get([]byte(fmt.Sprintf("key-%03d", count)))
+			assert.True(t, ok)
+			assert.Equal(t, fmt.Sprintf("value-%03d", count), value.String())
+		}
+	}))
+}
+
+func TestIngestSSTablesOverlappingMemtables(t *testing.T) {
+	directory := test_utility.SetupADirectoryWithTestName(t)
+	storageOptions := state.StorageOptions{
+		MemTableSizeInBytes:   1 * 1024,
+		Path:                  directory,
+		MaximumMemtables:      2,
+		FlushMemtableDuration: 1 * time.Millisecond,
+		SSTableSizeInBytes:    4096,
+	}
+	db, _ := go_lsm_workshop.Open(storageOptions)
+	defer func() {
+		db.Close()
+		test_utility.CleanupDirectoryWithTestName(t)
+	}()
+
+	future, err := db.Write(context.Background(), func(transaction *txn.Transaction) {
+		assert.Nil(t, transaction.Set([]byte("distributed"), []byte("TiKV")))
+	})
+	assert.Nil(t, err)
+	future.Wait()
+
+	externalPath := filepath.Join(directory, "external")
+	assert.Nil(t, os.MkdirAll(externalPath, os.ModePerm))
+
+	ssTableBuilder := table.NewSSTableBuilderWithDefaultBlockSize()
+	ssTableBuilder.Add(kv.NewStringKeyWithTimestamp("consensus", 0), kv.NewStringValue("raft"))
+	ssTableBuilder.Add(kv.NewStringKeyWithTimestamp("etcd", 0), kv.NewStringValue("bbolt"))
+	_, err = ssTableBuilder.Build(1, externalPath)
+	assert.Nil(t, err)
+
+	err = db.IngestSSTables(context.Background(), []string{table.SSTableFilePath(1, externalPath)})
+	assert.Equal(t, state.IngestionOverlapsMemtablesErr, err)
+
+	assert.Nil(t, db.Read(context.Background(), func(transaction *txn.Transaction) {
+		_, ok := transaction.Get([]byte("consensus"))
+		assert.False(t, ok)
+	}))
+}
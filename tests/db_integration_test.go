@@ -1,11 +1,15 @@
 package tests
 
 import (
+	"context"
+	"errors"
 	go_lsm_workshop "go-lsm-workshop"
 	"go-lsm-workshop/kv"
 	"go-lsm-workshop/state"
 	"go-lsm-workshop/test_utility"
 	"go-lsm-workshop/txn"
+	"strconv"
+	"sync"
 	"testing"
 	"time"
 
@@ -27,7 +31,7 @@ func TestReadWithEmptyDb(t *testing.T) {
 		test_utility.CleanupDirectoryWithTestName(t)
 	}()
 
-	err := db.Read(func(transaction *txn.Transaction) {
+	err := db.Read(context.Background(), func(transaction *txn.Transaction) {
 		_, ok := transaction.Get([]byte("consensus"))
 		assert.False(t, ok)
 	})
@@ -49,7 +53,7 @@ func TestReadAnExistingKeyValue(t *testing.T) {
 		test_utility.CleanupDirectoryWithTestName(t)
 	}()
 
-	future, err := db.Write(func(transaction *txn.Transaction) {
+	future, err := db.Write(context.Background(), func(transaction *txn.Transaction) {
 		assert.NoError(t, transaction.Set([]byte("raft"), []byte("consensus algorithm")))
 		assert.NoError(t, transaction.Set([]byte("VSR"), []byte("consensus algorithm")))
 	})
@@ -58,7 +62,7 @@ func TestReadAnExistingKeyValue(t *testing.T) {
 	future.Wait()
 	assert.True(t, future.Status().IsOk())
 
-	err = db.Read(func(transaction *txn.Transaction) {
+	err = db.Read(context.Background(), func(transaction *txn.Transaction) {
 		value, ok := transaction.Get([]byte("raft"))
 		assert.True(t, ok)
 		assert.Equal(t, []byte("consensus algorithm"), value.Bytes())
@@ -85,7 +89,7 @@ func TestScanKeyValues1(t *testing.T) {
 		test_utility.CleanupDirectoryWithTestName(t)
 	}()
 
-	future, err := db.Write(func(transaction *txn.Transaction) {
+	future, err := db.Write(context.Background(), func(transaction *txn.Transaction) {
 		assert.NoError(t, transaction.Set([]byte("raft"), []byte("consensus algorithm")))
 		assert.NoError(t, transaction.Set([]byte("vsr"), []byte("consensus algorithm")))
 		assert.NoError(t, transaction.Set([]byte("wisckey"), []byte("modified LSM")))
@@ -95,7 +99,7 @@ func TestScanKeyValues1(t *testing.T) {
 	future.Wait()
 	assert.True(t, future.Status().IsOk())
 
-	err = db.Read(func(transaction *txn.Transaction) {
+	err = db.Read(context.Background(), func(transaction *txn.Transaction) {
 		iterator, _ := transaction.Scan(kv.NewInclusiveKeyRange(kv.RawKey("storage"), kv.RawKey("wisckey")))
 		defer iterator.Close()
 
@@ -128,7 +132,7 @@ func TestScanKeyValues2(t *testing.T) {
 		test_utility.CleanupDirectoryWithTestName(t)
 	}()
 
-	future, err := db.Write(func(transaction *txn.Transaction) {
+	future, err := db.Write(context.Background(), func(transaction *txn.Transaction) {
 		assert.NoError(t, transaction.Set([]byte("raft"), []byte("consensus algorithm")))
 		assert.NoError(t, transaction.Set([]byte("vsr"), []byte("consensus algorithm")))
 		assert.NoError(t, transaction.Set([]byte("wisckey"), []byte("modified LSM")))
@@ -138,7 +142,7 @@ func TestScanKeyValues2(t *testing.T) {
 	future.Wait()
 	assert.True(t, future.Status().IsOk())
 
-	keyValues, err := db.Scan(kv.NewInclusiveKeyRange(kv.RawKey("storage"), kv.RawKey("wisckey")))
+	keyValues, err := db.Scan(context.Background(), kv.NewInclusiveKeyRange(kv.RawKey("storage"), kv.RawKey("wisckey")))
 
 	assert.NoError(t, err)
 	assert.Equal(t, []go_lsm_workshop.KeyValue{
@@ -163,7 +167,7 @@ func TestScanAndValidateReferencesOfSSTables(t *testing.T) {
 	}()
 
 	executeInTransaction := func(key, value []byte) {
-		resultingFuture, err := db.Write(func(transaction *txn.Transaction) {
+		resultingFuture, err := db.Write(context.Background(), func(transaction *txn.Transaction) {
 			assert.NoError(t, transaction.Set(key, value))
 		})
 		assert.Nil(t, err)
@@ -179,7 +183,7 @@ func TestScanAndValidateReferencesOfSSTables(t *testing.T) {
 	time.Sleep(2 * time.Second)
 	assert.True(t, db.StorageState().TotalSSTablesAtLevel(0) > 0)
 
-	keyValues, err := db.Scan(kv.NewInclusiveKeyRange(kv.RawKey("raft"), kv.RawKey("wisckey")))
+	keyValues, err := db.Scan(context.Background(), kv.NewInclusiveKeyRange(kv.RawKey("raft"), kv.RawKey("wisckey")))
 
 	assert.NoError(t, err)
 	assert.Equal(t, []go_lsm_workshop.KeyValue{
@@ -219,7 +223,7 @@ func TestGetAfterCompaction(t *testing.T) {
 	}()
 
 	runInTransaction := func(key, value []byte) {
-		resultingFuture, err := db.Write(func(transaction *txn.Transaction) {
+		resultingFuture, err := db.Write(context.Background(), func(transaction *txn.Transaction) {
 			assert.NoError(t, transaction.Set(key, value))
 		})
 		assert.NoError(t, err)
@@ -235,24 +239,359 @@ func TestGetAfterCompaction(t *testing.T) {
 
 	time.Sleep(2 * time.Second)
 
-	assert.Nil(t, db.Read(func(transaction *txn.Transaction) {
+	assert.Nil(t, db.Read(context.Background(), func(transaction *txn.Transaction) {
 		value, ok := transaction.Get([]byte("raft"))
 		assert.True(t, ok)
 		assert.Equal(t, "consensus algorithm", value.String())
 	}))
-	assert.Nil(t, db.Read(func(transaction *txn.Transaction) {
+	assert.Nil(t, db.Read(context.Background(), func(transaction *txn.Transaction) {
 		value, ok := transaction.Get([]byte("storage"))
 		assert.True(t, ok)
 		assert.Equal(t, "Flash SSD", value.String())
 	}))
-	assert.Nil(t, db.Read(func(transaction *txn.Transaction) {
+	assert.Nil(t, db.Read(context.Background(), func(transaction *txn.Transaction) {
 		value, ok := transaction.Get([]byte("disk type"))
 		assert.True(t, ok)
 		assert.Equal(t, "NVMe", value.String())
 	}))
-	assert.Nil(t, db.Read(func(transaction *txn.Transaction) {
+	assert.Nil(t, db.Read(context.Background(), func(transaction *txn.Transaction) {
 		value, ok := transaction.Get([]byte("data-structure"))
 		assert.True(t, ok)
 		assert.Equal(t, "Buffered BTree", value.String())
 	}))
 }
+
+func TestCommitAnExplicitTransaction(t *testing.T) {
+	directory := test_utility.SetupADirectoryWithTestName(t)
+	storageOptions := state.StorageOptions{
+		MemTableSizeInBytes:   1 * 1024,
+		Path:                  directory,
+		MaximumMemtables:      2,
+		FlushMemtableDuration: 1 * time.Millisecond,
+		SSTableSizeInBytes:    4096,
+	}
+	db, _ := go_lsm_workshop.Open(storageOptions)
+	defer func() {
+		db.Close()
+		test_utility.CleanupDirectoryWithTestName(t)
+	}()
+
+	transaction, err := db.NewTransaction(context.Background(), false)
+	assert.NoError(t, err)
+	defer transaction.Discard()
+
+	assert.NoError(t, transaction.Set([]byte("raft"), []byte("consensus algorithm")))
+	assert.NoError(t, transaction.Set([]byte("wisckey"), []byte("modified LSM")))
+
+	future, err := transaction.Commit(context.Background())
+	assert.NoError(t, err)
+
+	future.Wait()
+	assert.True(t, future.Status().IsOk())
+
+	readonlyTransaction, err := db.NewTransaction(context.Background(), true)
+	assert.NoError(t, err)
+	defer readonlyTransaction.Discard()
+
+	value, ok := readonlyTransaction.Get([]byte("raft"))
+	assert.True(t, ok)
+	assert.Equal(t, "consensus algorithm", value.String())
+
+	value, ok = readonlyTransaction.Get([]byte("wisckey"))
+	assert.True(t, ok)
+	assert.Equal(t, "modified LSM", value.String())
+}
+
+func TestDiscardAnExplicitTransaction(t *testing.T) {
+	directory := test_utility.SetupADirectoryWithTestName(t)
+	storageOptions := state.StorageOptions{
+		MemTableSizeInBytes:   1 * 1024,
+		Path:                  directory,
+		MaximumMemtables:      2,
+		FlushMemtableDuration: 1 * time.Millisecond,
+		SSTableSizeInBytes:    4096,
+	}
+	db, _ := go_lsm_workshop.Open(storageOptions)
+	defer func() {
+		db.Close()
+		test_utility.CleanupDirectoryWithTestName(t)
+	}()
+
+	transaction, err := db.NewTransaction(context.Background(), false)
+	assert.NoError(t, err)
+
+	assert.NoError(t, transaction.Set([]byte("raft"), []byte("consensus algorithm")))
+	transaction.Discard()
+
+	_, err = transaction.Commit(context.Background())
+	assert.Equal(t, txn.DiscardedTransactionErr, err)
+
+	assert.Nil(t, db.Read(context.Background(), func(transaction *txn.Transaction) {
+		_, ok := transaction.Get([]byte("raft"))
+		assert.False(t, ok)
+	}))
+}
+
+func TestExecuteWithCallbackReturningAnError(t *testing.T) {
+	directory := test_utility.SetupADirectoryWithTestName(t)
+	storageOptions := state.StorageOptions{
+		MemTableSizeInBytes:   1 * 1024,
+		Path:                  directory,
+		MaximumMemtables:      2,
+		FlushMemtableDuration: 1 * time.Millisecond,
+		SSTableSizeInBytes:    4096,
+	}
+	db, _ := go_lsm_workshop.Open(storageOptions)
+	defer func() {
+		db.Close()
+		test_utility.CleanupDirectoryWithTestName(t)
+	}()
+
+	callbackErr := errors.New("insufficient balance")
+	future, err := db.Execute(context.Background(), func(transaction *txn.Transaction) error {
+		assert.NoError(t, transaction.Set([]byte("raft"), []byte("consensus algorithm")))
+		return callbackErr
+	})
+	assert.Nil(t, future)
+	assert.Equal(t, callbackErr, err)
+
+	err = db.View(context.Background(), func(transaction *txn.Transaction) error {
+		_, ok := transaction.Get([]byte("raft"))
+		assert.False(t, ok)
+		return nil
+	})
+	assert.NoError(t, err)
+}
+
+func TestExecuteWithCallbackPanicking(t *testing.T) {
+	directory := test_utility.SetupADirectoryWithTestName(t)
+	storageOptions := state.StorageOptions{
+		MemTableSizeInBytes:   1 * 1024,
+		Path:                  directory,
+		MaximumMemtables:      2,
+		FlushMemtableDuration: 1 * time.Millisecond,
+		SSTableSizeInBytes:    4096,
+	}
+	db, _ := go_lsm_workshop.Open(storageOptions)
+	defer func() {
+		db.Close()
+		test_utility.CleanupDirectoryWithTestName(t)
+	}()
+
+	assert.Panics(t, func() {
+		_, _ = db.Execute(context.Background(), func(transaction *txn.Transaction) error {
+			assert.NoError(t, transaction.Set([]byte("raft"), []byte("consensus algorithm")))
+			panic("unexpected failure")
+		})
+	})
+
+	future, err := db.Execute(context.Background(), func(transaction *txn.Transaction) error {
+		return transaction.Set([]byte("storage"), []byte("Flash SSD"))
+	})
+	assert.NoError(t, err)
+
+	future.Wait()
+	assert.True(t, future.Status().IsOk())
+
+	err = db.View(context.Background(), func(transaction *txn.Transaction) error {
+		_, ok := transaction.Get([]byte("raft"))
+		assert.False(t, ok)
+
+		value, ok := transaction.Get([]byte("storage"))
+		assert.True(t, ok)
+		assert.Equal(t, "Flash SSD", value.String())
+		return nil
+	})
+	assert.NoError(t, err)
+}
+
+func TestReadWithCancelledContext(t *testing.T) {
+	directory := test_utility.SetupADirectoryWithTestName(t)
+	storageOptions := state.StorageOptions{
+		MemTableSizeInBytes:   1 * 1024,
+		Path:                  directory,
+		MaximumMemtables:      2,
+		FlushMemtableDuration: 1 * time.Millisecond,
+		SSTableSizeInBytes:    4096,
+	}
+	db, _ := go_lsm_workshop.Open(storageOptions)
+	defer func() {
+		db.Close()
+		test_utility.CleanupDirectoryWithTestName(t)
+	}()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	invoked := false
+	err := db.Read(ctx, func(transaction *txn.Transaction) {
+		invoked = true
+	})
+	assert.Equal(t, context.Canceled, err)
+	assert.False(t, invoked)
+
+	_, err = db.Scan(ctx, kv.NewInclusiveKeyRange(kv.RawKey("raft"), kv.RawKey("wisckey")))
+	assert.Equal(t, context.Canceled, err)
+}
+
+func TestWriteAndWaitWithContext(t *testing.T) {
+	directory := test_utility.SetupADirectoryWithTestName(t)
+	storageOptions := state.StorageOptions{
+		MemTableSizeInBytes:   1 * 1024,
+		Path:                  directory,
+		MaximumMemtables:      2,
+		FlushMemtableDuration: 1 * time.Millisecond,
+		SSTableSizeInBytes:    4096,
+	}
+	db, _ := go_lsm_workshop.Open(storageOptions)
+	defer func() {
+		db.Close()
+		test_utility.CleanupDirectoryWithTestName(t)
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	future, err := db.Write(ctx, func(transaction *txn.Transaction) {
+		assert.NoError(t, transaction.Set([]byte("raft"), []byte("consensus algorithm")))
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, future.WaitWithContext(ctx))
+	assert.True(t, future.Status().IsOk())
+
+	keyValues, err := db.Scan(ctx, kv.NewInclusiveKeyRange(kv.RawKey("raft"), kv.RawKey("wisckey")))
+	assert.NoError(t, err)
+	assert.Equal(t, []go_lsm_workshop.KeyValue{
+		{Key: kv.RawKey("raft"), Value: []byte("consensus algorithm")},
+	}, keyValues)
+}
+
+func TestWriteSkewWithSnapshotIsolationLevel(t *testing.T) {
+	directory := test_utility.SetupADirectoryWithTestName(t)
+	storageOptions := state.StorageOptions{
+		MemTableSizeInBytes:   1 * 1024,
+		Path:                  directory,
+		MaximumMemtables:      2,
+		FlushMemtableDuration: 1 * time.Millisecond,
+		SSTableSizeInBytes:    4096,
+	}
+	db, _ := go_lsm_workshop.Open(storageOptions)
+	defer func() {
+		db.Close()
+		test_utility.CleanupDirectoryWithTestName(t)
+	}()
+
+	aTransaction, err := db.NewTransactionWithIsolationLevel(context.Background(), txn.SnapshotIsolation)
+	assert.NoError(t, err)
+	defer aTransaction.Discard()
+
+	anotherTransaction, err := db.NewTransactionWithIsolationLevel(context.Background(), txn.SnapshotIsolation)
+	assert.NoError(t, err)
+	defer anotherTransaction.Discard()
+
+	_, ok := aTransaction.Get([]byte("raft"))
+	assert.False(t, ok)
+	assert.NoError(t, aTransaction.Set([]byte("paxos"), []byte("consensus algorithm")))
+
+	_, ok = anotherTransaction.Get([]byte("paxos"))
+	assert.False(t, ok)
+	assert.NoError(t, anotherTransaction.Set([]byte("raft"), []byte("consensus algorithm")))
+
+	future, err := aTransaction.Commit(context.Background())
+	assert.NoError(t, err)
+	future.Wait()
+
+	future, err = anotherTransaction.Commit(context.Background())
+	assert.NoError(t, err)
+	future.Wait()
+
+	future, err = db.WriteWithIsolationLevel(context.Background(), txn.Serializable, func(transaction *txn.Transaction) {
+		_, ok := transaction.Get([]byte("raft"))
+		assert.True(t, ok)
+		_, ok = transaction.Get([]byte("paxos"))
+		assert.True(t, ok)
+		_ = transaction.Set([]byte("consensus"), []byte("raft and paxos"))
+	})
+	assert.NoError(t, err)
+	future.Wait()
+}
+
+func TestConcurrentIncrementsOfAContendedKeyWithGetForUpdate(t *testing.T) {
+	directory := test_utility.SetupADirectoryWithTestName(t)
+	storageOptions := state.StorageOptions{
+		MemTableSizeInBytes:   1 * 1024,
+		Path:                  directory,
+		MaximumMemtables:      2,
+		FlushMemtableDuration: 1 * time.Millisecond,
+		SSTableSizeInBytes:    4096,
+	}
+	db, _ := go_lsm_workshop.Open(storageOptions)
+	defer func() {
+		db.Close()
+		test_utility.CleanupDirectoryWithTestName(t)
+	}()
+
+	const increments = 20
+
+	var waitGroup sync.WaitGroup
+	for increment := 0; increment < increments; increment++ {
+		waitGroup.Add(1)
+		go func() {
+			defer waitGroup.Done()
+			future, err := db.Execute(context.Background(), func(transaction *txn.Transaction) error {
+				value, ok, err := transaction.GetForUpdate([]byte("balance"))
+				if err != nil {
+					return err
+				}
+				balance := 0
+				if ok {
+					balance, _ = strconv.Atoi(value.String())
+				}
+				return transaction.Set([]byte("balance"), []byte(strconv.Itoa(balance+1)))
+			})
+			assert.NoError(t, err)
+			future.Wait()
+		}()
+	}
+	waitGroup.Wait()
+
+	err := db.Read(context.Background(), func(transaction *txn.Transaction) {
+		value, ok := transaction.Get([]byte("balance"))
+		assert.True(t, ok)
+		assert.Equal(t, strconv.Itoa(increments), value.String())
+	})
+	assert.NoError(t, err)
+}
+
+func TestGetForUpdateTimesOutWithTheConfiguredLockTimeout(t *testing.T) {
+	directory := test_utility.SetupADirectoryWithTestName(t)
+	storageOptions := state.StorageOptions{
+		MemTableSizeInBytes:   1 * 1024,
+		Path:                  directory,
+		MaximumMemtables:      2,
+		FlushMemtableDuration: 1 * time.Millisecond,
+		SSTableSizeInBytes:    4096,
+		LockTimeout:           5 * time.Millisecond,
+	}
+	db, _ := go_lsm_workshop.Open(storageOptions)
+	defer func() {
+		db.Close()
+		test_utility.CleanupDirectoryWithTestName(t)
+	}()
+
+	aTransaction, err := db.NewTransaction(context.Background(), false)
+	assert.NoError(t, err)
+	defer aTransaction.Discard()
+
+	anotherTransaction, err := db.NewTransaction(context.Background(), false)
+	assert.NoError(t, err)
+	defer anotherTransaction.Discard()
+
+	_, _, err = aTransaction.GetForUpdate([]byte("balance"))
+	assert.NoError(t, err)
+
+	waitStart := time.Now()
+	_, _, err = anotherTransaction.GetForUpdate([]byte("balance"))
+	assert.Equal(t, txn.LockTimeoutErr, err)
+	assert.True(t, time.Since(waitStart) < txn.DefaultLockTimeout)
+}
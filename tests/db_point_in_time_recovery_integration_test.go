@@ -0,0 +1,196 @@
+package tests
+
+import (
+	"context"
+	"fmt"
+	go_lsm_workshop "go-lsm-workshop"
+	"go-lsm-workshop/kv"
+	"go-lsm-workshop/log"
+	"go-lsm-workshop/state"
+	"go-lsm-workshop/table"
+	"go-lsm-workshop/test_utility"
+	"go-lsm-workshop/txn"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecoverToPointInTimeFromArchivedWALs(t *testing.T) {
+	directory := test_utility.SetupADirectoryWithTestName(t)
+	storageOptions := backupTestStorageOptions(filepath.Join(directory, "db"))
+	storageOptions.WALArchiveOptions = state.WALArchiveOptions{Path: filepath.Join(directory, "archive")}
+
+	db, _ := go_lsm_workshop.Open(storageOptions)
+	defer func() {
+		db.Close()
+		test_utility.CleanupDirectoryWithTestName(t)
+	}()
+
+	writeKeys(t, db, 0, 30)
+	checkpointDirectory := filepath.Join(directory, "checkpoint")
+	assert.Nil(t, db.Checkpoint(context.Background(), checkpointDirectory))
+
+	writeKeys(t, db, 30, 80)
+	targetCommitTimestamp, err := db.LatestCommitTimestamp(context.Background())
+	assert.Nil(t, err)
+	writeKeys(t, db, 80, 100)
+
+	recoveredDb, err := go_lsm_workshop.RecoverToPointInTime(
+		backupTestStorageOptions(filepath.Join(directory, "recovered")),
+		go_lsm_workshop.PointInTimeRecoveryOptions{
+			CheckpointDirectory: checkpointDirectory,
+			WALDirectoryPaths: []string{
+				storageOptions.WALArchiveOptions.Path,
+				log.NewWALPath(storageOptions.Path).DirectoryPath,
+			},
+			TargetCommitTimestamp: targetCommitTimestamp,
+		},
+	)
+	assert.Nil(t, err)
+	defer recoveredDb.Close()
+
+	assert.Nil(t, recoveredDb.Read(context.Background(), func(transaction *txn.Transaction) {
+		for count := 0; count < 80; count++ {
+			value, ok := transaction.Get([]byte(fmt.Sprintf("key-%03d", count)))
+			assert.True(t, ok)
+			assert.Equal(t, fmt.Sprintf("value-%03d", count), value.String())
+		}
+		for count := 80; count < 100; count++ {
+			_, ok := transaction.Get([]byte(fmt.Sprintf("key-%03d", count)))
+			assert.False(t, ok)
+		}
+	}))
+}
+
+func TestRecoverToPointInTimeFailsGivenAnIngestionAfterTheCheckpoint(t *testing.T) {
+	directory := test_utility.SetupADirectoryWithTestName(t)
+	storageOptions := backupTestStorageOptions(filepath.Join(directory, "db"))
+	storageOptions.WALArchiveOptions = state.WALArchiveOptions{Path: filepath.Join(directory, "archive")}
+
+	db, _ := go_lsm_workshop.Open(storageOptions)
+	defer func() {
+		db.Close()
+		test_utility.CleanupDirectoryWithTestName(t)
+	}()
+
+	writeKeys(t, db, 0, 30)
+	checkpointDirectory := filepath.Join(directory, "checkpoint")
+	assert.Nil(t, db.Checkpoint(context.Background(), checkpointDirectory))
+
+	externalPath := filepath.Join(directory, "external")
+	assert.Nil(t, os.MkdirAll(externalPath, os.ModePerm))
+	ssTableBuilder := table.NewSSTableBuilderWithDefaultBlockSize()
+	ssTableBuilder.Add(kv.NewStringKeyWithTimestamp("ingested", 0), kv.NewStringValue("value"))
+	_, err := ssTableBuilder.Build(1, externalPath)
+	assert.Nil(t, err)
+	assert.Nil(t, db.IngestSSTables(context.Background(), []string{table.SSTableFilePath(1, externalPath)}))
+
+	writeKeys(t, db, 30, 40)
+	targetCommitTimestamp, err := db.LatestCommitTimestamp(context.Background())
+	assert.Nil(t, err)
+
+	_, err = go_lsm_workshop.RecoverToPointInTime(
+		backupTestStorageOptions(filepath.Join(directory, "recovered")),
+		go_lsm_workshop.PointInTimeRecoveryOptions{
+			CheckpointDirectory: checkpointDirectory,
+			WALDirectoryPaths: []string{
+				storageOptions.WALArchiveOptions.Path,
+				log.NewWALPath(storageOptions.Path).DirectoryPath,
+			},
+			TargetCommitTimestamp: targetCommitTimestamp,
+		},
+	)
+	assert.ErrorIs(t, err, state.IngestionInReplayWindowErr)
+}
+
+func TestRecoverToPointInTimeInAnExistingDirectory(t *testing.T) {
+	directory := test_utility.SetupADirectoryWithTestName(t)
+	defer test_utility.CleanupDirectoryWithTestName(t)
+
+	_, err := go_lsm_workshop.RecoverToPointInTime(
+		backupTestStorageOptions(directory),
+		go_lsm_workshop.PointInTimeRecoveryOptions{CheckpointDirectory: filepath.Join(directory, "checkpoint")},
+	)
+	assert.Equal(t, go_lsm_workshop.PointInTimeRecoveryDirectoryExistsErr, err)
+}
+
+func TestRecoverToPointInTimeFailsGivenAMissingArchivedWALAfterTheCheckpoint(t *testing.T) {
+	directory := test_utility.SetupADirectoryWithTestName(t)
+	storageOptions := backupTestStorageOptions(filepath.Join(directory, "db"))
+	storageOptions.WALArchiveOptions = state.WALArchiveOptions{Path: filepath.Join(directory, "archive")}
+
+	db, _ := go_lsm_workshop.Open(storageOptions)
+	defer func() {
+		db.Close()
+		test_utility.CleanupDirectoryWithTestName(t)
+	}()
+
+	writeKeys(t, db, 0, 30)
+	checkpointDirectory := filepath.Join(directory, "checkpoint")
+	checkpointCommitTimestamp, err := db.LatestCommitTimestamp(context.Background())
+	assert.Nil(t, err)
+	assert.Nil(t, db.Checkpoint(context.Background(), checkpointDirectory))
+
+	writeKeys(t, db, 30, 100)
+	targetCommitTimestamp, err := db.LatestCommitTimestamp(context.Background())
+	assert.Nil(t, err)
+
+	var archivedWALsAfterCheckpoint []log.ArchivedWAL
+	assert.Eventually(t, func() bool {
+		archivedWALs, err := log.ArchivedWALsIn(storageOptions.WALArchiveOptions.Path)
+		assert.Nil(t, err)
+		archivedWALsAfterCheckpoint = nil
+		for _, archivedWAL := range archivedWALs {
+			if archivedWAL.FirstCommitTimestamp > checkpointCommitTimestamp {
+				archivedWALsAfterCheckpoint = append(archivedWALsAfterCheckpoint, archivedWAL)
+			}
+		}
+		return len(archivedWALsAfterCheckpoint) >= 3
+	}, 5*time.Second, 5*time.Millisecond)
+
+	missingWAL := archivedWALsAfterCheckpoint[1]
+	assert.Nil(t, os.Remove(log.CreateWalPathFor(missingWAL.Id, storageOptions.WALArchiveOptions.Path)))
+
+	recoveryOptions := go_lsm_workshop.PointInTimeRecoveryOptions{
+		CheckpointDirectory: checkpointDirectory,
+		WALDirectoryPaths: []string{
+			storageOptions.WALArchiveOptions.Path,
+			log.NewWALPath(storageOptions.Path).DirectoryPath,
+		},
+		TargetCommitTimestamp: targetCommitTimestamp,
+	}
+	_, err = go_lsm_workshop.RecoverToPointInTime(backupTestStorageOptions(filepath.Join(directory, "recovered")), recoveryOptions)
+	assert.ErrorIs(t, err, state.MissingWALInReplayWindowErr)
+
+	_, err = os.Stat(filepath.Join(directory, "recovered"))
+	assert.True(t, os.IsNotExist(err))
+
+	recoveryOptions.TargetCommitTimestamp = missingWAL.FirstCommitTimestamp - 1
+	recoveredDb, err := go_lsm_workshop.RecoverToPointInTime(backupTestStorageOptions(filepath.Join(directory, "recovered")), recoveryOptions)
+	assert.Nil(t, err)
+	defer recoveredDb.Close()
+
+	latestCommitTimestamp, err := recoveredDb.LatestCommitTimestamp(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, missingWAL.FirstCommitTimestamp-1, latestCommitTimestamp)
+}
+
+func TestRecoverToPointInTimeWithTheWALArchiveInTheWALDirectoriesToReplay(t *testing.T) {
+	directory := test_utility.SetupADirectoryWithTestName(t)
+	defer test_utility.CleanupDirectoryWithTestName(t)
+
+	storageOptions := backupTestStorageOptions(filepath.Join(directory, "recovered"))
+	storageOptions.WALArchiveOptions = state.WALArchiveOptions{Path: filepath.Join(directory, "archive")}
+
+	_, err := go_lsm_workshop.RecoverToPointInTime(
+		storageOptions,
+		go_lsm_workshop.PointInTimeRecoveryOptions{
+			CheckpointDirectory: filepath.Join(directory, "checkpoint"),
+			WALDirectoryPaths:   []string{filepath.Join(directory, "archive") + string(os.PathSeparator)},
+		},
+	)
+	assert.Equal(t, go_lsm_workshop.PointInTimeRecoveryArchiveInWALDirectoriesErr, err)
+}
@@ -0,0 +1,68 @@
+package tests
+
+import (
+	"context"
+	"fmt"
+	go_lsm_workshop "go-lsm-workshop"
+	"go-lsm-workshop/state"
+	"go-lsm-workshop/test_utility"
+	"go-lsm-workshop/txn"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetAfterCompactionWithSubcompactions(t *testing.T) {
+	directory := test_utility.SetupADirectoryWithTestName(t)
+	storageOptions := state.StorageOptions{
+		MemTableSizeInBytes:   1 * 1024,
+		Path:                  directory,
+		MaximumMemtables:      2,
+		FlushMemtableDuration: 1 * time.Millisecond,
+		SSTableSizeInBytes:    4096,
+		CompactionOptions: state.CompactionOptions{
+			StrategyOptions: state.SimpleLeveledCompactionOptions{
+				NumberOfSSTablesRatioPercentage: 200,
+				MaxLevels:                       3,
+				Level0FilesCompactionTrigger:    2,
+			},
+			MaxSubcompactions: 4,
+			Duration:          5 * time.Millisecond,
+		},
+	}
+	db, _ := go_lsm_workshop.Open(storageOptions)
+	defer func() {
+		db.Close()
+		test_utility.CleanupDirectoryWithTestName(t)
+	}()
+
+	writeKeys(t, db, 0, 500)
+	for count := 0; count < 500; count += 2 {
+		future, err := db.Write(context.Background(), func(transaction *txn.Transaction) {
+			key, value := fmt.Sprintf("key-%03d", count), fmt.Sprintf("updated-%03d", count)
+			assert.Nil(t, transaction.Set([]byte(key), []byte(value)))
+		})
+		assert.Nil(t, err)
+		future.Wait()
+	}
+	assert.Eventually(t, func() bool {
+		totalSSTablesBelowLevel0 := 0
+		for level := 1; level <= 3; level++ {
+			totalSSTablesBelowLevel0 += db.StorageState().TotalSSTablesAtLevel(level)
+		}
+		return totalSSTablesBelowLevel0 > 0
+	}, 5*time.Second, 5*time.Millisecond)
+
+	assert.Nil(t, db.Read(context.Background(), func(transaction *txn.Transaction) {
+		for count := 0; count < 500; count++ {
+			value, ok := transaction.Get([]byte(fmt.Sprintf("key-%03d", count)))
+			assert.True(t, ok)
+			if count%2 == 0 {
+				assert.Equal(t, fmt.Sprintf("updated-%03d", count), value.String())
+				continue
+			}
+			assert.Equal(t, fmt.Sprintf("value-%03d", count), value.String())
+		}
+	}))
+}
@@ -0,0 +1,118 @@
+package tests
+
+import (
+	"context"
+	"fmt"
+	go_lsm_workshop "go-lsm-workshop"
+	"go-lsm-workshop/state"
+	"go-lsm-workshop/test_utility"
+	"go-lsm-workshop/txn"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetAfterTieredCompaction(t *testing.T) {
+	directory := test_utility.SetupADirectoryWithTestName(t)
+	storageOptions := state.StorageOptions{
+		MemTableSizeInBytes:   1 * 1024,
+		Path:                  directory,
+		MaximumMemtables:      2,
+		FlushMemtableDuration: 1 * time.Millisecond,
+		SSTableSizeInBytes:    1024,
+		CompactionOptions: state.CompactionOptions{
+			Strategy: state.TieredCompactionStrategy,
+			TieredStrategyOptions: state.TieredCompactionOptions{
+				SortedRunsCompactionTrigger: 3,
+				MaxSizeAmplificationPercent: 200,
+				SizeRatioPercent:            1,
+				MinMergeWidth:               2,
+				MaxLevels:                   3,
+			},
+			Duration: 5 * time.Millisecond,
+		},
+	}
+	db, _ := go_lsm_workshop.Open(storageOptions)
+	defer func() {
+		db.Close()
+		test_utility.CleanupDirectoryWithTestName(t)
+	}()
+
+	const keys = 300
+	for count := 0; count < keys; count++ {
+		future, err := db.Write(context.Background(), func(transaction *txn.Transaction) {
+			key, value := fmt.Sprintf("key-%03d", (count*7)%keys), fmt.Sprintf("value-%03d", count)
+			assert.Nil(t, transaction.Set([]byte(key), []byte(value)))
+		})
+		assert.Nil(t, err)
+		future.Wait()
+	}
+
+	assert.Eventually(t, func() bool {
+		totalSSTablesAtLevels := 0
+		for level := 1; level <= 3; level++ {
+			totalSSTablesAtLevels += db.StorageState().TotalSSTablesAtLevel(level)
+		}
+		return totalSSTablesAtLevels > 0
+	}, 5*time.Second, 5*time.Millisecond)
+
+	assert.Nil(t, db.Read(context.Background(), func(transaction *txn.Transaction) {
+		for count := 0; count < keys; count++ {
+			value, ok := transaction.Get([]byte(fmt.Sprintf("key-%03d", (count*7)%keys)))
+			assert.True(t, ok)
+			assert.Equal(t, fmt.Sprintf("value-%03d", count), value.String())
+		}
+	}))
+}
+
+func TestGetAfterTieredCompactionAndReopen(t *testing.T) {
+	directory := test_utility.SetupADirectoryWithTestName(t)
+	storageOptions := state.StorageOptions{
+		MemTableSizeInBytes:   1 * 1024,
+		Path:                  directory,
+		MaximumMemtables:      2,
+		FlushMemtableDuration: 1 * time.Millisecond,
+		SSTableSizeInBytes:    1024,
+		CompactionOptions: state.CompactionOptions{
+			Strategy: state.TieredCompactionStrategy,
+			TieredStrategyOptions: state.TieredCompactionOptions{
+				SortedRunsCompactionTrigger: 2,
+				MaxSizeAmplificationPercent: 100,
+				SizeRatioPercent:            1,
+				MinMergeWidth:               2,
+				MaxLevels:                   2,
+			},
+			Duration: 5 * time.Millisecond,
+		},
+	}
+	db, _ := go_lsm_workshop.Open(storageOptions)
+	defer test_utility.CleanupDirectoryWithTestName(t)
+
+	const keys = 200
+	for count := 0; count < keys; count++ {
+		future, err := db.Write(context.Background(), func(transaction *txn.Transaction) {
+			key, value := fmt.Sprintf("key-%03d", (count*7)%keys), fmt.Sprintf("value-%03d", count)
+			assert.Nil(t, transaction.Set([]byte(key), []byte(value)))
+		})
+		assert.Nil(t, err)
+		future.Wait()
+	}
+
+	assert.Eventually(t, func() bool {
+		return db.StorageState().TotalSSTablesAtLevel(1)+db.StorageState().TotalSSTablesAtLevel(2) > 0
+	}, 5*time.Second, 5*time.Millisecond)
+	db.Close()
+
+	db, err := go_lsm_workshop.Open(storageOptions)
+	assert.Nil(t, err)
+	defer db.Close()
+
+	assert.Nil(t, db.Read(context.Background(), func(transaction *txn.Transaction) {
+		for count := 0; count < keys; count++ {
+			value, ok := transaction.Get([]byte(fmt.Sprintf("key-%03d", (count*7)%keys)))
+			assert.True(t, ok)
+			assert.Equal(t, fmt.Sprintf("value-%03d", count), value.String())
+		}
+	}))
+}
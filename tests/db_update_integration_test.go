@@ -0,0 +1,170 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	go_lsm_workshop "go-lsm-workshop"
+	"go-lsm-workshop/state"
+	"go-lsm-workshop/test_utility"
+	"go-lsm-workshop/txn"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUpdateRetriesOnConflict(t *testing.T) {
+	directory := test_utility.SetupADirectoryWithTestName(t)
+	storageOptions := state.StorageOptions{
+		MemTableSizeInBytes:   1 * 1024,
+		Path:                  directory,
+		MaximumMemtables:      2,
+		FlushMemtableDuration: 1 * time.Millisecond,
+		SSTableSizeInBytes:    4096,
+	}
+	db, _ := go_lsm_workshop.Open(storageOptions)
+	defer func() {
+		db.Close()
+		test_utility.CleanupDirectoryWithTestName(t)
+	}()
+
+	attempts := 0
+	future, err := db.Update(context.Background(), func(transaction *txn.Transaction) error {
+		attempts++
+		transaction.Get([]byte("counter"))
+		if attempts == 1 {
+			concurrentFuture, err := db.Write(context.Background(), func(transaction *txn.Transaction) {
+				assert.NoError(t, transaction.Set([]byte("counter"), []byte("1")))
+			})
+			assert.NoError(t, err)
+			concurrentFuture.Wait()
+		}
+		return transaction.Set([]byte("counter"), []byte("2"))
+	}, go_lsm_workshop.DefaultRetryPolicy())
+
+	assert.NoError(t, err)
+	future.Wait()
+	assert.True(t, future.Status().IsOk())
+	assert.Equal(t, 2, attempts)
+
+	assert.Nil(t, db.Read(context.Background(), func(transaction *txn.Transaction) {
+		value, ok := transaction.Get([]byte("counter"))
+		assert.True(t, ok)
+		assert.Equal(t, "2", value.String())
+	}))
+
+	stats := db.Stats()
+	assert.Equal(t, uint64(1), stats.TransactionRetries)
+	assert.Equal(t, uint64(1), stats.TransactionConflicts)
+	assert.Equal(t, uint64(0), stats.TransactionsAborted)
+}
+
+func TestUpdateGivesUpAfterMaxAttempts(t *testing.T) {
+	directory := test_utility.SetupADirectoryWithTestName(t)
+	storageOptions := state.StorageOptions{
+		MemTableSizeInBytes:   1 * 1024,
+		Path:                  directory,
+		MaximumMemtables:      2,
+		FlushMemtableDuration: 1 * time.Millisecond,
+		SSTableSizeInBytes:    4096,
+	}
+	db, _ := go_lsm_workshop.Open(storageOptions)
+	defer func() {
+		db.Close()
+		test_utility.CleanupDirectoryWithTestName(t)
+	}()
+
+	attempts := 0
+	_, err := db.Update(context.Background(), func(transaction *txn.Transaction) error {
+		attempts++
+		transaction.Get([]byte("counter"))
+
+		concurrentFuture, err := db.Write(context.Background(), func(transaction *txn.Transaction) {
+			assert.NoError(t, transaction.Set([]byte("counter"), []byte("1")))
+		})
+		assert.NoError(t, err)
+		concurrentFuture.Wait()
+
+		return transaction.Set([]byte("counter"), []byte("2"))
+	}, go_lsm_workshop.RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond, MaxBackoff: 2 * time.Millisecond})
+
+	assert.Error(t, err)
+	assert.Equal(t, txn.ConflictErr, err)
+	assert.Equal(t, 3, attempts)
+
+	stats := db.Stats()
+	assert.Equal(t, uint64(2), stats.TransactionRetries)
+	assert.Equal(t, uint64(3), stats.TransactionConflicts)
+	assert.Equal(t, uint64(1), stats.TransactionsAborted)
+}
+
+func TestUpdateDoesNotRetryOnCallbackError(t *testing.T) {
+	directory := test_utility.SetupADirectoryWithTestName(t)
+	storageOptions := state.StorageOptions{
+		MemTableSizeInBytes:   1 * 1024,
+		Path:                  directory,
+		MaximumMemtables:      2,
+		FlushMemtableDuration: 1 * time.Millisecond,
+		SSTableSizeInBytes:    4096,
+	}
+	db, _ := go_lsm_workshop.Open(storageOptions)
+	defer func() {
+		db.Close()
+		test_utility.CleanupDirectoryWithTestName(t)
+	}()
+
+	attempts := 0
+	callbackErr := errors.New("invalid state")
+	_, err := db.Update(context.Background(), func(transaction *txn.Transaction) error {
+		attempts++
+		return callbackErr
+	}, go_lsm_workshop.DefaultRetryPolicy())
+
+	assert.Equal(t, callbackErr, err)
+	assert.Equal(t, 1, attempts)
+	assert.Equal(t, uint64(0), db.Stats().TransactionRetries)
+}
+
+func TestUpdateWithCancelledContext(t *testing.T) {
+	directory := test_utility.SetupADirectoryWithTestName(t)
+	storageOptions := state.StorageOptions{
+		MemTableSizeInBytes:   1 * 1024,
+		Path:                  directory,
+		MaximumMemtables:      2,
+		FlushMemtableDuration: 1 * time.Millisecond,
+		SSTableSizeInBytes:    4096,
+	}
+	db, _ := go_lsm_workshop.Open(storageOptions)
+	defer func() {
+		db.Close()
+		test_utility.CleanupDirectoryWithTestName(t)
+	}()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	attempts := 0
+	_, err := db.Update(ctx, func(transaction *txn.Transaction) error {
+		attempts++
+		return transaction.Set([]byte("counter"), []byte("1"))
+	}, go_lsm_workshop.DefaultRetryPolicy())
+
+	assert.Equal(t, context.Canceled, err)
+	assert.Equal(t, 0, attempts)
+}
+
+func TestRetryPolicyBackoffWithoutMaxBackoffDoesNotOverflow(t *testing.T) {
+	policy := go_lsm_workshop.RetryPolicy{MaxAttempts: 100, InitialBackoff: time.Millisecond}
+
+	for retry := uint(1); retry < policy.MaxAttempts; retry++ {
+		assert.True(t, policy.Backoff(retry) >= 0)
+	}
+}
+
+func TestRetryPolicyBackoffIsCappedByMaxBackoff(t *testing.T) {
+	policy := go_lsm_workshop.RetryPolicy{MaxAttempts: 100, InitialBackoff: time.Millisecond, MaxBackoff: 4 * time.Millisecond}
+
+	for retry := uint(1); retry < policy.MaxAttempts; retry++ {
+		assert.True(t, policy.Backoff(retry) <= 4*time.Millisecond)
+	}
+}
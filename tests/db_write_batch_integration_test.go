@@ -0,0 +1,82 @@
+package tests
+
+import (
+	"context"
+	"fmt"
+	go_lsm_workshop "go-lsm-workshop"
+	"go-lsm-workshop/state"
+	"go-lsm-workshop/test_utility"
+	"go-lsm-workshop/txn"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteBatchSplitAcrossMemtables(t *testing.T) {
+	directory := test_utility.SetupADirectoryWithTestName(t)
+	storageOptions := state.StorageOptions{
+		MemTableSizeInBytes:   1 * 1024,
+		Path:                  directory,
+		MaximumMemtables:      2,
+		FlushMemtableDuration: 1 * time.Millisecond,
+		SSTableSizeInBytes:    4096,
+	}
+	db, _ := go_lsm_workshop.Open(storageOptions)
+	defer func() {
+		db.Close()
+		test_utility.CleanupDirectoryWithTestName(t)
+	}()
+
+	const keys = 200
+
+	writeBatch := db.NewWriteBatch(context.Background())
+	for count := 0; count < keys; count++ {
+		key, value := fmt.Sprintf("key-%03d", count), fmt.Sprintf("value-%03d", count)
+		assert.NoError(t, writeBatch.Set([]byte(key), []byte(value)))
+	}
+	assert.NoError(t, writeBatch.Delete([]byte("key-200")))
+
+	future, err := writeBatch.Flush()
+	assert.NoError(t, err)
+	future.Wait()
+	assert.True(t, future.Status().IsOk())
+
+	assert.Nil(t, db.Read(context.Background(), func(transaction *txn.Transaction) {
+		_, ok := transaction.Get([]byte("key-200"))
+		assert.False(t, ok)
+
+		for count := 0; count < keys; count++ {
+			value, ok := transaction.Get([]byte(fmt.Sprintf("key-%03d", count)))
+			assert.True(t, ok)
+			assert.Equal(t, fmt.Sprintf("value-%03d", count), value.String())
+		}
+	}))
+}
+
+func TestWriteBatchAfterFlush(t *testing.T) {
+	directory := test_utility.SetupADirectoryWithTestName(t)
+	storageOptions := state.StorageOptions{
+		MemTableSizeInBytes:   1 * 1024,
+		Path:                  directory,
+		MaximumMemtables:      2,
+		FlushMemtableDuration: 1 * time.Millisecond,
+		SSTableSizeInBytes:    4096,
+	}
+	db, _ := go_lsm_workshop.Open(storageOptions)
+	defer func() {
+		db.Close()
+		test_utility.CleanupDirectoryWithTestName(t)
+	}()
+
+	writeBatch := db.NewWriteBatch(context.Background())
+	assert.NoError(t, writeBatch.Set([]byte("raft"), []byte("consensus algorithm")))
+
+	future, err := writeBatch.Flush()
+	assert.NoError(t, err)
+	future.Wait()
+
+	assert.Equal(t, go_lsm_workshop.WriteBatchFlushedErr, writeBatch.Set([]byte("paxos"), []byte("consensus algorithm")))
+	_, err = writeBatch.Flush()
+	assert.Equal(t, go_lsm_workshop.WriteBatchFlushedErr, err)
+}
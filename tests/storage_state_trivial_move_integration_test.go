@@ -0,0 +1,72 @@
+package tests
+
+import (
+	"go-lsm-workshop/compact"
+	"go-lsm-workshop/kv"
+	"go-lsm-workshop/state"
+	"go-lsm-workshop/test_utility"
+	"go-lsm-workshop/txn"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStorageStateLoadExistingStateAfterTrivialMove(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	storageState, _ := state.NewStorageStateWithOptions(testStorageStateOptionsWithCompactionOptions(250, rootPath))
+
+	oracle := txn.NewOracle(txn.NewExecutor(storageState))
+	compaction := compact.NewCompaction(oracle, storageState.SSTableIdGenerator(), storageState.Options())
+
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+		oracle.Close()
+	}()
+
+	batch := kv.NewBatch()
+	_ = batch.Put([]byte("consensus"), []byte("raft"))
+	assert.Nil(t, storageState.Set(kv.NewTimestampedBatchFrom(*batch, 8)))
+
+	batch = kv.NewBatch()
+	_ = batch.Put([]byte("storage"), []byte("Flash SSD"))
+	assert.Nil(t, storageState.Set(kv.NewTimestampedBatchFrom(*batch, 9)))
+
+	batch = kv.NewBatch()
+	_ = batch.Put([]byte("transaction"), []byte("serialized snapshot isolation"))
+	assert.Nil(t, storageState.Set(kv.NewTimestampedBatchFrom(*batch, 10)))
+
+	assert.Nil(t, storageState.ForceFlushNextImmutableMemtable())
+	assert.Nil(t, storageState.ForceFlushNextImmutableMemtable())
+
+	l0SSTableIds := storageState.Snapshot().L0SSTableIds
+	assert.Equal(t, 2, len(l0SSTableIds))
+
+	stateChangeEvent, err := compaction.Start(storageState.Snapshot())
+	assert.Nil(t, err)
+
+	assert.True(t, stateChangeEvent.MovesSSTables())
+	assert.Equal(t, l0SSTableIds, stateChangeEvent.NewSSTableIds)
+	assert.Nil(t, storageState.Apply(stateChangeEvent, false))
+
+	assert.Equal(t, 0, storageState.TotalSSTablesAtLevel(0))
+	assert.Equal(t, l0SSTableIds, storageState.Snapshot().Levels[0].SSTableIds)
+
+	storageState.Close()
+	loadedStorageState, _ := state.NewStorageStateWithOptions(testStorageStateOptionsWithCompactionOptions(250, rootPath))
+
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+		loadedStorageState.Close()
+	}()
+
+	assert.Equal(t, 0, loadedStorageState.TotalSSTablesAtLevel(0))
+	assert.Equal(t, l0SSTableIds, loadedStorageState.Snapshot().Levels[0].SSTableIds)
+
+	value, ok := loadedStorageState.Get(kv.NewStringKeyWithTimestamp("consensus", 11))
+	assert.True(t, ok)
+	assert.Equal(t, kv.NewStringValue("raft"), value)
+
+	value, ok = loadedStorageState.Get(kv.NewStringKeyWithTimestamp("storage", 11))
+	assert.True(t, ok)
+	assert.Equal(t, kv.NewStringValue("Flash SSD"), value)
+}
@@ -0,0 +1,115 @@
+package txn
+
+import (
+	"go-lsm-workshop/kv"
+	"hash/maphash"
+	"slices"
+)
+
+// ConflictIndex is an index of the keys written by the ReadyToCommitTransaction(s), and is used by Oracle to detect
+// read-write conflicts.
+// A naive conflict detection compares every key read by the committing transaction against the batch of every
+// ReadyToCommitTransaction, which is O(reads × ReadyToCommitTransactions × batch size), and it runs under the Oracle lock.
+// ConflictIndex maintains a map from the fingerprint (/hash) of a key to the latest commit-timestamp of the key, which makes
+// conflict detection O(reads).
+// Like [Badger](https://github.com/dgraph-io/badger), it uses key fingerprints instead of keys. A fingerprint collision
+// can only result in a false conflict (the transaction is retried), it never results in a missed conflict.
+// ConflictIndex is not thread-safe, it is always used under the Oracle lock.
+type ConflictIndex struct {
+	seed                   maphash.Seed
+	latestCommitTimestamps map[uint64]uint64
+	//oldest to latest (by commit-timestamp) committed fingerprints.
+	committedFingerprints []CommittedFingerprints
+}
+
+// CommittedFingerprints represents the fingerprints of all the keys written by a transaction with the commitTimestamp.
+type CommittedFingerprints struct {
+	commitTimestamp uint64
+	fingerprints    []uint64
+}
+
+// NewConflictIndex creates a new instance of ConflictIndex.
+func NewConflictIndex() *ConflictIndex {
+	return &ConflictIndex{
+		seed:                   maphash.MakeSeed(),
+		latestCommitTimestamps: make(map[uint64]uint64),
+	}
+}
+
+// track tracks all the keys present in the batch with the given commitTimestamp.
+// Commit-timestamps are given in increasing order by Oracle, so committedFingerprints remain sorted by commit-timestamp.
+func (index *ConflictIndex) track(batch *kv.Batch, commitTimestamp uint64) {
+	pairs := batch.CloneKeyValuePairs()
+	fingerprints := make([]uint64, 0, len(pairs))
+	for _, pair := range pairs {
+		fingerprint := index.fingerprint(pair.Key())
+		index.latestCommitTimestamps[fingerprint] = commitTimestamp
+		fingerprints = append(fingerprints, fingerprint)
+	}
+	index.committedFingerprints = append(index.committedFingerprints, CommittedFingerprints{
+		commitTimestamp: commitTimestamp,
+		fingerprints:    fingerprints,
+	})
+}
+
+// hasConflictFor returns true if any of the keys in reads is written by a transaction with commit-timestamp > beginTimestamp.
+func (index *ConflictIndex) hasConflictFor(reads []kv.RawKey, beginTimestamp uint64) bool {
+	//Assignment 3
+	//Step 1: Determine conflict
+	//Hint: A transaction (with the given beginTimestamp) will conflict, if the latest commit-timestamp of the fingerprint
+	//of any of the keys read by it is > beginTimestamp.
+	return false
+}
+
+// prune removes the fingerprints of all the transactions with commit-timestamp <= maxBeginTimestamp.
+// No running transaction can conflict with these transactions, because all the running transactions have begin-timestamp
+// > maxBeginTimestamp. A fingerprint is removed from latestCommitTimestamps only if it has not been written again by a
+// later transaction.
+func (index *ConflictIndex) prune(maxBeginTimestamp uint64) {
+	prunable := 0
+	for _, committed := range index.committedFingerprints {
+		if committed.commitTimestamp > maxBeginTimestamp {
+			break
+		}
+		for _, fingerprint := range committed.fingerprints {
+			if index.latestCommitTimestamps[fingerprint] == committed.commitTimestamp {
+				delete(index.latestCommitTimestamps, fingerprint)
+			}
+		}
+		prunable++
+	}
+	index.committedFingerprints = index.committedFingerprints[prunable:]
+}
+
+// untrack removes the fingerprints of the transaction with the commitTimestamp, which is not going to be applied (Refer to
+// Oracle.untrackReadyToCommitTransaction). The latest commit-timestamp of each of its fingerprints falls back to the latest
+// commit-timestamp among the remaining transactions which wrote the fingerprint.
+func (index *ConflictIndex) untrack(commitTimestamp uint64) {
+	position := slices.IndexFunc(index.committedFingerprints, func(committed CommittedFingerprints) bool {
+		return committed.commitTimestamp == commitTimestamp
+	})
+	if position == -1 {
+		return
+	}
+	untracked := index.committedFingerprints[position]
+	index.committedFingerprints = slices.Delete(index.committedFingerprints, position, position+1)
+
+	for _, fingerprint := range untracked.fingerprints {
+		if index.latestCommitTimestamps[fingerprint] != commitTimestamp {
+			continue
+		}
+		delete(index.latestCommitTimestamps, fingerprint)
+		for committedIndex := len(index.committedFingerprints) - 1; committedIndex >= 0; committedIndex-- {
+			committed := index.committedFingerprints[committedIndex]
+			if slices.Contains(committed.fingerprints, fingerprint) {
+				index.latestCommitTimestamps[fingerprint] = committed.commitTimestamp
+				break
+			}
+		}
+	}
+}
+
+// fingerprint returns the fingerprint of the key.
+func (index *ConflictIndex) fingerprint(key []byte) uint64 {
+	return maphash.Bytes(index.seed, key)
+}
@@ -0,0 +1,160 @@
+package txn
+
+import (
+	"fmt"
+	"go-lsm-workshop/kv"
+	"go-lsm-workshop/state"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConflictIndexWithAConflict(t *testing.T) {
+	batch := kv.NewBatch()
+	_ = batch.Put([]byte("HDD"), []byte("Hard disk"))
+
+	index := NewConflictIndex()
+	index.track(batch, 5)
+
+	assert.True(t, index.hasConflictFor([]kv.RawKey{kv.RawKey("SSD"), kv.RawKey("HDD")}, 4))
+}
+
+func TestConflictIndexWithoutAConflictGivenTheKeyIsWrittenBeforeTheBeginTimestamp(t *testing.T) {
+	batch := kv.NewBatch()
+	_ = batch.Put([]byte("HDD"), []byte("Hard disk"))
+
+	index := NewConflictIndex()
+	index.track(batch, 5)
+
+	assert.False(t, index.hasConflictFor([]kv.RawKey{kv.RawKey("HDD")}, 5))
+}
+
+func TestConflictIndexWithoutAConflictGivenTheKeyIsNotWritten(t *testing.T) {
+	batch := kv.NewBatch()
+	_ = batch.Put([]byte("HDD"), []byte("Hard disk"))
+
+	index := NewConflictIndex()
+	index.track(batch, 5)
+
+	assert.False(t, index.hasConflictFor([]kv.RawKey{kv.RawKey("SSD")}, 2))
+}
+
+func TestConflictIndexTracksTheLatestCommitTimestampOfAKey(t *testing.T) {
+	batch := kv.NewBatch()
+	_ = batch.Put([]byte("HDD"), []byte("Hard disk"))
+
+	index := NewConflictIndex()
+	index.track(batch, 5)
+	index.track(batch, 8)
+
+	assert.True(t, index.hasConflictFor([]kv.RawKey{kv.RawKey("HDD")}, 6))
+}
+
+func TestConflictIndexPrune(t *testing.T) {
+	aBatch := kv.NewBatch()
+	_ = aBatch.Put([]byte("HDD"), []byte("Hard disk"))
+	_ = aBatch.Put([]byte("SSD"), []byte("Solid state drive"))
+
+	anotherBatch := kv.NewBatch()
+	_ = anotherBatch.Put([]byte("HDD"), []byte("Hard disk drive"))
+
+	index := NewConflictIndex()
+	index.track(aBatch, 5)
+	index.track(anotherBatch, 8)
+
+	index.prune(6)
+
+	assert.Equal(t, 1, len(index.committedFingerprints))
+	assert.Equal(t, 1, len(index.latestCommitTimestamps))
+	assert.True(t, index.hasConflictFor([]kv.RawKey{kv.RawKey("HDD")}, 7))
+	assert.False(t, index.hasConflictFor([]kv.RawKey{kv.RawKey("SSD")}, 4))
+
+	index.prune(8)
+
+	assert.Equal(t, 0, len(index.committedFingerprints))
+	assert.Equal(t, 0, len(index.latestCommitTimestamps))
+}
+
+func BenchmarkConflictIndexWithManyReadyToCommitTransactions(b *testing.B) {
+	const readyToCommitTransactions = 1000
+	const keysPerTransaction = 16
+	const reads = 64
+
+	index := NewConflictIndex()
+	for transaction := 0; transaction < readyToCommitTransactions; transaction++ {
+		batch := kv.NewBatch()
+		for key := 0; key < keysPerTransaction; key++ {
+			_ = batch.Put([]byte(fmt.Sprintf("key-%d-%d", transaction, key)), []byte("value"))
+		}
+		index.track(batch, uint64(transaction+1))
+	}
+	readKeys := make([]kv.RawKey, 0, reads)
+	for read := 0; read < reads; read++ {
+		readKeys = append(readKeys, kv.RawKey(fmt.Sprintf("read-%d", read)))
+	}
+
+	b.ResetTimer()
+	for iteration := 0; iteration < b.N; iteration++ {
+		index.hasConflictFor(readKeys, 0)
+	}
+}
+
+func BenchmarkOracleCommitUnderContention(b *testing.B) {
+	const hotKeys = 8
+	const keySpace = 10_000
+
+	storageState, _ := state.NewStorageState(b.TempDir())
+	oracle := NewOracle(NewExecutor(storageState))
+
+	defer func() {
+		storageState.Close()
+		oracle.Close()
+	}()
+
+	var counter atomic.Uint64
+	var conflicts atomic.Uint64
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			sequence := counter.Add(1)
+
+			transaction := NewReadwriteTransaction(oracle, storageState)
+			transaction.trackReads(kv.RawKey(fmt.Sprintf("hot-%d", sequence%hotKeys)))
+			for read := uint64(0); read < 16; read++ {
+				transaction.trackReads(kv.RawKey(fmt.Sprintf("key-%d", (sequence*31+read)%keySpace)))
+			}
+			_ = transaction.Set([]byte(fmt.Sprintf("hot-%d", (sequence+1)%hotKeys)), []byte("value"))
+			_ = transaction.Set([]byte(fmt.Sprintf("key-%d", sequence%keySpace)), []byte("value"))
+
+			commitTimestamp, err := oracle.mayBeCommitTimestampFor(transaction)
+			if err != nil {
+				conflicts.Add(1)
+			} else {
+				oracle.commitTimestampMark.Finish(commitTimestamp)
+			}
+			oracle.FinishBeginTimestamp(transaction)
+		}
+	})
+	b.ReportMetric(float64(conflicts.Load())/float64(b.N), "conflicts/op")
+}
+
+func TestConflictIndexUntrack(t *testing.T) {
+	aBatch := kv.NewBatch()
+	_ = aBatch.Put([]byte("HDD"), []byte("Hard disk"))
+
+	anotherBatch := kv.NewBatch()
+	_ = anotherBatch.Put([]byte("HDD"), []byte("Hard disk drive"))
+	_ = anotherBatch.Put([]byte("SSD"), []byte("Solid state drive"))
+
+	index := NewConflictIndex()
+	index.track(aBatch, 5)
+	index.track(anotherBatch, 8)
+	index.untrack(8)
+
+	assert.True(t, index.hasConflictFor([]kv.RawKey{kv.RawKey("HDD")}, 4))
+	assert.False(t, index.hasConflictFor([]kv.RawKey{kv.RawKey("HDD")}, 6))
+	assert.False(t, index.hasConflictFor([]kv.RawKey{kv.RawKey("SSD")}, 6))
+	assert.Equal(t, 1, len(index.committedFingerprints))
+}
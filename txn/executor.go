@@ -1,25 +1,53 @@
 package txn
 
 import (
+	"context"
+	"errors"
 	"go-lsm-workshop/future"
 	"go-lsm-workshop/kv"
 	"go-lsm-workshop/state"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
 const incomingChannelSize = 1 * 1024
 
+// writeStopCheckInterval is the interval at which a stopped write re-checks the state.WriteStallCondition.
+const writeStopCheckInterval = 1 * time.Millisecond
+
+var ExecutorStoppedErr = errors.New("executor is stopped, can not commit the transaction")
+
 // Executor is an implementation of [Singular Update Queue](https://martinfowler.com/articles/patterns-of-distributed-systems/singular-update-queue.html).
 // Executor applies all the commits sequentially.
 //
 // It is a single goroutine that reads kv.TimestampedBatch from the incomingChannel.
 // Anytime a Readwrite Transaction is ready to commit, its kv.TimestampedBatch is sent to the TransactionExecutor via the Add() method.
 // Executor applies the batch to the instance of state.StorageState.
+// Executor also applies backpressure on the commits (Refer to mayStallWrite), when the flush and the compaction fall behind
+// the writes.
 type Executor struct {
 	state           *state.StorageState
 	incomingChannel chan ExecutionRequest
 	stopChannel     chan struct{}
 	stopOnce        sync.Once
+	writeStalls     writeStalls
+}
+
+// WriteStallStats is a point-in-time view of the write stalls applied by the Executor.
+// DelayedWrites is the total number of commits delayed because of state.WriteSlowdown, StoppedWrites is the total number
+// of commits blocked because of state.WriteStop, and StallDuration is the total time spent by the commits in the stalls.
+type WriteStallStats struct {
+	DelayedWrites uint64
+	StoppedWrites uint64
+	StallDuration time.Duration
+}
+
+// writeStalls maintains the counters of the write stalls, which are exposed as WriteStallStats.
+type writeStalls struct {
+	delayedWrites      atomic.Uint64
+	stoppedWrites      atomic.Uint64
+	stallDurationNanos atomic.Int64
 }
 
 // NewExecutor creates a new instance of Executor, and starts a single goroutine which will apply the commits sequentially.
@@ -62,10 +90,70 @@ func (executor *Executor) start() {
 // submit submits the kv.TimestampedBatch along with callback to the Executor.
 // kv.TimestampedBatch and callback is wrapped in ExecutionRequest.
 // It returns an instance of Future to allow the clients to wait until the transactional batch is applied to the state machine.
-func (executor *Executor) submit(batch kv.TimestampedBatch, callback func()) *future.Future {
+// Submission blocks if the incomingChannel is full, it returns ctx.Err() if the ctx is done before the request could be submitted.
+func (executor *Executor) submit(ctx context.Context, batch kv.TimestampedBatch, callback func()) (*future.Future, error) {
 	executionRequest := NewExecutionRequest(batch, callback)
-	executor.incomingChannel <- executionRequest
-	return executionRequest.future
+	select {
+	case executor.incomingChannel <- executionRequest:
+		return executionRequest.future, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// mayStallWrite delays or blocks a commit based on the state.WriteStallCondition (Refer to state.WriteStallOptions).
+// With state.WriteSlowdown, the commit is delayed by the SlowdownDelay. With state.WriteStop, the commit is blocked till
+// the flush (or the compaction) brings the state.StorageState below the stop thresholds.
+// It is invoked before a commit gets its commit-timestamp, so the stalled commits do not block the new transactions.
+// It returns ctx.Err() if the ctx is done, and ExecutorStoppedErr if the Executor is stopped during the stall.
+func (executor *Executor) mayStallWrite(ctx context.Context) error {
+	condition := executor.state.WriteStallCondition()
+	if condition == state.NoWriteStall {
+		return nil
+	}
+	stallStart := time.Now()
+	defer func() {
+		executor.writeStalls.stallDurationNanos.Add(int64(time.Since(stallStart)))
+	}()
+
+	if condition == state.WriteStop {
+		executor.writeStalls.stoppedWrites.Add(1)
+		for condition == state.WriteStop {
+			if err := executor.wait(ctx, writeStopCheckInterval); err != nil {
+				return err
+			}
+			condition = executor.state.WriteStallCondition()
+		}
+	}
+	if condition == state.WriteSlowdown {
+		executor.writeStalls.delayedWrites.Add(1)
+		return executor.wait(ctx, executor.state.Options().WriteStallOptions.SlowdownDelay)
+	}
+	return nil
+}
+
+// wait waits for the duration, it returns ctx.Err() if the ctx is done, and ExecutorStoppedErr if the Executor is stopped.
+func (executor *Executor) wait(ctx context.Context, duration time.Duration) error {
+	timer := time.NewTimer(duration)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-executor.stopChannel:
+		return ExecutorStoppedErr
+	}
+}
+
+// WriteStallStats returns the point-in-time WriteStallStats.
+func (executor *Executor) WriteStallStats() WriteStallStats {
+	return WriteStallStats{
+		DelayedWrites: executor.writeStalls.delayedWrites.Load(),
+		StoppedWrites: executor.writeStalls.stoppedWrites.Load(),
+		StallDuration: time.Duration(executor.writeStalls.stallDurationNanos.Load()),
+	}
 }
 
 // stop stops the Executor.
@@ -1,10 +1,12 @@
 package txn
 
 import (
+	"context"
 	"go-lsm-workshop/kv"
 	"go-lsm-workshop/state"
 	"go-lsm-workshop/test_utility"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -24,7 +26,7 @@ func TestSetsABatchWithOneKeyValueUsingExecutor(t *testing.T) {
 	_ = batch.Put([]byte("kv"), []byte("distributed"))
 
 	executor := NewExecutor(storageState)
-	future := executor.submit(kv.NewTimestampedBatchFrom(*batch, 5), nothingCallback)
+	future, _ := executor.submit(context.Background(), kv.NewTimestampedBatchFrom(*batch, 5), nothingCallback)
 
 	future.Wait()
 	assert.True(t, future.Status().IsOk())
@@ -48,7 +50,7 @@ func TestSetsABatchWithOneKeyValueUsingExecutorAndRunsTheCallback(t *testing.T)
 
 	var applied bool
 	executor := NewExecutor(storageState)
-	future := executor.submit(kv.NewTimestampedBatchFrom(*batch, 5), func() {
+	future, _ := executor.submit(context.Background(), kv.NewTimestampedBatchFrom(*batch, 5), func() {
 		applied = true
 	})
 
@@ -75,7 +77,7 @@ func TestSetsABatchWithMultipleKeyValuesUsingExecutor(t *testing.T) {
 	_ = batch.Put([]byte("kv"), []byte("distributed"))
 
 	executor := NewExecutor(storageState)
-	future := executor.submit(kv.NewTimestampedBatchFrom(*batch, 5), nothingCallback)
+	future, _ := executor.submit(context.Background(), kv.NewTimestampedBatchFrom(*batch, 5), nothingCallback)
 
 	future.Wait()
 	assert.True(t, future.Status().IsOk())
@@ -101,7 +103,7 @@ func TestSetsABatchWithMultipleKeyValuesUsingExecutor1(t *testing.T) {
 	executeSet := func(executor *Executor) {
 		batch := kv.NewBatch()
 		_ = batch.Put([]byte("raft"), []byte("consensus"))
-		future := executor.submit(kv.NewTimestampedBatchFrom(*batch, 5), nothingCallback)
+		future, _ := executor.submit(context.Background(), kv.NewTimestampedBatchFrom(*batch, 5), nothingCallback)
 
 		future.Wait()
 		assert.True(t, future.Status().IsOk())
@@ -110,7 +112,7 @@ func TestSetsABatchWithMultipleKeyValuesUsingExecutor1(t *testing.T) {
 	executeDelete := func(executor *Executor) {
 		batch := kv.NewBatch()
 		batch.Delete([]byte("raft"))
-		future := executor.submit(kv.NewTimestampedBatchFrom(*batch, 5), nothingCallback)
+		future, _ := executor.submit(context.Background(), kv.NewTimestampedBatchFrom(*batch, 5), nothingCallback)
 
 		future.Wait()
 		assert.True(t, future.Status().IsOk())
@@ -123,3 +125,127 @@ func TestSetsABatchWithMultipleKeyValuesUsingExecutor1(t *testing.T) {
 	_, ok := storageState.Get(kv.NewKey([]byte("raft"), 6))
 	assert.False(t, ok)
 }
+
+func TestSubmitsABatchToExecutorWithCancelledContext(t *testing.T) {
+	executor := &Executor{
+		incomingChannel: make(chan ExecutionRequest),
+		stopChannel:     make(chan struct{}),
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	batch := kv.NewBatch()
+	_ = batch.Put([]byte("kv"), []byte("distributed"))
+
+	future, err := executor.submit(ctx, kv.NewTimestampedBatchFrom(*batch, 5), nothingCallback)
+	assert.Nil(t, future)
+	assert.Equal(t, context.Canceled, err)
+}
+
+func TestExecutorDelaysTheWriteWithWriteSlowdown(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	storageState, _ := state.NewStorageStateWithOptions(state.StorageOptions{
+		MemTableSizeInBytes:   250,
+		Path:                  rootPath,
+		MaximumMemtables:      10,
+		FlushMemtableDuration: 1 * time.Minute,
+		WriteStallOptions: state.WriteStallOptions{
+			SlowdownImmutableMemtables: 1,
+			SlowdownDelay:              5 * time.Millisecond,
+		},
+	})
+
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+		storageState.Close()
+	}()
+
+	executor := NewExecutor(storageState)
+	defer executor.stop()
+
+	assert.Nil(t, executor.mayStallWrite(context.Background()))
+	assert.Equal(t, WriteStallStats{}, executor.WriteStallStats())
+
+	for timestamp, key := range []string{"consensus", "storage"} {
+		batch := kv.NewBatch()
+		_ = batch.Put([]byte(key), []byte("distributed"))
+		assert.Nil(t, storageState.Set(kv.NewTimestampedBatchFrom(*batch, uint64(timestamp+1))))
+	}
+	assert.Equal(t, state.WriteSlowdown, storageState.WriteStallCondition())
+
+	assert.Nil(t, executor.mayStallWrite(context.Background()))
+	writeStallStats := executor.WriteStallStats()
+	assert.Equal(t, uint64(1), writeStallStats.DelayedWrites)
+	assert.Equal(t, uint64(0), writeStallStats.StoppedWrites)
+	assert.True(t, writeStallStats.StallDuration >= 5*time.Millisecond)
+}
+
+func TestExecutorBlocksTheWriteWithWriteStopTillTheContextIsDone(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	storageState, _ := state.NewStorageStateWithOptions(state.StorageOptions{
+		MemTableSizeInBytes:   250,
+		Path:                  rootPath,
+		MaximumMemtables:      0,
+		FlushMemtableDuration: 1 * time.Minute,
+		WriteStallOptions: state.WriteStallOptions{
+			StopImmutableMemtables: 1,
+		},
+	})
+
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+		storageState.Close()
+	}()
+
+	executor := NewExecutor(storageState)
+	defer executor.stop()
+
+	for timestamp, key := range []string{"consensus", "storage"} {
+		batch := kv.NewBatch()
+		_ = batch.Put([]byte(key), []byte("distributed"))
+		assert.Nil(t, storageState.Set(kv.NewTimestampedBatchFrom(*batch, uint64(timestamp+1))))
+	}
+	assert.Equal(t, state.WriteStop, storageState.WriteStallCondition())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	assert.ErrorIs(t, executor.mayStallWrite(ctx), context.DeadlineExceeded)
+	assert.Equal(t, uint64(1), executor.WriteStallStats().StoppedWrites)
+}
+
+func TestExecutorUnblocksTheWriteWithWriteStopAfterTheFlush(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	storageState, _ := state.NewStorageStateWithOptions(state.StorageOptions{
+		MemTableSizeInBytes:   250,
+		Path:                  rootPath,
+		MaximumMemtables:      0,
+		FlushMemtableDuration: 1 * time.Minute,
+		WriteStallOptions: state.WriteStallOptions{
+			StopImmutableMemtables: 1,
+		},
+	})
+
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+		storageState.Close()
+	}()
+
+	executor := NewExecutor(storageState)
+	defer executor.stop()
+
+	for timestamp, key := range []string{"consensus", "storage"} {
+		batch := kv.NewBatch()
+		_ = batch.Put([]byte(key), []byte("distributed"))
+		assert.Nil(t, storageState.Set(kv.NewTimestampedBatchFrom(*batch, uint64(timestamp+1))))
+	}
+	go func() {
+		time.Sleep(5 * time.Millisecond)
+		_ = storageState.ForceFlushNextImmutableMemtable()
+	}()
+
+	assert.Nil(t, executor.mayStallWrite(context.Background()))
+	assert.Equal(t, state.NoWriteStall, storageState.WriteStallCondition())
+	assert.Equal(t, uint64(1), executor.WriteStallStats().StoppedWrites)
+}
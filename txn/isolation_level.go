@@ -0,0 +1,30 @@
+package txn
+
+// IsolationLevel represents the isolation level of a Readwrite transaction.
+// Serializable (serialized-snapshot-isolation) detects read-write conflicts: a transaction T2 conflicts with T1, if T1 has
+// committed any of the keys read (or any key in the key ranges scanned) by T2 with a commit-timestamp > begin-timestamp of T2.
+// It prevents: dirty-read, fuzzy-read, phantom-read, write-skew and lost-update.
+// SnapshotIsolation detects write-write conflicts: a transaction T2 conflicts with T1, if T1 has committed any of the keys
+// written by T2 with a commit-timestamp > begin-timestamp of T2.
+// It prevents: dirty-read, fuzzy-read and lost-update, but it allows write-skew (and phantoms).
+// SnapshotIsolation is cheaper and does not abort a transaction that only reads the keys being written concurrently,
+// which suits write-heavy workloads that can tolerate write-skew.
+type IsolationLevel uint8
+
+// Isolation levels.
+const (
+	Serializable      IsolationLevel = iota
+	SnapshotIsolation IsolationLevel = 1
+)
+
+// String returns the string representation of IsolationLevel.
+func (isolationLevel IsolationLevel) String() string {
+	switch isolationLevel {
+	case Serializable:
+		return "Serializable"
+	case SnapshotIsolation:
+		return "SnapshotIsolation"
+	default:
+		return "Unknown"
+	}
+}
@@ -0,0 +1,164 @@
+package txn
+
+import (
+	"context"
+	"errors"
+	"go-lsm-workshop/kv"
+	"sync"
+	"time"
+)
+
+var DeadlockErr = errors.New("transaction would deadlock waiting for the key lock, retry")
+var LockTimeoutErr = errors.New("transaction timed out waiting for the key lock, retry")
+
+const DefaultLockTimeout = 1 * time.Second
+
+// LockManager manages the exclusive key locks acquired by the Readwrite transactions (Refer to Transaction.GetForUpdate).
+// Locking is optional, only the keys read using Transaction.GetForUpdate are locked. It suits highly contended keys which
+// would otherwise abort repeatedly with ConflictErr.
+// Every locked key has an owner (the transaction holding the lock) and a FIFO queue of the waiting transactions. When the
+// owner releases the lock (on commit or discard), the lock is handed over to the first waiter.
+// LockManager maintains a wait-for graph (waitsFor) to detect deadlocks: a transaction T1 waits for T2 if T2 owns the key
+// that T1 is waiting for. A transaction attempting to wait for a key which would create a cycle in the graph gets
+// DeadlockErr, without waiting.
+// A transaction waits for a key for at most lockTimeout, after which it gets LockTimeoutErr.
+type LockManager struct {
+	lock        sync.Mutex
+	lockTimeout time.Duration
+	keyLocks    map[string]*KeyLock
+	waitsFor    map[*Transaction]*Transaction
+}
+
+// KeyLock represents the exclusive lock on a key, with its owner and the waiters.
+type KeyLock struct {
+	owner   *Transaction
+	waiters []*LockWaiter
+}
+
+// LockWaiter represents a transaction waiting for a KeyLock, granted is closed when the lock is handed over to the transaction.
+type LockWaiter struct {
+	transaction *Transaction
+	granted     chan struct{}
+}
+
+// NewLockManager creates a new instance of LockManager.
+func NewLockManager(lockTimeout time.Duration) *LockManager {
+	return &LockManager{
+		lockTimeout: lockTimeout,
+		keyLocks:    make(map[string]*KeyLock),
+		waitsFor:    make(map[*Transaction]*Transaction),
+	}
+}
+
+// acquire acquires the exclusive lock on the key for the transaction.
+// It returns immediately if the key is not locked, or is already locked by the same transaction.
+// Else, the transaction is queued behind the other waiters of the key, and it waits till:
+// 1) the lock is handed over to the transaction (returns nil), or
+// 2) lockTimeout elapses (returns LockTimeoutErr), or
+// 3) the ctx is done (returns ctx.Err()).
+// It returns DeadlockErr (without waiting) if waiting for the key would result in a deadlock.
+func (lockManager *LockManager) acquire(ctx context.Context, transaction *Transaction, key kv.RawKey) error {
+	lockManager.lock.Lock()
+	keyLock, ok := lockManager.keyLocks[string(key)]
+	if !ok {
+		lockManager.keyLocks[string(key)] = &KeyLock{owner: transaction}
+		lockManager.lock.Unlock()
+		return nil
+	}
+	if keyLock.owner == transaction {
+		lockManager.lock.Unlock()
+		return nil
+	}
+	if lockManager.wouldDeadlock(transaction, keyLock.owner) {
+		lockManager.lock.Unlock()
+		return DeadlockErr
+	}
+	waiter := &LockWaiter{transaction: transaction, granted: make(chan struct{})}
+	keyLock.waiters = append(keyLock.waiters, waiter)
+	lockManager.waitsFor[transaction] = keyLock.owner
+	lockManager.lock.Unlock()
+
+	timer := time.NewTimer(lockManager.lockTimeout)
+	defer timer.Stop()
+
+	var err error
+	select {
+	case <-waiter.granted:
+		return nil
+	case <-timer.C:
+		err = LockTimeoutErr
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	lockManager.lock.Lock()
+	defer lockManager.lock.Unlock()
+
+	select {
+	case <-waiter.granted:
+		//the lock was handed over while giving up.
+		return nil
+	default:
+	}
+	keyLock.removeWaiter(waiter)
+	delete(lockManager.waitsFor, transaction)
+	return err
+}
+
+// release releases the locks held by the transaction on the given keys.
+// The lock on each key is handed over to its first waiter (if any), and the remaining waiters now wait for the new owner.
+func (lockManager *LockManager) release(transaction *Transaction, keys []kv.RawKey) {
+	lockManager.lock.Lock()
+	defer lockManager.lock.Unlock()
+
+	for _, key := range keys {
+		keyLock, ok := lockManager.keyLocks[string(key)]
+		if !ok || keyLock.owner != transaction {
+			continue
+		}
+		if len(keyLock.waiters) == 0 {
+			delete(lockManager.keyLocks, string(key))
+			continue
+		}
+		next := keyLock.waiters[0]
+		keyLock.waiters = keyLock.waiters[1:]
+		keyLock.owner = next.transaction
+
+		delete(lockManager.waitsFor, next.transaction)
+		for _, waiter := range keyLock.waiters {
+			lockManager.waitsFor[waiter.transaction] = next.transaction
+		}
+		close(next.granted)
+	}
+}
+
+// isLockedByOtherThan returns true if the key is locked by a transaction other than the given transaction.
+func (lockManager *LockManager) isLockedByOtherThan(transaction *Transaction, key kv.RawKey) bool {
+	lockManager.lock.Lock()
+	defer lockManager.lock.Unlock()
+
+	keyLock, ok := lockManager.keyLocks[string(key)]
+	return ok && keyLock.owner != transaction
+}
+
+// wouldDeadlock returns true if the transaction waiting for the owner results in a cycle in the wait-for graph.
+// The graph is acyclic before the wait (every cycle is rejected), so it is enough to follow the chain of owners from the
+// given owner, and check if it reaches the transaction.
+func (lockManager *LockManager) wouldDeadlock(transaction *Transaction, owner *Transaction) bool {
+	for current := owner; current != nil; current = lockManager.waitsFor[current] {
+		if current == transaction {
+			return true
+		}
+	}
+	return false
+}
+
+// removeWaiter removes the waiter from the KeyLock.
+func (keyLock *KeyLock) removeWaiter(waiter *LockWaiter) {
+	for index, existing := range keyLock.waiters {
+		if existing == waiter {
+			keyLock.waiters = append(keyLock.waiters[:index], keyLock.waiters[index+1:]...)
+			return
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package txn
+
+import (
+	"context"
+	"go-lsm-workshop/kv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAcquiresAnUnlockedKey(t *testing.T) {
+	lockManager := NewLockManager(DefaultLockTimeout)
+	transaction := &Transaction{}
+
+	assert.NoError(t, lockManager.acquire(context.Background(), transaction, kv.RawKey("balance")))
+	assert.NoError(t, lockManager.acquire(context.Background(), transaction, kv.RawKey("balance")))
+
+	assert.False(t, lockManager.isLockedByOtherThan(transaction, kv.RawKey("balance")))
+	assert.True(t, lockManager.isLockedByOtherThan(&Transaction{}, kv.RawKey("balance")))
+}
+
+func TestHandsOverTheLockToTheFirstWaiterOnRelease(t *testing.T) {
+	lockManager := NewLockManager(DefaultLockTimeout)
+	owner, aWaiter, anotherWaiter := &Transaction{}, &Transaction{}, &Transaction{}
+
+	assert.NoError(t, lockManager.acquire(context.Background(), owner, kv.RawKey("balance")))
+
+	acquiredBy := make(chan *Transaction, 2)
+	go func() {
+		assert.NoError(t, lockManager.acquire(context.Background(), aWaiter, kv.RawKey("balance")))
+		acquiredBy <- aWaiter
+	}()
+	assert.Eventually(t, func() bool { return lockManager.waitersOf(kv.RawKey("balance")) == 1 }, time.Second, time.Millisecond)
+
+	go func() {
+		assert.NoError(t, lockManager.acquire(context.Background(), anotherWaiter, kv.RawKey("balance")))
+		acquiredBy <- anotherWaiter
+	}()
+	assert.Eventually(t, func() bool { return lockManager.waitersOf(kv.RawKey("balance")) == 2 }, time.Second, time.Millisecond)
+
+	lockManager.release(owner, []kv.RawKey{kv.RawKey("balance")})
+	assert.Equal(t, aWaiter, <-acquiredBy)
+
+	lockManager.release(aWaiter, []kv.RawKey{kv.RawKey("balance")})
+	assert.Equal(t, anotherWaiter, <-acquiredBy)
+
+	lockManager.release(anotherWaiter, []kv.RawKey{kv.RawKey("balance")})
+	assert.False(t, lockManager.isLockedByOtherThan(owner, kv.RawKey("balance")))
+}
+
+func TestDetectsADeadlock(t *testing.T) {
+	lockManager := NewLockManager(DefaultLockTimeout)
+	aTransaction, anotherTransaction := &Transaction{}, &Transaction{}
+
+	assert.NoError(t, lockManager.acquire(context.Background(), aTransaction, kv.RawKey("HDD")))
+	assert.NoError(t, lockManager.acquire(context.Background(), anotherTransaction, kv.RawKey("SSD")))
+
+	acquired := make(chan error, 1)
+	go func() {
+		acquired <- lockManager.acquire(context.Background(), aTransaction, kv.RawKey("SSD"))
+	}()
+	assert.Eventually(t, func() bool { return lockManager.waitersOf(kv.RawKey("SSD")) == 1 }, time.Second, time.Millisecond)
+
+	err := lockManager.acquire(context.Background(), anotherTransaction, kv.RawKey("HDD"))
+	assert.Equal(t, DeadlockErr, err)
+
+	lockManager.release(anotherTransaction, []kv.RawKey{kv.RawKey("SSD")})
+	assert.NoError(t, <-acquired)
+}
+
+func TestTimesOutWaitingForALock(t *testing.T) {
+	lockManager := NewLockManager(5 * time.Millisecond)
+	owner, waiter := &Transaction{}, &Transaction{}
+
+	assert.NoError(t, lockManager.acquire(context.Background(), owner, kv.RawKey("balance")))
+
+	err := lockManager.acquire(context.Background(), waiter, kv.RawKey("balance"))
+	assert.Equal(t, LockTimeoutErr, err)
+	assert.Equal(t, 0, lockManager.waitersOf(kv.RawKey("balance")))
+
+	lockManager.release(owner, []kv.RawKey{kv.RawKey("balance")})
+	assert.NoError(t, lockManager.acquire(context.Background(), waiter, kv.RawKey("balance")))
+}
+
+func TestWaitsForALockWithCancelledContext(t *testing.T) {
+	lockManager := NewLockManager(DefaultLockTimeout)
+	owner, waiter := &Transaction{}, &Transaction{}
+
+	assert.NoError(t, lockManager.acquire(context.Background(), owner, kv.RawKey("balance")))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := lockManager.acquire(ctx, waiter, kv.RawKey("balance"))
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, 0, lockManager.waitersOf(kv.RawKey("balance")))
+}
@@ -0,0 +1,17 @@
+//go:build test
+
+package txn
+
+import "go-lsm-workshop/kv"
+
+// waitersOf returns the number of transactions waiting for the lock on the key, only for testing.
+func (lockManager *LockManager) waitersOf(key kv.RawKey) int {
+	lockManager.lock.Lock()
+	defer lockManager.lock.Unlock()
+
+	keyLock, ok := lockManager.keyLocks[string(key)]
+	if !ok {
+		return 0
+	}
+	return len(keyLock.waiters)
+}
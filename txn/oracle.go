@@ -3,15 +3,20 @@ package txn
 import (
 	"context"
 	"errors"
+	"go-lsm-workshop/future"
+	"go-lsm-workshop/kv"
+	"slices"
 	"sync"
+	"time"
 )
 
 var ConflictErr = errors.New("transaction conflicts with other concurrent transaction, retry")
 
-// ReadyToCommitTransaction is a concurrently running Readwrite transaction which is ready to be committed.
+// ReadyToCommitTransaction is a concurrently running Readwrite transaction (or a batch submitted using SubmitBatch) which
+// is ready to be committed. Only the kv.Batch of the transaction is needed to detect conflicts.
 type ReadyToCommitTransaction struct {
 	commitTimestamp uint64
-	transaction     *Transaction
+	batch           *kv.Batch
 }
 
 // Oracle is the central authority that assigns begin and commit timestamp to the transactions.
@@ -21,6 +26,9 @@ type ReadyToCommitTransaction struct {
 // beginTimestampMark is used to indicate till what timestamp have the transactions begun. This information is used to clean up
 // the readyToCommitTransactions.
 // commitTimestampMark is used to block the new transactions, so all previous commits are visible to a new read.
+// conflictIndex indexes the keys written by the readyToCommitTransactions, and is used to detect read-write conflicts.
+// readyToCommitTransactions are still needed to detect the conflicts on scanned key ranges (Refer to hasRangeConflictFor).
+// lockManager manages the exclusive key locks acquired by the transactions using Transaction.GetForUpdate.
 type Oracle struct {
 	lock                      sync.Mutex
 	executorLock              sync.Mutex
@@ -29,6 +37,8 @@ type Oracle struct {
 	commitTimestampMark       *TransactionTimestampWaterMark
 	executor                  *Executor
 	readyToCommitTransactions []ReadyToCommitTransaction
+	conflictIndex             *ConflictIndex
+	lockManager               *LockManager
 }
 
 // NewOracle creates a new instance of Oracle. It is called once in the entire application.
@@ -36,19 +46,26 @@ type Oracle struct {
 // As a part creating a new instance of NewOracle, we also mark beginTimestampMark and commitTimestampMark
 // as finished for timestamp 0.
 func NewOracle(executor *Executor) *Oracle {
-	return NewOracleWithLastCommitTimestamp(executor, 0)
+	return NewOracleWithLastCommitTimestamp(executor, 0, DefaultLockTimeout)
 }
 
 // NewOracleWithLastCommitTimestamp creates a new instance of Oracle. It is called once in the entire application.
 // Oracle is initialized with nextTimestamp as the lastCommitTimestamp + 1.
 // As a part creating a new instance of NewOracle, we also mark beginTimestampMark and commitTimestampMark
 // as finished for timestamp lastCommitTimestamp.
-func NewOracleWithLastCommitTimestamp(executor *Executor, lastCommitTimestamp uint64) *Oracle {
+// The transactions wait for a key lock for at most lockTimeout (Refer to LockManager), a lockTimeout <= 0 means
+// DefaultLockTimeout.
+func NewOracleWithLastCommitTimestamp(executor *Executor, lastCommitTimestamp uint64, lockTimeout time.Duration) *Oracle {
+	if lockTimeout <= 0 {
+		lockTimeout = DefaultLockTimeout
+	}
 	oracle := &Oracle{
 		nextTimestamp:       lastCommitTimestamp + 1,
 		beginTimestampMark:  NewTransactionTimestampWaterMark(),
 		commitTimestampMark: NewTransactionTimestampWaterMark(),
 		executor:            executor,
+		conflictIndex:       NewConflictIndex(),
+		lockManager:         NewLockManager(lockTimeout),
 	}
 
 	oracle.beginTimestampMark.Finish(oracle.nextTimestamp - 1)
@@ -66,8 +83,12 @@ func (oracle *Oracle) Close() {
 // FinishBeginTimestamp indicates that the beginTimestamp of the transaction is finished.
 // This is an indication to the TransactionTimestampWaterMark that all the transactions upto a given `beginTimestamp`
 // are done. This information will be used in cleaning up the committed transactions.
+// FinishBeginTimestamp is idempotent, the beginTimestamp of a transaction is finished only once, irrespective of the number of
+// invocations (commit, discard, deferred cleanup).
 func (oracle *Oracle) FinishBeginTimestamp(transaction *Transaction) {
-	oracle.beginTimestampMark.Finish(transaction.beginTimestamp)
+	if transaction.beginTimestampFinished.CompareAndSwap(false, true) {
+		oracle.beginTimestampMark.Finish(transaction.beginTimestamp)
+	}
 }
 
 // MaxBeginTimestamp returns the maximum begin timestamp.
@@ -76,11 +97,23 @@ func (oracle *Oracle) MaxBeginTimestamp() uint64 {
 	return oracle.beginTimestampMark.DoneTill()
 }
 
-// beginTimestamp returns the begin-timestamp of a transaction.
+// beginTimestamp returns the begin-timestamp of a transaction, it waits (without any deadline) on the commitTimestampMark.
+// Refer to beginTimestampWithContext.
+func (oracle *Oracle) beginTimestamp() uint64 {
+	beginTimestamp, _ := oracle.beginTimestampWithContext(context.Background())
+	return beginTimestamp
+}
+
+// beginTimestampWithContext returns the begin-timestamp of a transaction.
 // beginTimestamp = nextTimestamp - 1
 // Before returning the begin-timestamp, the system performs a wait on the commitTimestampMark.
 // This wait is to ensure that all the commits till begin-timestamp are applied in the storage.
-func (oracle *Oracle) beginTimestamp() uint64 {
+// If the ctx is done before the wait is over, the begin-timestamp is finished in beginTimestampMark (so that it does not
+// hold back the cleanup of readyToCommitTransactions and compaction) and ctx.Err() is returned.
+func (oracle *Oracle) beginTimestampWithContext(ctx context.Context) (uint64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
 	oracle.lock.Lock()
 
 	//Assignment 1
@@ -89,30 +122,135 @@ func (oracle *Oracle) beginTimestamp() uint64 {
 	oracle.beginTimestampMark.Begin(beginTimestamp)
 	oracle.lock.Unlock()
 
-	_ = oracle.commitTimestampMark.WaitForMark(context.Background(), beginTimestamp)
-	return beginTimestamp
+	if err := oracle.commitTimestampMark.WaitForMark(ctx, beginTimestamp); err != nil {
+		oracle.beginTimestampMark.Finish(beginTimestamp)
+		return 0, err
+	}
+	return beginTimestamp, nil
+}
+
+// latestCommitTimestampWithContext returns the latest commit-timestamp (nextTimestamp - 1), after waiting for all the commits
+// till that timestamp to be applied in the storage. It is used to read a key after locking it (Refer to
+// Transaction.GetForUpdateWithContext).
+// It returns ctx.Err() if the ctx is done before the wait is over.
+func (oracle *Oracle) latestCommitTimestampWithContext(ctx context.Context) (uint64, error) {
+	oracle.lock.Lock()
+	latestCommitTimestamp := oracle.nextTimestamp - 1
+	oracle.lock.Unlock()
+
+	if err := oracle.commitTimestampMark.WaitForMark(ctx, latestCommitTimestamp); err != nil {
+		return 0, err
+	}
+	return latestCommitTimestamp, nil
+}
+
+// LatestCommitTimestamp returns the latest commit-timestamp, after waiting for all the commits till that timestamp to be
+// applied in the storage (Refer to latestCommitTimestampWithContext).
+func (oracle *Oracle) LatestCommitTimestamp(ctx context.Context) (uint64, error) {
+	return oracle.latestCommitTimestampWithContext(ctx)
 }
 
 // mayBeCommitTimestampFor returns the commit-timestamp for a  transaction if there are no conflicts.
-// A ReadWrite transaction Tx conflicts with other transaction if:
-// the keys read by the transaction Tx are modified by another transaction that has the commitTimestamp > beginTimestampOf(Tx).
+// A Serializable ReadWrite transaction Tx conflicts with other transaction if:
+// the keys read by the transaction Tx are modified by another transaction that has the commitTimestamp > beginTimestampOf(Tx), or
+// any key falling in the key ranges scanned by the transaction Tx is written by another transaction that has the
+// commitTimestamp > beginTimestampOf(Tx).
+// A SnapshotIsolation ReadWrite transaction Tx conflicts with other transaction if:
+// the keys written by the transaction Tx are modified by another transaction that has the commitTimestamp > beginTimestampOf(Tx).
+// Irrespective of the IsolationLevel, the keys locked by Tx (using Transaction.GetForUpdate) are not validated, and
+// Tx conflicts if it writes any key locked by another transaction.
 // If there are no conflicts:
 // 1. the current transaction is marked as `beginFinished` by invoking FinishBeginTimestamp.
-// 2. readyToCommitTransactions are cleaned up.
-// 3. commitTimestamp is assigned to the transaction and the nextTimestamp is increased by 1
-// 4. The current transaction is tracked as readyToCommitTransaction
-// 5. commitTimestampMark is used to indicate that a transaction with the `commitTimestamp` has begun.
-// The cleanupReadyToCommitTransactions removes all the committed transactions Ti...Tj where
-// the commitTimestamp of Ti <= maxBeginTransactionTimestamp.
+// 2. the commitTimestamp is assigned to the kv.Batch of the transaction (Refer to commitTimestampForBatch).
 func (oracle *Oracle) mayBeCommitTimestampFor(transaction *Transaction) (uint64, error) {
 	oracle.lock.Lock()
 	defer oracle.lock.Unlock()
 
-	if oracle.hasConflictFor(transaction) {
+	if oracle.hasConflictsFor(transaction) {
 		return 0, ConflictErr
 	}
 
 	oracle.FinishBeginTimestamp(transaction)
+	return oracle.commitTimestampForBatch(transaction.batch), nil
+}
+
+// SubmitBatch submits the kv.Batch to the Executor, without any conflict detection. It is used for bulk writes
+// (Refer to go_lsm_workshop.WriteBatch), which do not read anything and hence do not need read tracking or conflict checks.
+// The batch gets a commit-timestamp (like a Readwrite transaction), and it is tracked as a readyToCommitTransaction, so the
+// concurrent Readwrite transactions conflicting with the batch are still detected.
+// Like Transaction.Commit, the batch may be stalled (Refer to Executor.mayStallWrite), and the executorLock ensures that the
+// batches are sent to the Executor in the order of their commit-timestamps.
+// It returns EmptyTransactionErr if the batch is empty, and ctx.Err() if the ctx is done before the batch could be submitted
+// (the batch is then no longer tracked as a readyToCommitTransaction).
+func (oracle *Oracle) SubmitBatch(ctx context.Context, batch *kv.Batch) (*future.Future, error) {
+	if batch.IsEmpty() {
+		return nil, EmptyTransactionErr
+	}
+	if err := oracle.executor.mayStallWrite(ctx); err != nil {
+		return nil, err
+	}
+
+	oracle.executorLock.Lock()
+	defer oracle.executorLock.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	oracle.lock.Lock()
+	commitTimestamp := oracle.commitTimestampForBatch(batch)
+	oracle.lock.Unlock()
+
+	resultingFuture, err := oracle.executor.submit(ctx, kv.NewTimestampedBatchFrom(*batch, commitTimestamp), func() {
+		oracle.commitTimestampMark.Finish(commitTimestamp)
+	})
+	if err != nil {
+		oracle.untrackReadyToCommitTransaction(commitTimestamp)
+		oracle.commitTimestampMark.Finish(commitTimestamp)
+		return nil, err
+	}
+	return resultingFuture, nil
+}
+
+// ExecuteAtCommitTimestamp assigns a commit-timestamp (without any conflict detection) and invokes the callback with it,
+// after all the commits before the commit-timestamp are applied. It is used for the writes which are not applied by the
+// Executor (Refer to go_lsm_workshop.Db.IngestSSTables).
+// The executorLock is held till the callback returns, so no commit after the commit-timestamp is applied before the callback
+// returns, and the new transactions (with begin-timestamp >= commit-timestamp) wait till the callback returns.
+// The writes done by the callback are not tracked for conflict detection.
+// It returns ctx.Err() if the ctx is done before all the commits before the commit-timestamp are applied, else the error
+// returned by the callback.
+func (oracle *Oracle) ExecuteAtCommitTimestamp(ctx context.Context, callback func(commitTimestamp uint64) error) error {
+	oracle.executorLock.Lock()
+	defer oracle.executorLock.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	oracle.lock.Lock()
+	commitTimestamp := oracle.commitTimestampForBatch(kv.NewBatch())
+	oracle.lock.Unlock()
+
+	defer oracle.commitTimestampMark.Finish(commitTimestamp)
+	if err := oracle.commitTimestampMark.WaitForMark(ctx, commitTimestamp-1); err != nil {
+		return err
+	}
+	return callback(commitTimestamp)
+}
+
+// WriteStallStats returns the point-in-time WriteStallStats of the Executor.
+func (oracle *Oracle) WriteStallStats() WriteStallStats {
+	return oracle.executor.WriteStallStats()
+}
+
+// commitTimestampForBatch returns the commit-timestamp for a kv.Batch, it is invoked with the Oracle lock held.
+// It involves the following:
+// 1. readyToCommitTransactions are cleaned up.
+// 2. commitTimestamp is assigned to the batch and the nextTimestamp is increased by 1
+// 3. The batch is tracked as readyToCommitTransaction
+// 4. commitTimestampMark is used to indicate that a transaction with the `commitTimestamp` has begun.
+// The cleanupReadyToCommitTransactions removes all the committed transactions Ti...Tj where
+// the commitTimestamp of Ti <= maxBeginTransactionTimestamp.
+func (oracle *Oracle) commitTimestampForBatch(batch *kv.Batch) uint64 {
 	oracle.cleanupReadyToCommitTransactions()
 
 	//Assignment 2
@@ -121,29 +259,90 @@ func (oracle *Oracle) mayBeCommitTimestampFor(transaction *Transaction) (uint64,
 	commitTimestamp := 
 	oracle.nextTimestamp = oracle.nextTimestamp + 1
 
-	oracle.trackReadyToCommitTransaction(transaction, commitTimestamp)
+	oracle.trackReadyToCommitTransaction(batch, commitTimestamp)
 	oracle.commitTimestampMark.Begin(commitTimestamp)
-	return commitTimestamp, nil
+	return commitTimestamp
+}
+
+// hasConflictsFor determines if the transaction has a conflict with other concurrent transactions, as per its IsolationLevel.
+func (oracle *Oracle) hasConflictsFor(transaction *Transaction) bool {
+	if oracle.hasLockConflictFor(transaction) {
+		return true
+	}
+	if transaction.isolationLevel == SnapshotIsolation {
+		return oracle.hasWriteConflictFor(transaction)
+	}
+	return oracle.hasConflictFor(transaction) || oracle.hasRangeConflictFor(transaction)
 }
 
 // hasConflictFor determines of the transaction has a conflict with other concurrent transactions.
 // A Readwrite transaction Tx conflicts with other transaction if:
 // the keys read by the transaction Tx are modified by another transaction that has the commitTimestamp > beginTimestampOf(Tx).
 // ReadWriteTransaction tracks its read keys in the `reads` property.
+// The keys modified by readyToCommitTransactions are looked up in the ConflictIndex.
+// The keys locked by the transaction are skipped.
 func (oracle *Oracle) hasConflictFor(transaction *Transaction) bool {
-	
-	//Assignment 3
-	//Step 1: Determine conflict
-	//Hint: The incoming transaction (I) will conflict with any other readyToCommitTransaction (T), if 
-	//T has modified any of the keys read by I with a commit-timestamp > I's begin-timestamp.
+	return oracle.conflictIndex.hasConflictFor(transaction.withoutLockedKeys(transaction.reads), transaction.beginTimestamp)
+}
+
+// hasRangeConflictFor determines if the transaction has a phantom conflict with other concurrent transactions.
+// A Readwrite transaction Tx conflicts with other transaction T if:
+// T has written any key falling in the key ranges scanned by Tx, and T has the commitTimestamp > beginTimestampOf(Tx).
+// Unlike hasConflictFor, the key written by T need not be one of the keys seen by Tx, it could be a new key inserted in a
+// scanned range (/phantom).
+// ReadWriteTransaction tracks its scanned key ranges in the `readRanges` property.
+func (oracle *Oracle) hasRangeConflictFor(transaction *Transaction) bool {
+	if len(transaction.readRanges) == 0 {
+		return false
+	}
+	for _, committedTransaction := range oracle.readyToCommitTransactions {
+		if committedTransaction.commitTimestamp <= transaction.beginTimestamp {
+			continue
+		}
+		for _, keyRange := range transaction.readRanges {
+			if committedTransaction.batch.ContainsAnyKeyIn(keyRange) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// hasWriteConflictFor determines if the transaction has a write-write conflict with other concurrent transactions.
+// A Readwrite transaction Tx conflicts with other transaction if:
+// the keys written by the transaction Tx are modified by another transaction that has the commitTimestamp > beginTimestampOf(Tx).
+// The keys written by Tx are looked up in the ConflictIndex, the same way the keys read by Tx are (in hasConflictFor).
+// The keys locked by the transaction are skipped.
+func (oracle *Oracle) hasWriteConflictFor(transaction *Transaction) bool {
+	return oracle.conflictIndex.hasConflictFor(transaction.withoutLockedKeys(oracle.writesOf(transaction)), transaction.beginTimestamp)
+}
+
+// hasLockConflictFor determines if the transaction writes any key locked by another transaction.
+func (oracle *Oracle) hasLockConflictFor(transaction *Transaction) bool {
+	for _, key := range oracle.writesOf(transaction) {
+		if oracle.lockManager.isLockedByOtherThan(transaction, key) {
+			return true
+		}
+	}
 	return false
 }
 
+// writesOf returns the keys written by the transaction.
+func (oracle *Oracle) writesOf(transaction *Transaction) []kv.RawKey {
+	pairs := transaction.batch.CloneKeyValuePairs()
+	writes := make([]kv.RawKey, 0, len(pairs))
+	for _, pair := range pairs {
+		writes = append(writes, pair.Key())
+	}
+	return writes
+}
+
 // cleanupReadyToCommitTransactions cleans up the readyToCommitTransactions.
 // In order to clean up the transactions the following is done:
 // 1. Get the latest beginTimestampMark
 // 2. For all the readyToCommitTransactions, if the transaction.commitTimestamp <= maxBeginTransactionTimestamp, skip this transaction
 // 3. Create a new array (or slice) of ReadyToCommitTransaction excluding the transactions from step 2.
+// 4. Prune the ConflictIndex using the same maxBeginTransactionTimestamp.
 func (oracle *Oracle) cleanupReadyToCommitTransactions() {
 	readyToCommitTransactions := oracle.readyToCommitTransactions[:0]
 	maxBeginTransactionTimestamp := oracle.beginTimestampMark.DoneTill()
@@ -155,12 +354,28 @@ func (oracle *Oracle) cleanupReadyToCommitTransactions() {
 		readyToCommitTransactions = append(readyToCommitTransactions, transaction)
 	}
 	oracle.readyToCommitTransactions = readyToCommitTransactions
+	oracle.conflictIndex.prune(maxBeginTransactionTimestamp)
+}
+
+// untrackReadyToCommitTransaction stops tracking the transaction (/batch) with the commitTimestamp, which got its
+// commit-timestamp but could not be submitted to the Executor (for example, the ctx was done). Such a batch is never
+// applied, so the concurrent transactions must not conflict with it.
+func (oracle *Oracle) untrackReadyToCommitTransaction(commitTimestamp uint64) {
+	oracle.lock.Lock()
+	defer oracle.lock.Unlock()
+
+	oracle.readyToCommitTransactions = slices.DeleteFunc(oracle.readyToCommitTransactions, func(transaction ReadyToCommitTransaction) bool {
+		return transaction.commitTimestamp == commitTimestamp
+	})
+	oracle.conflictIndex.untrack(commitTimestamp)
 }
 
-// trackReadyToCommitTransaction tracks all the transactions that are ready to be committed.
-func (oracle *Oracle) trackReadyToCommitTransaction(transaction *Transaction, commitTimestamp uint64) {
+// trackReadyToCommitTransaction tracks all the transactions (/batches) that are ready to be committed, and indexes the keys
+// written by the transaction in the ConflictIndex.
+func (oracle *Oracle) trackReadyToCommitTransaction(batch *kv.Batch, commitTimestamp uint64) {
 	oracle.readyToCommitTransactions = append(oracle.readyToCommitTransactions, ReadyToCommitTransaction{
 		commitTimestamp: commitTimestamp,
-		transaction:     transaction,
+		batch:           batch,
 	})
+	oracle.conflictIndex.track(batch, commitTimestamp)
 }
@@ -2,9 +2,11 @@ package txn
 
 import (
 	"context"
+	"go-lsm-workshop/kv"
 	"go-lsm-workshop/state"
 	"go-lsm-workshop/test_utility"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -41,6 +43,31 @@ func TestGetsTheBeginTimestampAfterAPseudoCommit(t *testing.T) {
 	assert.Equal(t, uint64(5), oracle.beginTimestamp())
 }
 
+func TestGetsTheBeginTimestampWithCancelledContext(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	storageState, _ := state.NewStorageState(rootPath)
+	oracle := NewOracle(NewExecutor(storageState))
+
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+		storageState.Close()
+		oracle.Close()
+	}()
+
+	//simulate a commit with commitTimestamp 5 which is not yet applied
+	oracle.nextTimestamp = uint64(6)
+	oracle.commitTimestampMark.Begin(5)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Millisecond)
+	defer cancel()
+
+	_, err := oracle.beginTimestampWithContext(ctx)
+	assert.Equal(t, context.DeadlineExceeded, err)
+
+	assert.Nil(t, oracle.beginTimestampMark.WaitForMark(context.Background(), 5))
+	assert.Equal(t, uint64(5), oracle.MaxBeginTimestamp())
+}
+
 func TestGetsTheMaxBeginTimestamp(t *testing.T) {
 	rootPath := test_utility.SetupADirectoryWithTestName(t)
 	storageState, _ := state.NewStorageState(rootPath)
@@ -168,3 +195,250 @@ func TestResultsInConflictErrorForOneTransaction(t *testing.T) {
 	assert.Error(t, err)
 	assert.Equal(t, ConflictErr, err)
 }
+
+func TestResultsInConflictErrorForATransactionWhichScannedARangeWithAPhantomKey(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	storageState, _ := state.NewStorageState(rootPath)
+	oracle := NewOracle(NewExecutor(storageState))
+
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+		storageState.Close()
+		oracle.Close()
+	}()
+
+	aTransaction := NewReadwriteTransaction(oracle, storageState)
+	iterator, _ := aTransaction.Scan(kv.NewInclusiveKeyRange(kv.RawKey("HDD"), kv.RawKey("SSD")))
+	assert.False(t, iterator.IsValid())
+	iterator.Close()
+	_ = aTransaction.Set([]byte("disk count"), []byte("0"))
+
+	anotherTransaction := NewReadwriteTransaction(oracle, storageState)
+	_ = anotherTransaction.Set([]byte("NVMe"), []byte("Non-volatile memory express"))
+
+	commitTimestamp, _ := oracle.mayBeCommitTimestampFor(anotherTransaction)
+	oracle.commitTimestampMark.Finish(commitTimestamp)
+	assert.Equal(t, uint64(1), commitTimestamp)
+
+	_, err := oracle.mayBeCommitTimestampFor(aTransaction)
+	assert.Error(t, err)
+	assert.Equal(t, ConflictErr, err)
+}
+
+func TestGetsCommitTimestampForATransactionWhichScannedARangeGivenTheOtherTransactionWritesOutsideTheRange(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	storageState, _ := state.NewStorageState(rootPath)
+	oracle := NewOracle(NewExecutor(storageState))
+
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+		storageState.Close()
+		oracle.Close()
+	}()
+
+	aTransaction := NewReadwriteTransaction(oracle, storageState)
+	iterator, _ := aTransaction.Scan(kv.NewInclusiveKeyRange(kv.RawKey("HDD"), kv.RawKey("SSD")))
+	iterator.Close()
+	_ = aTransaction.Set([]byte("disk count"), []byte("0"))
+
+	anotherTransaction := NewReadwriteTransaction(oracle, storageState)
+	_ = anotherTransaction.Set([]byte("Tape"), []byte("Magnetic tape"))
+
+	commitTimestamp, _ := oracle.mayBeCommitTimestampFor(anotherTransaction)
+	oracle.commitTimestampMark.Finish(commitTimestamp)
+	assert.Equal(t, uint64(1), commitTimestamp)
+
+	commitTimestamp, err := oracle.mayBeCommitTimestampFor(aTransaction)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(2), commitTimestamp)
+}
+
+func TestResultsInConflictErrorForWriteSkewWithSerializableIsolationLevel(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	storageState, _ := state.NewStorageState(rootPath)
+	oracle := NewOracle(NewExecutor(storageState))
+
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+		storageState.Close()
+		oracle.Close()
+	}()
+
+	aTransaction := NewReadwriteTransactionWithIsolationLevel(oracle, storageState, Serializable)
+	aTransaction.Get([]byte("HDD"))
+	aTransaction.Get([]byte("SSD"))
+	_ = aTransaction.Set([]byte("HDD"), []byte("Hard disk"))
+
+	anotherTransaction := NewReadwriteTransactionWithIsolationLevel(oracle, storageState, Serializable)
+	anotherTransaction.Get([]byte("HDD"))
+	anotherTransaction.Get([]byte("SSD"))
+	_ = anotherTransaction.Set([]byte("SSD"), []byte("Solid state drive"))
+
+	commitTimestamp, _ := oracle.mayBeCommitTimestampFor(aTransaction)
+	oracle.commitTimestampMark.Finish(commitTimestamp)
+	assert.Equal(t, uint64(1), commitTimestamp)
+
+	_, err := oracle.mayBeCommitTimestampFor(anotherTransaction)
+	assert.Error(t, err)
+	assert.Equal(t, ConflictErr, err)
+}
+
+func TestAllowsWriteSkewWithSnapshotIsolationLevel(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	storageState, _ := state.NewStorageState(rootPath)
+	oracle := NewOracle(NewExecutor(storageState))
+
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+		storageState.Close()
+		oracle.Close()
+	}()
+
+	aTransaction := NewReadwriteTransactionWithIsolationLevel(oracle, storageState, SnapshotIsolation)
+	aTransaction.Get([]byte("HDD"))
+	aTransaction.Get([]byte("SSD"))
+	_ = aTransaction.Set([]byte("HDD"), []byte("Hard disk"))
+
+	anotherTransaction := NewReadwriteTransactionWithIsolationLevel(oracle, storageState, SnapshotIsolation)
+	anotherTransaction.Get([]byte("HDD"))
+	anotherTransaction.Get([]byte("SSD"))
+	_ = anotherTransaction.Set([]byte("SSD"), []byte("Solid state drive"))
+
+	commitTimestamp, _ := oracle.mayBeCommitTimestampFor(aTransaction)
+	oracle.commitTimestampMark.Finish(commitTimestamp)
+	assert.Equal(t, uint64(1), commitTimestamp)
+
+	commitTimestamp, err := oracle.mayBeCommitTimestampFor(anotherTransaction)
+	oracle.commitTimestampMark.Finish(commitTimestamp)
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(2), commitTimestamp)
+}
+
+func TestResultsInConflictErrorForLostUpdateWithSnapshotIsolationLevel(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	storageState, _ := state.NewStorageState(rootPath)
+	oracle := NewOracle(NewExecutor(storageState))
+
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+		storageState.Close()
+		oracle.Close()
+	}()
+
+	aTransaction := NewReadwriteTransactionWithIsolationLevel(oracle, storageState, SnapshotIsolation)
+	aTransaction.Get([]byte("disk count"))
+	_ = aTransaction.Set([]byte("disk count"), []byte("1"))
+
+	anotherTransaction := NewReadwriteTransactionWithIsolationLevel(oracle, storageState, SnapshotIsolation)
+	anotherTransaction.Get([]byte("disk count"))
+	_ = anotherTransaction.Set([]byte("disk count"), []byte("1"))
+
+	commitTimestamp, _ := oracle.mayBeCommitTimestampFor(aTransaction)
+	oracle.commitTimestampMark.Finish(commitTimestamp)
+	assert.Equal(t, uint64(1), commitTimestamp)
+
+	_, err := oracle.mayBeCommitTimestampFor(anotherTransaction)
+	assert.Error(t, err)
+	assert.Equal(t, ConflictErr, err)
+}
+
+func TestGetsCommitTimestampForATransactionReadingAConcurrentlyWrittenKeyWithSnapshotIsolationLevel(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	storageState, _ := state.NewStorageState(rootPath)
+	oracle := NewOracle(NewExecutor(storageState))
+
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+		storageState.Close()
+		oracle.Close()
+	}()
+
+	aTransaction := NewReadwriteTransactionWithIsolationLevel(oracle, storageState, SnapshotIsolation)
+	aTransaction.Get([]byte("HDD"))
+	iterator, _ := aTransaction.Scan(kv.NewInclusiveKeyRange(kv.RawKey("HDD"), kv.RawKey("SSD")))
+	iterator.Close()
+	_ = aTransaction.Set([]byte("disk count"), []byte("0"))
+
+	anotherTransaction := NewReadwriteTransaction(oracle, storageState)
+	_ = anotherTransaction.Set([]byte("HDD"), []byte("Hard disk"))
+	_ = anotherTransaction.Set([]byte("NVMe"), []byte("Non-volatile memory express"))
+
+	commitTimestamp, _ := oracle.mayBeCommitTimestampFor(anotherTransaction)
+	oracle.commitTimestampMark.Finish(commitTimestamp)
+	assert.Equal(t, uint64(1), commitTimestamp)
+
+	commitTimestamp, err := oracle.mayBeCommitTimestampFor(aTransaction)
+	oracle.commitTimestampMark.Finish(commitTimestamp)
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(2), commitTimestamp)
+}
+
+func TestResultsInConflictErrorForATransactionReadingAKeyWrittenBySubmittedBatch(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	storageState, _ := state.NewStorageState(rootPath)
+	oracle := NewOracle(NewExecutor(storageState))
+
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+		storageState.Close()
+		oracle.Close()
+	}()
+
+	transaction := NewReadwriteTransaction(oracle, storageState)
+	transaction.Get([]byte("HDD"))
+	_ = transaction.Set([]byte("SSD"), []byte("Solid state drive"))
+
+	batch := kv.NewBatch()
+	_ = batch.Put([]byte("HDD"), []byte("Hard disk"))
+
+	future, err := oracle.SubmitBatch(context.Background(), batch)
+	assert.NoError(t, err)
+	future.Wait()
+	assert.Equal(t, 1, len(oracle.readyToCommitTransactions))
+
+	_, err = oracle.mayBeCommitTimestampFor(transaction)
+	assert.Equal(t, ConflictErr, err)
+}
+
+func TestSubmitAnEmptyBatch(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	storageState, _ := state.NewStorageState(rootPath)
+	oracle := NewOracle(NewExecutor(storageState))
+
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+		storageState.Close()
+		oracle.Close()
+	}()
+
+	_, err := oracle.SubmitBatch(context.Background(), kv.NewBatch())
+	assert.Equal(t, EmptyTransactionErr, err)
+}
+
+func TestGetsCommitTimestampForATransactionGivenTheConflictingTransactionIsUntracked(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	storageState, _ := state.NewStorageState(rootPath)
+	oracle := NewOracle(NewExecutor(storageState))
+
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+		storageState.Close()
+		oracle.Close()
+	}()
+
+	transaction := NewReadwriteTransaction(oracle, storageState)
+	transaction.Get([]byte("HDD"))
+	_ = transaction.Set([]byte("SSD"), []byte("Solid state drive"))
+
+	cancelledTransaction := NewReadwriteTransaction(oracle, storageState)
+	_ = cancelledTransaction.Set([]byte("HDD"), []byte("Hard disk"))
+
+	commitTimestamp, _ := oracle.mayBeCommitTimestampFor(cancelledTransaction)
+	oracle.untrackReadyToCommitTransaction(commitTimestamp)
+	oracle.commitTimestampMark.Finish(commitTimestamp)
+
+	assert.Equal(t, 0, len(oracle.readyToCommitTransactions))
+
+	_, err := oracle.mayBeCommitTimestampFor(transaction)
+	assert.NoError(t, err)
+}
@@ -3,7 +3,6 @@ package txn
 import (
 	"bytes"
 	"go-lsm-workshop/kv"
-	"sort"
 )
 
 // PendingWritesIterator iterates over the key/value pairs of a Readwrite Transaction that is yet to be committed.
@@ -16,16 +15,15 @@ type PendingWritesIterator struct {
 
 // NewPendingWritesIterator creates a new instance of PendingWritesIterator.
 // It involves the following:
-// 1) Clone all the key/value pairs present in the kv.Batch, and sorts the keys in increasing order.
-// 2) Sort allows a binary search in the first seek operation.
+// 1) Clone all the key/value pairs present in the kv.Batch, the cloned pairs are sorted by key (kv.Batch is an ordered map).
+// 2) Sorted pairs allow a binary search in the first seek operation.
 // 3) Seek to a key greater than or equal to the starting key of the keyRange.
 // Clone is done to ensure that iterator is not impacted even if the kv.Batch is modified after creating an instance of
 // PendingWritesIterator.
+// kv.Batch contains a key at most once (last-write-wins), so a key deleted in the transaction is returned with an empty
+// value (which is skipped by the transaction Iterator), even if it was put earlier in the transaction.
 func NewPendingWritesIterator(batch *kv.Batch, beginTimestamp uint64, keyRange kv.InclusiveKeyRange[kv.RawKey]) *PendingWritesIterator {
 	keyValuePairs := batch.CloneKeyValuePairs()
-	sort.Slice(keyValuePairs, func(i, j int) bool {
-		return bytes.Compare(keyValuePairs[i].Key(), keyValuePairs[j].Key()) < 0
-	})
 	iterator := &PendingWritesIterator{
 		keyValuePairs:     keyValuePairs,
 		index:             0,
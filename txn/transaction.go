@@ -1,15 +1,20 @@
 package txn
 
 import (
+	"context"
 	"errors"
 	"go-lsm-workshop/future"
 	"go-lsm-workshop/iterator"
 	"go-lsm-workshop/kv"
 	"go-lsm-workshop/state"
 	"sync"
+	"sync/atomic"
 )
 
 var EmptyTransactionErr = errors.New("transaction batch is empty, invoke Set in a transaction before committing")
+var DiscardedTransactionErr = errors.New("transaction is discarded, can not perform the operation")
+var AlreadyCommittedErr = errors.New("transaction is already committed, can not commit it again")
+var InvalidSavepointErr = errors.New("savepoint does not belong to the transaction or is already rolled back")
 
 /*
 The transaction implementation in the system follows serialized-snapshot-isolation.
@@ -19,9 +24,14 @@ A brief background on serialized-snapshot-isolation:
    committed data.
 3) When a transaction is ready to commit, and there are no conflicts, it is given a commit-timestamp.
 4) ReadWrite transactions keep a track of the keys read by them.
+   A Readwrite transaction can opt for SnapshotIsolation, in which case only the write-write conflicts are detected.
+   Check IsolationLevel.
    Implementations like [Badger](https://github.com/dgraph-io/badger) keep track of key-hashes inside ReadWrite transactions.
 5) Two transactions conflict if there is a read-write conflict. A transaction T2 conflicts with another transaction T1, if,
    T1 has committed to any of the keys read by T2 with a commit-timestamp greater than the begin-timestamp of T2.
+   ReadWrite transactions also keep a track of the key ranges scanned by them. T2 also conflicts with T1, if T1 has committed
+   any key (including a key that did not exist when T2 scanned) falling in the key ranges scanned by T2, with a commit-timestamp
+   greater than the begin-timestamp of T2. Tracking the individual keys seen by a scan is not enough to prevent phantoms.
 6) Readonly transactions never abort.
 7) It prevents: dirty-read, fuzzy-read, phantom-read, write-skew and lost-update.
 8) Serialized-snapshot-isolation involves keeping a track of `ReadyToCommitTransaction`. Check `Oracle`.
@@ -34,39 +44,104 @@ More details are available [here](https://tech-lessons.in/en/blog/serializable_s
 // An instance of Readwrite transaction maintains:
 // - a reference to kv.Batch which is a collection of key/value pairs, that a transaction operates on.
 // - a collection of all the keys read within the transaction.
-// readLock is used as a lock over the `reads` field, because multiple iterators can be created in a Readwrite transaction.
+// - a collection of all the key ranges scanned within the transaction.
+// - the IsolationLevel, which decides the conflicts detected by the Oracle on commit.
+// - the keys locked (using GetForUpdate) within the transaction, along with the timestamp at which each of them is read.
+// readLock is used as a lock over the `reads`, `readRanges` and `lockedKeys` fields, because multiple iterators can be
+// created in a Readwrite transaction.
+// beginTimestampFinished ensures that the begin-timestamp of the transaction is finished only once in Oracle
+// (Refer to Oracle.FinishBeginTimestamp).
 type Transaction struct {
-	oracle         *Oracle
-	state          *state.StorageState
-	beginTimestamp uint64
-	readonly       bool
-	batch          *kv.Batch
-	reads          []kv.RawKey
-	readLock       sync.Mutex
+	oracle                 *Oracle
+	state                  *state.StorageState
+	beginTimestamp         uint64
+	readonly               bool
+	batch                  *kv.Batch
+	reads                  []kv.RawKey
+	readRanges             []kv.InclusiveKeyRange[kv.RawKey]
+	isolationLevel         IsolationLevel
+	lockedKeys             map[string]uint64
+	readLock               sync.Mutex
+	discarded              atomic.Bool
+	committed              atomic.Bool
+	beginTimestampFinished atomic.Bool
+}
+
+// Savepoint represents a point within a Readwrite transaction, which the transaction can be rolled back to.
+// It captures the number of mutations (in kv.Batch), reads and scanned key ranges of the transaction.
+type Savepoint struct {
+	transaction      *Transaction
+	batchMutations   int
+	readsLength      int
+	readRangesLength int
 }
 
 // NewReadonlyTransaction creates a new instance of Readonly transaction.
 func NewReadonlyTransaction(oracle *Oracle, state *state.StorageState) *Transaction {
+	transaction, _ := NewReadonlyTransactionWithContext(context.Background(), oracle, state)
+	return transaction
+}
+
+// NewReadonlyTransactionWithContext creates a new instance of Readonly transaction.
+// Getting the begin-timestamp involves waiting for all the commits till begin-timestamp to be applied.
+// It returns ctx.Err() if the ctx is done before the wait is over.
+func NewReadonlyTransactionWithContext(ctx context.Context, oracle *Oracle, state *state.StorageState) (*Transaction, error) {
+	beginTimestamp, err := oracle.beginTimestampWithContext(ctx)
+	if err != nil {
+		return nil, err
+	}
 	return &Transaction{
 		oracle:         oracle,
 		state:          state,
-		beginTimestamp: oracle.beginTimestamp(),
+		beginTimestamp: beginTimestamp,
 		readonly:       true,
 		batch:          nil,
 		reads:          nil,
-	}
+	}, nil
 }
 
-// NewReadwriteTransaction creates a new instance of Readwrite transaction.
+// NewReadwriteTransaction creates a new instance of Readwrite transaction with Serializable isolation level.
 func NewReadwriteTransaction(oracle *Oracle, state *state.StorageState) *Transaction {
+	transaction, _ := NewReadwriteTransactionWithContext(context.Background(), oracle, state)
+	return transaction
+}
+
+// NewReadwriteTransactionWithIsolationLevel creates a new instance of Readwrite transaction with the given IsolationLevel.
+func NewReadwriteTransactionWithIsolationLevel(oracle *Oracle, state *state.StorageState, isolationLevel IsolationLevel) *Transaction {
+	transaction, _ := NewReadwriteTransactionWithContextAndIsolationLevel(context.Background(), oracle, state, isolationLevel)
+	return transaction
+}
+
+// NewReadwriteTransactionWithContext creates a new instance of Readwrite transaction with Serializable isolation level.
+// Getting the begin-timestamp involves waiting for all the commits till begin-timestamp to be applied.
+// It returns ctx.Err() if the ctx is done before the wait is over.
+func NewReadwriteTransactionWithContext(ctx context.Context, oracle *Oracle, state *state.StorageState) (*Transaction, error) {
+	return NewReadwriteTransactionWithContextAndIsolationLevel(ctx, oracle, state, Serializable)
+}
+
+// NewReadwriteTransactionWithContextAndIsolationLevel creates a new instance of Readwrite transaction with the given
+// IsolationLevel.
+// Getting the begin-timestamp involves waiting for all the commits till begin-timestamp to be applied.
+// It returns ctx.Err() if the ctx is done before the wait is over.
+func NewReadwriteTransactionWithContextAndIsolationLevel(
+	ctx context.Context,
+	oracle *Oracle,
+	state *state.StorageState,
+	isolationLevel IsolationLevel,
+) (*Transaction, error) {
+	beginTimestamp, err := oracle.beginTimestampWithContext(ctx)
+	if err != nil {
+		return nil, err
+	}
 	return &Transaction{
 		oracle:         oracle,
 		state:          state,
-		beginTimestamp: oracle.beginTimestamp(),
+		beginTimestamp: beginTimestamp,
 		readonly:       false,
 		batch:          kv.NewBatch(),
 		reads:          nil,
-	}
+		isolationLevel: isolationLevel,
+	}, nil
 }
 
 // Get gets the value for the given key.
@@ -75,6 +150,7 @@ func NewReadwriteTransaction(oracle *Oracle, state *state.StorageState) *Transac
 // 1) Getting the begin-timestamp of the transaction.
 // 2) Getting the value corresponding to the timestamped key from state.StorageState.
 // Please note: the system returns the value where the timestamp of the key in the system <= begin-timestamp of the transaction.
+// A Readwrite transaction reads its own writes from kv.Batch first, a key deleted in the transaction does not exist for it.
 func (transaction *Transaction) Get(key []byte) (kv.Value, bool) {
 	versionedKey := kv.NewKey(key, transaction.beginTimestamp)
 	if transaction.readonly {
@@ -85,17 +161,65 @@ func (transaction *Transaction) Get(key []byte) (kv.Value, bool) {
 	//Step1: Track the keys read by the transaction.
 
 	if value, ok := transaction.batch.Get(key); ok {
-		return value, true
+		return value, !value.IsEmpty()
+	}
+	if readTimestamp, ok := transaction.lockReadTimestamp(key); ok {
+		versionedKey = kv.NewKey(key, readTimestamp)
 	}
 	return transaction.state.Get(versionedKey)
 }
 
+// GetForUpdate is a variant of Get which acquires an exclusive lock on the key, before reading it.
+// Refer to GetForUpdateWithContext.
+func (transaction *Transaction) GetForUpdate(key []byte) (kv.Value, bool, error) {
+	return transaction.GetForUpdateWithContext(context.Background(), key)
+}
+
+// GetForUpdateWithContext acquires an exclusive lock on the key (using LockManager), and gets the value for the key.
+// It panics if the transaction is Readonly.
+// Locking is pessimistic, it suits highly contended keys which would otherwise abort repeatedly with ConflictErr:
+// 1) A transaction waits (in a FIFO queue) for the lock to be released by its owner, it gets DeadlockErr if waiting would
+// result in a deadlock, LockTimeoutErr if the lock could not be acquired in time, and ctx.Err() if the ctx is done.
+// 2) Once the lock is acquired, the key is read at the latest commit-timestamp (and not at the begin-timestamp), after
+// waiting for all the commits till that timestamp to be applied. All subsequent Get(s) of the key within the
+// transaction read at the same timestamp.
+// 3) The Oracle does not validate the reads and writes of a locked key on commit, because no other transaction can commit
+// the key while it is locked (a commit writing a key locked by another transaction gets ConflictErr).
+// The locks are released on Commit or Discard.
+// It returns DiscardedTransactionErr if the transaction is discarded.
+func (transaction *Transaction) GetForUpdateWithContext(ctx context.Context, key []byte) (kv.Value, bool, error) {
+	if transaction.readonly {
+		panic("transaction is readonly")
+	}
+	if transaction.discarded.Load() {
+		return kv.EmptyValue, false, DiscardedTransactionErr
+	}
+	if err := transaction.oracle.lockManager.acquire(ctx, transaction, key); err != nil {
+		return kv.EmptyValue, false, err
+	}
+	readTimestamp, ok := transaction.lockReadTimestamp(key)
+	if !ok {
+		var err error
+		if readTimestamp, err = transaction.oracle.latestCommitTimestampWithContext(ctx); err != nil {
+			transaction.oracle.lockManager.release(transaction, []kv.RawKey{key})
+			return kv.EmptyValue, false, err
+		}
+		transaction.trackLockedKey(key, readTimestamp)
+	}
+	if value, ok := transaction.batch.Get(key); ok {
+		return value, !value.IsEmpty(), nil
+	}
+	value, ok := transaction.state.Get(kv.NewKey(key, readTimestamp))
+	return value, ok, nil
+}
+
 // Scan supports scan operation by taking an instance of kv.InclusiveKeyRange.
 // Scan involves the following:
 // 1) Getting the begin-timestamp of the transaction.
 // 2) Creating a versionedKeyRange.
 // 3) Scanning over state.StorageState if the transaction is a Readonly transaction.
 // 4) Scanning over the kv.Batch and state.StorageState if the transaction is a Readwrite transaction.
+// 5) Tracking the scanned keyRange if the transaction is a Readwrite transaction.
 func (transaction *Transaction) Scan(keyRange kv.InclusiveKeyRange[kv.RawKey]) (iterator.Iterator, error) {
 	versionedKeyRange := kv.NewInclusiveKeyRange(
 		kv.NewKey(keyRange.Start(), transaction.beginTimestamp),
@@ -104,6 +228,7 @@ func (transaction *Transaction) Scan(keyRange kv.InclusiveKeyRange[kv.RawKey]) (
 	if transaction.readonly {
 		return transaction.state.Scan(versionedKeyRange), nil
 	}
+	transaction.trackReadRange(keyRange)
 	pendingWritesIteratorMergedWithStateIterator := iterator.NewMergeIterator(
 		[]iterator.Iterator{
 			NewPendingWritesIterator(transaction.batch, transaction.beginTimestamp, keyRange),
@@ -119,20 +244,29 @@ func (transaction *Transaction) Scan(keyRange kv.InclusiveKeyRange[kv.RawKey]) (
 }
 
 // Set sets the key/value pair in the kv.Batch associated with the Transaction.
-// It panics if the same key is added again or the transaction is a Readonly transaction.
+// A later Set or Delete of the same key in the transaction replaces the earlier one (last-write-wins).
+// It panics if the transaction is a Readonly transaction.
+// It returns DiscardedTransactionErr if the transaction is discarded.
 func (transaction *Transaction) Set(key, value []byte) error {
 	if transaction.readonly {
 		panic("transaction is readonly")
 	}
+	if transaction.discarded.Load() {
+		return DiscardedTransactionErr
+	}
 	return transaction.batch.Put(key, value)
 }
 
 // Delete adds the key in the kv.Batch.
 // It panics if the transaction is a Readonly transaction.
+// It returns DiscardedTransactionErr if the transaction is discarded.
 func (transaction *Transaction) Delete(key []byte) error {
 	if transaction.readonly {
 		panic("transaction is readonly")
 	}
+	if transaction.discarded.Load() {
+		return DiscardedTransactionErr
+	}
 	transaction.batch.Delete(key)
 	return nil
 }
@@ -140,32 +274,134 @@ func (transaction *Transaction) Delete(key []byte) error {
 // Commit commits the transaction. It panics if the transaction is Readonly or kv.Batch is empty.
 // Commit involves the following:
 // 1) Acquiring an executorLock to ensure that the transaction are sent to the Executor in the order they invoke Commit.
-// 2) Getting the commit timestamp for the transaction. Commit timestamp is only provided if the transaction does not have any RW conflict.
+// 2) Getting the commit timestamp for the transaction. Commit timestamp is only provided if the transaction does not have any
+//    conflict (RW conflict for Serializable, WW conflict for SnapshotIsolation).
 // 3) Submitting the kv.TimestampedBatch to the Executor.
 // 4) Passing a commit callback along with kv.TimestampedBatch to the Executor which is invoked when the entire batch is applied.
 // 5) The commit callback informs the `commitTimestampMark` of Oracle that a transaction with `commitTimestamp` is done.
-func (transaction *Transaction) Commit() (*future.Future, error) {
+// The key locks (if any) are released once the transaction gets its commit timestamp.
+// Before acquiring the executorLock, the commit may be stalled if the flush and the compaction fall behind the writes
+// (Refer to Executor.mayStallWrite).
+// If the ctx is done before the kv.TimestampedBatch could be submitted to the Executor, the transaction is no longer tracked
+// for conflict detection (it is never applied), the `commitTimestamp` is marked as done (so that new transactions are not
+// blocked on it), and ctx.Err() is returned.
+// A transaction is committed at most once, a repeated Commit returns AlreadyCommittedErr (and the kv.Batch is not applied again).
+func (transaction *Transaction) Commit(ctx context.Context) (*future.Future, error) {
 	if transaction.readonly {
 		panic("transaction is readonly")
 	}
+	if transaction.discarded.Load() {
+		return nil, DiscardedTransactionErr
+	}
+	if transaction.committed.Load() {
+		return nil, AlreadyCommittedErr
+	}
 	if transaction.batch.IsEmpty() {
 		return nil, EmptyTransactionErr
 	}
+	if err := transaction.oracle.executor.mayStallWrite(ctx); err != nil {
+		return nil, err
+	}
 
 	transaction.oracle.executorLock.Lock()
 	defer transaction.oracle.executorLock.Unlock()
 
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if transaction.committed.Load() {
+		return nil, AlreadyCommittedErr
+	}
 	commitTimestamp, err := transaction.oracle.mayBeCommitTimestampFor(transaction)
 	if err != nil {
 		return nil, err
 	}
+	transaction.releaseLocks()
+
 	commitCallback := func() {
 		transaction.oracle.commitTimestampMark.Finish(commitTimestamp)
 	}
 
 	//Assignment 5:
 	//Step1: Send the transaction to be applied serially.
-	return , nil
+	resultingFuture, err := 
+	if err != nil {
+		transaction.oracle.untrackReadyToCommitTransaction(commitTimestamp)
+		transaction.oracle.commitTimestampMark.Finish(commitTimestamp)
+		return nil, err
+	}
+	transaction.committed.Store(true)
+	return resultingFuture, nil
+}
+
+// Savepoint creates a Savepoint at the current state of the Readwrite transaction. It panics if the transaction is Readonly.
+// Savepoints can be nested, a transaction can be rolled back to any of its savepoints (Refer to RollbackTo).
+func (transaction *Transaction) Savepoint() Savepoint {
+	if transaction.readonly {
+		panic("transaction is readonly")
+	}
+	transaction.readLock.Lock()
+	defer transaction.readLock.Unlock()
+
+	return Savepoint{
+		transaction:      transaction,
+		batchMutations:   transaction.batch.Savepoint(),
+		readsLength:      len(transaction.reads),
+		readRangesLength: len(transaction.readRanges),
+	}
+}
+
+// RollbackTo rolls back the Readwrite transaction to the given Savepoint. It panics if the transaction is Readonly.
+// It discards the writes, reads and scanned key ranges of the transaction after the savepoint, so that:
+// 1) Get and Scan (via PendingWritesIterator) do not see the writes discarded by the rollback, the iterators created
+// before the rollback are not affected.
+// 2) The Oracle does not validate the reads discarded by the rollback.
+// The key locks acquired (using GetForUpdate) after the savepoint are retained till Commit or Discard.
+// The savepoint remains valid after the rollback, the transaction can be rolled back to it again. The savepoints created
+// after the given savepoint must not be used after the rollback.
+// It returns InvalidSavepointErr if the savepoint belongs to another transaction or is ahead of the current state of the
+// transaction, and DiscardedTransactionErr if the transaction is discarded.
+func (transaction *Transaction) RollbackTo(savepoint Savepoint) error {
+	if transaction.readonly {
+		panic("transaction is readonly")
+	}
+	if transaction.discarded.Load() {
+		return DiscardedTransactionErr
+	}
+	transaction.readLock.Lock()
+	defer transaction.readLock.Unlock()
+
+	if savepoint.transaction != transaction ||
+		savepoint.batchMutations > transaction.batch.Mutations() ||
+		savepoint.readsLength > len(transaction.reads) ||
+		savepoint.readRangesLength > len(transaction.readRanges) {
+		return InvalidSavepointErr
+	}
+	transaction.batch.RollbackTo(savepoint.batchMutations)
+	transaction.reads = transaction.reads[:savepoint.readsLength]
+	transaction.readRanges = transaction.readRanges[:savepoint.readRangesLength]
+	return nil
+}
+
+// BeginTimestamp returns the begin-timestamp of the transaction, all the commits till the begin-timestamp are visible to it.
+func (transaction *Transaction) BeginTimestamp() uint64 {
+	return transaction.beginTimestamp
+}
+
+// IsolationLevel returns the IsolationLevel of the transaction.
+func (transaction *Transaction) IsolationLevel() IsolationLevel {
+	return transaction.isolationLevel
+}
+
+// Discard discards the transaction. It is safe to invoke Discard multiple times, and also after Commit.
+// Discarding a transaction informs the Oracle that the begin-timestamp of the transaction is finished (if not already done),
+// releases the key locks (if any), and any subsequent Set, Delete, GetForUpdate or Commit return DiscardedTransactionErr.
+// Discard does not roll back a transaction which has already been committed.
+// The usual pattern is to invoke Discard (using defer) immediately after creating a transaction.
+func (transaction *Transaction) Discard() {
+	transaction.discarded.Store(true)
+	transaction.oracle.FinishBeginTimestamp(transaction)
+	transaction.releaseLocks()
 }
 
 // trackReads keeps a track of all the keys read in the Readwrite transaction.
@@ -174,3 +410,64 @@ func (transaction *Transaction) trackReads(key kv.RawKey) {
 	transaction.reads = append(transaction.reads, key)
 	transaction.readLock.Unlock()
 }
+
+// trackReadRange keeps a track of all the key ranges scanned in the Readwrite transaction.
+// The entire keyRange is tracked, irrespective of how far the iterator is moved. This is conservative, because a key
+// inserted by a concurrent transaction anywhere in the keyRange could have been seen by the scan.
+func (transaction *Transaction) trackReadRange(keyRange kv.InclusiveKeyRange[kv.RawKey]) {
+	transaction.readLock.Lock()
+	transaction.readRanges = append(transaction.readRanges, keyRange)
+	transaction.readLock.Unlock()
+}
+
+// trackLockedKey keeps a track of the key locked in the Readwrite transaction, along with the timestamp at which it is read.
+func (transaction *Transaction) trackLockedKey(key kv.RawKey, readTimestamp uint64) {
+	transaction.readLock.Lock()
+	defer transaction.readLock.Unlock()
+
+	if transaction.lockedKeys == nil {
+		transaction.lockedKeys = make(map[string]uint64)
+	}
+	transaction.lockedKeys[string(key)] = readTimestamp
+}
+
+// lockReadTimestamp returns the timestamp at which the locked key is read, and false if the key is not locked.
+func (transaction *Transaction) lockReadTimestamp(key kv.RawKey) (uint64, bool) {
+	transaction.readLock.Lock()
+	defer transaction.readLock.Unlock()
+
+	readTimestamp, ok := transaction.lockedKeys[string(key)]
+	return readTimestamp, ok
+}
+
+// withoutLockedKeys returns the keys which are not locked in the Readwrite transaction.
+func (transaction *Transaction) withoutLockedKeys(keys []kv.RawKey) []kv.RawKey {
+	transaction.readLock.Lock()
+	defer transaction.readLock.Unlock()
+
+	if len(transaction.lockedKeys) == 0 {
+		return keys
+	}
+	unlockedKeys := make([]kv.RawKey, 0, len(keys))
+	for _, key := range keys {
+		if _, ok := transaction.lockedKeys[string(key)]; !ok {
+			unlockedKeys = append(unlockedKeys, key)
+		}
+	}
+	return unlockedKeys
+}
+
+// releaseLocks releases all the key locks held by the Readwrite transaction.
+func (transaction *Transaction) releaseLocks() {
+	transaction.readLock.Lock()
+	lockedKeys := make([]kv.RawKey, 0, len(transaction.lockedKeys))
+	for key := range transaction.lockedKeys {
+		lockedKeys = append(lockedKeys, kv.RawKey(key))
+	}
+	transaction.lockedKeys = nil
+	transaction.readLock.Unlock()
+
+	if len(lockedKeys) > 0 {
+		transaction.oracle.lockManager.release(transaction, lockedKeys)
+	}
+}
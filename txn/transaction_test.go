@@ -1,6 +1,7 @@
 package txn
 
 import (
+	"context"
 	"go-lsm-workshop/kv"
 	"go-lsm-workshop/state"
 	"go-lsm-workshop/table"
@@ -167,12 +168,93 @@ func TestAttemptsToCommitAnEmptyReadwriteTransaction(t *testing.T) {
 	oracle.commitTimestampMark.Finish(2)
 	transaction := NewReadwriteTransaction(oracle, storageState)
 
-	_, err := transaction.Commit()
+	_, err := transaction.Commit(context.Background())
 
 	assert.Error(t, err)
 	assert.Equal(t, EmptyTransactionErr, err)
 }
 
+func TestAttemptsToCommitADiscardedReadwriteTransaction(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	storageState, _ := state.NewStorageState(rootPath)
+	oracle := NewOracle(NewExecutor(storageState))
+
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+		storageState.Close()
+		oracle.Close()
+	}()
+
+	transaction := NewReadwriteTransaction(oracle, storageState)
+	_ = transaction.Set([]byte("HDD"), []byte("Hard disk"))
+	transaction.Discard()
+
+	_, err := transaction.Commit(context.Background())
+	assert.Error(t, err)
+	assert.Equal(t, DiscardedTransactionErr, err)
+
+	err = transaction.Set([]byte("SSD"), []byte("Solid state drive"))
+	assert.Equal(t, DiscardedTransactionErr, err)
+
+	err = transaction.Delete([]byte("HDD"))
+	assert.Equal(t, DiscardedTransactionErr, err)
+}
+
+func TestAttemptsToCommitAReadwriteTransactionTwice(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	storageState, _ := state.NewStorageState(rootPath)
+	oracle := NewOracle(NewExecutor(storageState))
+
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+		storageState.Close()
+		oracle.Close()
+	}()
+
+	transaction := NewReadwriteTransaction(oracle, storageState)
+	_ = transaction.Set([]byte("HDD"), []byte("Hard disk"))
+
+	future, err := transaction.Commit(context.Background())
+	assert.Nil(t, err)
+	future.Wait()
+	nextTimestamp := oracle.nextTimestamp
+
+	_, err = transaction.Commit(context.Background())
+	assert.Equal(t, AlreadyCommittedErr, err)
+	assert.Equal(t, nextTimestamp, oracle.nextTimestamp)
+}
+
+func TestDiscardsAReadwriteTransactionMultipleTimes(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	storageState, _ := state.NewStorageState(rootPath)
+	oracle := NewOracle(NewExecutor(storageState))
+
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+		storageState.Close()
+		oracle.Close()
+	}()
+
+	transaction := NewReadwriteTransaction(oracle, storageState)
+	_ = transaction.Set([]byte("HDD"), []byte("Hard disk"))
+
+	future, err := transaction.Commit(context.Background())
+	assert.NoError(t, err)
+	future.Wait()
+
+	transaction.Discard()
+	transaction.Discard()
+
+	assert.True(t, transaction.beginTimestampFinished.Load())
+
+	readonlyTransaction := NewReadonlyTransaction(oracle, storageState)
+	defer readonlyTransaction.Discard()
+
+	value, ok := readonlyTransaction.Get([]byte("HDD"))
+	assert.True(t, ok)
+	assert.Equal(t, "Hard disk", value.String())
+}
+
 func TestGetsAnExistingKeyInAReadwriteTransaction(t *testing.T) {
 	rootPath := test_utility.SetupADirectoryWithTestName(t)
 	storageState, _ := state.NewStorageState(rootPath)
@@ -186,12 +268,12 @@ func TestGetsAnExistingKeyInAReadwriteTransaction(t *testing.T) {
 
 	transaction := NewReadwriteTransaction(oracle, storageState)
 	_ = transaction.Set([]byte("HDD"), []byte("Hard disk"))
-	future, _ := transaction.Commit()
+	future, _ := transaction.Commit(context.Background())
 	future.Wait()
 
 	anotherTransaction := NewReadwriteTransaction(oracle, storageState)
 	_ = anotherTransaction.Set([]byte("SSD"), []byte("Solid state drive"))
-	future, _ = anotherTransaction.Commit()
+	future, _ = anotherTransaction.Commit(context.Background())
 	future.Wait()
 
 	readonlyTransaction := NewReadonlyTransaction(oracle, storageState)
@@ -226,7 +308,7 @@ func TestGetsTheValueFromAKeyInAReadwriteTransactionFromBatch(t *testing.T) {
 	assert.Equal(t, true, ok)
 	assert.Equal(t, "Hard disk", value.String())
 
-	future, _ := transaction.Commit()
+	future, _ := transaction.Commit(context.Background())
 	future.Wait()
 }
 
@@ -245,7 +327,7 @@ func TestTracksReadsInAReadwriteTransactionWithGet(t *testing.T) {
 	_ = transaction.Set([]byte("HDD"), []byte("Hard disk"))
 	transaction.Get([]byte("SSD"))
 
-	future, _ := transaction.Commit()
+	future, _ := transaction.Commit(context.Background())
 	future.Wait()
 
 	assert.Equal(t, 1, len(transaction.reads))
@@ -443,3 +525,235 @@ func TestReferencesToSSTableInTransactionScan(t *testing.T) {
 
 	assert.Equal(t, int64(0), ssTable.TotalReferences())
 }
+
+func TestGetForUpdateReadsTheValueCommittedByThePreviousLockOwner(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	storageState, _ := state.NewStorageState(rootPath)
+	oracle := NewOracle(NewExecutor(storageState))
+
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+		storageState.Close()
+		oracle.Close()
+	}()
+
+	aTransaction := NewReadwriteTransaction(oracle, storageState)
+	defer aTransaction.Discard()
+
+	anotherTransaction := NewReadwriteTransaction(oracle, storageState)
+	defer anotherTransaction.Discard()
+
+	_, ok, err := aTransaction.GetForUpdate([]byte("balance"))
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	type valueWithError struct {
+		value kv.Value
+		ok    bool
+		err   error
+	}
+	lockedValue := make(chan valueWithError, 1)
+	go func() {
+		value, ok, err := anotherTransaction.GetForUpdate([]byte("balance"))
+		lockedValue <- valueWithError{value: value, ok: ok, err: err}
+	}()
+
+	_ = aTransaction.Set([]byte("balance"), []byte("10"))
+	future, err := aTransaction.Commit(context.Background())
+	assert.NoError(t, err)
+	future.Wait()
+
+	result := <-lockedValue
+	assert.NoError(t, result.err)
+	assert.True(t, result.ok)
+	assert.Equal(t, "10", result.value.String())
+
+	value, ok := anotherTransaction.Get([]byte("balance"))
+	assert.True(t, ok)
+	assert.Equal(t, "10", value.String())
+
+	_ = anotherTransaction.Set([]byte("balance"), []byte("20"))
+	future, err = anotherTransaction.Commit(context.Background())
+	assert.NoError(t, err)
+	future.Wait()
+
+	readonlyTransaction := NewReadonlyTransaction(oracle, storageState)
+	defer readonlyTransaction.Discard()
+
+	value, ok = readonlyTransaction.Get([]byte("balance"))
+	assert.True(t, ok)
+	assert.Equal(t, "20", value.String())
+}
+
+func TestAttemptsToCommitAKeyLockedByAnotherTransaction(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	storageState, _ := state.NewStorageState(rootPath)
+	oracle := NewOracle(NewExecutor(storageState))
+
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+		storageState.Close()
+		oracle.Close()
+	}()
+
+	aTransaction := NewReadwriteTransaction(oracle, storageState)
+	_, _, err := aTransaction.GetForUpdate([]byte("balance"))
+	assert.NoError(t, err)
+
+	anotherTransaction := NewReadwriteTransaction(oracle, storageState)
+	defer anotherTransaction.Discard()
+
+	_ = anotherTransaction.Set([]byte("balance"), []byte("10"))
+	_, err = anotherTransaction.Commit(context.Background())
+	assert.Equal(t, ConflictErr, err)
+
+	aTransaction.Discard()
+
+	future, err := anotherTransaction.Commit(context.Background())
+	assert.NoError(t, err)
+	future.Wait()
+}
+
+func TestRollbackAReadwriteTransactionToASavepoint(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	storageState, _ := state.NewStorageState(rootPath)
+	oracle := NewOracle(NewExecutor(storageState))
+
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+		storageState.Close()
+		oracle.Close()
+	}()
+
+	transaction := NewReadwriteTransaction(oracle, storageState)
+	_ = transaction.Set([]byte("HDD"), []byte("Hard disk"))
+	transaction.Get([]byte("HDD"))
+
+	savepoint := transaction.Savepoint()
+
+	_ = transaction.Set([]byte("SSD"), []byte("Solid state drive"))
+	transaction.Get([]byte("NVMe"))
+	iterator, _ := transaction.Scan(kv.NewInclusiveKeyRange(kv.RawKey("HDD"), kv.RawKey("SSD")))
+	iterator.Close()
+
+	assert.NoError(t, transaction.RollbackTo(savepoint))
+
+	_, ok := transaction.Get([]byte("SSD"))
+	assert.False(t, ok)
+
+	value, ok := transaction.Get([]byte("HDD"))
+	assert.True(t, ok)
+	assert.Equal(t, "Hard disk", value.String())
+
+	assert.Equal(t, 0, len(transaction.readRanges))
+
+	iterator, _ = transaction.Scan(kv.NewInclusiveKeyRange(kv.RawKey("HDD"), kv.RawKey("SSD")))
+	defer iterator.Close()
+
+	assert.Equal(t, "HDD", iterator.Key().RawString())
+	_ = iterator.Next()
+	assert.False(t, iterator.IsValid())
+
+	assert.NoError(t, transaction.Set([]byte("SSD"), []byte("Solid state drive")))
+}
+
+func TestRollbackAReadwriteTransactionToNestedSavepoints(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	storageState, _ := state.NewStorageState(rootPath)
+	oracle := NewOracle(NewExecutor(storageState))
+
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+		storageState.Close()
+		oracle.Close()
+	}()
+
+	transaction := NewReadwriteTransaction(oracle, storageState)
+	outer := transaction.Savepoint()
+	_ = transaction.Set([]byte("HDD"), []byte("Hard disk"))
+
+	inner := transaction.Savepoint()
+	_ = transaction.Set([]byte("SSD"), []byte("Solid state drive"))
+
+	assert.NoError(t, transaction.RollbackTo(inner))
+	_, ok := transaction.Get([]byte("SSD"))
+	assert.False(t, ok)
+	_, ok = transaction.Get([]byte("HDD"))
+	assert.True(t, ok)
+
+	assert.NoError(t, transaction.RollbackTo(outer))
+	_, ok = transaction.Get([]byte("HDD"))
+	assert.False(t, ok)
+
+	_, err := transaction.Commit(context.Background())
+	assert.Equal(t, EmptyTransactionErr, err)
+}
+
+func TestRollbackAReadwriteTransactionToAnInvalidSavepoint(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	storageState, _ := state.NewStorageState(rootPath)
+	oracle := NewOracle(NewExecutor(storageState))
+
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+		storageState.Close()
+		oracle.Close()
+	}()
+
+	aTransaction := NewReadwriteTransaction(oracle, storageState)
+	anotherTransaction := NewReadwriteTransaction(oracle, storageState)
+
+	assert.Equal(t, InvalidSavepointErr, anotherTransaction.RollbackTo(aTransaction.Savepoint()))
+
+	outer := aTransaction.Savepoint()
+	_ = aTransaction.Set([]byte("HDD"), []byte("Hard disk"))
+	inner := aTransaction.Savepoint()
+
+	assert.NoError(t, aTransaction.RollbackTo(outer))
+	assert.Equal(t, InvalidSavepointErr, aTransaction.RollbackTo(inner))
+}
+
+func TestReadwriteTransactionReadsItsOwnPutDeletePutWrites(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	storageState, _ := state.NewStorageState(rootPath)
+	oracle := NewOracle(NewExecutor(storageState))
+
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+		storageState.Close()
+		oracle.Close()
+	}()
+
+	commitTimestamp := uint64(5)
+	oracle.nextTimestamp = commitTimestamp + 1
+
+	batch := kv.NewBatch()
+	_ = batch.Put([]byte("HDD"), []byte("Hard disk"))
+	assert.Nil(t, storageState.Set(kv.NewTimestampedBatchFrom(*batch, commitTimestamp)))
+	oracle.commitTimestampMark.Finish(commitTimestamp)
+
+	transaction := NewReadwriteTransaction(oracle, storageState)
+	_ = transaction.Set([]byte("HDD"), []byte("Hard disk drive"))
+	assert.NoError(t, transaction.Delete([]byte("HDD")))
+
+	_, ok := transaction.Get([]byte("HDD"))
+	assert.False(t, ok)
+
+	iterator, _ := transaction.Scan(kv.NewInclusiveKeyRange(kv.RawKey("HDD"), kv.RawKey("SSD")))
+	assert.False(t, iterator.IsValid())
+	iterator.Close()
+
+	_ = transaction.Set([]byte("HDD"), []byte("Hard disk drive"))
+
+	value, ok := transaction.Get([]byte("HDD"))
+	assert.True(t, ok)
+	assert.Equal(t, "Hard disk drive", value.String())
+
+	iterator, _ = transaction.Scan(kv.NewInclusiveKeyRange(kv.RawKey("HDD"), kv.RawKey("SSD")))
+	defer iterator.Close()
+
+	assert.Equal(t, "HDD", iterator.Key().RawString())
+	assert.Equal(t, "Hard disk drive", iterator.Value().String())
+	_ = iterator.Next()
+	assert.False(t, iterator.IsValid())
+}
@@ -0,0 +1,122 @@
+package go_lsm_workshop
+
+import (
+	"context"
+	"errors"
+	"go-lsm-workshop/future"
+	"go-lsm-workshop/kv"
+	"go-lsm-workshop/memory"
+)
+
+var WriteBatchFlushedErr = errors.New("write batch is flushed, can not perform the operation")
+
+// WriteBatch is a non-transactional batch of writes, meant for bulk imports.
+// Unlike Db.Write, a WriteBatch does not track reads and is not checked for conflicts, it only takes a commit-timestamp from
+// the txn.Oracle for every kv.Batch it submits.
+// The writes are accumulated in a kv.Batch, and as soon as the kv.Batch would not fit in an empty memtable (of size
+// state.StorageOptions.MemTableSizeInBytes, Refer to memory.MaxSizeInBytesForEntries), it is submitted to the txn.Executor
+// (via txn.Oracle.SubmitBatch), and a new kv.Batch is started. The submissions are pipelined: WriteBatch does not wait for a submitted kv.Batch to be applied before
+// accumulating the next one. Flush submits the last kv.Batch and returns a single future.Future for all the submissions.
+// WriteBatch is not atomic: every submitted kv.Batch is applied independently (with its own commit-timestamp), and the
+// batches submitted before an error are not rolled back.
+// WriteBatch is not thread-safe.
+type WriteBatch struct {
+	ctx                 context.Context
+	db                  *Db
+	batch               *kv.Batch
+	batchSizeInBytes    int64
+	maxBatchSizeInBytes int64
+	futures             []*future.Future
+	err                 error
+	flushed             bool
+}
+
+// NewWriteBatch creates a new WriteBatch. The ctx is used for all the submissions to the txn.Executor.
+func (db *Db) NewWriteBatch(ctx context.Context) *WriteBatch {
+	return &WriteBatch{
+		ctx:                 ctx,
+		db:                  db,
+		batch:               kv.NewBatch(),
+		maxBatchSizeInBytes: memory.MaxSizeInBytesForEntries(db.storageState.Options().MemTableSizeInBytes),
+	}
+}
+
+// Set sets the key/value pair in the WriteBatch.
+// It returns the error (if any) in submitting the earlier kv.Batch, all the subsequent operations return the same error.
+func (writeBatch *WriteBatch) Set(key, value []byte) error {
+	return writeBatch.add(memory.MaxSizeInBytesOf(len(key), len(value)), func(batch *kv.Batch) error {
+		return batch.Put(key, value)
+	})
+}
+
+// Delete deletes the key in the WriteBatch.
+// It returns the error (if any) in submitting the earlier kv.Batch, all the subsequent operations return the same error.
+func (writeBatch *WriteBatch) Delete(key []byte) error {
+	return writeBatch.add(memory.MaxSizeInBytesOf(len(key), 0), func(batch *kv.Batch) error {
+		batch.Delete(key)
+		return nil
+	})
+}
+
+// Flush submits the last kv.Batch (if not empty), and returns a future.Future which is done when all the kv.Batch(es)
+// submitted by the WriteBatch are applied. The future.Future has Status Error if any of the kv.Batch(es) could not be applied.
+// A WriteBatch can not be used after Flush.
+func (writeBatch *WriteBatch) Flush() (*future.Future, error) {
+	if writeBatch.flushed {
+		return nil, WriteBatchFlushedErr
+	}
+	writeBatch.flushed = true
+	if writeBatch.err != nil {
+		return nil, writeBatch.err
+	}
+	if !writeBatch.batch.IsEmpty() {
+		if err := writeBatch.submit(); err != nil {
+			return nil, err
+		}
+	}
+	return future.NewCompositeFuture(writeBatch.futures), nil
+}
+
+// add adds an entry of the given size using the add function, after submitting the current kv.Batch if the entry would
+// make it exceed the maxBatchSizeInBytes.
+// An entry larger than maxBatchSizeInBytes is submitted in a kv.Batch of its own.
+func (writeBatch *WriteBatch) add(entrySizeInBytes int64, add func(batch *kv.Batch) error) error {
+	if writeBatch.flushed {
+		return WriteBatchFlushedErr
+	}
+	if writeBatch.err != nil {
+		return writeBatch.err
+	}
+	if !writeBatch.batch.IsEmpty() && writeBatch.batchSizeInBytes+entrySizeInBytes > writeBatch.maxBatchSizeInBytes {
+		if err := writeBatch.submit(); err != nil {
+			return err
+		}
+	}
+	if err := add(writeBatch.batch); err != nil {
+		return err
+	}
+	writeBatch.batchSizeInBytes += entrySizeInBytes
+	return nil
+}
+
+// submit submits the current kv.Batch to the txn.Executor and starts a new kv.Batch.
+// The error (if any) is retained in the WriteBatch.
+func (writeBatch *WriteBatch) submit() error {
+	if writeBatch.db.stopped.Load() {
+		writeBatch.err = DbAlreadyStoppedErr
+		return writeBatch.err
+	}
+	if err := writeBatch.db.readonlyErr(); err != nil {
+		writeBatch.err = err
+		return err
+	}
+	resultingFuture, err := writeBatch.db.oracle.SubmitBatch(writeBatch.ctx, writeBatch.batch)
+	if err != nil {
+		writeBatch.err = err
+		return err
+	}
+	writeBatch.futures = append(writeBatch.futures, resultingFuture)
+	writeBatch.batch = kv.NewBatch()
+	writeBatch.batchSizeInBytes = 0
+	return nil
+}